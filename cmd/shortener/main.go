@@ -6,24 +6,38 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/faust8888/shortener/internal/app/audit"
 	"github.com/faust8888/shortener/internal/app/config"
 	"github.com/faust8888/shortener/internal/app/handler"
+	"github.com/faust8888/shortener/internal/app/health"
 	"github.com/faust8888/shortener/internal/app/migration"
 	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/app/repository/cache"
 	"github.com/faust8888/shortener/internal/app/repository/inmemory"
 	"github.com/faust8888/shortener/internal/app/repository/postgres"
 	"github.com/faust8888/shortener/internal/app/route"
+	"github.com/faust8888/shortener/internal/app/rpc"
+	"github.com/faust8888/shortener/internal/app/security"
 	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/app/shortcode"
+	"github.com/faust8888/shortener/internal/app/urlcheck"
+	"github.com/faust8888/shortener/internal/middleware/auth"
 	"github.com/faust8888/shortener/internal/middleware/logger"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"net"
 	"net/http"
 	_ "net/http/pprof" // Import pprof for profiling endpoints
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -57,18 +71,57 @@ func run() error {
 	if err := logger.Initialize(cfg.LoggingLevel); err != nil {
 		return fmt.Errorf("failed to initialize logger: %w", err)
 	}
+	shutdownTracing, err := tracing.Initialize(cfg.TraceExporter, cfg.TraceEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	// Flush the remaining spans after everything else has stopped.
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Log.Error("Failed to flush traces", zap.Error(err))
+		}
+	}()
 
 	// Initialize repository
 	var repo repository.Repository
+	var inMemoryRepo *inmemory.Repository
+	var db *sql.DB
+	checker := health.NewChecker()
 	if cfg.DataSourceName != "" {
 		err := migration.Run(cfg.DataSourceName)
 		if err != nil {
 			return fmt.Errorf("migration failed: %w", err)
 		}
-		repo = postgres.NewPostgresRepository(cfg)
+		postgresRepo := postgres.NewPostgresRepository(cfg)
+		if err = metrics.RegisterDBStats(postgresRepo.DB()); err != nil {
+			return fmt.Errorf("failed to register database metrics: %w", err)
+		}
+		repo = postgresRepo
+		db = postgresRepo.DB()
+		checker.Add("migrations", func(ctx context.Context) error {
+			return migration.Check(ctx, postgresRepo.DB())
+		})
+		cachedRepo, err := newCachedRepository(cfg, postgresRepo)
+		if err != nil {
+			return fmt.Errorf("failed to create link cache: %w", err)
+		}
+		if cachedRepo != nil {
+			if err = metrics.RegisterCache(cachedRepo.Stats); err != nil {
+				return fmt.Errorf("failed to register cache metrics: %w", err)
+			}
+			repo = cachedRepo
+		}
 	} else {
-		repo = inmemory.NewInMemoryRepository(cfg)
+		inMemoryRepo = inmemory.NewInMemoryRepository(cfg)
+		repo = inMemoryRepo
+		checker.Add("backup", inMemoryRepo.CheckBackup)
 	}
+	checker.Add("repository", func(ctx context.Context) error {
+		_, err := repo.Ping(ctx)
+		return err
+	})
 
 	// Ensure repository cleanup
 	defer func() {
@@ -79,8 +132,61 @@ func run() error {
 		}
 	}()
 
-	shortener := service.CreateShortener(repo, cfg.BaseShortURL)
-	h := handler.CreateHandler(shortener, repo, cfg)
+	generator, err := shortcode.NewGenerator(cfg.ShortCodeStrategy, cfg.ShortCodeLength, repo)
+	if err != nil {
+		return fmt.Errorf("failed to create short code generator: %w", err)
+	}
+	deletions, err := service.NewDeletionQueue(repo, cfg.DeleteQueueCapacity, cfg.DeleteBatchSize,
+		cfg.DeleteFlushInterval, cfg.DeleteWorkers, cfg.DeleteQueueFile)
+	if err != nil {
+		return fmt.Errorf("failed to create deletion queue: %w", err)
+	}
+	if err = metrics.RegisterDeletionQueue(deletions.Stats); err != nil {
+		return fmt.Errorf("failed to register deletion queue metrics: %w", err)
+	}
+	checker.Add("deletion_queue", deletions.CheckCapacity)
+	var blocklist *urlcheck.Blocklist
+	if cfg.URLBlocklistFile != "" {
+		blocklist, err = urlcheck.NewBlocklist(cfg.URLBlocklistFile, cfg.URLBlocklistReloadInterval)
+		if err != nil {
+			return fmt.Errorf("failed to load URL blocklist: %w", err)
+		}
+	}
+	auditSink, err := audit.NewSink(cfg, db)
+	if err != nil {
+		return fmt.Errorf("failed to create audit sink: %w", err)
+	}
+	// Close the audit sink after the recorder and the deletion queue have written their last events.
+	defer func() {
+		if err := auditSink.Close(); err != nil {
+			logger.Log.Error("Failed to close audit sink", zap.Error(err))
+		}
+	}()
+	auditRecorder := audit.NewRecorder(auditSink, cfg.AuditBufferSize, cfg.AuditFlushInterval)
+	if err = metrics.RegisterAudit(auditRecorder.Dropped); err != nil {
+		return fmt.Errorf("failed to register audit metrics: %w", err)
+	}
+	shortener := service.CreateShortener(repo, cfg.BaseShortURL, generator, urlcheck.NewValidator(cfg, blocklist), deletions, auditRecorder)
+	clicks := service.NewClickCollector(repo, cfg.ClickBufferSize, cfg.ClickFlushInterval)
+	var recovery handler.RecoveryReporter
+	if inMemoryRepo != nil {
+		recovery = inMemoryRepo
+	}
+	adminRepo, ok := repo.(repository.AdminRepository)
+	if !ok {
+		return errors.New("repository doesn't support admin operations")
+	}
+	apiKeyRepo, ok := repo.(repository.APIKeyRepository)
+	if !ok {
+		return errors.New("repository doesn't support api keys")
+	}
+	apiKeys := service.NewAPIKeys(apiKeyRepo)
+	authenticator, err := newAuthenticator(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create authenticator: %w", err)
+	}
+	users := auth.NewMiddleware(authenticator, apiKeys)
+	h := handler.CreateHandler(shortener, repo, checker, clicks, recovery, service.NewModerator(adminRepo), auditRecorder, apiKeys, authenticator, cfg)
 
 	// Log build metadata
 	printBuildInfo()
@@ -97,7 +203,7 @@ func run() error {
 	// Create the HTTP server.
 	server := &http.Server{
 		Addr:    cfg.ServerAddress,
-		Handler: route.Create(h),
+		Handler: route.Create(h, users, cfg),
 	}
 
 	// Goroutine to run the HTTP server.
@@ -127,12 +233,74 @@ func run() error {
 		return fmt.Errorf("server failed: %w", err)
 	})
 
+	// Goroutine to purge expired links.
+	if cfg.ExpiredSweepInterval > 0 {
+		sweeper := service.NewExpiredSweeper(repo, cfg.ExpiredSweepInterval)
+		g.Go(func() error {
+			return sweeper.Run(gctx)
+		})
+	}
+
+	// Goroutine to compact the backup file of the in-memory repository.
+	if inMemoryRepo != nil && cfg.BackupCompactInterval > 0 {
+		compactor := inmemory.NewCompactor(inMemoryRepo, cfg.BackupCompactInterval, cfg.BackupCompactThreshold)
+		g.Go(func() error {
+			return compactor.Run(gctx)
+		})
+	}
+
+	// Goroutine to save click events for link statistics.
+	g.Go(func() error {
+		return clicks.Run(gctx)
+	})
+
+	// Goroutine to write audit events; events recorded after it stops are written synchronously.
+	g.Go(func() error {
+		return auditRecorder.Run(gctx)
+	})
+
+	// Goroutine to reload the URL blocklist when its file changes.
+	if blocklist != nil {
+		g.Go(func() error {
+			return blocklist.Run(gctx)
+		})
+	}
+
+	// Goroutine to delete links in batches; pending deletions are drained on shutdown.
+	g.Go(func() error {
+		return deletions.Run(gctx)
+	})
+
+	// Create the gRPC server, if enabled.
+	var grpcServer *grpc.Server
+	if cfg.GRPCServerAddress != "" {
+		grpcServer = route.CreateGRPC(rpc.NewServer(shortener, repo), authenticator, apiKeys, cfg)
+
+		// Goroutine to run the gRPC server.
+		g.Go(func() error {
+			listener, err := net.Listen("tcp", cfg.GRPCServerAddress)
+			if err != nil {
+				return fmt.Errorf("grpc listen failed: %w", err)
+			}
+			logger.Log.Info("Starting gRPC server", zap.String("address", cfg.GRPCServerAddress))
+
+			// grpc.ErrServerStopped is the expected error if the server was stopped before Serve.
+			if err = grpcServer.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+				return fmt.Errorf("grpc server failed: %w", err)
+			}
+			logger.Log.Info("gRPC server stopped")
+			return nil
+		})
+	}
+
 	// Goroutine to handle graceful shutdown.
 	g.Go(func() error {
 		// Wait for the context to be canceled (i.e., a signal is received).
 		<-gctx.Done()
 
 		logger.Log.Info("Shutting down server gracefully...")
+		// Report not ready right away so that load balancers stop sending new requests.
+		checker.Shutdown()
 
 		// Create a separate context with a timeout for the shutdown process.
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -142,7 +310,9 @@ func run() error {
 		if err := server.Shutdown(shutdownCtx); err != nil {
 			return fmt.Errorf("server shutdown failed: %w", err)
 		}
-
+		if grpcServer != nil {
+			stopGRPCServer(shutdownCtx, grpcServer)
+		}
 		logger.Log.Info("Server gracefully stopped")
 		return nil
 	})
@@ -161,6 +331,67 @@ func run() error {
 	return nil
 }
 
+// newCachedRepository wraps the repository with the link cache configured in cfg.
+// It returns nil if the cache is disabled.
+func newCachedRepository(cfg *config.Config, repo repository.Repository) (*cache.Repository, error) {
+	if cfg.CacheRedisURL != "" {
+		store, err := cache.NewRedisStore(cfg.CacheRedisURL, cfg.CacheTTL)
+		if err != nil {
+			return nil, err
+		}
+		logger.Log.Info("Using Redis link cache", zap.Duration("ttl", cfg.CacheTTL))
+		return cache.NewRepository(repo, store), nil
+	}
+	if cfg.CacheSize <= 0 {
+		return nil, nil
+	}
+	logger.Log.Info("Using in-process link cache", zap.Int("size", cfg.CacheSize), zap.Duration("ttl", cfg.CacheTTL))
+	return cache.NewRepository(repo, cache.NewLRUStore(cfg.CacheSize, cfg.CacheTTL)), nil
+}
+
+// newAuthenticator creates the issuer and verifier of auth tokens configured in cfg.
+func newAuthenticator(cfg *config.Config) (*security.Authenticator, error) {
+	if cfg.AuthKey == "" {
+		return nil, errors.New("auth key is empty")
+	}
+	keys, err := security.NewKeyringWithOptions(security.KeyringOptions{
+		Algorithm:              cfg.AuthSigningAlgorithm,
+		Secret:                 cfg.AuthKey,
+		PreviousSecrets:        strings.Split(cfg.AuthPreviousKeys, ","),
+		PrivateKeyFile:         cfg.AuthPrivateKeyFile,
+		PreviousPublicKeyFiles: strings.Split(cfg.AuthPreviousPublicKeyFiles, ","),
+		Issuer:                 cfg.AuthIssuer,
+		Audience:               cfg.AuthAudience,
+		AllowLegacyTokens:      cfg.AuthAllowLegacyTokens,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return security.NewAuthenticator(keys, security.AuthOptions{
+		TokenTTL:       cfg.AuthTokenTTL,
+		RefreshWindow:  cfg.AuthTokenRefreshWindow,
+		CookieSecure:   cfg.AuthCookieSecure,
+		CookieHTTPOnly: cfg.AuthCookieHTTPOnly,
+		CookieSameSite: cfg.AuthCookieSameSite,
+		CookiePath:     cfg.AuthCookiePath,
+	})
+}
+
+// stopGRPCServer gracefully stops the gRPC server, forcing the stop if ctx expires first.
+func stopGRPCServer(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		logger.Log.Warn("gRPC graceful stop timed out, forcing stop")
+		server.Stop()
+	}
+}
+
 func printBuildInfo() {
 	fmt.Printf("Build version: %s\n", buildVersion)
 	fmt.Printf("Build date: %s\n", buildDate)
@@ -0,0 +1,313 @@
+// Package audit ведёт журнал изменяющих операций с короткими ссылками: кто, когда и с какого адреса
+// создал ссылки, запросил их удаление и когда удаление было выполнено.
+//
+// События записываются асинхронно: Recorder накапливает их в буфере, а фоновая задача Run
+// пакетами передаёт их приёмнику (Sink). Приёмник выбирается в конфигурации: файл JSON Lines
+// с ротацией по размеру (FileSink) или таблица audit_log в PostgreSQL (PostgresSink).
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// batchSize — количество событий, при накоплении которого пакет записывается, не дожидаясь таймера.
+	batchSize = 500
+	// defaultFindLimit — количество найденных событий, если лимит не задан.
+	defaultFindLimit = 100
+	// maxFindLimit — максимальное количество найденных событий за один запрос.
+	maxFindLimit = 1000
+	// recordTimeout — сколько Record ждёт места в заполненном буфере, прежде чем отбросить событие.
+	recordTimeout = 100 * time.Millisecond
+)
+
+// ErrDisabled — ошибка поиска событий, когда журнал не ведётся (приёмник none).
+var ErrDisabled = errors.New("audit log is disabled")
+
+// ErrInvalidTimeRange — ошибка поиска событий с началом интервала не раньше его конца.
+var ErrInvalidTimeRange = errors.New("from must be before to")
+
+// Sink — приёмник событий журнала.
+//
+// Реализации должны допускать одновременные вызовы методов.
+type Sink interface {
+	// Write сохраняет пакет событий.
+	Write(ctx context.Context, events []model.AuditEvent) error
+	// Find возвращает события, удовлетворяющие фильтру, начиная с новых (не больше filter.Limit).
+	Find(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error)
+	// Close освобождает ресурсы приёмника.
+	Close() error
+}
+
+// Recorder — асинхронный буферизированный регистратор событий журнала.
+//
+// Если буфер заполнен (например, приёмник долго недоступен), Record ждёт освобождения места
+// не дольше recordTimeout, а RecordNoWait не ждёт совсем; не поместившееся событие отбрасывается
+// с записью в лог и учитывается в Dropped, чтобы недоступный журнал не останавливал создание
+// и удаление ссылок. После остановки Run события записываются в приёмник синхронно, поэтому
+// удаления, выполняемые при остановке сервиса, тоже попадают в журнал.
+type Recorder struct {
+	sink     Sink                  // Приёмник событий
+	events   chan model.AuditEvent // Буфер событий
+	interval time.Duration         // Период записи накопленных событий
+	timeout  time.Duration         // Максимальное ожидание места в буфере в Record
+	dropped  atomic.Int64          // Количество событий, отброшенных из-за заполненного буфера
+
+	mu      sync.RWMutex // Отделяет запись в буфер от остановки Run
+	stopped bool         // true после остановки Run; события пишутся в приёмник напрямую
+}
+
+// Record ставит событие в очередь на запись, ожидая места в заполненном буфере не дольше recordTimeout.
+//
+// Параметры:
+//   - ctx: контекст операции; если он отменён, пока буфер заполнен, событие отбрасывается раньше.
+//   - event: событие журнала.
+func (r *Recorder) Record(ctx context.Context, event model.AuditEvent) {
+	r.record(ctx, event, r.timeout)
+}
+
+// RecordNoWait ставит событие в очередь на запись, не ожидая места в буфере: если буфер заполнен,
+// событие сразу отбрасывается. Используется фоновыми задачами, которые не должны зависеть от приёмника.
+//
+// Параметры:
+//   - ctx: контекст операции.
+//   - event: событие журнала.
+func (r *Recorder) RecordNoWait(ctx context.Context, event model.AuditEvent) {
+	r.record(ctx, event, 0)
+}
+
+// Dropped возвращает количество событий, отброшенных из-за заполненного буфера.
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// record ставит событие в буфер, ожидая места не дольше wait, или, после остановки Run,
+// записывает его в приёмник напрямую.
+func (r *Recorder) record(ctx context.Context, event model.AuditEvent, wait time.Duration) {
+	r.mu.RLock()
+	if r.stopped {
+		r.mu.RUnlock()
+		r.write(context.WithoutCancel(ctx), []model.AuditEvent{event})
+		return
+	}
+	defer r.mu.RUnlock()
+	select {
+	case r.events <- event:
+		return
+	default:
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case r.events <- event:
+			return
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+	r.dropped.Add(1)
+	logger.FromContext(ctx).Error("audit buffer is full, event is lost",
+		zap.String("type", event.Type), zap.String("userID", event.UserID), zap.Strings("hashURLs", event.HashURLs))
+}
+
+// Find ищет события журнала, начиная с новых.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - filter: условия поиска; лимит по умолчанию — 100, максимальный — 1000.
+//
+// Возвращает:
+//   - []model.AuditEvent: найденные события.
+//   - error: nil, если успешно; ErrInvalidTimeRange, если интервал пуст; ErrDisabled, если журнал не ведётся;
+//     иначе — ошибку приёмника.
+func (r *Recorder) Find(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, ErrInvalidTimeRange
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultFindLimit
+	}
+	filter.Limit = min(filter.Limit, maxFindLimit)
+	events, err := r.sink.Find(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("audit.recorder.find: %w", err)
+	}
+	return events, nil
+}
+
+// Run записывает накопленные события каждые interval или при накоплении batchSize событий
+// и блокируется до отмены контекста.
+//
+// Если приёмник недоступен, пакет остаётся в памяти и записывается повторно со следующим
+// тиком; пока он не записан, новые события копятся в буфере, а при его заполнении отбрасываются
+// (см. Recorder). Перед завершением Run записывает
+// события, оставшиеся в буфере; эта запись не прерывается отменой ctx.
+//
+// Параметры:
+//   - ctx: контекст, при отмене которого регистратор переходит к синхронной записи.
+//
+// Возвращает:
+//   - error: всегда nil, чтобы корректно завершаться в составе errgroup.
+func (r *Recorder) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	batch := make([]model.AuditEvent, 0, batchSize)
+	for {
+		events := r.events
+		if len(batch) >= batchSize {
+			events = nil
+		}
+		select {
+		case <-ctx.Done():
+			batch = r.stop(batch)
+			if !r.write(context.WithoutCancel(ctx), batch) {
+				logger.Log.Error("couldn't write audit events on shutdown, events are lost", zap.Int("count", len(batch)))
+			}
+			logger.Log.Info("audit recorder stopped")
+			return nil
+		case event := <-events:
+			batch = append(batch, event)
+			if len(batch) >= batchSize {
+				batch = r.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(ctx, batch)
+		}
+	}
+}
+
+// stop переводит Record в режим синхронной записи и забирает все события из буфера.
+// Пока остановка ждёт завершения начатых Record, буфер продолжает разбираться.
+func (r *Recorder) stop(batch []model.AuditEvent) []model.AuditEvent {
+	stopped := make(chan struct{})
+	go func() {
+		r.mu.Lock()
+		r.stopped = true
+		r.mu.Unlock()
+		close(stopped)
+	}()
+	for {
+		select {
+		case event := <-r.events:
+			batch = append(batch, event)
+		case <-stopped:
+			for {
+				select {
+				case event := <-r.events:
+					batch = append(batch, event)
+				default:
+					return batch
+				}
+			}
+		}
+	}
+}
+
+// flush записывает пакет в приёмник.
+//
+// Возвращает:
+//   - []model.AuditEvent: пустой пакет для повторного использования или тот же пакет, если запись не удалась.
+func (r *Recorder) flush(ctx context.Context, batch []model.AuditEvent) []model.AuditEvent {
+	if !r.write(ctx, batch) {
+		return batch
+	}
+	return batch[:0]
+}
+
+// write записывает события в приёмник и логирует ошибку записи.
+//
+// Возвращает:
+//   - bool: true, если события записаны или их нет.
+func (r *Recorder) write(ctx context.Context, events []model.AuditEvent) bool {
+	if len(events) == 0 {
+		return true
+	}
+	if err := r.sink.Write(ctx, events); err != nil {
+		logger.Log.Error("couldn't write audit events", zap.Int("count", len(events)), zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// NewRecorder создаёт регистратор событий журнала.
+//
+// Параметры:
+//   - sink: приёмник событий.
+//   - bufferSize: ёмкость буфера событий; неположительное значение заменяется на batchSize.
+//   - interval: период записи накопленных событий; неположительное значение заменяется на секунду.
+//
+// Возвращает:
+//   - *Recorder: регистратор; его Run запускается вызывающим кодом.
+func NewRecorder(sink Sink, bufferSize int, interval time.Duration) *Recorder {
+	if bufferSize <= 0 {
+		bufferSize = batchSize
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Recorder{
+		sink:     sink,
+		events:   make(chan model.AuditEvent, bufferSize),
+		interval: interval,
+		timeout:  recordTimeout,
+	}
+}
+
+// NewSink создаёт приёмник событий, выбранный в конфигурации.
+//
+// Параметры:
+//   - cfg: конфигурация приложения (AuditSink, AuditFilePath, AuditFileMaxSize, AuditFileMaxBackups, QueryTimeout).
+//   - db: подключение к PostgreSQL для приёмника postgres; nil, если БД не используется.
+//
+// Возвращает:
+//   - Sink: приёмник событий; для config.AuditSinkNone — приёмник, отбрасывающий события.
+//   - error: nil, если успешно; иначе — ошибку открытия файла, неизвестный приёмник
+//     или отсутствие БД для приёмника postgres.
+func NewSink(cfg *config.Config, db *sql.DB) (Sink, error) {
+	switch cfg.AuditSink {
+	case config.AuditSinkNone:
+		return nopSink{}, nil
+	case config.AuditSinkFile:
+		sink, err := NewFileSink(cfg.AuditFilePath, cfg.AuditFileMaxSize, cfg.AuditFileMaxBackups)
+		if err != nil {
+			return nil, fmt.Errorf("audit.newSink: %w", err)
+		}
+		return sink, nil
+	case config.AuditSinkPostgres:
+		if db == nil {
+			return nil, errors.New("audit.newSink: postgres sink requires a database")
+		}
+		return NewPostgresSink(db, cfg.QueryTimeout), nil
+	default:
+		return nil, fmt.Errorf("audit.newSink: unknown sink %q", cfg.AuditSink)
+	}
+}
+
+// nopSink — приёмник, отбрасывающий события, когда журнал не ведётся.
+type nopSink struct{}
+
+// Write отбрасывает события.
+func (nopSink) Write(context.Context, []model.AuditEvent) error {
+	return nil
+}
+
+// Find всегда возвращает ErrDisabled.
+func (nopSink) Find(context.Context, model.AuditFilter) ([]model.AuditEvent, error) {
+	return nil, ErrDisabled
+}
+
+// Close ничего не делает.
+func (nopSink) Close() error {
+	return nil
+}
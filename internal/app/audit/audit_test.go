@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newEvent создаёт событие пользователя с заданным кодом ссылки и моментом.
+func newEvent(userID, hashURL string, timestamp time.Time) model.AuditEvent {
+	return model.AuditEvent{Type: model.AuditLinkCreated, UserID: userID, HashURLs: []string{hashURL}, Timestamp: timestamp}
+}
+
+// hashURLs возвращает коды ссылок событий по порядку.
+func hashURLs(events []model.AuditEvent) []string {
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		result = append(result, event.HashURLs...)
+	}
+	return result
+}
+
+func TestFileSinkFind(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	require.NoError(t, err)
+	defer func() {
+		_ = sink.Close()
+	}()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, sink.Write(context.Background(), []model.AuditEvent{
+		newEvent("alice", "a1", start),
+		newEvent("bob", "b1", start.Add(time.Hour)),
+		newEvent("alice", "a2", start.Add(2*time.Hour)),
+		newEvent("alice", "a3", start.Add(3*time.Hour)),
+	}))
+
+	tests := []struct {
+		name   string
+		filter model.AuditFilter
+		want   []string
+	}{
+		{name: "All events newest first", filter: model.AuditFilter{}, want: []string{"a3", "a2", "b1", "a1"}},
+		{name: "By user", filter: model.AuditFilter{UserID: "alice"}, want: []string{"a3", "a2", "a1"}},
+		{name: "From is inclusive", filter: model.AuditFilter{From: start.Add(time.Hour)}, want: []string{"a3", "a2", "b1"}},
+		{name: "To is exclusive", filter: model.AuditFilter{To: start.Add(2 * time.Hour)}, want: []string{"b1", "a1"}},
+		{name: "Limit keeps the newest", filter: model.AuditFilter{UserID: "alice", Limit: 2}, want: []string{"a3", "a2"}},
+		{name: "Nothing found", filter: model.AuditFilter{UserID: "carol"}, want: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := sink.Find(context.Background(), test.filter)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, hashURLs(events))
+		})
+	}
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// Размер файла рассчитан на два события: каждое третье приводит к ротации.
+	sink, err := NewFileSink(path, 200, 2)
+	require.NoError(t, err)
+	for i := 0; i < 8; i++ {
+		require.NoError(t, sink.Write(context.Background(), []model.AuditEvent{newEvent("alice", fmt.Sprint(i), start.Add(time.Duration(i)*time.Minute))}))
+	}
+	require.NoError(t, sink.Close())
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(200))
+	}
+	_, err = os.Stat(path + ".3")
+	assert.ErrorIs(t, err, os.ErrNotExist, "files older than maxBackups must be removed")
+
+	// После перезапуска поиск видит текущий и ротированные файлы; самые старые события удалены.
+	sink, err = NewFileSink(path, 200, 2)
+	require.NoError(t, err)
+	defer func() {
+		_ = sink.Close()
+	}()
+	events, err := sink.Find(context.Background(), model.AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"7", "6", "5", "4", "3", "2"}, hashURLs(events))
+
+	// Повреждённые строки пропускаются.
+	file, err := os.OpenFile(path+".2", os.O_WRONLY|os.O_APPEND, 0666)
+	require.NoError(t, err)
+	_, err = file.WriteString("{corrupted\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	events, err = sink.Find(context.Background(), model.AuditFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, events, 6)
+}
+
+func TestRecorder(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	require.NoError(t, err)
+	defer func() {
+		_ = sink.Close()
+	}()
+	recorder := NewRecorder(sink, 10, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = recorder.Run(ctx)
+		close(done)
+	}()
+	now := time.Now().UTC()
+
+	recorder.Record(context.Background(), newEvent("alice", "a1", now))
+	recorder.Record(context.Background(), newEvent("alice", "a2", now))
+	require.Eventually(t, func() bool {
+		events, err := recorder.Find(context.Background(), model.AuditFilter{UserID: "alice"})
+		return err == nil && len(events) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// После остановки события записываются синхронно.
+	cancel()
+	<-done
+	recorder.Record(context.Background(), newEvent("alice", "a3", now))
+	events, err := recorder.Find(context.Background(), model.AuditFilter{UserID: "alice", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a3"}, hashURLs(events))
+
+	_, err = recorder.Find(context.Background(), model.AuditFilter{From: now, To: now})
+	assert.ErrorIs(t, err, ErrInvalidTimeRange)
+}
+
+func TestRecorderDrainsOnShutdown(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	require.NoError(t, err)
+	defer func() {
+		_ = sink.Close()
+	}()
+	recorder := NewRecorder(sink, 100, time.Hour)
+	for i := 0; i < 50; i++ {
+		recorder.Record(context.Background(), newEvent("alice", fmt.Sprint(i), time.Now()))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, recorder.Run(ctx))
+
+	events, err := sink.Find(context.Background(), model.AuditFilter{})
+	require.NoError(t, err)
+	assert.Len(t, events, 50)
+}
+
+func TestRecorderDropsWhenBufferIsFull(t *testing.T) {
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	require.NoError(t, err)
+	defer func() {
+		_ = sink.Close()
+	}()
+	// Run не запущен, поэтому буфер никто не разбирает, как при долго недоступном приёмнике.
+	recorder := NewRecorder(sink, 1, time.Hour)
+	recorder.timeout = 10 * time.Millisecond
+	recorder.Record(context.Background(), newEvent("alice", "a1", time.Now()))
+
+	start := time.Now()
+	recorder.Record(context.Background(), newEvent("alice", "a2", time.Now()))
+	assert.Less(t, time.Since(start), time.Second, "Record must not wait for the sink")
+	recorder.RecordNoWait(context.Background(), newEvent("alice", "a3", time.Now()))
+	assert.Equal(t, int64(2), recorder.Dropped())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, recorder.Run(ctx))
+	events, err := sink.Find(context.Background(), model.AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1"}, hashURLs(events))
+}
+
+func TestNewSink(t *testing.T) {
+	cfg := &config.Config{AuditSink: config.AuditSinkNone}
+	sink, err := NewSink(cfg, nil)
+	require.NoError(t, err)
+	_, err = NewRecorder(sink, 0, 0).Find(context.Background(), model.AuditFilter{})
+	assert.ErrorIs(t, err, ErrDisabled)
+
+	cfg.AuditSink = config.AuditSinkPostgres
+	_, err = NewSink(cfg, nil)
+	assert.Error(t, err, "postgres sink requires a database")
+
+	cfg.AuditSink = "kafka"
+	_, err = NewSink(cfg, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,222 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"go.uber.org/zap"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// FileSink — приёмник, записывающий события в файл JSON Lines (по событию в строке).
+//
+// Когда размер файла превысил бы maxSize, файл ротируется: path переименовывается в path.1,
+// path.1 — в path.2 и так далее; файлы старше path.<maxBackups> удаляются.
+// Поиск просматривает все хранимые файлы, поэтому для больших журналов лучше подходит PostgresSink.
+type FileSink struct {
+	path       string // Путь к текущему файлу журнала
+	maxSize    int64  // Размер файла, после которого он ротируется; 0 — без ротации
+	maxBackups int    // Количество хранимых файлов после ротации
+
+	mu     sync.Mutex // Защищает поля ниже и файлы журнала от ротации во время поиска
+	file   *os.File   // Текущий файл, открытый на дозапись; nil, если его не удалось открыть после ротации
+	size   int64      // Размер текущего файла
+	closed bool       // true после Close
+}
+
+// Write дописывает события в файл и сбрасывает его на диск, предварительно ротируя файл,
+// если пакет не помещается в maxSize.
+//
+// Параметры:
+//   - ctx: контекст записи.
+//   - events: пакет событий.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку сериализации, ротации или записи.
+func (s *FileSink) Write(ctx context.Context, events []model.AuditEvent) error {
+	_, span := tracing.Start(ctx, "audit.FileSink.Write")
+	defer span.End()
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("audit.fileSink.write: marshal event: %w", err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("audit.fileSink.write: sink is closed")
+	}
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return fmt.Errorf("audit.fileSink.write: %w", err)
+		}
+	}
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(data.Len()) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("audit.fileSink.write: %w", err)
+		}
+	}
+	n, err := s.file.Write(data.Bytes())
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("audit.fileSink.write: %w", err)
+	}
+	if err = s.file.Sync(); err != nil {
+		return fmt.Errorf("audit.fileSink.write: sync: %w", err)
+	}
+	return nil
+}
+
+// Find просматривает хранимые файлы от старых к новым и возвращает последние подходящие события,
+// начиная с новых. Повреждённые строки пропускаются.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - filter: условия поиска; неположительный лимит — без ограничения.
+//
+// Возвращает:
+//   - []model.AuditEvent: найденные события.
+//   - error: nil, если успешно, иначе — ошибку чтения файлов.
+func (s *FileSink) Find(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	_, span := tracing.Start(ctx, "audit.FileSink.Find")
+	defer span.End()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matched := make([]model.AuditEvent, 0)
+	for i := s.maxBackups; i >= 0; i-- {
+		var err error
+		matched, err = s.scan(s.backupPath(i), filter, matched)
+		if err != nil {
+			return nil, fmt.Errorf("audit.fileSink.find: %w", err)
+		}
+	}
+	if filter.Limit > 0 && len(matched) > filter.Limit {
+		matched = matched[len(matched)-filter.Limit:]
+	}
+	slices.Reverse(matched)
+	return matched, nil
+}
+
+// Close закрывает текущий файл журнала.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// scan дописывает к matched подходящие события из файла; отсутствующий файл пропускается.
+// Чтобы не держать в памяти весь журнал, из matched сохраняются только последние filter.Limit событий.
+// Вызывающий код должен удерживать s.mu.
+func (s *FileSink) scan(path string, filter model.AuditFilter, matched []model.AuditEvent) ([]model.AuditEvent, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return matched, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event model.AuditEvent
+		if err = json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			logger.Log.Warn("skipping corrupted audit record", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		if !filter.Match(event) {
+			continue
+		}
+		matched = append(matched, event)
+		if filter.Limit > 0 && len(matched) >= 2*filter.Limit {
+			matched = append(matched[:0], matched[len(matched)-filter.Limit:]...)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return matched, nil
+}
+
+// rotate сдвигает хранимые файлы на один номер, удаляя самый старый, и открывает новый текущий файл.
+// Вызывающий код должен удерживать s.mu.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+	s.file = nil
+	if err := os.Remove(s.backupPath(s.maxBackups)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove oldest: %w", err)
+	}
+	for i := s.maxBackups - 1; i >= 0; i-- {
+		if err := os.Rename(s.backupPath(i), s.backupPath(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("rename: %w", err)
+		}
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	logger.Log.Info("audit log rotated", zap.String("path", s.path))
+	return nil
+}
+
+// open открывает текущий файл на дозапись, создавая его при необходимости.
+// Вызывающий код должен удерживать s.mu (кроме вызова из конструктора).
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("stat: %w", err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// backupPath возвращает путь к файлу с номером i; 0 — текущий файл.
+func (s *FileSink) backupPath(i int) string {
+	if i == 0 {
+		return s.path
+	}
+	return s.path + "." + strconv.Itoa(i)
+}
+
+// NewFileSink открывает файл журнала на дозапись.
+//
+// Параметры:
+//   - path: путь к текущему файлу журнала.
+//   - maxSize: размер файла в байтах, после которого он ротируется; неположительное значение отключает ротацию.
+//   - maxBackups: количество хранимых файлов после ротации; отрицательное значение заменяется на 0
+//     (при ротации текущий файл просто удаляется).
+//
+// Возвращает:
+//   - *FileSink: готовый к использованию приёмник.
+//   - error: nil, если успешно, иначе — ошибку открытия файла.
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{path: path, maxSize: max(maxSize, 0), maxBackups: max(maxBackups, 0)}
+	if err := s.open(); err != nil {
+		return nil, fmt.Errorf("audit.newFileSink: %w", err)
+	}
+	return s, nil
+}
@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"github.com/lib/pq"
+	"time"
+)
+
+// PostgresSink — приёмник, записывающий события в таблицу audit_log.
+//
+// Таблица создаётся миграцией 000008_audit_log; индекс по (user_id, created_at)
+// обслуживает поиск событий пользователя за интервал.
+type PostgresSink struct {
+	db           *sql.DB       // Подключение к базе данных
+	queryTimeout time.Duration // Максимальная длительность одного запроса; 0 — без ограничения
+}
+
+// Write сохраняет пакет событий одной транзакцией.
+//
+// Параметры:
+//   - ctx: контекст записи.
+//   - events: пакет событий.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку.
+func (s *PostgresSink) Write(ctx context.Context, events []model.AuditEvent) error {
+	ctx, span := tracing.Start(ctx, "audit.PostgresSink.Write")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("audit.postgresSink.write: begin: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	stmt, err := tx.PrepareContext(ctx, `
+        INSERT INTO audit_log (event_type, user_id, request_id, client_ip, short_urls, original_urls, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `)
+	if err != nil {
+		return fmt.Errorf("audit.postgresSink.write: prepare: %w", err)
+	}
+	defer stmt.Close()
+	for _, event := range events {
+		if _, err = stmt.ExecContext(ctx, event.Type, event.UserID, event.RequestID, event.ClientIP,
+			pq.Array(nonNil(event.HashURLs)), pq.Array(nonNil(event.OriginalURLs)), event.Timestamp); err != nil {
+			return fmt.Errorf("audit.postgresSink.write: insert: %w", err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("audit.postgresSink.write: commit: %w", err)
+	}
+	return nil
+}
+
+// Find возвращает события, удовлетворяющие фильтру, начиная с новых.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - filter: условия поиска; неположительный лимит — без ограничения.
+//
+// Возвращает:
+//   - []model.AuditEvent: найденные события.
+//   - error: nil, если успешно, иначе — ошибку.
+func (s *PostgresSink) Find(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	ctx, span := tracing.Start(ctx, "audit.PostgresSink.Find")
+	defer span.End()
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+	from := sql.NullTime{Time: filter.From, Valid: !filter.From.IsZero()}
+	to := sql.NullTime{Time: filter.To, Valid: !filter.To.IsZero()}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT event_type, user_id, request_id, client_ip, short_urls, original_urls, created_at
+        FROM audit_log
+        WHERE ($1 = '' OR user_id = $1)
+          AND ($2::timestamptz IS NULL OR created_at >= $2)
+          AND ($3::timestamptz IS NULL OR created_at < $3)
+        ORDER BY created_at DESC, id DESC
+        LIMIT NULLIF($4, 0)
+    `, filter.UserID, from, to, max(filter.Limit, 0))
+	if err != nil {
+		return nil, fmt.Errorf("audit.postgresSink.find: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]model.AuditEvent, 0)
+	for rows.Next() {
+		var event model.AuditEvent
+		if err = rows.Scan(&event.Type, &event.UserID, &event.RequestID, &event.ClientIP,
+			pq.Array(&event.HashURLs), pq.Array(&event.OriginalURLs), &event.Timestamp); err != nil {
+			return nil, fmt.Errorf("audit.postgresSink.find: failed to scan row: %w", err)
+		}
+		result = append(result, event)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("audit.postgresSink.find: error during row iteration: %w", err)
+	}
+	return result, nil
+}
+
+// Close ничего не делает: подключением к базе данных владеет репозиторий.
+func (s *PostgresSink) Close() error {
+	return nil
+}
+
+// withTimeout ограничивает контекст запроса длительностью queryTimeout, если она задана.
+func (s *PostgresSink) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
+
+// nonNil заменяет nil на пустой срез, чтобы в NOT NULL-колонку массива записался '{}', а не NULL.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// NewPostgresSink создаёт приёмник, записывающий события в таблицу audit_log.
+//
+// Параметры:
+//   - db: подключение к базе данных с применёнными миграциями.
+//   - queryTimeout: максимальная длительность одного запроса; 0 — без ограничения.
+//
+// Возвращает:
+//   - *PostgresSink: готовый к использованию приёмник.
+func NewPostgresSink(db *sql.DB, queryTimeout time.Duration) *PostgresSink {
+	return &PostgresSink{db: db, queryTimeout: queryTimeout}
+}
@@ -9,8 +9,11 @@ import (
 	"io"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/caarlos0/env/v6"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/shortcode"
 	"github.com/faust8888/shortener/internal/middleware/logger"
 	"go.uber.org/zap"
 )
@@ -31,12 +34,136 @@ const (
 	AuthKeyNameFlag = "k"
 	// EnableTLSOnServerFlag - флаг для включения HTTPS (-s).
 	EnableTLSOnServerFlag = "s"
+	// GRPCServerAddressFlag - флаг для адреса gRPC-сервера (-g).
+	GRPCServerAddressFlag = "g"
+	// ExpiredSweepIntervalFlag - флаг для периода очистки истёкших ссылок (-e).
+	ExpiredSweepIntervalFlag = "e"
+	// ClickBufferSizeFlag - флаг для ёмкости буфера событий переходов (-click-buffer-size).
+	ClickBufferSizeFlag = "click-buffer-size"
+	// ClickFlushIntervalFlag - флаг для периода сохранения событий переходов (-click-flush-interval).
+	ClickFlushIntervalFlag = "click-flush-interval"
+	// BackupCompactIntervalFlag - флаг для периода проверки необходимости сжатия файла бэкапа (-backup-compact-interval).
+	BackupCompactIntervalFlag = "backup-compact-interval"
+	// BackupCompactThresholdFlag - флаг для порога сжатия файла бэкапа (-backup-compact-threshold).
+	BackupCompactThresholdFlag = "backup-compact-threshold"
+	// BackupSyncModeFlag - флаг для режима fsync файла бэкапа (-backup-sync-mode).
+	BackupSyncModeFlag = "backup-sync-mode"
+	// BackupSyncIntervalFlag - флаг для периода fsync файла бэкапа в режиме interval (-backup-sync-interval).
+	BackupSyncIntervalFlag = "backup-sync-interval"
+	// ShortCodeStrategyFlag - флаг для стратегии генерации кодов коротких ссылок (-short-code-strategy).
+	ShortCodeStrategyFlag = "short-code-strategy"
+	// ShortCodeLengthFlag - флаг для длины кодов коротких ссылок (-short-code-length).
+	ShortCodeLengthFlag = "short-code-length"
+	// AdminTokenFlag - флаг для токена служебного API (-admin-token).
+	AdminTokenFlag = "admin-token"
+	// QueryTimeoutFlag - флаг для таймаута запроса к базе данных (-query-timeout).
+	QueryTimeoutFlag = "query-timeout"
+	// DeleteQueueCapacityFlag - флаг для ёмкости очереди удаления ссылок (-delete-queue-capacity).
+	DeleteQueueCapacityFlag = "delete-queue-capacity"
+	// DeleteBatchSizeFlag - флаг для размера пакета удаления ссылок (-delete-batch-size).
+	DeleteBatchSizeFlag = "delete-batch-size"
+	// DeleteFlushIntervalFlag - флаг для периода выполнения накопленных удалений (-delete-flush-interval).
+	DeleteFlushIntervalFlag = "delete-flush-interval"
+	// DeleteWorkersFlag - флаг для количества обработчиков очереди удаления (-delete-workers).
+	DeleteWorkersFlag = "delete-workers"
+	// DeleteQueueFileFlag - флаг для пути к журналу очереди удаления (-delete-queue-file).
+	DeleteQueueFileFlag = "delete-queue-file"
+	// TraceExporterFlag - флаг для экспортёра спанов трассировки (-trace-exporter).
+	TraceExporterFlag = "trace-exporter"
+	// TraceEndpointFlag - флаг для адреса коллектора OTLP/HTTP (-trace-endpoint).
+	TraceEndpointFlag = "trace-endpoint"
+	// CacheSizeFlag - флаг для размера кэша коротких ссылок (-cache-size).
+	CacheSizeFlag = "cache-size"
+	// CacheTTLFlag - флаг для времени жизни записей кэша коротких ссылок (-cache-ttl).
+	CacheTTLFlag = "cache-ttl"
+	// CacheRedisURLFlag - флаг для адреса Redis, хранящего кэш коротких ссылок (-cache-redis-url).
+	CacheRedisURLFlag = "cache-redis-url"
+	// RateLimitCreateFlag - флаг для скорости восстановления бюджета создания ссылок (-rate-limit-create).
+	RateLimitCreateFlag = "rate-limit-create"
+	// RateLimitCreateBurstFlag - флаг для ёмкости бюджета создания ссылок (-rate-limit-create-burst).
+	RateLimitCreateBurstFlag = "rate-limit-create-burst"
+	// RateLimitRedirectFlag - флаг для скорости восстановления бюджета переходов по ссылкам (-rate-limit-redirect).
+	RateLimitRedirectFlag = "rate-limit-redirect"
+	// RateLimitRedirectBurstFlag - флаг для ёмкости бюджета переходов по ссылкам (-rate-limit-redirect-burst).
+	RateLimitRedirectBurstFlag = "rate-limit-redirect-burst"
+	// RateLimitTrustedProxiesFlag - флаг для адресов обратных прокси, которым доверяется X-Forwarded-For (-rate-limit-trusted-proxies).
+	RateLimitTrustedProxiesFlag = "rate-limit-trusted-proxies"
+	// URLAllowedSchemesFlag - флаг для разрешённых схем оригинальных URL (-url-allowed-schemes).
+	URLAllowedSchemesFlag = "url-allowed-schemes"
+	// URLMaxLengthFlag - флаг для максимальной длины оригинального URL (-url-max-length).
+	URLMaxLengthFlag = "url-max-length"
+	// URLAllowPrivateHostsFlag - флаг, разрешающий оригинальные URL с локальными и частными адресами (-url-allow-private-hosts).
+	URLAllowPrivateHostsFlag = "url-allow-private-hosts"
+	// URLBlocklistFileFlag - флаг для пути к списку запрещённых доменов (-url-blocklist-file).
+	URLBlocklistFileFlag = "url-blocklist-file"
+	// URLBlocklistReloadIntervalFlag - флаг для периода проверки изменения списка запрещённых доменов (-url-blocklist-reload-interval).
+	URLBlocklistReloadIntervalFlag = "url-blocklist-reload-interval"
+	// AuditSinkFlag - флаг для приёмника журнала изменяющих операций (-audit-sink).
+	AuditSinkFlag = "audit-sink"
+	// AuditFilePathFlag - флаг для пути к файлу журнала изменяющих операций (-audit-file).
+	AuditFilePathFlag = "audit-file"
+	// AuditFileMaxSizeFlag - флаг для максимального размера файла журнала до ротации (-audit-file-max-size).
+	AuditFileMaxSizeFlag = "audit-file-max-size"
+	// AuditFileMaxBackupsFlag - флаг для количества хранимых файлов журнала после ротации (-audit-file-max-backups).
+	AuditFileMaxBackupsFlag = "audit-file-max-backups"
+	// AuditBufferSizeFlag - флаг для ёмкости буфера событий журнала (-audit-buffer-size).
+	AuditBufferSizeFlag = "audit-buffer-size"
+	// AuditFlushIntervalFlag - флаг для периода записи событий журнала (-audit-flush-interval).
+	AuditFlushIntervalFlag = "audit-flush-interval"
+	// AuthPreviousKeysFlag - флаг для прежних ключей аутентификации (-auth-previous-keys).
+	AuthPreviousKeysFlag = "auth-previous-keys"
+	// AuthTokenTTLFlag - флаг для времени жизни токена аутентификации (-auth-token-ttl).
+	AuthTokenTTLFlag = "auth-token-ttl"
+	// AuthTokenRefreshWindowFlag - флаг для окна продления токена аутентификации (-auth-token-refresh-window).
+	AuthTokenRefreshWindowFlag = "auth-token-refresh-window"
+	// AuthCookieSecureFlag - флаг для атрибута Secure куки аутентификации (-auth-cookie-secure).
+	AuthCookieSecureFlag = "auth-cookie-secure"
+	// AuthCookieHTTPOnlyFlag - флаг для атрибута HttpOnly куки аутентификации (-auth-cookie-http-only).
+	AuthCookieHTTPOnlyFlag = "auth-cookie-http-only"
+	// AuthCookieSameSiteFlag - флаг для атрибута SameSite куки аутентификации (-auth-cookie-same-site).
+	AuthCookieSameSiteFlag = "auth-cookie-same-site"
+	// AuthCookiePathFlag - флаг для атрибута Path куки аутентификации (-auth-cookie-path).
+	AuthCookiePathFlag = "auth-cookie-path"
+	// AuthSigningAlgorithmFlag - флаг для алгоритма подписи токенов аутентификации (-auth-signing-algorithm).
+	AuthSigningAlgorithmFlag = "auth-signing-algorithm"
+	// AuthPrivateKeyFileFlag - флаг для PEM-файла закрытого ключа подписи токенов (-auth-private-key-file).
+	AuthPrivateKeyFileFlag = "auth-private-key-file"
+	// AuthPreviousPublicKeyFilesFlag - флаг для PEM-файлов прежних открытых ключей (-auth-previous-public-key-files).
+	AuthPreviousPublicKeyFilesFlag = "auth-previous-public-key-files"
+	// AuthIssuerFlag - флаг для claim iss токенов аутентификации (-auth-issuer).
+	AuthIssuerFlag = "auth-issuer"
+	// AuthAudienceFlag - флаг для claim aud токенов аутентификации (-auth-audience).
+	AuthAudienceFlag = "auth-audience"
+	// AuthAllowLegacyTokensFlag - флаг для приёма токенов без iss и aud (-auth-allow-legacy-tokens).
+	AuthAllowLegacyTokensFlag = "auth-allow-legacy-tokens"
 	// ConfigFileFlag - флаг для пути к файлу конфигурации (-c).
 	ConfigFileFlag = "c"
 	// ConfigFileFlagAlias - псевдоним флага для пути к файлу конфигурации (-config).
 	ConfigFileFlagAlias = "config"
 	// HashKeyURLQueryParam - имя параметра URL, содержащего хэш.
 	HashKeyURLQueryParam = "hashKeyURL"
+	// APIKeyIDURLParam - имя параметра URL, содержащего идентификатор ключа API.
+	APIKeyIDURLParam = "apiKeyID"
+)
+
+// Режимы fsync файла бэкапа.
+const (
+	// BackupSyncAlways - fsync после записи каждой пачки событий; запрос завершается только после fsync.
+	BackupSyncAlways = "always"
+	// BackupSyncInterval - fsync не чаще одного раза за BackupSyncInterval; при сбое питания теряются записи за последний период.
+	BackupSyncInterval = "interval"
+	// BackupSyncNever - fsync не выполняется, сброс данных на диск остаётся на усмотрение ОС.
+	BackupSyncNever = "never"
+)
+
+// Приёмники журнала изменяющих операций.
+const (
+	// AuditSinkFile - файл JSON Lines с ротацией по размеру.
+	AuditSinkFile = "file"
+	// AuditSinkPostgres - таблица audit_log в PostgreSQL; требует DataSourceName.
+	AuditSinkPostgres = "postgres"
+	// AuditSinkNone - журнал не ведётся.
+	AuditSinkNone = "none"
 )
 
 // Config хранит все настройки конфигурации приложения.
@@ -53,19 +180,187 @@ type Config struct {
 	DataSourceName string `env:"DATABASE_DSN" json:"database_dsn"`
 	// AuthKey - секретный ключ для подписи токенов аутентификации (флаг -k, env AUTH_KEY).
 	AuthKey string `env:"AUTH_KEY"`
+	// AuthPreviousKeys - прежние ключи аутентификации через запятую: ими токены только проверяются и перевыпускаются
+	// с AuthKey, что позволяет сменить ключ без потери сессий (флаг -auth-previous-keys, env AUTH_PREVIOUS_KEYS).
+	AuthPreviousKeys string `env:"AUTH_PREVIOUS_KEYS"`
+	// AuthTokenTTL - время жизни токена аутентификации и Max-Age его куки (флаг -auth-token-ttl, env AUTH_TOKEN_TTL).
+	AuthTokenTTL time.Duration `env:"AUTH_TOKEN_TTL" json:"auth_token_ttl"`
+	// AuthTokenRefreshWindow - если до истечения токена осталось меньше этого времени, он перевыпускается
+	// с тем же идентификатором пользователя (флаг -auth-token-refresh-window, env AUTH_TOKEN_REFRESH_WINDOW).
+	AuthTokenRefreshWindow time.Duration `env:"AUTH_TOKEN_REFRESH_WINDOW" json:"auth_token_refresh_window"`
+	// AuthCookieSecure - атрибут Secure куки аутентификации (флаг -auth-cookie-secure, env AUTH_COOKIE_SECURE).
+	AuthCookieSecure bool `env:"AUTH_COOKIE_SECURE" json:"auth_cookie_secure"`
+	// AuthCookieHTTPOnly - атрибут HttpOnly куки аутентификации (флаг -auth-cookie-http-only, env AUTH_COOKIE_HTTP_ONLY).
+	AuthCookieHTTPOnly bool `env:"AUTH_COOKIE_HTTP_ONLY" json:"auth_cookie_http_only"`
+	// AuthCookieSameSite - атрибут SameSite куки аутентификации: lax, strict или none (флаг -auth-cookie-same-site, env AUTH_COOKIE_SAME_SITE).
+	AuthCookieSameSite string `env:"AUTH_COOKIE_SAME_SITE" json:"auth_cookie_same_site"`
+	// AuthCookiePath - атрибут Path куки аутентификации (флаг -auth-cookie-path, env AUTH_COOKIE_PATH).
+	AuthCookiePath string `env:"AUTH_COOKIE_PATH" json:"auth_cookie_path"`
+	// AuthSigningAlgorithm - алгоритм подписи токенов аутентификации: HS256 (AuthKey), EdDSA или RS256
+	// (AuthPrivateKeyFile) (флаг -auth-signing-algorithm, env AUTH_SIGNING_ALGORITHM).
+	AuthSigningAlgorithm string `env:"AUTH_SIGNING_ALGORITHM" json:"auth_signing_algorithm"`
+	// AuthPrivateKeyFile - PEM-файл закрытого ключа для EdDSA или RS256; при асимметричной подписи AuthKey
+	// и AuthPreviousKeys только проверяют ранее выпущенные токены (флаг -auth-private-key-file, env AUTH_PRIVATE_KEY_FILE).
+	AuthPrivateKeyFile string `env:"AUTH_PRIVATE_KEY_FILE" json:"auth_private_key_file"`
+	// AuthPreviousPublicKeyFiles - PEM-файлы прежних открытых ключей через запятую: ими токены только проверяются,
+	// а ключи публикуются в JWKS (флаг -auth-previous-public-key-files, env AUTH_PREVIOUS_PUBLIC_KEY_FILES).
+	AuthPreviousPublicKeyFiles string `env:"AUTH_PREVIOUS_PUBLIC_KEY_FILES" json:"auth_previous_public_key_files"`
+	// AuthIssuer - claim iss выпускаемых токенов, обязательный при проверке (флаг -auth-issuer, env AUTH_ISSUER).
+	AuthIssuer string `env:"AUTH_ISSUER" json:"auth_issuer"`
+	// AuthAudience - claim aud выпускаемых токенов, обязательный при проверке (флаг -auth-audience, env AUTH_AUDIENCE).
+	AuthAudience string `env:"AUTH_AUDIENCE" json:"auth_audience"`
+	// AuthAllowLegacyTokens - принимать HMAC-токены без iss и aud, выпущенные до их появления, и перевыпускать их
+	// (флаг -auth-allow-legacy-tokens, env AUTH_ALLOW_LEGACY_TOKENS).
+	AuthAllowLegacyTokens bool `env:"AUTH_ALLOW_LEGACY_TOKENS" json:"auth_allow_legacy_tokens"`
 	// EnableHTTPS - флаг, включающий HTTPS на сервере (флаг -s, env ENABLE_HTTPS).
 	EnableHTTPS bool `env:"ENABLE_HTTPS" json:"enable_https"`
+	// GRPCServerAddress - сетевой адрес и порт для запуска gRPC-сервера; пустое значение отключает его (флаг -g, env GRPC_SERVER_ADDRESS).
+	GRPCServerAddress string `env:"GRPC_SERVER_ADDRESS" json:"grpc_server_address"`
+	// ExpiredSweepInterval - период фоновой очистки ссылок с истёкшим сроком жизни (флаг -e, env EXPIRED_SWEEP_INTERVAL).
+	ExpiredSweepInterval time.Duration `env:"EXPIRED_SWEEP_INTERVAL" json:"expired_sweep_interval"`
+	// ClickBufferSize - ёмкость буфера событий переходов; при переполнении события отбрасываются (флаг -click-buffer-size, env CLICK_BUFFER_SIZE).
+	ClickBufferSize int `env:"CLICK_BUFFER_SIZE" json:"click_buffer_size"`
+	// ClickFlushInterval - период сохранения накопленных событий переходов в хранилище (флаг -click-flush-interval, env CLICK_FLUSH_INTERVAL).
+	ClickFlushInterval time.Duration `env:"CLICK_FLUSH_INTERVAL" json:"click_flush_interval"`
+	// BackupCompactInterval - период проверки необходимости сжатия файла бэкапа; 0 отключает сжатие (флаг -backup-compact-interval, env BACKUP_COMPACT_INTERVAL).
+	BackupCompactInterval time.Duration `env:"BACKUP_COMPACT_INTERVAL" json:"backup_compact_interval"`
+	// BackupCompactThreshold - минимальное количество событий в журнале бэкапа после последнего снимка для запуска сжатия (флаг -backup-compact-threshold, env BACKUP_COMPACT_THRESHOLD).
+	BackupCompactThreshold int64 `env:"BACKUP_COMPACT_THRESHOLD" json:"backup_compact_threshold"`
+	// BackupSyncMode - режим fsync файла бэкапа: always, interval или never (флаг -backup-sync-mode, env BACKUP_SYNC_MODE).
+	BackupSyncMode string `env:"BACKUP_SYNC_MODE" json:"backup_sync_mode"`
+	// BackupSyncInterval - период fsync файла бэкапа в режиме interval (флаг -backup-sync-interval, env BACKUP_SYNC_INTERVAL).
+	BackupSyncInterval time.Duration `env:"BACKUP_SYNC_INTERVAL" json:"backup_sync_interval"`
+	// ShortCodeStrategy - стратегия генерации кодов коротких ссылок: hash, counter или random (флаг -short-code-strategy, env SHORT_CODE_STRATEGY).
+	ShortCodeStrategy string `env:"SHORT_CODE_STRATEGY" json:"short_code_strategy"`
+	// ShortCodeLength - длина кодов коротких ссылок (для counter — минимальная); 0 — значение по умолчанию для стратегии (флаг -short-code-length, env SHORT_CODE_LENGTH).
+	ShortCodeLength int `env:"SHORT_CODE_LENGTH" json:"short_code_length"`
+	// AdminToken - токен для доступа к служебному API /api/admin/* и метрикам /metrics; пустое значение отключает их (флаг -admin-token, env ADMIN_TOKEN).
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// QueryTimeout - таймаут одного запроса к базе данных; пакетные операции получают кратно больший таймаут (флаг -query-timeout, env QUERY_TIMEOUT).
+	QueryTimeout time.Duration `env:"QUERY_TIMEOUT" json:"query_timeout"`
+	// DeleteQueueCapacity - максимальное количество ссылок, ожидающих удаления; при переполнении запросы отклоняются (флаг -delete-queue-capacity, env DELETE_QUEUE_CAPACITY).
+	DeleteQueueCapacity int `env:"DELETE_QUEUE_CAPACITY" json:"delete_queue_capacity"`
+	// DeleteBatchSize - количество ссылок, при накоплении которого удаление выполняется, не дожидаясь таймера (флаг -delete-batch-size, env DELETE_BATCH_SIZE).
+	DeleteBatchSize int `env:"DELETE_BATCH_SIZE" json:"delete_batch_size"`
+	// DeleteFlushInterval - период выполнения накопленных удалений (флаг -delete-flush-interval, env DELETE_FLUSH_INTERVAL).
+	DeleteFlushInterval time.Duration `env:"DELETE_FLUSH_INTERVAL" json:"delete_flush_interval"`
+	// DeleteWorkers - количество параллельных обработчиков очереди удаления (флаг -delete-workers, env DELETE_WORKERS).
+	DeleteWorkers int `env:"DELETE_WORKERS" json:"delete_workers"`
+	// DeleteQueueFile - путь к журналу невыполненных удалений, переживающему перезапуск; пустое значение отключает журнал (флаг -delete-queue-file, env DELETE_QUEUE_FILE).
+	DeleteQueueFile string `env:"DELETE_QUEUE_FILE" json:"delete_queue_file"`
+	// TraceExporter - экспортёр спанов трассировки: none, stdout или otlp (флаг -trace-exporter, env TRACE_EXPORTER).
+	TraceExporter string `env:"TRACE_EXPORTER" json:"trace_exporter"`
+	// TraceEndpoint - URL коллектора OTLP/HTTP для экспортёра otlp (флаг -trace-endpoint, env TRACE_ENDPOINT).
+	TraceEndpoint string `env:"TRACE_ENDPOINT" json:"trace_endpoint"`
+	// CacheSize - максимальное количество ссылок во внутрипроцессном кэше перед PostgreSQL; 0 отключает кэш (флаг -cache-size, env CACHE_SIZE).
+	CacheSize int `env:"CACHE_SIZE" json:"cache_size"`
+	// CacheTTL - время жизни записи кэша коротких ссылок, но не дольше срока жизни самой ссылки; неположительное значение заменяется на минуту (флаг -cache-ttl, env CACHE_TTL).
+	CacheTTL time.Duration `env:"CACHE_TTL" json:"cache_ttl"`
+	// CacheRedisURL - адрес Redis (redis://host:port/db) для кэша коротких ссылок вместо внутрипроцессного; пустое значение — внутрипроцессный кэш (флаг -cache-redis-url, env CACHE_REDIS_URL).
+	CacheRedisURL string `env:"CACHE_REDIS_URL" json:"cache_redis_url"`
+	// RateLimitCreate - количество запросов на создание ссылок в секунду, восстанавливаемое в бюджете клиента; 0 отключает ограничение (флаг -rate-limit-create, env RATE_LIMIT_CREATE).
+	RateLimitCreate float64 `env:"RATE_LIMIT_CREATE" json:"rate_limit_create"`
+	// RateLimitCreateBurst - максимальное количество запросов на создание ссылок подряд (флаг -rate-limit-create-burst, env RATE_LIMIT_CREATE_BURST).
+	RateLimitCreateBurst int `env:"RATE_LIMIT_CREATE_BURST" json:"rate_limit_create_burst"`
+	// RateLimitRedirect - количество переходов по ссылкам в секунду, восстанавливаемое в бюджете клиента; 0 отключает ограничение (флаг -rate-limit-redirect, env RATE_LIMIT_REDIRECT).
+	RateLimitRedirect float64 `env:"RATE_LIMIT_REDIRECT" json:"rate_limit_redirect"`
+	// RateLimitRedirectBurst - максимальное количество переходов по ссылкам подряд (флаг -rate-limit-redirect-burst, env RATE_LIMIT_REDIRECT_BURST).
+	RateLimitRedirectBurst int `env:"RATE_LIMIT_REDIRECT_BURST" json:"rate_limit_redirect_burst"`
+	// RateLimitTrustedProxies - IP-адреса и подсети CIDR обратных прокси через запятую: для запросов от них IP-адрес клиента берётся из X-Forwarded-For; пустое значение — всегда адрес соединения (флаг -rate-limit-trusted-proxies, env RATE_LIMIT_TRUSTED_PROXIES).
+	RateLimitTrustedProxies string `env:"RATE_LIMIT_TRUSTED_PROXIES" json:"rate_limit_trusted_proxies"`
+	// URLAllowedSchemes - разрешённые схемы оригинальных URL через запятую (флаг -url-allowed-schemes, env URL_ALLOWED_SCHEMES).
+	URLAllowedSchemes string `env:"URL_ALLOWED_SCHEMES" json:"url_allowed_schemes"`
+	// URLMaxLength - максимальная длина оригинального URL; 0 снимает ограничение (флаг -url-max-length, env URL_MAX_LENGTH).
+	URLMaxLength int `env:"URL_MAX_LENGTH" json:"url_max_length"`
+	// URLAllowPrivateHosts - разрешает оригинальные URL с локальными и частными адресами (флаг -url-allow-private-hosts, env URL_ALLOW_PRIVATE_HOSTS).
+	URLAllowPrivateHosts bool `env:"URL_ALLOW_PRIVATE_HOSTS" json:"url_allow_private_hosts"`
+	// URLBlocklistFile - путь к списку запрещённых доменов, по одному в строке; пустое значение отключает список (флаг -url-blocklist-file, env URL_BLOCKLIST_FILE).
+	URLBlocklistFile string `env:"URL_BLOCKLIST_FILE" json:"url_blocklist_file"`
+	// URLBlocklistReloadInterval - период проверки изменения списка запрещённых доменов (флаг -url-blocklist-reload-interval, env URL_BLOCKLIST_RELOAD_INTERVAL).
+	URLBlocklistReloadInterval time.Duration `env:"URL_BLOCKLIST_RELOAD_INTERVAL" json:"url_blocklist_reload_interval"`
+	// AuditSink - приёмник журнала изменяющих операций: file, postgres или none (флаг -audit-sink, env AUDIT_SINK).
+	AuditSink string `env:"AUDIT_SINK" json:"audit_sink"`
+	// AuditFilePath - путь к файлу журнала изменяющих операций для приёмника file (флаг -audit-file, env AUDIT_FILE_PATH).
+	AuditFilePath string `env:"AUDIT_FILE_PATH" json:"audit_file_path"`
+	// AuditFileMaxSize - размер файла журнала в байтах, после которого он ротируется; 0 отключает ротацию (флаг -audit-file-max-size, env AUDIT_FILE_MAX_SIZE).
+	AuditFileMaxSize int64 `env:"AUDIT_FILE_MAX_SIZE" json:"audit_file_max_size"`
+	// AuditFileMaxBackups - количество хранимых файлов журнала после ротации, старые удаляются (флаг -audit-file-max-backups, env AUDIT_FILE_MAX_BACKUPS).
+	AuditFileMaxBackups int `env:"AUDIT_FILE_MAX_BACKUPS" json:"audit_file_max_backups"`
+	// AuditBufferSize - ёмкость буфера событий журнала; при переполнении события отбрасываются после короткого ожидания (флаг -audit-buffer-size, env AUDIT_BUFFER_SIZE).
+	AuditBufferSize int `env:"AUDIT_BUFFER_SIZE" json:"audit_buffer_size"`
+	// AuditFlushInterval - период записи накопленных событий журнала в приёмник (флаг -audit-flush-interval, env AUDIT_FLUSH_INTERVAL).
+	AuditFlushInterval time.Duration `env:"AUDIT_FLUSH_INTERVAL" json:"audit_flush_interval"`
 }
 
 // JSONConfig - это вспомогательная структура для разбора конфигурации из JSON-файла.
 // Использование указателей позволяет отличить отсутствующее в JSON поле от поля с нулевым значением
 // (например, пустой строки или false).
 type JSONConfig struct {
-	ServerAddress   *string `json:"server_address"`
-	BaseShortURL    *string `json:"base_url"`
-	StorageFilePath *string `json:"file_storage_path"`
-	DataSourceName  *string `json:"database_dsn"`
-	EnableHTTPS     *bool   `json:"enable_https"`
+	ServerAddress     *string `json:"server_address"`
+	BaseShortURL      *string `json:"base_url"`
+	StorageFilePath   *string `json:"file_storage_path"`
+	DataSourceName    *string `json:"database_dsn"`
+	EnableHTTPS       *bool   `json:"enable_https"`
+	GRPCServerAddress *string `json:"grpc_server_address"`
+	// ExpiredSweepInterval задаётся строкой в формате time.ParseDuration (например, "1m").
+	ExpiredSweepInterval *string `json:"expired_sweep_interval"`
+	ClickBufferSize      *int    `json:"click_buffer_size"`
+	// ClickFlushInterval задаётся строкой в формате time.ParseDuration (например, "5s").
+	ClickFlushInterval *string `json:"click_flush_interval"`
+	// BackupCompactInterval задаётся строкой в формате time.ParseDuration (например, "10m").
+	BackupCompactInterval  *string `json:"backup_compact_interval"`
+	BackupCompactThreshold *int64  `json:"backup_compact_threshold"`
+	BackupSyncMode         *string `json:"backup_sync_mode"`
+	// BackupSyncInterval задаётся строкой в формате time.ParseDuration (например, "1s").
+	BackupSyncInterval *string `json:"backup_sync_interval"`
+	ShortCodeStrategy  *string `json:"short_code_strategy"`
+	ShortCodeLength    *int    `json:"short_code_length"`
+	// QueryTimeout задаётся строкой в формате time.ParseDuration (например, "1s").
+	QueryTimeout        *string `json:"query_timeout"`
+	DeleteQueueCapacity *int    `json:"delete_queue_capacity"`
+	DeleteBatchSize     *int    `json:"delete_batch_size"`
+	// DeleteFlushInterval задаётся строкой в формате time.ParseDuration (например, "1s").
+	DeleteFlushInterval *string `json:"delete_flush_interval"`
+	DeleteWorkers       *int    `json:"delete_workers"`
+	DeleteQueueFile     *string `json:"delete_queue_file"`
+	TraceExporter       *string `json:"trace_exporter"`
+	TraceEndpoint       *string `json:"trace_endpoint"`
+	CacheSize           *int    `json:"cache_size"`
+	// CacheTTL задаётся строкой в формате time.ParseDuration (например, "1m").
+	CacheTTL                *string  `json:"cache_ttl"`
+	CacheRedisURL           *string  `json:"cache_redis_url"`
+	RateLimitCreate         *float64 `json:"rate_limit_create"`
+	RateLimitCreateBurst    *int     `json:"rate_limit_create_burst"`
+	RateLimitRedirect       *float64 `json:"rate_limit_redirect"`
+	RateLimitRedirectBurst  *int     `json:"rate_limit_redirect_burst"`
+	RateLimitTrustedProxies *string  `json:"rate_limit_trusted_proxies"`
+	URLAllowedSchemes       *string  `json:"url_allowed_schemes"`
+	URLMaxLength            *int     `json:"url_max_length"`
+	URLAllowPrivateHosts    *bool    `json:"url_allow_private_hosts"`
+	URLBlocklistFile        *string  `json:"url_blocklist_file"`
+	// URLBlocklistReloadInterval задаётся строкой в формате time.ParseDuration (например, "30s").
+	URLBlocklistReloadInterval *string `json:"url_blocklist_reload_interval"`
+	AuditSink                  *string `json:"audit_sink"`
+	AuditFilePath              *string `json:"audit_file_path"`
+	AuditFileMaxSize           *int64  `json:"audit_file_max_size"`
+	AuditFileMaxBackups        *int    `json:"audit_file_max_backups"`
+	AuditBufferSize            *int    `json:"audit_buffer_size"`
+	// AuditFlushInterval задаётся строкой в формате time.ParseDuration (например, "1s").
+	AuditFlushInterval *string `json:"audit_flush_interval"`
+	// AuthTokenTTL задаётся строкой в формате time.ParseDuration (например, "720h").
+	AuthTokenTTL *string `json:"auth_token_ttl"`
+	// AuthTokenRefreshWindow задаётся строкой в формате time.ParseDuration (например, "168h").
+	AuthTokenRefreshWindow     *string `json:"auth_token_refresh_window"`
+	AuthCookieSecure           *bool   `json:"auth_cookie_secure"`
+	AuthCookieHTTPOnly         *bool   `json:"auth_cookie_http_only"`
+	AuthCookieSameSite         *string `json:"auth_cookie_same_site"`
+	AuthCookiePath             *string `json:"auth_cookie_path"`
+	AuthSigningAlgorithm       *string `json:"auth_signing_algorithm"`
+	AuthPrivateKeyFile         *string `json:"auth_private_key_file"`
+	AuthPreviousPublicKeyFiles *string `json:"auth_previous_public_key_files"`
+	AuthIssuer                 *string `json:"auth_issuer"`
+	AuthAudience               *string `json:"auth_audience"`
+	AuthAllowLegacyTokens      *bool   `json:"auth_allow_legacy_tokens"`
 }
 
 var (
@@ -115,13 +410,53 @@ func Create() *Config {
 // defaultConfig создает новый экземпляр Config со значениями по умолчанию.
 func defaultConfig() *Config {
 	return &Config{
-		ServerAddress:   "localhost:8080",
-		BaseShortURL:    "http://localhost:8080",
-		LoggingLevel:    "INFO",
-		StorageFilePath: "./storage.txt",
-		DataSourceName:  "",
-		AuthKey:         "dd109d0b86dc6a06584a835538768c6a2ceb588560755c7f7b90c0bf774237c8",
-		EnableHTTPS:     false,
+		ServerAddress:              "localhost:8080",
+		BaseShortURL:               "http://localhost:8080",
+		LoggingLevel:               "INFO",
+		StorageFilePath:            "./storage.txt",
+		DataSourceName:             "",
+		AuthKey:                    "dd109d0b86dc6a06584a835538768c6a2ceb588560755c7f7b90c0bf774237c8",
+		EnableHTTPS:                false,
+		GRPCServerAddress:          "localhost:3200",
+		ExpiredSweepInterval:       time.Minute,
+		ClickBufferSize:            10000,
+		ClickFlushInterval:         5 * time.Second,
+		BackupCompactInterval:      10 * time.Minute,
+		BackupCompactThreshold:     1000,
+		BackupSyncMode:             BackupSyncInterval,
+		BackupSyncInterval:         time.Second,
+		ShortCodeStrategy:          shortcode.StrategyHash,
+		QueryTimeout:               time.Second,
+		DeleteQueueCapacity:        100000,
+		DeleteBatchSize:            1000,
+		DeleteFlushInterval:        time.Second,
+		DeleteWorkers:              2,
+		TraceExporter:              "none",
+		TraceEndpoint:              "http://localhost:4318",
+		CacheSize:                  10000,
+		CacheTTL:                   time.Minute,
+		RateLimitCreate:            10,
+		RateLimitCreateBurst:       50,
+		RateLimitRedirect:          100,
+		RateLimitRedirectBurst:     200,
+		URLAllowedSchemes:          "http,https",
+		URLMaxLength:               2048,
+		URLBlocklistReloadInterval: 30 * time.Second,
+		AuditSink:                  AuditSinkFile,
+		AuditFilePath:              "./audit.jsonl",
+		AuditFileMaxSize:           100 << 20,
+		AuditFileMaxBackups:        5,
+		AuditBufferSize:            10000,
+		AuditFlushInterval:         time.Second,
+		AuthTokenTTL:               30 * 24 * time.Hour,
+		AuthTokenRefreshWindow:     7 * 24 * time.Hour,
+		AuthCookieHTTPOnly:         true,
+		AuthCookieSameSite:         security.SameSiteLax,
+		AuthCookiePath:             "/",
+		AuthSigningAlgorithm:       security.AlgorithmHS256,
+		AuthIssuer:                 "shortener",
+		AuthAudience:               "shortener",
+		AuthAllowLegacyTokens:      true,
 	}
 }
 
@@ -175,6 +510,164 @@ func (c *Config) applyJSONConfig(path string) {
 	if jsonCfg.EnableHTTPS != nil {
 		c.EnableHTTPS = *jsonCfg.EnableHTTPS
 	}
+	if jsonCfg.GRPCServerAddress != nil {
+		c.GRPCServerAddress = *jsonCfg.GRPCServerAddress
+	}
+	if jsonCfg.ExpiredSweepInterval != nil {
+		c.ExpiredSweepInterval = parseJSONDuration("expired_sweep_interval", *jsonCfg.ExpiredSweepInterval, c.ExpiredSweepInterval)
+	}
+	if jsonCfg.ClickBufferSize != nil {
+		c.ClickBufferSize = *jsonCfg.ClickBufferSize
+	}
+	if jsonCfg.ClickFlushInterval != nil {
+		c.ClickFlushInterval = parseJSONDuration("click_flush_interval", *jsonCfg.ClickFlushInterval, c.ClickFlushInterval)
+	}
+	if jsonCfg.BackupCompactInterval != nil {
+		c.BackupCompactInterval = parseJSONDuration("backup_compact_interval", *jsonCfg.BackupCompactInterval, c.BackupCompactInterval)
+	}
+	if jsonCfg.BackupCompactThreshold != nil {
+		c.BackupCompactThreshold = *jsonCfg.BackupCompactThreshold
+	}
+	if jsonCfg.BackupSyncMode != nil {
+		c.BackupSyncMode = *jsonCfg.BackupSyncMode
+	}
+	if jsonCfg.BackupSyncInterval != nil {
+		c.BackupSyncInterval = parseJSONDuration("backup_sync_interval", *jsonCfg.BackupSyncInterval, c.BackupSyncInterval)
+	}
+	if jsonCfg.ShortCodeStrategy != nil {
+		c.ShortCodeStrategy = *jsonCfg.ShortCodeStrategy
+	}
+	if jsonCfg.ShortCodeLength != nil {
+		c.ShortCodeLength = *jsonCfg.ShortCodeLength
+	}
+	if jsonCfg.QueryTimeout != nil {
+		c.QueryTimeout = parseJSONDuration("query_timeout", *jsonCfg.QueryTimeout, c.QueryTimeout)
+	}
+	if jsonCfg.DeleteQueueCapacity != nil {
+		c.DeleteQueueCapacity = *jsonCfg.DeleteQueueCapacity
+	}
+	if jsonCfg.DeleteBatchSize != nil {
+		c.DeleteBatchSize = *jsonCfg.DeleteBatchSize
+	}
+	if jsonCfg.DeleteFlushInterval != nil {
+		c.DeleteFlushInterval = parseJSONDuration("delete_flush_interval", *jsonCfg.DeleteFlushInterval, c.DeleteFlushInterval)
+	}
+	if jsonCfg.DeleteWorkers != nil {
+		c.DeleteWorkers = *jsonCfg.DeleteWorkers
+	}
+	if jsonCfg.DeleteQueueFile != nil {
+		c.DeleteQueueFile = *jsonCfg.DeleteQueueFile
+	}
+	if jsonCfg.TraceExporter != nil {
+		c.TraceExporter = *jsonCfg.TraceExporter
+	}
+	if jsonCfg.TraceEndpoint != nil {
+		c.TraceEndpoint = *jsonCfg.TraceEndpoint
+	}
+	if jsonCfg.CacheSize != nil {
+		c.CacheSize = *jsonCfg.CacheSize
+	}
+	if jsonCfg.CacheTTL != nil {
+		c.CacheTTL = parseJSONDuration("cache_ttl", *jsonCfg.CacheTTL, c.CacheTTL)
+	}
+	if jsonCfg.CacheRedisURL != nil {
+		c.CacheRedisURL = *jsonCfg.CacheRedisURL
+	}
+	if jsonCfg.RateLimitCreate != nil {
+		c.RateLimitCreate = *jsonCfg.RateLimitCreate
+	}
+	if jsonCfg.RateLimitCreateBurst != nil {
+		c.RateLimitCreateBurst = *jsonCfg.RateLimitCreateBurst
+	}
+	if jsonCfg.RateLimitRedirect != nil {
+		c.RateLimitRedirect = *jsonCfg.RateLimitRedirect
+	}
+	if jsonCfg.RateLimitRedirectBurst != nil {
+		c.RateLimitRedirectBurst = *jsonCfg.RateLimitRedirectBurst
+	}
+	if jsonCfg.RateLimitTrustedProxies != nil {
+		c.RateLimitTrustedProxies = *jsonCfg.RateLimitTrustedProxies
+	}
+	if jsonCfg.URLAllowedSchemes != nil {
+		c.URLAllowedSchemes = *jsonCfg.URLAllowedSchemes
+	}
+	if jsonCfg.URLMaxLength != nil {
+		c.URLMaxLength = *jsonCfg.URLMaxLength
+	}
+	if jsonCfg.URLAllowPrivateHosts != nil {
+		c.URLAllowPrivateHosts = *jsonCfg.URLAllowPrivateHosts
+	}
+	if jsonCfg.URLBlocklistFile != nil {
+		c.URLBlocklistFile = *jsonCfg.URLBlocklistFile
+	}
+	if jsonCfg.URLBlocklistReloadInterval != nil {
+		c.URLBlocklistReloadInterval = parseJSONDuration("url_blocklist_reload_interval", *jsonCfg.URLBlocklistReloadInterval, c.URLBlocklistReloadInterval)
+	}
+	if jsonCfg.AuditSink != nil {
+		c.AuditSink = *jsonCfg.AuditSink
+	}
+	if jsonCfg.AuditFilePath != nil {
+		c.AuditFilePath = *jsonCfg.AuditFilePath
+	}
+	if jsonCfg.AuditFileMaxSize != nil {
+		c.AuditFileMaxSize = *jsonCfg.AuditFileMaxSize
+	}
+	if jsonCfg.AuditFileMaxBackups != nil {
+		c.AuditFileMaxBackups = *jsonCfg.AuditFileMaxBackups
+	}
+	if jsonCfg.AuditBufferSize != nil {
+		c.AuditBufferSize = *jsonCfg.AuditBufferSize
+	}
+	if jsonCfg.AuditFlushInterval != nil {
+		c.AuditFlushInterval = parseJSONDuration("audit_flush_interval", *jsonCfg.AuditFlushInterval, c.AuditFlushInterval)
+	}
+	if jsonCfg.AuthTokenTTL != nil {
+		c.AuthTokenTTL = parseJSONDuration("auth_token_ttl", *jsonCfg.AuthTokenTTL, c.AuthTokenTTL)
+	}
+	if jsonCfg.AuthTokenRefreshWindow != nil {
+		c.AuthTokenRefreshWindow = parseJSONDuration("auth_token_refresh_window", *jsonCfg.AuthTokenRefreshWindow, c.AuthTokenRefreshWindow)
+	}
+	if jsonCfg.AuthCookieSecure != nil {
+		c.AuthCookieSecure = *jsonCfg.AuthCookieSecure
+	}
+	if jsonCfg.AuthCookieHTTPOnly != nil {
+		c.AuthCookieHTTPOnly = *jsonCfg.AuthCookieHTTPOnly
+	}
+	if jsonCfg.AuthCookieSameSite != nil {
+		c.AuthCookieSameSite = *jsonCfg.AuthCookieSameSite
+	}
+	if jsonCfg.AuthCookiePath != nil {
+		c.AuthCookiePath = *jsonCfg.AuthCookiePath
+	}
+	if jsonCfg.AuthSigningAlgorithm != nil {
+		c.AuthSigningAlgorithm = *jsonCfg.AuthSigningAlgorithm
+	}
+	if jsonCfg.AuthPrivateKeyFile != nil {
+		c.AuthPrivateKeyFile = *jsonCfg.AuthPrivateKeyFile
+	}
+	if jsonCfg.AuthPreviousPublicKeyFiles != nil {
+		c.AuthPreviousPublicKeyFiles = *jsonCfg.AuthPreviousPublicKeyFiles
+	}
+	if jsonCfg.AuthIssuer != nil {
+		c.AuthIssuer = *jsonCfg.AuthIssuer
+	}
+	if jsonCfg.AuthAudience != nil {
+		c.AuthAudience = *jsonCfg.AuthAudience
+	}
+	if jsonCfg.AuthAllowLegacyTokens != nil {
+		c.AuthAllowLegacyTokens = *jsonCfg.AuthAllowLegacyTokens
+	}
+}
+
+// parseJSONDuration разбирает длительность из JSON-файла конфигурации.
+// При ошибке разбора логирует предупреждение и возвращает текущее значение.
+func parseJSONDuration(name, value string, current time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Log.Warn("Failed to parse duration from JSON config, skipping", zap.String("field", name), zap.Error(err))
+		return current
+	}
+	return d
 }
 
 // defineGlobalFlags определяет все флаги командной строки приложения в глобальном наборе flag.CommandLine.
@@ -188,6 +681,57 @@ func defineGlobalFlags() {
 	flag.BoolVar(&cfg.EnableHTTPS, EnableTLSOnServerFlag, cfg.EnableHTTPS, "Enable HTTPS")
 	flag.StringVar(&cfg.LoggingLevel, LoggingLevelFlag, cfg.LoggingLevel, "Level of logging to use")
 	flag.StringVar(&cfg.AuthKey, AuthKeyNameFlag, cfg.AuthKey, "Auth Key for authentication")
+	flag.StringVar(&cfg.GRPCServerAddress, GRPCServerAddressFlag, cfg.GRPCServerAddress, "Address of the gRPC server, empty to disable (ex: localhost:3200)")
+	flag.DurationVar(&cfg.ExpiredSweepInterval, ExpiredSweepIntervalFlag, cfg.ExpiredSweepInterval, "Interval of purging expired links (ex: 1m)")
+	flag.IntVar(&cfg.ClickBufferSize, ClickBufferSizeFlag, cfg.ClickBufferSize, "Capacity of the click events buffer")
+	flag.DurationVar(&cfg.ClickFlushInterval, ClickFlushIntervalFlag, cfg.ClickFlushInterval, "Interval of saving click events (ex: 5s)")
+	flag.DurationVar(&cfg.BackupCompactInterval, BackupCompactIntervalFlag, cfg.BackupCompactInterval, "Interval of checking whether the backup file needs compaction, 0 to disable (ex: 10m)")
+	flag.Int64Var(&cfg.BackupCompactThreshold, BackupCompactThresholdFlag, cfg.BackupCompactThreshold, "Minimal number of backup log records since the last snapshot to start compaction")
+	flag.StringVar(&cfg.BackupSyncMode, BackupSyncModeFlag, cfg.BackupSyncMode, "Backup fsync mode: always, interval or never")
+	flag.DurationVar(&cfg.BackupSyncInterval, BackupSyncIntervalFlag, cfg.BackupSyncInterval, "Interval of backup fsync in the interval mode (ex: 1s)")
+	flag.StringVar(&cfg.ShortCodeStrategy, ShortCodeStrategyFlag, cfg.ShortCodeStrategy, "Short code generation strategy: hash, counter or random")
+	flag.IntVar(&cfg.ShortCodeLength, ShortCodeLengthFlag, cfg.ShortCodeLength, "Length of short codes (minimal length for counter), 0 for the strategy default")
+	flag.StringVar(&cfg.AdminToken, AdminTokenFlag, cfg.AdminToken, "Token for the admin API, empty to disable")
+	flag.DurationVar(&cfg.QueryTimeout, QueryTimeoutFlag, cfg.QueryTimeout, "Timeout of a single database query (ex: 1s)")
+	flag.IntVar(&cfg.DeleteQueueCapacity, DeleteQueueCapacityFlag, cfg.DeleteQueueCapacity, "Maximal number of links waiting for deletion")
+	flag.IntVar(&cfg.DeleteBatchSize, DeleteBatchSizeFlag, cfg.DeleteBatchSize, "Number of links deleted with a single query")
+	flag.DurationVar(&cfg.DeleteFlushInterval, DeleteFlushIntervalFlag, cfg.DeleteFlushInterval, "Interval of executing pending deletions (ex: 1s)")
+	flag.IntVar(&cfg.DeleteWorkers, DeleteWorkersFlag, cfg.DeleteWorkers, "Number of concurrent deletion workers")
+	flag.StringVar(&cfg.DeleteQueueFile, DeleteQueueFileFlag, cfg.DeleteQueueFile, "Path to the journal of pending deletions, empty to disable")
+	flag.StringVar(&cfg.TraceExporter, TraceExporterFlag, cfg.TraceExporter, "Trace span exporter: none, stdout or otlp")
+	flag.StringVar(&cfg.TraceEndpoint, TraceEndpointFlag, cfg.TraceEndpoint, "URL of the OTLP/HTTP collector (ex: http://localhost:4318)")
+	flag.IntVar(&cfg.CacheSize, CacheSizeFlag, cfg.CacheSize, "Maximal number of links in the in-process cache in front of PostgreSQL, 0 to disable")
+	flag.DurationVar(&cfg.CacheTTL, CacheTTLFlag, cfg.CacheTTL, "Time to live of cached links (ex: 1m)")
+	flag.StringVar(&cfg.CacheRedisURL, CacheRedisURLFlag, cfg.CacheRedisURL, "Redis URL for the link cache instead of the in-process one (ex: redis://localhost:6379/0)")
+	flag.Float64Var(&cfg.RateLimitCreate, RateLimitCreateFlag, cfg.RateLimitCreate, "Link creation requests per second restored to a client budget, 0 to disable")
+	flag.IntVar(&cfg.RateLimitCreateBurst, RateLimitCreateBurstFlag, cfg.RateLimitCreateBurst, "Maximal number of link creation requests of a client in a row")
+	flag.Float64Var(&cfg.RateLimitRedirect, RateLimitRedirectFlag, cfg.RateLimitRedirect, "Redirects per second restored to a client budget, 0 to disable")
+	flag.IntVar(&cfg.RateLimitRedirectBurst, RateLimitRedirectBurstFlag, cfg.RateLimitRedirectBurst, "Maximal number of redirects of a client in a row")
+	flag.StringVar(&cfg.RateLimitTrustedProxies, RateLimitTrustedProxiesFlag, cfg.RateLimitTrustedProxies, "Comma-separated IP addresses and CIDR subnets of reverse proxies whose X-Forwarded-For is trusted (ex: 10.0.0.0/8)")
+	flag.StringVar(&cfg.URLAllowedSchemes, URLAllowedSchemesFlag, cfg.URLAllowedSchemes, "Comma-separated schemes allowed in shortened URLs (ex: http,https)")
+	flag.IntVar(&cfg.URLMaxLength, URLMaxLengthFlag, cfg.URLMaxLength, "Maximal length of a shortened URL, 0 for no limit")
+	flag.BoolVar(&cfg.URLAllowPrivateHosts, URLAllowPrivateHostsFlag, cfg.URLAllowPrivateHosts, "Allow shortening URLs with local and private addresses")
+	flag.StringVar(&cfg.URLBlocklistFile, URLBlocklistFileFlag, cfg.URLBlocklistFile, "Path to the file of blocked domains, one per line, empty to disable")
+	flag.DurationVar(&cfg.URLBlocklistReloadInterval, URLBlocklistReloadIntervalFlag, cfg.URLBlocklistReloadInterval, "Interval of checking the blocked domains file for changes (ex: 30s)")
+	flag.StringVar(&cfg.AuditSink, AuditSinkFlag, cfg.AuditSink, "Sink of the audit log of mutating operations: file, postgres or none")
+	flag.StringVar(&cfg.AuditFilePath, AuditFilePathFlag, cfg.AuditFilePath, "Path to the audit log file of the file sink")
+	flag.Int64Var(&cfg.AuditFileMaxSize, AuditFileMaxSizeFlag, cfg.AuditFileMaxSize, "Size of the audit log file in bytes to rotate it at, 0 to disable rotation")
+	flag.IntVar(&cfg.AuditFileMaxBackups, AuditFileMaxBackupsFlag, cfg.AuditFileMaxBackups, "Number of rotated audit log files to keep")
+	flag.IntVar(&cfg.AuditBufferSize, AuditBufferSizeFlag, cfg.AuditBufferSize, "Capacity of the audit events buffer")
+	flag.DurationVar(&cfg.AuditFlushInterval, AuditFlushIntervalFlag, cfg.AuditFlushInterval, "Interval of writing audit events to the sink (ex: 1s)")
+	flag.StringVar(&cfg.AuthPreviousKeys, AuthPreviousKeysFlag, cfg.AuthPreviousKeys, "Comma-separated previous auth keys still accepted for tokens until they are re-issued")
+	flag.DurationVar(&cfg.AuthTokenTTL, AuthTokenTTLFlag, cfg.AuthTokenTTL, "Time to live of auth tokens and their cookie (ex: 720h)")
+	flag.DurationVar(&cfg.AuthTokenRefreshWindow, AuthTokenRefreshWindowFlag, cfg.AuthTokenRefreshWindow, "Auth tokens expiring sooner than this are re-issued for the same user (ex: 168h)")
+	flag.BoolVar(&cfg.AuthCookieSecure, AuthCookieSecureFlag, cfg.AuthCookieSecure, "Set the Secure attribute of the auth cookie")
+	flag.BoolVar(&cfg.AuthCookieHTTPOnly, AuthCookieHTTPOnlyFlag, cfg.AuthCookieHTTPOnly, "Set the HttpOnly attribute of the auth cookie")
+	flag.StringVar(&cfg.AuthCookieSameSite, AuthCookieSameSiteFlag, cfg.AuthCookieSameSite, "SameSite attribute of the auth cookie: lax, strict or none")
+	flag.StringVar(&cfg.AuthCookiePath, AuthCookiePathFlag, cfg.AuthCookiePath, "Path attribute of the auth cookie")
+	flag.StringVar(&cfg.AuthSigningAlgorithm, AuthSigningAlgorithmFlag, cfg.AuthSigningAlgorithm, "Signing algorithm of auth tokens: HS256, EdDSA or RS256")
+	flag.StringVar(&cfg.AuthPrivateKeyFile, AuthPrivateKeyFileFlag, cfg.AuthPrivateKeyFile, "PEM file with the private key signing auth tokens with EdDSA or RS256")
+	flag.StringVar(&cfg.AuthPreviousPublicKeyFiles, AuthPreviousPublicKeyFilesFlag, cfg.AuthPreviousPublicKeyFiles, "Comma-separated PEM files with previous public keys still accepted for tokens and published in JWKS")
+	flag.StringVar(&cfg.AuthIssuer, AuthIssuerFlag, cfg.AuthIssuer, "Issuer (iss) of auth tokens required on verification")
+	flag.StringVar(&cfg.AuthAudience, AuthAudienceFlag, cfg.AuthAudience, "Audience (aud) of auth tokens required on verification")
+	flag.BoolVar(&cfg.AuthAllowLegacyTokens, AuthAllowLegacyTokensFlag, cfg.AuthAllowLegacyTokens, "Accept HS256 auth tokens issued without iss and aud and re-issue them")
 
 	// Определяем флаг -c/-config здесь еще раз, чтобы он отображался в справке (-h).
 	// Его значение нам уже не нужно, так как мы его получили ранее.
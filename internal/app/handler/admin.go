@@ -0,0 +1,344 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/audit"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/middleware/admin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Admin — это HTTP-обработчик служебного API (/api/admin/*).
+// Проверка административного токена выполняется middleware admin.NewMiddleware.
+type Admin struct {
+	recovery  RecoveryReporter
+	deletions DeletionQueueReporter
+	moderator LinkModerator
+	auditLog  AuditLog
+}
+
+// AuditLog — интерфейс журнала изменяющих операций (см. audit.Recorder).
+type AuditLog interface {
+	Find(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error)
+}
+
+// LinkModerator — интерфейс сервиса модерации ссылок (см. service.Moderator).
+type LinkModerator interface {
+	SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.AdminLink, error)
+	DisableLinks(ctx context.Context, req model.AdminLinksRequest, actor string) (model.AdminAuditEntry, error)
+	EnableLinks(ctx context.Context, req model.AdminLinksRequest, actor string) (model.AdminAuditEntry, error)
+	TakedownDomain(ctx context.Context, req model.AdminTakedownRequest, actor string) (model.AdminAuditEntry, error)
+	FindAuditLog(ctx context.Context, limit int) ([]model.AdminAuditEntry, error)
+}
+
+// RecoveryReporter — интерфейс хранилища, восстанавливаемого из файла бэкапа при запуске.
+type RecoveryReporter interface {
+	RecoverySummary() model.BackupRecoverySummary
+}
+
+// DeletionQueueReporter — интерфейс сервиса с очередью фонового удаления ссылок.
+type DeletionQueueReporter interface {
+	DeletionQueueStats() model.DeletionQueueStats
+}
+
+// FindBackupRecovery обрабатывает GET-запрос на получение итогов восстановления хранилища из бэкапа.
+//
+// Путь: /api/admin/backup/recovery
+//
+// Пример ответа:
+//
+//	{"snapshot_records": 10, "tail_records": 3, "restored": 12, "skipped": 1, "truncated_bytes": 0,
+//	 "quarantine_file": "./storage.txt.quarantine", "recovered_at": "2024-01-01T00:00:00Z", "duration_ms": 2}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — итоги восстановления возвращены.
+// - 404 Not Found — хранилище не использует файл бэкапа (например, PostgreSQL).
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Admin) FindBackupRecovery(res http.ResponseWriter, _ *http.Request) {
+	if handler.recovery == nil {
+		http.Error(res, "backup is not used by the storage", http.StatusNotFound)
+		return
+	}
+	resp, err := json.Marshal(handler.recovery.RecoverySummary())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	_, err = res.Write(resp)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// FindDeletionQueue обрабатывает GET-запрос на получение состояния очереди фонового удаления ссылок.
+//
+// Путь: /api/admin/deletions/queue
+//
+// Пример ответа:
+//
+//	{"pending": 120, "in_flight": 1000, "capacity": 100000, "deleted": 53210, "retries": 2, "failed": 0, "rejected": 0}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — состояние очереди возвращено.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Admin) FindDeletionQueue(res http.ResponseWriter, _ *http.Request) {
+	resp, err := json.Marshal(handler.deletions.DeletionQueueStats())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	_, err = res.Write(resp)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// SearchLinks обрабатывает GET-запрос на поиск ссылок по коду, подстроке URL и/или владельцу.
+//
+// Путь: /api/admin/links?hash=abc&url=example.com&user=42&limit=100
+//
+// Пример ответа:
+//
+//	[{"hash": "abc", "short_url": "http://localhost:8080/abc", "original_url": "https://example.com",
+//	  "owners": ["42"], "deleted": false, "disabled": false}]
+//
+// Возможные HTTP-статусы:
+// - 200 OK — найденные ссылки возвращены (в том числе пустой список).
+// - 400 Bad Request — не задано ни одного условия поиска или лимит некорректен.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Admin) SearchLinks(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	filter := model.AdminLinkFilter{
+		HashURL: query.Get("hash"),
+		URL:     query.Get("url"),
+		UserID:  query.Get("user"),
+	}
+	limit, ok := parseLimit(res, req)
+	if !ok {
+		return
+	}
+	filter.Limit = limit
+	links, err := handler.moderator.SearchLinks(req.Context(), filter)
+	if errors.Is(err, service.ErrEmptyAdminFilter) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(res, links)
+}
+
+// DisableLinks обрабатывает POST-запрос на отключение ссылок: переход по ним возвращает 410 Gone.
+//
+// Путь: /api/admin/links/disable
+//
+// Пример запроса:
+//
+//	{"short_urls": ["abc", "def"], "reason": "phishing"}
+//
+// Пример ответа (запись журнала; в short_urls — коды, состояние которых изменилось):
+//
+//	{"action": "disable", "actor": "alice", "target": "abc,def", "short_urls": ["abc"],
+//	 "reason": "phishing", "created_at": "2024-01-01T00:00:00Z"}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — ссылки отключены.
+// - 400 Bad Request — некорректное тело запроса или пустой список кодов.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Admin) DisableLinks(res http.ResponseWriter, req *http.Request) {
+	handler.moderateLinks(res, req, handler.moderator.DisableLinks)
+}
+
+// EnableLinks обрабатывает POST-запрос на включение ранее отключённых ссылок.
+//
+// Путь: /api/admin/links/enable
+//
+// Тело запроса и ответ — как у DisableLinks.
+//
+// Возможные HTTP-статусы:
+// - 200 OK — ссылки включены.
+// - 400 Bad Request — некорректное тело запроса или пустой список кодов.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Admin) EnableLinks(res http.ResponseWriter, req *http.Request) {
+	handler.moderateLinks(res, req, handler.moderator.EnableLinks)
+}
+
+// TakedownDomain обрабатывает POST-запрос на отключение всех ссылок на домен и его поддомены.
+//
+// Путь: /api/admin/takedowns
+//
+// Пример запроса:
+//
+//	{"domain": "example.com", "reason": "malware"}
+//
+// Пример ответа:
+//
+//	{"action": "takedown_domain", "target": "example.com", "short_urls": ["abc", "def"],
+//	 "reason": "malware", "created_at": "2024-01-01T00:00:00Z"}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — ссылки на домен отключены.
+// - 400 Bad Request — некорректное тело запроса или домен.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Admin) TakedownDomain(res http.ResponseWriter, req *http.Request) {
+	var takedown model.AdminTakedownRequest
+	if err := json.NewDecoder(req.Body).Decode(&takedown); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := handler.moderator.TakedownDomain(req.Context(), takedown, req.Header.Get(admin.ActorHeader))
+	if errors.Is(err, service.ErrInvalidDomain) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(res, entry)
+}
+
+// FindAuditLog обрабатывает GET-запрос на получение журнала действий администраторов, начиная с новых.
+//
+// Путь: /api/admin/audit?limit=100
+//
+// Возможные HTTP-статусы:
+// - 200 OK — записи журнала возвращены.
+// - 400 Bad Request — некорректный лимит.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Admin) FindAuditLog(res http.ResponseWriter, req *http.Request) {
+	limit, ok := parseLimit(res, req)
+	if !ok {
+		return
+	}
+	entries, err := handler.moderator.FindAuditLog(req.Context(), limit)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(res, entries)
+}
+
+// FindAuditEvents обрабатывает GET-запрос на поиск событий журнала изменяющих операций
+// (создание ссылок, запрос и выполнение удаления), начиная с новых.
+//
+// Путь: /api/admin/audit/events?user=42&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z&limit=100
+//
+// Границы интервала задаются в формате RFC 3339: from включительно, to не включительно.
+//
+// Пример ответа:
+//
+//	[{"type": "link.created", "user_id": "42", "request_id": "5f0c...", "client_ip": "203.0.113.7",
+//	  "short_urls": ["abc"], "original_urls": ["https://example.com"], "timestamp": "2024-01-15T10:00:00Z"}]
+//
+// Возможные HTTP-статусы:
+// - 200 OK — найденные события возвращены (в том числе пустой список).
+// - 400 Bad Request — некорректные границы интервала или лимит.
+// - 404 Not Found — журнал изменяющих операций не ведётся.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Admin) FindAuditEvents(res http.ResponseWriter, req *http.Request) {
+	if handler.auditLog == nil {
+		http.Error(res, audit.ErrDisabled.Error(), http.StatusNotFound)
+		return
+	}
+	query := req.URL.Query()
+	filter := model.AuditFilter{UserID: query.Get("user")}
+	var ok bool
+	if filter.From, ok = parseTime(res, req, "from"); !ok {
+		return
+	}
+	if filter.To, ok = parseTime(res, req, "to"); !ok {
+		return
+	}
+	if filter.Limit, ok = parseLimit(res, req); !ok {
+		return
+	}
+	events, err := handler.auditLog.Find(req.Context(), filter)
+	if errors.Is(err, audit.ErrInvalidTimeRange) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, audit.ErrDisabled) {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(res, events)
+}
+
+// moderateLinks разбирает запрос на отключение или включение ссылок и выполняет его действием moderate.
+func (handler *Admin) moderateLinks(res http.ResponseWriter, req *http.Request, moderate func(ctx context.Context, req model.AdminLinksRequest, actor string) (model.AdminAuditEntry, error)) {
+	var linksRequest model.AdminLinksRequest
+	if err := json.NewDecoder(req.Body).Decode(&linksRequest); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := moderate(req.Context(), linksRequest, req.Header.Get(admin.ActorHeader))
+	if errors.Is(err, service.ErrNoShortURLs) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeAdminJSON(res, entry)
+}
+
+// parseLimit разбирает необязательный параметр limit запроса и отвечает 400 Bad Request, если он некорректен.
+//
+// Возвращает:
+//   - int: лимит; 0, если параметр не задан.
+//   - bool: false, если ответ уже записан.
+func parseLimit(res http.ResponseWriter, req *http.Request) (int, bool) {
+	rawLimit := req.URL.Query().Get("limit")
+	if rawLimit == "" {
+		return 0, true
+	}
+	limit, err := strconv.Atoi(rawLimit)
+	if err != nil || limit <= 0 {
+		http.Error(res, "limit must be a positive integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return limit, true
+}
+
+// parseTime разбирает необязательный параметр запроса в формате RFC 3339 и отвечает 400 Bad Request,
+// если он некорректен.
+//
+// Возвращает:
+//   - time.Time: момент времени; нулевой, если параметр не задан.
+//   - bool: false, если ответ уже записан.
+func parseTime(res http.ResponseWriter, req *http.Request, name string) (time.Time, bool) {
+	raw := req.URL.Query().Get(name)
+	if raw == "" {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		http.Error(res, name+" must be an RFC 3339 timestamp", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// writeAdminJSON записывает ответ служебного API в формате JSON со статусом 200 OK.
+func writeAdminJSON(res http.ResponseWriter, v any) {
+	writeJSON(res, http.StatusOK, v)
+}
@@ -0,0 +1,305 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/audit"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/health"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository/inmemory"
+	"github.com/faust8888/shortener/internal/app/route"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/app/shortcode"
+	"github.com/faust8888/shortener/internal/middleware/admin"
+	"github.com/faust8888/shortener/internal/middleware/requestmeta"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testAdminToken = "admin-secret"
+
+func startAdminTestServer(t *testing.T, adminToken string, withRecovery bool) *httptest.Server {
+	cfg := config.Create()
+	cfg.StorageFilePath = filepath.Join(t.TempDir(), "storage.txt")
+	cfg.AdminToken = adminToken
+	repo := inmemory.NewInMemoryRepository(cfg)
+	t.Cleanup(func() {
+		_ = repo.Close()
+	})
+	var recovery RecoveryReporter
+	if withRecovery {
+		recovery = repo
+	}
+	clicks := service.NewClickCollector(repo, cfg.ClickBufferSize, cfg.ClickFlushInterval)
+	generator, err := shortcode.NewGenerator(cfg.ShortCodeStrategy, cfg.ShortCodeLength, repo)
+	require.NoError(t, err)
+	deletions, err := service.NewDeletionQueue(repo, cfg.DeleteQueueCapacity, cfg.DeleteBatchSize, 10*time.Millisecond, cfg.DeleteWorkers, "")
+	require.NoError(t, err)
+	auditSink, err := audit.NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"), 0, 0)
+	require.NoError(t, err)
+	auditRecorder := audit.NewRecorder(auditSink, 100, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		_ = auditSink.Close()
+	})
+	go func() {
+		_ = deletions.Run(ctx)
+	}()
+	go func() {
+		_ = auditRecorder.Run(ctx)
+	}()
+	shortener := service.CreateShortener(repo, cfg.BaseShortURL, generator, nil, deletions, auditRecorder)
+	apiKeys := service.NewAPIKeys(repo)
+	handler := CreateHandler(shortener, repo, health.NewChecker(), clicks, recovery, service.NewModerator(repo), auditRecorder, apiKeys, createTestAuthenticator(cfg), cfg)
+	return httptest.NewServer(route.Create(handler, createTestUsers(cfg, apiKeys), cfg))
+}
+
+func TestFindBackupRecovery(t *testing.T) {
+	tests := []struct {
+		name         string
+		adminToken   string
+		requestToken string
+		withRecovery bool
+		wantCode     int
+	}{
+		{
+			name:         "Successfully returned recovery summary",
+			adminToken:   testAdminToken,
+			requestToken: testAdminToken,
+			withRecovery: true,
+			wantCode:     http.StatusOK,
+		},
+		{
+			name:         "Wrong admin token",
+			adminToken:   testAdminToken,
+			requestToken: "wrong",
+			withRecovery: true,
+			wantCode:     http.StatusUnauthorized,
+		},
+		{
+			name:         "Missing admin token",
+			adminToken:   testAdminToken,
+			withRecovery: true,
+			wantCode:     http.StatusUnauthorized,
+		},
+		{
+			name:         "Admin API is disabled",
+			requestToken: testAdminToken,
+			withRecovery: true,
+			wantCode:     http.StatusNotFound,
+		},
+		{
+			name:         "Storage without backup",
+			adminToken:   testAdminToken,
+			requestToken: testAdminToken,
+			wantCode:     http.StatusNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := startAdminTestServer(t, test.adminToken, test.withRecovery)
+			defer server.Close()
+
+			req := resty.New().R()
+			if test.requestToken != "" {
+				req.SetHeader(admin.TokenHeader, test.requestToken)
+			}
+			resp, err := req.Get(fmt.Sprintf("%s/api/admin/backup/recovery", server.URL))
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCode, resp.StatusCode())
+
+			if test.wantCode == http.StatusOK {
+				var summary model.BackupRecoverySummary
+				require.NoError(t, json.Unmarshal(resp.Body(), &summary))
+				assert.Equal(t, int64(0), summary.Skipped)
+				assert.False(t, summary.RecoveredAt.IsZero())
+			}
+		})
+	}
+}
+
+func TestFindDeletionQueue(t *testing.T) {
+	server := startAdminTestServer(t, testAdminToken, false)
+	defer server.Close()
+
+	resp, err := resty.New().R().
+		SetHeader(admin.TokenHeader, testAdminToken).
+		Get(fmt.Sprintf("%s/api/admin/deletions/queue", server.URL))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+
+	var stats model.DeletionQueueStats
+	require.NoError(t, json.Unmarshal(resp.Body(), &stats))
+	assert.Equal(t, int64(0), stats.Pending)
+	assert.Equal(t, int64(config.Create().DeleteQueueCapacity), stats.Capacity)
+}
+
+func TestLinkModeration(t *testing.T) {
+	server := startAdminTestServer(t, testAdminToken, false)
+	defer server.Close()
+	adminRequest := func() *resty.Request {
+		return resty.New().R().SetHeader(admin.TokenHeader, testAdminToken).SetHeader(admin.ActorHeader, "alice")
+	}
+	redirectCode := func(hashURL string) int {
+		// С отключёнными редиректами resty возвращает ответ 307 вместе с ошибкой.
+		resp, _ := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R().Get(server.URL + "/" + hashURL)
+		return resp.StatusCode()
+	}
+
+	created, err := createShortURLRequest(server.URL, "https://login.evil.com/phishing").Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, created.StatusCode())
+	hashURL := extractHashKeyURLFrom(string(created.Body()))[1:]
+	userID, err := security.GetUserID(getTokenFromResponse(created), config.Create().AuthKey)
+	require.NoError(t, err)
+
+	resp, err := adminRequest().SetQueryParam("user", userID).Get(server.URL + "/api/admin/links")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	var links []model.AdminLink
+	require.NoError(t, json.Unmarshal(resp.Body(), &links))
+	require.Len(t, links, 1)
+	assert.Equal(t, hashURL, links[0].HashURL)
+	assert.Equal(t, []string{userID}, links[0].Owners)
+
+	resp, err = adminRequest().SetBody(`{"short_urls": ["` + hashURL + `"], "reason": "phishing"}`).Post(server.URL + "/api/admin/links/disable")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	var entry model.AdminAuditEntry
+	require.NoError(t, json.Unmarshal(resp.Body(), &entry))
+	assert.Equal(t, service.AdminActionDisable, entry.Action)
+	assert.Equal(t, []string{hashURL}, entry.HashURLs)
+	assert.Equal(t, http.StatusGone, redirectCode(hashURL))
+
+	resp, err = adminRequest().SetBody(`{"short_urls": ["` + hashURL + `"]}`).Post(server.URL + "/api/admin/links/enable")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, http.StatusTemporaryRedirect, redirectCode(hashURL))
+
+	resp, err = adminRequest().SetBody(`{"domain": "EVIL.com.", "reason": "malware"}`).Post(server.URL + "/api/admin/takedowns")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	require.NoError(t, json.Unmarshal(resp.Body(), &entry))
+	assert.Equal(t, "evil.com", entry.Target)
+	assert.Equal(t, []string{hashURL}, entry.HashURLs)
+	assert.Equal(t, http.StatusGone, redirectCode(hashURL))
+
+	resp, err = adminRequest().SetQueryParam("limit", "10").Get(server.URL + "/api/admin/audit")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode())
+	var entries []model.AdminAuditEntry
+	require.NoError(t, json.Unmarshal(resp.Body(), &entries))
+	require.Len(t, entries, 3)
+	assert.Equal(t, service.AdminActionTakedownDomain, entries[0].Action)
+	assert.Equal(t, "alice", entries[0].Actor)
+	assert.Equal(t, "phishing", entries[2].Reason)
+}
+
+func TestLinkModerationBadRequests(t *testing.T) {
+	server := startAdminTestServer(t, testAdminToken, false)
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{name: "Search without filter", method: http.MethodGet, path: "/api/admin/links"},
+		{name: "Search with invalid limit", method: http.MethodGet, path: "/api/admin/links?hash=abc&limit=-1"},
+		{name: "Disable without short URLs", method: http.MethodPost, path: "/api/admin/links/disable", body: `{"short_urls": []}`},
+		{name: "Enable with invalid body", method: http.MethodPost, path: "/api/admin/links/enable", body: `[`},
+		{name: "Takedown of a top-level domain", method: http.MethodPost, path: "/api/admin/takedowns", body: `{"domain": "com"}`},
+		{name: "Takedown of a URL instead of a domain", method: http.MethodPost, path: "/api/admin/takedowns", body: `{"domain": "https://evil.com"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := resty.New().R().
+				SetHeader(admin.TokenHeader, testAdminToken).
+				SetBody(test.body).
+				Execute(test.method, server.URL+test.path)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+		})
+	}
+}
+
+func TestFindAuditEvents(t *testing.T) {
+	server := startAdminTestServer(t, testAdminToken, false)
+	defer server.Close()
+	findEvents := func(params map[string]string) (*resty.Response, []model.AuditEvent) {
+		resp, err := resty.New().R().SetHeader(admin.TokenHeader, testAdminToken).SetQueryParams(params).
+			Get(server.URL + "/api/admin/audit/events")
+		require.NoError(t, err)
+		var events []model.AuditEvent
+		if resp.StatusCode() == http.StatusOK {
+			require.NoError(t, json.Unmarshal(resp.Body(), &events))
+		}
+		return resp, events
+	}
+	startedAt := time.Now().UTC().Add(-time.Second)
+
+	created, err := createShortURLRequest(server.URL, "https://example.com/audit",
+		RequestHeader{HeaderName: requestmeta.Header, HeaderValue: "create-1"}).Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, created.StatusCode())
+	assert.Equal(t, "create-1", created.Header().Get(requestmeta.Header))
+	hashURL := extractHashKeyURLFrom(string(created.Body()))[1:]
+	token := getTokenFromResponse(created)
+	userID, err := security.GetUserID(token, config.Create().AuthKey)
+	require.NoError(t, err)
+
+	// Идентификатор запроса генерируется, если клиент его не передал.
+	deleted, err := resty.New().R().
+		SetCookie(&http.Cookie{Name: security.AuthorizationTokenName, Value: token}).
+		SetBody(fmt.Sprintf(`["%s"]`, hashURL)).
+		Delete(server.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, deleted.StatusCode())
+	deleteRequestID := deleted.Header().Get(requestmeta.Header)
+	require.NotEmpty(t, deleteRequestID)
+
+	var events []model.AuditEvent
+	require.Eventually(t, func() bool {
+		_, events = findEvents(map[string]string{"user": userID})
+		return len(events) == 3
+	}, time.Second, 20*time.Millisecond)
+	assert.Equal(t, model.AuditDeleteApplied, events[0].Type)
+	assert.Equal(t, deleteRequestID, events[0].RequestID)
+	assert.Equal(t, model.AuditDeleteRequested, events[1].Type)
+	assert.Equal(t, deleteRequestID, events[1].RequestID)
+	assert.Equal(t, model.AuditLinkCreated, events[2].Type)
+	assert.Equal(t, "create-1", events[2].RequestID)
+	assert.Equal(t, "127.0.0.1", events[2].ClientIP)
+	assert.Equal(t, []string{hashURL}, events[2].HashURLs)
+	assert.Equal(t, []string{"https://example.com/audit"}, events[2].OriginalURLs)
+	for _, event := range events {
+		assert.Equal(t, userID, event.UserID)
+		assert.True(t, event.Timestamp.After(startedAt))
+	}
+
+	// Фильтр по интервалу и лимит.
+	_, events = findEvents(map[string]string{"user": userID, "to": startedAt.Format(time.RFC3339)})
+	assert.Empty(t, events)
+	_, events = findEvents(map[string]string{"user": userID, "from": startedAt.Format(time.RFC3339), "limit": "1"})
+	require.Len(t, events, 1)
+	assert.Equal(t, model.AuditDeleteApplied, events[0].Type)
+	_, events = findEvents(map[string]string{"user": "unknown"})
+	assert.Empty(t, events)
+
+	// Некорректные параметры.
+	resp, _ := findEvents(map[string]string{"from": "yesterday"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	resp, _ = findEvents(map[string]string{"from": "2024-02-01T00:00:00Z", "to": "2024-01-01T00:00:00Z"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+}
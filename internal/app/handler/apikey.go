@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/go-chi/chi/v5"
+	"io"
+	"net/http"
+)
+
+// APIKeys — это HTTP-обработчик ключей API текущего пользователя (/api/user/api-keys).
+// Пользователя определяет auth.Middleware.
+type APIKeys struct {
+	service APIKeyManager
+}
+
+// APIKeyManager — интерфейс сервиса ключей API (см. service.APIKeys).
+type APIKeyManager interface {
+	Create(ctx context.Context, userID string, req model.CreateAPIKeyRequest) (model.CreateAPIKeyResponse, error)
+	List(ctx context.Context, userID string) ([]model.APIKeyResponse, error)
+	Revoke(ctx context.Context, id, userID string) (model.APIKeyResponse, error)
+}
+
+// CreateAPIKey обрабатывает POST-запрос на создание ключа API.
+// Ключ возвращается только в этом ответе: сервис хранит лишь его отпечаток.
+// Тело запроса необязательно.
+//
+// Путь: /api/user/api-keys
+//
+// Пример запроса:
+//
+//	{"name": "ci"}
+//
+// Пример ответа:
+//
+//	{"id": "6f1c...", "name": "ci", "prefix": "shk_Xb3f9Q", "created_at": "2024-01-01T00:00:00Z",
+//	 "key": "shk_Xb3f9Q..."}
+//
+// Возможные HTTP-статусы:
+// - 201 Created — ключ создан.
+// - 400 Bad Request — некорректное тело запроса, слишком длинное название или превышен лимит ключей.
+// - 401 Unauthorized — отсутствующий или недействительный токен.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *APIKeys) CreateAPIKey(res http.ResponseWriter, req *http.Request) {
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
+		return
+	}
+	var createRequest model.CreateAPIKeyRequest
+	if err := json.NewDecoder(req.Body).Decode(&createRequest); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := handler.service.Create(req.Context(), userID, createRequest)
+	if errors.Is(err, service.ErrAPIKeyNameTooLong) || errors.Is(err, service.ErrTooManyAPIKeys) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(res, http.StatusCreated, created)
+}
+
+// FindAPIKeys обрабатывает GET-запрос на получение ключей API пользователя, включая отозванные.
+//
+// Путь: /api/user/api-keys
+//
+// Пример ответа:
+//
+//	[{"id": "6f1c...", "name": "ci", "prefix": "shk_Xb3f9Q", "created_at": "2024-01-01T00:00:00Z"}]
+//
+// Возможные HTTP-статусы:
+// - 200 OK — ключи возвращены (в том числе пустой список).
+// - 401 Unauthorized — отсутствующий или недействительный токен.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *APIKeys) FindAPIKeys(res http.ResponseWriter, req *http.Request) {
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
+		return
+	}
+	keys, err := handler.service.List(req.Context(), userID)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(res, http.StatusOK, keys)
+}
+
+// RevokeAPIKey обрабатывает DELETE-запрос на отзыв ключа API. Отозванный ключ остаётся в списке
+// с заполненным revoked_at, а запросы с ним отклоняются.
+//
+// Путь: /api/user/api-keys/{id}
+//
+// Пример ответа:
+//
+//	{"id": "6f1c...", "name": "ci", "prefix": "shk_Xb3f9Q", "created_at": "2024-01-01T00:00:00Z",
+//	 "revoked_at": "2024-02-01T00:00:00Z"}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — ключ отозван (в том числе если он был отозван раньше).
+// - 401 Unauthorized — отсутствующий или недействительный токен.
+// - 404 Not Found — у пользователя нет такого ключа.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *APIKeys) RevokeAPIKey(res http.ResponseWriter, req *http.Request) {
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
+		return
+	}
+	revoked, err := handler.service.Revoke(req.Context(), chi.URLParam(req, config.APIKeyIDURLParam), userID)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(res, http.StatusOK, revoked)
+}
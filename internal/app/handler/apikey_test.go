@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+
+	created, err := createShortURLRequest(server.URL, "https://yandex.ru/api-keys").Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, created.StatusCode())
+	token := getTokenFromResponse(created)
+	require.NotEmpty(t, token)
+	withCookie := func() *resty.Request {
+		return resty.New().R().SetCookie(&http.Cookie{Name: security.AuthorizationTokenName, Value: token})
+	}
+	withBearer := func(credential string) *resty.Request {
+		return resty.New().R().SetAuthToken(credential)
+	}
+
+	res, err := resty.New().R().SetBody(`{"name": "ci"}`).Post(server.URL + "/api/user/api-keys")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode(), "creating a key must require a token")
+
+	res, err = withCookie().SetBody(`{"name": "ci"}`).Post(server.URL + "/api/user/api-keys")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, res.StatusCode())
+	var key model.CreateAPIKeyResponse
+	require.NoError(t, json.Unmarshal(res.Body(), &key))
+	assert.Equal(t, "ci", key.Name)
+	assert.True(t, security.IsAPIKey(key.Key))
+	assert.Equal(t, key.Prefix, key.Key[:len(key.Prefix)])
+
+	// Ключ API и JWT-токен в заголовке Authorization заменяют куку.
+	for name, credential := range map[string]string{"API key": key.Key, "Bearer token": token} {
+		t.Run(name, func(t *testing.T) {
+			res, err := withBearer(credential).Get(server.URL + "/api/user/urls")
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, res.StatusCode())
+			var urls []model.FindURLByUserIDResponse
+			require.NoError(t, json.Unmarshal(res.Body(), &urls))
+			assert.True(t, containsShortURL(urls, string(created.Body()), "https://yandex.ru/api-keys"))
+			assert.Empty(t, res.Cookies(), "bearer clients must not get cookies")
+		})
+	}
+	res, err = withBearer(key.Key).SetHeader("Content-Type", "application/json").
+		SetBody(`{"url": "https://yandex.ru/api-keys/json"}`).Post(server.URL + "/api/shorten")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, res.StatusCode())
+
+	// Ключом API нельзя управлять ключами: утёкший ключ не должен выпускать новые.
+	res, err = withBearer(key.Key).SetBody(`{"name": "leaked"}`).Post(server.URL + "/api/user/api-keys")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode(), "an api key must not create keys")
+	res, err = withBearer(key.Key).Get(server.URL + "/api/user/api-keys")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode(), "an api key must not list keys")
+	res, err = withBearer(key.Key).Delete(server.URL + "/api/user/api-keys/" + key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, res.StatusCode(), "an api key must not revoke keys")
+	res, err = withBearer(token).Get(server.URL + "/api/user/api-keys")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode(), "a bearer token must manage keys")
+
+	res, err = withCookie().Get(server.URL + "/api/user/api-keys")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode())
+	assert.NotContains(t, res.String(), key.Key, "the key must be shown only once")
+	var keys []model.APIKeyResponse
+	require.NoError(t, json.Unmarshal(res.Body(), &keys))
+	require.Len(t, keys, 1)
+	assert.Equal(t, key.ID, keys[0].ID)
+	assert.Nil(t, keys[0].RevokedAt)
+
+	otherToken, err := security.BuildToken(config.Create().AuthKey)
+	require.NoError(t, err)
+	res, err = withBearer(otherToken).Delete(server.URL + "/api/user/api-keys/" + key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode(), "another user must not revoke the key")
+
+	res, err = withCookie().Delete(server.URL + "/api/user/api-keys/" + key.ID)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode())
+	var revoked model.APIKeyResponse
+	require.NoError(t, json.Unmarshal(res.Body(), &revoked))
+	assert.NotNil(t, revoked.RevokedAt)
+
+	res, err = withBearer(key.Key).Get(server.URL + "/api/user/urls")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode(), "a revoked key must be rejected")
+	res, err = withBearer(key.Key).Post(server.URL + "/")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode(), "a revoked key must not be replaced by a new user")
+}
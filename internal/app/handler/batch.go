@@ -1,29 +1,29 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/faust8888/shortener/internal/app/model"
-	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
 	"net/http"
 )
 
 // Batch — это HTTP-обработчик, предназначенный для обработки пакетного создания коротких ссылок.
-// Для сохранения данных используется интерфейс batchSaver, а для авторизации — authKey.
+// Для сохранения данных используется интерфейс batchSaver; пользователя определяет auth.Middleware.
 type Batch struct {
 	service batchSaver
-	authKey string
 }
 
 type batchSaver interface {
-	CreateWithBatch(batch []model.CreateShortRequestBatchItemRequest, userID string) ([]model.CreateShortRequestBatchItemResponse, error)
+	CreateWithBatch(ctx context.Context, batch []model.CreateShortRequestBatchItemRequest, userID string) ([]model.CreateShortRequestBatchItemResponse, error)
 }
 
 // CreateLinkWithBatch обрабатывает входящий POST-запрос с пакетом данных для создания коротких ссылок.
 //
 // Метод:
-// - Проверяет или генерирует токен авторизации.
-// - Извлекает идентификатор пользователя из токена.
+// - Получает идентификатор пользователя из контекста запроса (см. auth.Middleware).
 // - Декодирует JSON-тело запроса.
 // - Передаёт данные сервису для сохранения.
 // - Возвращает JSON-ответ со списком созданных ссылок.
@@ -32,48 +32,50 @@ type batchSaver interface {
 //
 //	[
 //	  {"correlation_id": "id1", "original_url": "http://example.com/1"},
-//	  {"correlation_id": "id2", "original_url": "http://example.com/2"}
+//	  {"correlation_id": "id2", "original_url": "http://example.com/2", "alias": "promo", "ttl_seconds": 3600}
 //	]
 //
 // Ответ:
 //
 //	[
 //	  {"correlation_id": "id1", "short_url": "http://your-shortener.com/abc"},
-//	  {"correlation_id": "id2", "short_url": "http://your-shortener.com/def"}
+//	  {"correlation_id": "id2", "short_url": "http://your-shortener.com/promo"}
 //	]
 //
 // Возможные HTTP-статусы:
 // - 201 Created — успешно созданы.
-// - 400 Bad Request — невалидное тело запроса.
+// - 400 Bad Request — невалидное тело запроса, алиас или срок жизни.
 // - 401 Unauthorized — отсутствующий или недействительный токен.
+// - 409 Conflict — один из алиасов уже занят.
+// - 422 Unprocessable Entity — URL элемента не прошёл проверки безопасности (причина и correlation_id в JSON).
 // - 500 Internal Server Error — внутренняя ошибка сервера.
 func (handler *Batch) CreateLinkWithBatch(res http.ResponseWriter, req *http.Request) {
-	token := security.GetToken(req)
-	if token == "" {
-		newToken, err := security.BuildToken(handler.authKey)
-		if err != nil {
-			http.Error(res, fmt.Sprintf("build token: %s", err.Error()), http.StatusInternalServerError)
-			return
-		}
-		http.SetCookie(res, &http.Cookie{
-			Name:  security.AuthorizationTokenName,
-			Value: newToken,
-		})
-		token = newToken
-	}
-	userID, err := security.GetUserID(token, handler.authKey)
-	if err != nil {
-		http.Error(res, "unauthorized", http.StatusUnauthorized)
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
 		return
 	}
 
 	decoder := json.NewDecoder(http.MaxBytesReader(nil, req.Body, 10<<20))
 	var batchRequest []model.CreateShortRequestBatchItemRequest
-	if err = decoder.Decode(&batchRequest); err != nil {
+	_, span := tracing.Start(req.Context(), "json.Decode")
+	err := decoder.Decode(&batchRequest)
+	tracing.End(span, err)
+	if err != nil {
 		http.Error(res, "Invalid request payload", http.StatusBadRequest)
 		return
 	}
-	batchResponse, err := handler.service.CreateWithBatch(batchRequest, userID)
+	batchResponse, err := handler.service.CreateWithBatch(req.Context(), batchRequest, userID)
+	if writeURLRejection(res, err) {
+		return
+	}
+	if isInvalidInput(err) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, repository.ErrAliasAlreadyTaken) {
+		http.Error(res, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
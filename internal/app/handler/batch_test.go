@@ -2,11 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/faust8888/shortener/internal/app/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"testing"
+	"time"
 )
 
 func TestCreateWithBatch(t *testing.T) {
@@ -37,6 +39,32 @@ func TestCreateWithBatch(t *testing.T) {
 				code: http.StatusCreated,
 			},
 		},
+		{
+			name: "Successfully created Short URLs with Batch and alias",
+			batch: []model.CreateShortRequestBatchItemRequest{
+				{
+					CorrelationID: "1",
+					OriginalURL:   "https://yandex.ru/promo",
+					Alias:         fmt.Sprintf("promo-%d", time.Now().UnixNano()),
+				},
+			},
+			want: want{
+				code: http.StatusCreated,
+			},
+		},
+		{
+			name: "Batch with reserved alias is rejected",
+			batch: []model.CreateShortRequestBatchItemRequest{
+				{
+					CorrelationID: "1",
+					OriginalURL:   "https://yandex.ru/promo",
+					Alias:         "debug",
+				},
+			},
+			want: want{
+				code: http.StatusBadRequest,
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -50,7 +78,30 @@ func TestCreateWithBatch(t *testing.T) {
 			var batchResponse []model.CreateShortRequestBatchItemResponse
 			_ = json.Unmarshal(resp.Body(), &batchResponse)
 
-			assert.Equal(t, len(test.batch), len(batchResponse))
+			if test.want.code == http.StatusCreated {
+				assert.Equal(t, len(test.batch), len(batchResponse))
+			}
 		})
 	}
 }
+
+func TestCreateWithBatchUnsafeURL(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+
+	batch := []model.CreateShortRequestBatchItemRequest{
+		{CorrelationID: "1", OriginalURL: "https://yandex.ru"},
+		{CorrelationID: "2", OriginalURL: "file:///etc/passwd"},
+	}
+	batchAsJSON, _ := json.Marshal(&batch)
+	resp, err := createShortURLRequest(server.URL+"/api/shorten/batch", batchAsJSON).Send()
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode())
+
+	var rejection model.URLRejectionResponse
+	require.NoError(t, json.Unmarshal(resp.Body(), &rejection))
+	assert.Equal(t, "scheme_not_allowed", rejection.Code)
+	assert.Equal(t, "2", rejection.CorrelationID)
+	assert.Equal(t, "file:///etc/passwd", rejection.URL)
+}
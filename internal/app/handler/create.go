@@ -2,35 +2,33 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/faust8888/shortener/internal/app/model"
-	"github.com/faust8888/shortener/internal/app/repository/postgres"
-	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/repository"
 	"github.com/faust8888/shortener/internal/middleware/logger"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
 )
 
 // Create — это HTTP-обработчик для создания короткой ссылки из обычного текста.
-// Использует интерфейс creator для сохранения данных и требует ключ аутентификации.
+// Использует интерфейс creator для сохранения данных; пользователя определяет auth.Middleware.
 type Create struct {
 	service creator
-	authKey string
 }
 
 type creator interface {
-	Create(fullURL string, userID string) (string, error)
+	Create(ctx context.Context, fullURL string, userID string) (string, error)
 }
 
 // CreateLink обрабатывает POST-запрос на создание короткой ссылки.
 //
 // Метод:
 // - Читает тело запроса как plain text.
-// - Проверяет или генерирует токен авторизации.
-// - Извлекает userID из токена.
+// - Получает userID из контекста запроса (см. auth.Middleware).
 // - Передаёт данные сервису для сохранения.
 // - Возвращает короткий URL в теле ответа.
 //
@@ -39,6 +37,7 @@ type creator interface {
 // - 400 Bad Request — невалидное тело запроса.
 // - 401 Unauthorized — отсутствующий или недействительный токен.
 // - 409 Conflict — дублирующаяся запись.
+// - 422 Unprocessable Entity — URL не прошёл проверки безопасности (причина в JSON, см. writeURLRejection).
 // - 500 Internal Server Error — внутренняя ошибка сервера.
 func (handler *Create) CreateLink(res http.ResponseWriter, req *http.Request) {
 	requestBody, err := io.ReadAll(req.Body)
@@ -47,30 +46,24 @@ func (handler *Create) CreateLink(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	token := security.GetToken(req)
-	if token == "" {
-		token, err = security.BuildToken(handler.authKey)
-		if err != nil {
-			http.Error(res, fmt.Sprintf("build token: %s", err.Error()), http.StatusInternalServerError)
-			return
-		}
-		http.SetCookie(res, &http.Cookie{
-			Name:  security.AuthorizationTokenName,
-			Value: token,
-		})
-	}
-	userID, err := security.GetUserID(token, handler.authKey)
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
 		return
 	}
 
 	fullURL := string(requestBody)
-	shortURL, err := handler.service.Create(fullURL, userID)
-	isUniqueConstraintViolation := errors.Is(err, postgres.ErrUniqueIndexConstraint)
+	shortURL, err := handler.service.Create(req.Context(), fullURL, userID)
+	if writeURLRejection(res, err) {
+		return
+	}
+	if isInvalidInput(err) {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	isUniqueConstraintViolation := errors.Is(err, repository.ErrURLAlreadyShortened)
 	if err != nil && !isUniqueConstraintViolation {
 		logger.Log.Error("Failed to CreateLink short URL", zap.String("body", fullURL), zap.Error(err))
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -88,34 +81,39 @@ func (handler *Create) CreateLink(res http.ResponseWriter, req *http.Request) {
 }
 
 // CreateWithJSON — HTTP-обработчик для создания короткой ссылки через JSON.
-// Использует тот же интерфейс creator для сохранения данных.
+// Использует интерфейс jsonCreator для сохранения данных.
 type CreateWithJSON struct {
-	service creator
-	authKey string
+	service jsonCreator
+}
+
+type jsonCreator interface {
+	CreateWithJSON(ctx context.Context, req model.CreateShortRequest, userID string) (string, error)
 }
 
 // CreateLinkWithJSON обрабатывает POST-запрос с JSON-телом вида {"url": "http://example.com"}.
+// Необязательное поле alias задаёт пользовательский код короткой ссылки,
+// а взаимоисключающие поля expires_at (RFC 3339) и ttl_seconds — срок её жизни.
 //
 // Метод:
 // - Читает и парсит JSON-запрос.
-// - Проверяет или генерирует токен авторизации.
-// - Извлекает userID из токена.
+// - Получает userID из контекста запроса (см. auth.Middleware).
 // - Передаёт данные сервису для сохранения.
 // - Возвращает JSON-ответ с результатом.
 //
 // Пример тела запроса:
 //
-//	{"url": "http://example.com"}
+//	{"url": "http://example.com", "alias": "spring-sale", "expires_at": "2030-04-01T00:00:00Z"}
 //
 // Ответ:
 //
-//	{"result": "http://your-shortener.com/abc"}
+//	{"result": "http://your-shortener.com/spring-sale"}
 //
 // Возможные HTTP-статусы:
 // - 201 Created — успешно создано.
-// - 400 Bad Request — невалидное тело запроса.
+// - 400 Bad Request — невалидное тело запроса, алиас или срок жизни.
 // - 401 Unauthorized — отсутствующий или недействительный токен.
-// - 409 Conflict — дублирующаяся запись.
+// - 409 Conflict — дублирующаяся запись или алиас уже занят.
+// - 422 Unprocessable Entity — URL не прошёл проверки безопасности (причина в JSON, см. writeURLRejection).
 // - 500 Internal Server Error — внутренняя ошибка сервера.
 func (handler *CreateWithJSON) CreateLinkWithJSON(res http.ResponseWriter, req *http.Request) {
 	var buf bytes.Buffer
@@ -125,26 +123,16 @@ func (handler *CreateWithJSON) CreateLinkWithJSON(res http.ResponseWriter, req *
 		return
 	}
 
-	token := security.GetToken(req)
-	if token == "" {
-		token, err = security.BuildToken(handler.authKey)
-		if err != nil {
-			http.Error(res, fmt.Sprintf("build token: %s", err.Error()), http.StatusInternalServerError)
-			return
-		}
-		http.SetCookie(res, &http.Cookie{
-			Name:  security.AuthorizationTokenName,
-			Value: token,
-		})
-	}
-	userID, err := security.GetUserID(token, handler.authKey)
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
 		return
 	}
 
 	var createRequest model.CreateShortRequest
-	if err = json.Unmarshal(buf.Bytes(), &createRequest); err != nil {
+	_, span := tracing.Start(req.Context(), "json.Decode")
+	err = json.Unmarshal(buf.Bytes(), &createRequest)
+	tracing.End(span, err)
+	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -154,12 +142,23 @@ func (handler *CreateWithJSON) CreateLinkWithJSON(res http.ResponseWriter, req *
 		return
 	}
 
-	shortURL, err := handler.service.Create(createRequest.URL, userID)
-	isUniqueConstraintViolation := errors.Is(err, postgres.ErrUniqueIndexConstraint)
-	if err != nil && !isUniqueConstraintViolation {
+	shortURL, err := handler.service.CreateWithJSON(req.Context(), createRequest, userID)
+	if writeURLRejection(res, err) {
+		return
+	}
+	if isInvalidInput(err) {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if errors.Is(err, repository.ErrAliasAlreadyTaken) {
+		http.Error(res, err.Error(), http.StatusConflict)
+		return
+	}
+	isUniqueConstraintViolation := errors.Is(err, repository.ErrURLAlreadyShortened)
+	if err != nil && !isUniqueConstraintViolation {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
 	resp, err := json.Marshal(&model.CreateShortResponse{Result: shortURL})
 	if err != nil {
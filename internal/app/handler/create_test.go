@@ -3,10 +3,20 @@ package handler
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/middleware/auth"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestPost(t *testing.T) {
@@ -171,6 +181,86 @@ func TestPostWithJsonCompress(t *testing.T) {
 	}
 }
 
+func TestPostWithJsonAlias(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+
+	alias := fmt.Sprintf("spring-sale-%d", time.Now().UnixNano())
+
+	tests := []struct {
+		name           string
+		body           string
+		wantCode       int
+		responseRegexp string
+	}{
+		{
+			name:           "Short URL successfully created with alias",
+			body:           fmt.Sprintf(`{"url":"https://yandex.ru/sale","alias":"%s"}`, alias),
+			wantCode:       http.StatusCreated,
+			responseRegexp: `^\{\"result\":\"http:\/\/localhost:\d{1,5}\/` + alias + `\"\}$`,
+		},
+		{
+			name:           "Alias is already taken",
+			body:           fmt.Sprintf(`{"url":"https://yandex.ru/other","alias":"%s"}`, alias),
+			wantCode:       http.StatusConflict,
+			responseRegexp: `alias is already taken`,
+		},
+		{
+			name:           "Reserved alias",
+			body:           `{"url":"https://yandex.ru/sale","alias":"api"}`,
+			wantCode:       http.StatusBadRequest,
+			responseRegexp: `alias is reserved`,
+		},
+		{
+			name:           "Both expires_at and ttl_seconds are set",
+			body:           `{"url":"https://yandex.ru/sale","expires_at":"2999-01-01T00:00:00Z","ttl_seconds":60}`,
+			wantCode:       http.StatusBadRequest,
+			responseRegexp: `mutually exclusive`,
+		},
+		{
+			name:           "expires_at in the past",
+			body:           `{"url":"https://yandex.ru/sale","expires_at":"2000-01-01T00:00:00Z"}`,
+			wantCode:       http.StatusBadRequest,
+			responseRegexp: `must be in the future`,
+		},
+		{
+			name:           "Alias with invalid characters",
+			body:           `{"url":"https://yandex.ru/sale","alias":"spring sale!"}`,
+			wantCode:       http.StatusBadRequest,
+			responseRegexp: `alias must be`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := createShortURLRequest(server.URL+"/api/shorten", test.body).Send()
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCode, resp.StatusCode())
+			assert.Regexp(t, test.responseRegexp, string(resp.Body()))
+		})
+	}
+}
+
+func TestPostWithJsonRouteAlias(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+
+	// Алиасы, совпадающие с маршрутами роутера, перекрывались бы ими и не открывались бы как короткие ссылки.
+	for alias, responseRegexp := range map[string]string{
+		"healthz":     `alias is reserved`,
+		"readyz":      `alias is reserved`,
+		"metrics":     `alias is reserved`,
+		"Metrics":     `alias is reserved`,
+		".well-known": `alias must be`,
+	} {
+		t.Run(alias, func(t *testing.T) {
+			resp, err := createShortURLRequest(server.URL+"/api/shorten", fmt.Sprintf(`{"url":"https://yandex.ru/sale","alias":"%s"}`, alias)).Send()
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+			assert.Regexp(t, responseRegexp, string(resp.Body()))
+		})
+	}
+}
+
 func compressString(input string) []byte {
 	// CreateHandler a buffer to hold the compressed data
 	var buf bytes.Buffer
@@ -191,3 +281,113 @@ func compressString(input string) []byte {
 	// Return the compressed data as a byte slice
 	return buf.Bytes()
 }
+
+func TestPostUnsafeURL(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		body     string
+		wantCode string
+	}{
+		{
+			name:     "javascript URL is rejected",
+			url:      server.URL + "/",
+			body:     "javascript:alert(1)",
+			wantCode: "scheme_not_allowed",
+		},
+		{
+			name:     "Loopback URL is rejected with JSON",
+			url:      server.URL + "/api/shorten",
+			body:     `{"url":"http://127.0.0.1/admin"}`,
+			wantCode: "private_address",
+		},
+		{
+			name:     "Decimal loopback URL is rejected with JSON",
+			url:      server.URL + "/api/shorten",
+			body:     `{"url":"http://2130706433/"}`,
+			wantCode: "private_address",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := createShortURLRequest(test.url, test.body).Send()
+
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode())
+			assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+			var rejection model.URLRejectionResponse
+			require.NoError(t, json.Unmarshal(resp.Body(), &rejection))
+			assert.Equal(t, test.wantCode, rejection.Code)
+			assert.NotEmpty(t, rejection.Message)
+		})
+	}
+}
+
+// failingCreator возвращает заданную ошибку на любой запрос создания ссылки.
+type failingCreator struct {
+	err error
+}
+
+func (c failingCreator) Create(context.Context, string, string) (string, error) {
+	return "", c.err
+}
+
+func (c failingCreator) CreateWithJSON(context.Context, model.CreateShortRequest, string) (string, error) {
+	return "", c.err
+}
+
+func TestPostServiceErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantCode     int
+		wantJSONCode int
+	}{
+		{
+			name:         "Invalid URL is a bad request",
+			err:          fmt.Errorf("hash for url: %w", security.ErrInvalidURL),
+			wantCode:     http.StatusBadRequest,
+			wantJSONCode: http.StatusBadRequest,
+		},
+		{
+			name:         "Reserved alias is a bad request",
+			err:          fmt.Errorf("alias: %w", model.ErrReservedAlias),
+			wantCode:     http.StatusBadRequest,
+			wantJSONCode: http.StatusBadRequest,
+		},
+		{
+			// Алиас задаётся только в JSON-запросе.
+			name:         "Alias taken by a concurrent request is a conflict",
+			err:          fmt.Errorf("saving data: %w", repository.ErrAliasAlreadyTaken),
+			wantCode:     http.StatusInternalServerError,
+			wantJSONCode: http.StatusConflict,
+		},
+		{
+			name:         "Query timeout is an internal error",
+			err:          fmt.Errorf("saving data: %w", context.DeadlineExceeded),
+			wantCode:     http.StatusInternalServerError,
+			wantJSONCode: http.StatusInternalServerError,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := failingCreator{err: test.err}
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://yandex.ru"))
+			req = req.WithContext(auth.WithUserID(req.Context(), "user"))
+			rec := httptest.NewRecorder()
+			(&Create{service: service}).CreateLink(rec, req)
+			assert.Equal(t, test.wantCode, rec.Code)
+
+			req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://yandex.ru","alias":"spring-sale"}`))
+			req = req.WithContext(auth.WithUserID(req.Context(), "user"))
+			rec = httptest.NewRecorder()
+			(&CreateWithJSON{service: service}).CreateLinkWithJSON(rec, req)
+			assert.Equal(t, test.wantJSONCode, rec.Code)
+		})
+	}
+}
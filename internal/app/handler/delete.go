@@ -1,28 +1,29 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"github.com/faust8888/shortener/internal/app/security"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
 	"io"
 	"net/http"
 )
 
 // Delete — это HTTP-обработчик для асинхронного удаления коротких ссылок.
-// Использует интерфейс deleter для выполнения операции удаления и требует ключ аутентификации.
+// Использует интерфейс deleter для выполнения операции удаления; пользователя определяет auth.Middleware.
 type Delete struct {
 	service deleter
-	authKey string
 }
 
 type deleter interface {
-	DeleteAsync(ids []string, userID string) error
+	DeleteAsync(ctx context.Context, ids []string, userID string) error
 }
 
 // DeleteLink обрабатывает POST-запрос на удаление нескольких коротких ссылок.
 //
 // Метод:
-// - Проверяет наличие токена авторизации.
-// - Извлекает идентификатор пользователя из токена.
+// - Получает идентификатор пользователя из контекста запроса (см. auth.Middleware).
 // - Читает и декодирует JSON-тело запроса, ожидая массив строк (ID ссылок).
 // - Передаёт данные сервису для асинхронного удаления.
 //
@@ -35,15 +36,10 @@ type deleter interface {
 // - 400 Bad Request — невалидное тело запроса или ошибка парсинга.
 // - 401 Unauthorized — отсутствующий или недействительный токен.
 // - 500 Internal Server Error — внутренняя ошибка сервера.
+// - 503 Service Unavailable — сервис останавливается или очередь удаления переполнена.
 func (handler *Delete) DeleteLink(res http.ResponseWriter, req *http.Request) {
-	token := security.GetToken(req)
-	if token == "" {
-		res.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	userID, err := security.GetUserID(token, handler.authKey)
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
 		return
 	}
 
@@ -54,13 +50,19 @@ func (handler *Delete) DeleteLink(res http.ResponseWriter, req *http.Request) {
 	}
 
 	var ids []string
+	_, span := tracing.Start(req.Context(), "json.Decode")
 	err = json.Unmarshal(requestBody, &ids)
+	tracing.End(span, err)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	err = handler.service.DeleteAsync(ids, userID)
+	err = handler.service.DeleteAsync(req.Context(), ids, userID)
+	if errors.Is(err, service.ErrShuttingDown) || errors.Is(err, service.ErrDeletionQueueFull) {
+		http.Error(res, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusBadRequest)
 		return
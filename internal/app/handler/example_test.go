@@ -2,31 +2,40 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/health"
 	"github.com/faust8888/shortener/internal/app/model"
 	"github.com/faust8888/shortener/internal/app/repository/inmemory"
 	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/app/shortcode"
+	"github.com/faust8888/shortener/internal/middleware/auth"
 	"net/http"
 	"net/http/httptest"
+	"time"
 )
 
 // mockRepo — минимальная реализация repository.Repository для тестов.
 type mockRepo struct{}
 
-func (m *mockRepo) Save(urlHash, fullURL, userID string) error {
+func (m *mockRepo) Save(_ context.Context, urlHash, fullURL, userID string) error {
 	return nil
 }
 
-func (m *mockRepo) FindByHash(hashURL string) (string, error) {
+func (m *mockRepo) SaveLink(_ context.Context, link model.CreateShortDTO, userID string) (string, error) {
+	return link.HashURL, nil
+}
+
+func (m *mockRepo) FindByHash(_ context.Context, hashURL string) (string, error) {
 	if hashURL == "abc123" {
 		return "http://example.com", nil
 	}
 	return "", fmt.Errorf("not found")
 }
 
-func (m *mockRepo) FindAllByUserID(userID string) ([]model.FindURLByUserIDResponse, error) {
+func (m *mockRepo) FindAllByUserID(_ context.Context, userID string) ([]model.FindURLByUserIDResponse, error) {
 	if userID == "testuser123" {
 		return []model.FindURLByUserIDResponse{
 			{ShortURL: "http://your-shortener.com/abc123", OriginalURL: "http://example.com"},
@@ -35,22 +44,46 @@ func (m *mockRepo) FindAllByUserID(userID string) ([]model.FindURLByUserIDRespon
 	return nil, nil
 }
 
-func (m *mockRepo) SaveAll(batch map[string]model.CreateShortDTO, userID string) error {
+func (m *mockRepo) SaveAll(_ context.Context, batch map[string]model.CreateShortDTO, userID string) error {
+	return nil
+}
+
+func (m *mockRepo) DeleteAll(_ context.Context, shortURLs []string, userID string) error {
 	return nil
 }
 
-func (m *mockRepo) DeleteAll(shortURLs []string, userID string) error {
+func (m *mockRepo) DeleteBatch(_ context.Context, requests []model.DeletionRequest) error {
 	return nil
 }
 
-func (m *mockRepo) Ping() (bool, error) {
+func (m *mockRepo) DeleteExpired(_ context.Context, now time.Time) ([]string, error) {
+	return nil, nil
+}
+
+func (m *mockRepo) SaveClicks(_ context.Context, clicks []model.ClickEvent) error {
+	return nil
+}
+
+func (m *mockRepo) FindStatsByHash(_ context.Context, hashURL, userID string) (model.LinkStatsResponse, error) {
+	return model.LinkStatsResponse{}, nil
+}
+
+func (m *mockRepo) NextID(_ context.Context) (int64, error) {
+	return 1, nil
+}
+
+func (m *mockRepo) ExistsByHash(_ context.Context, hashURL string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockRepo) Ping(_ context.Context) (bool, error) {
 	return true, nil
 }
 
 // batchSaverMock — реализация интерфейса batchSaver для тестов.
 type batchSaverMock struct{}
 
-func (b *batchSaverMock) CreateWithBatch(batch []model.CreateShortRequestBatchItemRequest, userID string) ([]model.CreateShortRequestBatchItemResponse, error) {
+func (b *batchSaverMock) CreateWithBatch(_ context.Context, batch []model.CreateShortRequestBatchItemRequest, userID string) ([]model.CreateShortRequestBatchItemResponse, error) {
 	responses := make([]model.CreateShortRequestBatchItemResponse, 0, len(batch))
 	for _, item := range batch {
 		responses = append(responses, model.CreateShortRequestBatchItemResponse{
@@ -64,29 +97,32 @@ func (b *batchSaverMock) CreateWithBatch(batch []model.CreateShortRequestBatchIt
 // creatorMock — реализация интерфейса creator для тестов.
 type creatorMock struct{}
 
-func (c *creatorMock) Create(fullURL string, userID string) (string, error) {
+func (c *creatorMock) Create(_ context.Context, fullURL string, userID string) (string, error) {
 	return fmt.Sprintf("http://your-shortener.com/%s", fullURL[len(fullURL)-3:]), nil
 }
 
 // pingCheckerMock — реализация интерфейса PingChecker для тестов.
 type pingCheckerMock struct{}
 
-func (p *pingCheckerMock) Ping() (bool, error) {
+func (p *pingCheckerMock) Ping(_ context.Context) (bool, error) {
 	return true, nil
 }
 
 // deleterMock — реализация интерфейса deleter для тестов.
 type deleterMock struct{}
 
-func (d *deleterMock) DeleteAsync(ids []string, userID string) error {
+func (d *deleterMock) DeleteAsync(_ context.Context, ids []string, userID string) error {
 	return nil
 }
 
 // createTestHandler создаёт готовый Handler для тестирования.
 func createTestHandler() *Handler {
 	cfg := config.Create()
-	shortener := service.CreateShortener(inmemory.NewInMemoryRepository(cfg), cfg.BaseShortURL)
-	return CreateHandler(shortener, &pingCheckerMock{}, cfg)
+	repo := inmemory.NewInMemoryRepository(cfg)
+	generator, _ := shortcode.NewGenerator(cfg.ShortCodeStrategy, cfg.ShortCodeLength, repo)
+	deletions, _ := service.NewDeletionQueue(repo, cfg.DeleteQueueCapacity, cfg.DeleteBatchSize, cfg.DeleteFlushInterval, cfg.DeleteWorkers, "")
+	shortener := service.CreateShortener(repo, cfg.BaseShortURL, generator, nil, deletions, nil)
+	return CreateHandler(shortener, &pingCheckerMock{}, health.NewChecker(), service.NewClickCollector(repo, cfg.ClickBufferSize, cfg.ClickFlushInterval), repo, nil, nil, service.NewAPIKeys(repo), nil, cfg)
 }
 
 // ExampleCreateWithBatch демонстрирует использование эндпоинта /api/shorten/Batch.
@@ -101,6 +137,8 @@ func ExampleBatch_CreateLinkWithBatch() {
 	bodyBytes, _ := json.Marshal(requestBody)
 	req := httptest.NewRequest(http.MethodPost, "/api/shorten/Batch", bytes.NewBuffer(bodyBytes))
 	req.Header.Set("Content-Type", "application/json")
+	// Идентификатор пользователя в контекст запроса записывает middleware аутентификации (auth.Middleware).
+	req = req.WithContext(auth.WithUserID(req.Context(), "testuser123"))
 
 	rec := httptest.NewRecorder()
 	h.Batch.CreateLinkWithBatch(rec, req)
@@ -125,6 +163,7 @@ func ExampleCreateWithJSON() {
 	bodyBytes, _ := json.Marshal(requestBody)
 	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(bodyBytes))
 	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(auth.WithUserID(req.Context(), "testuser123"))
 
 	rec := httptest.NewRecorder()
 	h.CreateWithJSON.CreateLinkWithJSON(rec, req)
@@ -144,6 +183,7 @@ func ExampleCreate() {
 
 	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("http://example.com"))
 	req.Header.Set("Content-Type", "text/plain")
+	req = req.WithContext(auth.WithUserID(req.Context(), "testuser123"))
 
 	rec := httptest.NewRecorder()
 	h.Create.CreateLink(rec, req)
@@ -1,13 +1,13 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/faust8888/shortener/internal/app/config"
 	"github.com/faust8888/shortener/internal/app/model"
-	"github.com/faust8888/shortener/internal/app/repository/postgres"
-	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
 	"github.com/go-chi/chi/v5"
 	"net/http"
 )
@@ -21,12 +21,13 @@ const LocationHeader = "Location"
 // - получение всех ссылок пользователя.
 type Find struct {
 	service finder
-	authKey string
+	clicks  ClickRecorder
+	authKey string // Ключ хэширования IP-адресов в событиях переходов
 }
 
 type finder interface {
-	FindByHash(hashURL string) (string, error)
-	FindAllByUserID(userID string) ([]model.FindURLByUserIDResponse, error)
+	FindByHash(ctx context.Context, hashURL string) (string, error)
+	FindAllByUserID(ctx context.Context, userID string) ([]model.FindURLByUserIDResponse, error)
 }
 
 // FindLinkByHash обрабатывает GET-запрос на редирект по короткой ссылке.
@@ -35,32 +36,37 @@ type finder interface {
 // - Извлекает хэш из пути запроса.
 // - Передаёт хэш сервису для поиска оригинального URL.
 // - Возвращает редирект или соответствующую ошибку.
+// - После успешного редиректа асинхронно записывает событие перехода для статистики.
 //
 // Путь: /{hash}
 //
 // Возможные HTTP-статусы:
 // - 307 Temporary Redirect — успешный редирект.
 // - 404 Not Found — ссылка не найдена.
-// - 410 Gone — ссылка была удалена.
+// - 410 Gone — ссылка была удалена, отключена администратором или истёк срок её жизни.
 func (handler *Find) FindLinkByHash(res http.ResponseWriter, req *http.Request) {
 	searchedHashURL := chi.URLParam(req, config.HashKeyURLQueryParam)
-	fullURL, err := handler.service.FindByHash(searchedHashURL)
-	if errors.Is(err, postgres.ErrRecordWasMarkedAsDeleted) {
+	fullURL, err := handler.service.FindByHash(req.Context(), searchedHashURL)
+	if errors.Is(err, repository.ErrRecordDeleted) || errors.Is(err, repository.ErrRecordExpired) || errors.Is(err, repository.ErrRecordDisabled) {
+		metrics.RecordRedirect(metrics.RedirectGone)
 		res.WriteHeader(http.StatusGone)
 		return
 	}
 	if err != nil {
+		metrics.RecordRedirect(metrics.RedirectMiss)
 		http.Error(res, err.Error(), http.StatusNotFound)
+		return
 	}
+	metrics.RecordRedirect(metrics.RedirectHit)
 	res.Header().Set(LocationHeader, fullURL)
 	res.WriteHeader(http.StatusTemporaryRedirect)
+	handler.clicks.Record(newClickEvent(req, searchedHashURL, handler.authKey))
 }
 
 // FindLinkByUserID обрабатывает GET-запрос для получения всех сокращённых ссылок текущего пользователя.
 //
 // Метод:
-// - Проверяет или генерирует токен авторизации.
-// - Извлекает идентификатор пользователя из токена.
+// - Получает идентификатор пользователя из контекста запроса (см. auth.Middleware).
 // - Передаёт запрос сервису для получения списка ссылок.
 // - Возвращает JSON-ответ со списком ссылок.
 //
@@ -77,24 +83,11 @@ func (handler *Find) FindLinkByHash(res http.ResponseWriter, req *http.Request)
 // - 401 Unauthorized — отсутствующий или недействительный токен.
 // - 500 Internal Server Error — внутренняя ошибка сервера.
 func (handler *Find) FindLinkByUserID(res http.ResponseWriter, req *http.Request) {
-	token := security.GetToken(req)
-	userID, err := security.GetUserID(token, handler.authKey)
-	if token == "" {
-		token, err = security.BuildToken(handler.authKey)
-		if err != nil {
-			http.Error(res, fmt.Sprintf("build token: %s", err.Error()), http.StatusInternalServerError)
-			return
-		}
-		http.SetCookie(res, &http.Cookie{
-			Name:  security.AuthorizationTokenName,
-			Value: token,
-		})
-	}
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusUnauthorized)
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
 		return
 	}
-	fullURL, err := handler.service.FindAllByUserID(userID)
+	fullURL, err := handler.service.FindAllByUserID(req.Context(), userID)
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
 		return
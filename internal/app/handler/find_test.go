@@ -2,11 +2,18 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/config"
 	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/security"
 	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestFindByHash(t *testing.T) {
@@ -50,6 +57,32 @@ func TestFindByHash(t *testing.T) {
 	}
 }
 
+func TestFindByHashExpired(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+
+	targetFullURL := fmt.Sprintf("https://yandex.ru/expiring/%d", time.Now().UnixNano())
+	expiresAt := time.Now().Add(200 * time.Millisecond).UTC().Format(time.RFC3339Nano)
+	body := fmt.Sprintf(`{"url":"%s","expires_at":"%s"}`, targetFullURL, expiresAt)
+	shortURLResponse, err := createShortURLRequest(server.URL+"/api/shorten", body).Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, shortURLResponse.StatusCode())
+
+	var createResponse model.CreateShortResponse
+	require.NoError(t, json.Unmarshal(shortURLResponse.Body(), &createResponse))
+
+	getFullURLRequest := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R()
+	getFullURLRequest.Method = http.MethodGet
+	getFullURLRequest.URL = server.URL + extractHashKeyURLFrom(createResponse.Result)
+
+	getFullURLResponse, _ := getFullURLRequest.Send()
+	assert.Equal(t, http.StatusTemporaryRedirect, getFullURLResponse.StatusCode())
+
+	time.Sleep(300 * time.Millisecond)
+	getFullURLResponse, _ = getFullURLRequest.Send()
+	assert.Equal(t, http.StatusGone, getFullURLResponse.StatusCode())
+}
+
 func TestFindByUserID(t *testing.T) {
 	server := startTestServer(t)
 	defer server.Close()
@@ -90,6 +123,34 @@ func TestFindByUserID(t *testing.T) {
 	}
 }
 
+func TestFindByUserIDSharedURL(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+
+	targetFullURL := fmt.Sprintf("https://yandex.ru/shared/%d", time.Now().UnixNano())
+	firstResponse, err := createShortURLRequest(server.URL, targetFullURL).Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, firstResponse.StatusCode())
+	firstCookie := &http.Cookie{Name: security.AuthorizationTokenName, Value: getTokenFromResponse(firstResponse)}
+
+	repeatedResponse, err := createShortURLRequest(server.URL, targetFullURL).SetCookie(firstCookie).Send()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, repeatedResponse.StatusCode(), "the same user must get a conflict")
+	assert.Equal(t, string(firstResponse.Body()), string(repeatedResponse.Body()))
+
+	secondResponse, err := createShortURLRequest(server.URL, targetFullURL).Send()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, secondResponse.StatusCode())
+	assert.NotEqual(t, string(firstResponse.Body()), string(secondResponse.Body()), "another user must get their own link")
+
+	userURLsResponse, err := resty.New().R().SetCookies(secondResponse.Cookies()).Get(server.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, userURLsResponse.StatusCode())
+	var userURLs []model.FindURLByUserIDResponse
+	require.NoError(t, json.Unmarshal(userURLsResponse.Body(), &userURLs))
+	assert.True(t, containsShortURL(userURLs, string(secondResponse.Body()), targetFullURL))
+}
+
 func TestGetWithCompress(t *testing.T) {
 	server := startTestServer(t)
 	defer server.Close()
@@ -137,3 +198,96 @@ func containsShortURL(slice []model.FindURLByUserIDResponse, shortURL, fullURL s
 	}
 	return false
 }
+
+func TestFindByHashDeleted(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+
+	targetFullURL := fmt.Sprintf("https://yandex.ru/deleted/%d", time.Now().UnixNano())
+	shortURLResponse, err := createShortURLRequest(server.URL, targetFullURL).Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, shortURLResponse.StatusCode())
+	hashKeyURL := extractHashKeyURLFrom(string(shortURLResponse.Body()))
+	authCookie := &http.Cookie{Name: security.AuthorizationTokenName, Value: getTokenFromResponse(shortURLResponse)}
+
+	otherToken, err := security.BuildToken(config.Create().AuthKey)
+	require.NoError(t, err)
+	deleteResponse, err := resty.New().R().
+		SetCookie(&http.Cookie{Name: security.AuthorizationTokenName, Value: otherToken}).
+		SetBody(fmt.Sprintf(`["%s"]`, strings.TrimPrefix(hashKeyURL, "/"))).
+		Delete(server.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, deleteResponse.StatusCode())
+
+	getFullURLRequest := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R()
+	getFullURLRequest.Method = http.MethodGet
+	getFullURLRequest.URL = server.URL + hashKeyURL
+
+	time.Sleep(50 * time.Millisecond)
+	getFullURLResponse, _ := getFullURLRequest.Send()
+	assert.Equal(t, http.StatusTemporaryRedirect, getFullURLResponse.StatusCode(), "link of another user must not be deleted")
+
+	deleteResponse, err = resty.New().R().
+		SetCookie(authCookie).
+		SetBody(fmt.Sprintf(`["%s"]`, strings.TrimPrefix(hashKeyURL, "/"))).
+		Delete(server.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, deleteResponse.StatusCode())
+
+	assert.Eventually(t, func() bool {
+		getFullURLResponse, _ = getFullURLRequest.Send()
+		return getFullURLResponse.StatusCode() == http.StatusGone
+	}, time.Second, 20*time.Millisecond)
+}
+
+func TestFindByUserIDRefreshesToken(t *testing.T) {
+	server := startTestServer(t)
+	defer server.Close()
+	cfg := config.Create()
+
+	targetFullURL := fmt.Sprintf("https://yandex.ru/refresh/%d", time.Now().UnixNano())
+	createResponse, err := createShortURLRequest(server.URL, targetFullURL).Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, createResponse.StatusCode())
+	var cookie *http.Cookie
+	for _, c := range createResponse.Cookies() {
+		if c.Name == security.AuthorizationTokenName {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie)
+	assert.Equal(t, cfg.AuthCookiePath, cookie.Path)
+	assert.Equal(t, int(cfg.AuthTokenTTL.Seconds()), cookie.MaxAge)
+	assert.Equal(t, cfg.AuthCookieHTTPOnly, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+	userID, err := security.GetUserID(cookie.Value, cfg.AuthKey)
+	require.NoError(t, err)
+
+	// Токен того же пользователя, который скоро истечёт, перевыпускается с тем же идентификатором.
+	expiringToken, err := security.NewKeyring(cfg.AuthKey).Sign(security.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		UserID:           userID,
+	})
+	require.NoError(t, err)
+	userURLsResponse, err := resty.New().R().
+		SetCookie(&http.Cookie{Name: security.AuthorizationTokenName, Value: expiringToken}).
+		Get(server.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, userURLsResponse.StatusCode())
+	refreshedToken := getTokenFromResponse(userURLsResponse)
+	require.NotEmpty(t, refreshedToken, "the expiring token must be refreshed")
+	refreshedUserID, err := security.GetUserID(refreshedToken, cfg.AuthKey)
+	require.NoError(t, err)
+	assert.Equal(t, userID, refreshedUserID)
+
+	// Действующий токен не перевыпускается.
+	userURLsResponse, err = resty.New().R().
+		SetCookie(&http.Cookie{Name: security.AuthorizationTokenName, Value: refreshedToken}).
+		Get(server.URL + "/api/user/urls")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, userURLsResponse.StatusCode())
+	assert.Empty(t, getTokenFromResponse(userURLsResponse))
+	var userURLs []model.FindURLByUserIDResponse
+	require.NoError(t, json.Unmarshal(userURLsResponse.Body(), &userURLs))
+	assert.True(t, containsShortURL(userURLs, string(createResponse.Body()), targetFullURL))
+}
@@ -1,8 +1,15 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/security"
 	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/app/urlcheck"
+	"github.com/faust8888/shortener/internal/middleware/auth"
+	"net/http"
 )
 
 // Handler — это объединяющая структура, содержащая все HTTP-обработчики приложения.
@@ -10,15 +17,22 @@ import (
 // - создание коротких ссылок (plain text и JSON),
 // - пакетное создание,
 // - поиск по хэшу и по пользователю,
+// - статистика переходов по ссылке,
 // - удаление,
-// - проверка состояния сервиса (Ping).
+// - проверка состояния, живости и готовности сервиса (Ping),
+// - ключи API пользователя (APIKeys),
+// - служебное API (Admin).
 type Handler struct {
 	Create
 	CreateWithJSON
 	Batch
 	Find
+	Stats
 	Ping
 	Delete
+	APIKeys
+	Keys
+	Admin
 }
 
 // CreateHandler инициализирует и возвращает новый экземпляр Handler с заданными зависимостями.
@@ -26,17 +40,91 @@ type Handler struct {
 // Параметры:
 //   - s: указатель на сервис типа *service.Shortener, реализующий бизнес-логику.
 //   - pingChecker: реализация интерфейса PingChecker для проверки состояния БД.
-//   - cfg: конфигурация приложения, включающая, например, ключ аутентификации.
+//   - healthChecker: проверки живости и готовности сервиса (/healthz, /readyz).
+//   - clicks: реализация интерфейса ClickRecorder для записи переходов по ссылкам.
+//   - recovery: хранилище, восстанавливаемое из бэкапа (nil, если бэкап не используется).
+//   - moderator: сервис модерации ссылок для служебного API.
+//   - auditLog: журнал изменяющих операций для служебного API (nil, если журнал недоступен).
+//   - apiKeys: сервис ключей API пользователей.
+//   - keys: источник открытых ключей подписи токенов для /.well-known/jwks.json.
+//   - cfg: конфигурация приложения, включающая, например, ключ хэширования IP-адресов.
 //
 // Возвращает:
 //   - *Handler: готовый к использованию объект обработчика HTTP-запросов.
-func CreateHandler(s *service.Shortener, pingChecker PingChecker, cfg *config.Config) *Handler {
+func CreateHandler(s *service.Shortener, pingChecker PingChecker, healthChecker HealthChecker, clicks ClickRecorder, recovery RecoveryReporter, moderator LinkModerator, auditLog AuditLog, apiKeys APIKeyManager, keys KeyPublisher, cfg *config.Config) *Handler {
 	return &Handler{
-		Create:         Create{service: s, authKey: cfg.AuthKey},
-		CreateWithJSON: CreateWithJSON{service: s, authKey: cfg.AuthKey},
-		Batch:          Batch{service: s, authKey: cfg.AuthKey},
-		Find:           Find{service: s, authKey: cfg.AuthKey},
-		Ping:           Ping{pingChecker},
-		Delete:         Delete{service: s, authKey: cfg.AuthKey},
+		Create:         Create{service: s},
+		CreateWithJSON: CreateWithJSON{service: s},
+		Batch:          Batch{service: s},
+		Find:           Find{service: s, clicks: clicks, authKey: cfg.AuthKey},
+		Stats:          Stats{service: s},
+		Ping:           Ping{service: pingChecker, health: healthChecker},
+		Delete:         Delete{service: s},
+		APIKeys:        APIKeys{service: apiKeys},
+		Keys:           Keys{publisher: keys},
+		Admin:          Admin{recovery: recovery, deletions: s, moderator: moderator, auditLog: auditLog},
 	}
 }
+
+// userIDFromRequest возвращает идентификатор пользователя, сохранённый в контексте запроса
+// middleware аутентификации (см. auth.Middleware). Если запрос не прошёл через middleware,
+// в res записывается 401 Unauthorized.
+//
+// Возвращает:
+//   - string: идентификатор пользователя.
+//   - bool: false, если ответ с ошибкой уже записан.
+func userIDFromRequest(res http.ResponseWriter, req *http.Request) (string, bool) {
+	userID, ok := auth.UserIDFromContext(req.Context())
+	if !ok {
+		http.Error(res, security.ErrNoAuthorizationToken.Error(), http.StatusUnauthorized)
+		return "", false
+	}
+	return userID, true
+}
+
+// writeURLRejection отвечает 422 Unprocessable Entity с причиной в JSON, если err — отклонение URL
+// проверками безопасности (*urlcheck.Error).
+//
+// Пример ответа:
+//
+//	{"code": "scheme_not_allowed", "message": "scheme \"javascript\" is not allowed", "url": "javascript:alert(1)"}
+//
+// Возвращает:
+//   - bool: true, если ответ записан.
+func writeURLRejection(res http.ResponseWriter, err error) bool {
+	var rejected *urlcheck.Error
+	if !errors.As(err, &rejected) {
+		return false
+	}
+	resp, err := json.Marshal(rejected.Response())
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusUnprocessableEntity)
+	_, _ = res.Write(resp)
+	return true
+}
+
+// isInvalidInput сообщает, что err — ошибка валидации данных запроса (URL, алиаса или срока жизни),
+// на которую следует отвечать 400 Bad Request, а не 500 Internal Server Error.
+func isInvalidInput(err error) bool {
+	return errors.Is(err, security.ErrInvalidURL) ||
+		errors.Is(err, model.ErrInvalidAlias) ||
+		errors.Is(err, model.ErrReservedAlias) ||
+		errors.Is(err, model.ErrInvalidExpiration)
+}
+
+// writeJSON записывает ответ в формате JSON с заданным статусом.
+// Если v не удалось сериализовать, отвечает 500 Internal Server Error.
+func writeJSON(res http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+	_, _ = res.Write(resp)
+}
@@ -1,18 +1,26 @@
 package handler
 
 import (
+	"context"
 	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/health"
 	"github.com/faust8888/shortener/internal/app/mocks"
 	"github.com/faust8888/shortener/internal/app/repository/inmemory"
 	"github.com/faust8888/shortener/internal/app/route"
 	"github.com/faust8888/shortener/internal/app/security"
 	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/app/shortcode"
+	"github.com/faust8888/shortener/internal/app/urlcheck"
+	"github.com/faust8888/shortener/internal/middleware/auth"
 	"github.com/go-resty/resty/v2"
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 )
 
 func startTestServer(t *testing.T) *httptest.Server {
@@ -21,10 +29,46 @@ func startTestServer(t *testing.T) *httptest.Server {
 	pingChecker := createPingCheckerMock(ctrl)
 
 	cfg := config.Create()
-	shortener := service.CreateShortener(inmemory.NewInMemoryRepository(cfg), cfg.BaseShortURL)
-	handler := CreateHandler(shortener, pingChecker, cfg)
+	repo := inmemory.NewInMemoryRepository(cfg)
+	generator, err := shortcode.NewGenerator(cfg.ShortCodeStrategy, cfg.ShortCodeLength, repo)
+	require.NoError(t, err)
+	deletions, err := service.NewDeletionQueue(repo, cfg.DeleteQueueCapacity, cfg.DeleteBatchSize, 10*time.Millisecond, cfg.DeleteWorkers, "")
+	require.NoError(t, err)
+	shortener := service.CreateShortener(repo, cfg.BaseShortURL, generator, urlcheck.NewValidator(cfg, nil), deletions, nil)
+	clicks := service.NewClickCollector(repo, cfg.ClickBufferSize, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		_ = clicks.Run(ctx)
+	}()
+	go func() {
+		_ = deletions.Run(ctx)
+	}()
+	apiKeys := service.NewAPIKeys(repo)
+	handler := CreateHandler(shortener, pingChecker, health.NewChecker(), clicks, repo, service.NewModerator(repo), nil, apiKeys, createTestAuthenticator(cfg), cfg)
 
-	return httptest.NewServer(route.Create(handler))
+	return httptest.NewServer(route.Create(handler, createTestUsers(cfg, apiKeys), cfg))
+}
+
+// createTestAuthenticator создаёт Authenticator с ключом и параметрами токенов и куки из конфигурации.
+func createTestAuthenticator(cfg *config.Config) *security.Authenticator {
+	authenticator, err := security.NewAuthenticator(security.NewKeyring(cfg.AuthKey, strings.Split(cfg.AuthPreviousKeys, ",")...), security.AuthOptions{
+		TokenTTL:       cfg.AuthTokenTTL,
+		RefreshWindow:  cfg.AuthTokenRefreshWindow,
+		CookieSecure:   cfg.AuthCookieSecure,
+		CookieHTTPOnly: cfg.AuthCookieHTTPOnly,
+		CookieSameSite: cfg.AuthCookieSameSite,
+		CookiePath:     cfg.AuthCookiePath,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return authenticator
+}
+
+// createTestUsers создаёт middleware аутентификации с Authenticator из createTestAuthenticator.
+func createTestUsers(cfg *config.Config, apiKeys auth.APIKeyVerifier) *auth.Middleware {
+	return auth.NewMiddleware(createTestAuthenticator(cfg), apiKeys)
 }
 
 func createShortURLRequest(url string, body interface{}, headers ...RequestHeader) *resty.Request {
@@ -45,7 +89,7 @@ func extractHashKeyURLFrom(shortURL string) string {
 
 func createPingCheckerMock(ctrl *gomock.Controller) *mocks.MockPingChecker {
 	pingChecker := mocks.NewMockPingChecker(ctrl)
-	pingChecker.EXPECT().Ping().AnyTimes().Return(true, nil)
+	pingChecker.EXPECT().Ping(gomock.Any()).AnyTimes().Return(true, nil)
 	return pingChecker
 }
 
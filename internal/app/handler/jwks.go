@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"github.com/faust8888/shortener/internal/app/security"
+	"net/http"
+)
+
+// Keys — это HTTP-обработчик открытых ключей подписи токенов (/.well-known/jwks.json).
+// По ним другие сервисы проверяют токены пользователей без общего секрета.
+type Keys struct {
+	publisher KeyPublisher
+}
+
+// KeyPublisher — интерфейс источника открытых ключей подписи токенов (см. security.Authenticator).
+type KeyPublisher interface {
+	JWKS() security.JWKS
+}
+
+// FindSigningKeys обрабатывает GET-запрос на получение открытых ключей подписи токенов
+// в формате JWKS (RFC 7517). Публикуются активный и прежние асимметричные ключи;
+// при подписи HS256 набор пуст. Ответ можно кэшировать на пять минут.
+//
+// Путь: /.well-known/jwks.json
+//
+// Пример ответа:
+//
+//	{"keys": [{"kty": "OKP", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+//	 "kid": "3f1c8e0a9b2d4c17", "alg": "EdDSA", "use": "sig"}]}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — набор ключей возвращён.
+func (handler *Keys) FindSigningKeys(res http.ResponseWriter, _ *http.Request) {
+	jwks := security.JWKS{Keys: []security.JWK{}}
+	if handler.publisher != nil {
+		jwks = handler.publisher.JWKS()
+	}
+	res.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(res, http.StatusOK, jwks)
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/route"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindSigningKeys(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	keyFile := filepath.Join(t.TempDir(), "ed25519.pem")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	keys, err := security.NewKeyringWithOptions(security.KeyringOptions{Algorithm: security.AlgorithmEdDSA, Secret: "key", PrivateKeyFile: keyFile})
+	require.NoError(t, err)
+	authenticator, err := security.NewAuthenticator(keys, security.AuthOptions{TokenTTL: time.Hour, CookieSameSite: security.SameSiteLax})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		publisher KeyPublisher
+		wantKeys  int
+	}{
+		{name: "Asymmetric key is published", publisher: authenticator, wantKeys: 1},
+		{name: "HMAC secrets are not published", publisher: createTestAuthenticator(config.Create())},
+		{name: "No publisher"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(route.Create(&Handler{Keys: Keys{publisher: test.publisher}}, createTestUsers(config.Create(), nil), config.Create()))
+			defer server.Close()
+
+			resp, err := resty.New().R().Get(server.URL + "/.well-known/jwks.json")
+
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, resp.StatusCode())
+			assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+			assert.NotEmpty(t, resp.Header().Get("Cache-Control"))
+			var jwks security.JWKS
+			require.NoError(t, json.Unmarshal(resp.Body(), &jwks))
+			require.NotNil(t, jwks.Keys, "keys must be an array even when empty")
+			require.Len(t, jwks.Keys, test.wantKeys)
+			if test.wantKeys > 0 {
+				assert.Equal(t, "OKP", jwks.Keys[0].KeyType)
+				assert.Equal(t, "Ed25519", jwks.Keys[0].Curve)
+				assert.Equal(t, security.AlgorithmEdDSA, jwks.Keys[0].Algorithm)
+				assert.NotEmpty(t, jwks.Keys[0].X)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"fmt"
+	"github.com/faust8888/shortener/internal/middleware/admin"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	server := startAdminTestServer(t, "secret", false)
+	defer server.Close()
+
+	targetFullURL := fmt.Sprintf("https://yandex.ru/metrics/%d", time.Now().UnixNano())
+	shortURLResponse, err := createShortURLRequest(server.URL, targetFullURL).Send()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, shortURLResponse.StatusCode())
+	hashKeyURL := extractHashKeyURLFrom(string(shortURLResponse.Body()))
+
+	// С отключёнными редиректами resty возвращает ошибку, поэтому проверяется только статус.
+	redirectResponse, _ := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R().Get(server.URL + hashKeyURL)
+	require.Equal(t, http.StatusTemporaryRedirect, redirectResponse.StatusCode())
+	missResponse, _ := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R().Get(server.URL + "/not-existing-hash")
+	require.Equal(t, http.StatusNotFound, missResponse.StatusCode())
+
+	// Метрики доступны только с административным токеном.
+	metricsResponse, err := resty.New().R().Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, metricsResponse.StatusCode())
+	metricsResponse, err = resty.New().R().SetHeader(admin.TokenHeader, "secret").Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, metricsResponse.StatusCode())
+	body := string(metricsResponse.Body())
+	assert.Contains(t, body, `shortener_http_requests_total{code="307",method="GET",route="/{hashKeyURL}"}`)
+	assert.Contains(t, body, `shortener_http_request_duration_seconds_bucket{method="POST",route="/",le="+Inf"}`)
+	assert.Contains(t, body, `shortener_redirects_total{result="hit"}`)
+	assert.Contains(t, body, `shortener_redirects_total{result="miss"}`)
+	assert.Contains(t, body, `shortener_redirects_total{result="gone"}`)
+	assert.Contains(t, body, `shortener_storage_operation_duration_seconds_count{backend="inmemory",operation="FindByHash"}`)
+	assert.NotContains(t, body, "not-existing-hash", "request paths must not be used as label values")
+}
@@ -1,6 +1,10 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/faust8888/shortener/internal/app/health"
+	"github.com/faust8888/shortener/internal/app/model"
 	"net/http"
 )
 
@@ -8,12 +12,19 @@ import (
 // Используется для мониторинга доступности приложения и его зависимостей (например, БД).
 type Ping struct {
 	service PingChecker
+	health  HealthChecker
 }
 
 // PingChecker — интерфейс, определяющий метод для проверки работоспособности сервиса.
 // Реализация должна возвращать true и nil, если сервис доступен.
 type PingChecker interface {
-	Ping() (bool, error)
+	Ping(ctx context.Context) (bool, error)
+}
+
+// HealthChecker — интерфейс проверок живости и готовности сервиса (см. health.Checker).
+type HealthChecker interface {
+	Liveness() model.HealthReport
+	Readiness(ctx context.Context) model.HealthReport
 }
 
 // PingDatabase обрабатывает GET-запрос на эндпоинт /PingDatabase и проверяет доступность сервиса.
@@ -29,9 +40,58 @@ type PingChecker interface {
 // - 200 OK — сервис доступен.
 // - 500 Internal Server Error — внутренняя ошибка или недоступная зависимость.
 func (handler *Ping) PingDatabase(res http.ResponseWriter, req *http.Request) {
-	_, err := handler.service.Ping()
+	_, err := handler.service.Ping(req.Context())
 	if err != nil {
 		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
 	}
 	res.WriteHeader(http.StatusOK)
 }
+
+// CheckLiveness обрабатывает GET-запрос на проверку живости процесса.
+//
+// Путь: /healthz
+//
+// Пример ответа:
+//
+//	{"status": "ok"}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — процесс запущен.
+func (handler *Ping) CheckLiveness(res http.ResponseWriter, _ *http.Request) {
+	writeHealthReport(res, handler.health.Liveness())
+}
+
+// CheckReadiness обрабатывает GET-запрос на проверку готовности сервиса к обработке запросов.
+//
+// Путь: /readyz
+//
+// Пример ответа:
+//
+//	{"status": "failing", "checks": {"repository": {"status": "ok", "latency_ms": 0.42},
+//	 "deletion_queue": {"status": "failing", "latency_ms": 0.01, "error": "deletion queue is 95% full"}}}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — все проверки пройдены.
+// - 503 Service Unavailable — хотя бы одна проверка не пройдена или сервис останавливается.
+func (handler *Ping) CheckReadiness(res http.ResponseWriter, req *http.Request) {
+	writeHealthReport(res, handler.health.Readiness(req.Context()))
+}
+
+// writeHealthReport записывает отчёт в ответ: 200 OK для положительного отчёта, иначе 503.
+func writeHealthReport(res http.ResponseWriter, report model.HealthReport) {
+	resp, err := json.Marshal(report)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	code := http.StatusOK
+	if report.Status != health.StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(code)
+	_, _ = res.Write(resp)
+}
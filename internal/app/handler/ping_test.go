@@ -1,10 +1,19 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/health"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/route"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -39,3 +48,81 @@ func TestPing(t *testing.T) {
 		})
 	}
 }
+
+// failingPingChecker — реализация PingChecker с недоступным хранилищем.
+type failingPingChecker struct{}
+
+func (p failingPingChecker) Ping(_ context.Context) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestPingFailure(t *testing.T) {
+	res := httptest.NewRecorder()
+	handler := &Ping{service: failingPingChecker{}}
+
+	handler.PingDatabase(res, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, res.Code)
+	assert.Contains(t, res.Body.String(), "connection refused")
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		check      health.Check
+		shutdown   bool
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "Alive",
+			path:       "/healthz",
+			check:      func(context.Context) error { return errors.New("unreachable") },
+			wantCode:   http.StatusOK,
+			wantStatus: health.StatusOK,
+		},
+		{
+			name:       "Ready",
+			path:       "/readyz",
+			check:      func(context.Context) error { return nil },
+			wantCode:   http.StatusOK,
+			wantStatus: health.StatusOK,
+		},
+		{
+			name:       "Not ready when a dependency fails",
+			path:       "/readyz",
+			check:      func(context.Context) error { return errors.New("unreachable") },
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: health.StatusFailing,
+		},
+		{
+			name:       "Not ready while shutting down",
+			path:       "/readyz",
+			check:      func(context.Context) error { return nil },
+			shutdown:   true,
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: health.StatusShuttingDown,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker := health.NewChecker()
+			checker.Add("repository", test.check)
+			if test.shutdown {
+				checker.Shutdown()
+			}
+			server := httptest.NewServer(route.Create(&Handler{Ping: Ping{health: checker}}, createTestUsers(config.Create(), nil), config.Create()))
+			defer server.Close()
+
+			resp, err := resty.New().R().Get(server.URL + test.path)
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCode, resp.StatusCode())
+			assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+			var report model.HealthReport
+			require.NoError(t, json.Unmarshal(resp.Body(), &report))
+			assert.Equal(t, test.wantStatus, report.Status)
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/go-chi/chi/v5"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Stats — это HTTP-обработчик для получения статистики переходов по короткой ссылке.
+// Статистика доступна только владельцу ссылки.
+type Stats struct {
+	service statsFinder
+}
+
+type statsFinder interface {
+	FindStats(ctx context.Context, hashURL, userID string) (model.LinkStatsResponse, error)
+}
+
+// ClickRecorder — интерфейс асинхронной записи событий переходов по коротким ссылкам.
+// Реализация не должна блокировать обработчик редиректа.
+type ClickRecorder interface {
+	Record(event model.ClickEvent)
+}
+
+// FindLinkStats обрабатывает GET-запрос для получения статистики переходов по ссылке текущего пользователя.
+//
+// Путь: /api/user/urls/{hash}/stats
+//
+// Пример ответа:
+//
+//	{
+//	  "short_url": "http://localhost:8080/abc",
+//	  "total_clicks": 3,
+//	  "unique_visitors": 2,
+//	  "daily": [{"date": "2024-01-01", "clicks": 3, "unique_visitors": 2}],
+//	  "referrers": [{"value": "https://example.com/", "clicks": 2}],
+//	  "user_agents": [{"value": "curl/8.0", "clicks": 3}]
+//	}
+//
+// Возможные HTTP-статусы:
+// - 200 OK — статистика возвращена.
+// - 401 Unauthorized — отсутствующий или недействительный токен.
+// - 403 Forbidden — ссылка принадлежит другому пользователю.
+// - 404 Not Found — ссылка не найдена.
+// - 500 Internal Server Error — внутренняя ошибка сервера.
+func (handler *Stats) FindLinkStats(res http.ResponseWriter, req *http.Request) {
+	userID, ok := userIDFromRequest(res, req)
+	if !ok {
+		return
+	}
+	hashURL := chi.URLParam(req, config.HashKeyURLQueryParam)
+	stats, err := handler.service.FindStats(req.Context(), hashURL, userID)
+	if errors.Is(err, repository.ErrNotOwner) {
+		http.Error(res, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusNotFound)
+		return
+	}
+	resp, err := json.Marshal(&stats)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	_, err = res.Write(resp)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Ограничения длины источников перехода, сохраняемых в статистику.
+const (
+	// clickReferrerMaxLength — максимальная длина нормализованного referrer в байтах.
+	clickReferrerMaxLength = 512
+	// clickUserAgentMaxLength — максимальная длина user agent в байтах.
+	clickUserAgentMaxLength = 256
+)
+
+// newClickEvent формирует событие перехода по данным запроса.
+// IP-адрес клиента не сохраняется — в событие попадает только его хэш.
+// Referrer нормализуется (см. normalizeReferrer), а referrer и user agent обрезаются,
+// чтобы клиент не мог раздуть таблицы статистики произвольными заголовками.
+func newClickEvent(req *http.Request, hashURL string, authKey string) model.ClickEvent {
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	return model.ClickEvent{
+		HashURL:   hashURL,
+		Timestamp: time.Now(),
+		Referrer:  truncate(normalizeReferrer(req.Referer()), clickReferrerMaxLength),
+		UserAgent: truncate(strings.TrimSpace(req.UserAgent()), clickUserAgentMaxLength),
+		IPHash:    security.HashIP(ip, authKey),
+	}
+}
+
+// normalizeReferrer оставляет от referrer схему, хост и путь: учётные данные, параметры запроса
+// и фрагмент отбрасываются, так как могут содержать персональные данные и дробят статистику.
+// Значение, не являющееся абсолютным URL, заменяется пустой строкой.
+func normalizeReferrer(referrer string) string {
+	parsed, err := url.Parse(strings.TrimSpace(referrer))
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return ""
+	}
+	normalized := url.URL{Scheme: strings.ToLower(parsed.Scheme), Host: strings.ToLower(parsed.Host), Path: parsed.Path}
+	return normalized.String()
+}
+
+// truncate обрезает строку до maxLength байт, не разрывая символы UTF-8;
+// некорректные последовательности UTF-8 заменяются символом замены.
+func truncate(value string, maxLength int) string {
+	value = strings.ToValidUTF8(value, "\uFFFD")
+	if len(value) <= maxLength {
+		return value
+	}
+	cut := maxLength
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+	return value[:cut]
+}
@@ -0,0 +1,151 @@
+// Package health проверяет живость и готовность сервиса к обработке запросов.
+//
+// Живость (liveness) означает только то, что процесс запущен и отвечает. Готовность (readiness)
+// определяется набором проверок зависимостей: доступности хранилища, применённых миграций,
+// доступного на запись файла бэкапа, свободного места в очереди удаления. С начала остановки
+// сервиса готовность не проверяется и всегда отрицательна, чтобы балансировщик перестал
+// направлять на экземпляр новые запросы.
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/model"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Состояния проверок и итогового отчёта.
+const (
+	// StatusOK — проверка пройдена.
+	StatusOK = "ok"
+	// StatusFailing — проверка не пройдена.
+	StatusFailing = "failing"
+	// StatusShuttingDown — сервис останавливается и не принимает новые запросы.
+	StatusShuttingDown = "shutting_down"
+)
+
+// checkTimeout — максимальное время выполнения одной проверки.
+const checkTimeout = 2 * time.Second
+
+// ErrShuttingDown — ошибка проверки готовности после начала остановки сервиса.
+var ErrShuttingDown = errors.New("service is shutting down")
+
+// Check — проверка одной зависимости; возвращает nil, если зависимость готова.
+type Check func(ctx context.Context) error
+
+// namedCheck — проверка и её имя в отчёте.
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker — набор проверок готовности сервиса.
+type Checker struct {
+	mu     sync.RWMutex // Защищает checks
+	checks []namedCheck // Проверки в порядке добавления
+
+	shuttingDown atomic.Bool // true после начала остановки сервиса
+}
+
+// Add добавляет проверку готовности.
+//
+// Параметры:
+//   - name: имя проверки в отчёте, например "repository".
+//   - check: проверка.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Shutdown отмечает начало остановки сервиса: с этого момента Readiness возвращает StatusShuttingDown.
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Liveness возвращает отчёт о живости процесса; он всегда положителен.
+func (c *Checker) Liveness() model.HealthReport {
+	return model.HealthReport{Status: StatusOK}
+}
+
+// Readiness выполняет проверки параллельно, каждую не дольше checkTimeout.
+//
+// Параметр:
+//   - ctx: контекст запроса.
+//
+// Возвращает:
+//   - model.HealthReport: StatusOK, если все проверки пройдены; StatusFailing, если хотя бы одна
+//     не пройдена; StatusShuttingDown, если сервис останавливается (проверки при этом не выполняются).
+func (c *Checker) Readiness(ctx context.Context) model.HealthReport {
+	if c.shuttingDown.Load() {
+		return model.HealthReport{
+			Status: StatusShuttingDown,
+			Checks: map[string]model.HealthCheckResult{
+				"shutdown": {Status: StatusFailing, Error: ErrShuttingDown.Error()},
+			},
+		}
+	}
+	c.mu.RLock()
+	checks := c.checks
+	c.mu.RUnlock()
+
+	results := make([]model.HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check.check)
+		}()
+	}
+	wg.Wait()
+
+	report := model.HealthReport{Status: StatusOK, Checks: make(map[string]model.HealthCheckResult, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+// run выполняет проверку с ограничением по времени и измеряет её длительность.
+//
+// Проверка выполняется в отдельной горутине, поэтому проверка, игнорирующая ctx, не задерживает
+// отчёт дольше checkTimeout: по истечении времени она считается не пройденной, а её результат,
+// когда она всё же завершится, отбрасывается.
+func run(ctx context.Context, check Check) model.HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := model.HealthCheckResult{
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// NewChecker создаёт набор проверок без проверок; сервис без проверок считается готовым.
+//
+// Возвращает:
+//   - *Checker: пустой набор проверок.
+func NewChecker() *Checker {
+	return &Checker{}
+}
@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		checks     map[string]Check
+		shutdown   bool
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{
+			name:       "Ready without checks",
+			wantStatus: StatusOK,
+			wantChecks: map[string]string{},
+		},
+		{
+			name: "Ready when all checks pass",
+			checks: map[string]Check{
+				"repository": func(context.Context) error { return nil },
+				"backup":     func(context.Context) error { return nil },
+			},
+			wantStatus: StatusOK,
+			wantChecks: map[string]string{"repository": StatusOK, "backup": StatusOK},
+		},
+		{
+			name: "Failing when a check fails",
+			checks: map[string]Check{
+				"repository": func(context.Context) error { return nil },
+				"backup":     func(context.Context) error { return errors.New("disk is read-only") },
+			},
+			wantStatus: StatusFailing,
+			wantChecks: map[string]string{"repository": StatusOK, "backup": StatusFailing},
+		},
+		{
+			name: "Failing when a check hangs",
+			checks: map[string]Check{
+				"repository": func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			wantStatus: StatusFailing,
+			wantChecks: map[string]string{"repository": StatusFailing},
+		},
+		{
+			name: "Shutting down skips checks",
+			checks: map[string]Check{
+				"repository": func(context.Context) error {
+					t.Error("checks must not run after shutdown")
+					return nil
+				},
+			},
+			shutdown:   true,
+			wantStatus: StatusShuttingDown,
+			wantChecks: map[string]string{"shutdown": StatusFailing},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checker := NewChecker()
+			for name, check := range test.checks {
+				checker.Add(name, check)
+			}
+			if test.shutdown {
+				checker.Shutdown()
+			}
+
+			report := checker.Readiness(context.Background())
+
+			assert.Equal(t, test.wantStatus, report.Status)
+			require.Len(t, report.Checks, len(test.wantChecks))
+			for name, status := range test.wantChecks {
+				assert.Equal(t, status, report.Checks[name].Status, name)
+				if status == StatusFailing {
+					assert.NotEmpty(t, report.Checks[name].Error, name)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessIgnoringContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	checker := NewChecker()
+	// Проверка не следит за ctx и завершается только после окончания теста.
+	checker.Add("repository", func(context.Context) error {
+		<-release
+		return nil
+	})
+	checker.Add("backup", func(context.Context) error { return nil })
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	report := checker.Readiness(ctx)
+
+	assert.Less(t, time.Since(start), checkTimeout, "a check ignoring ctx must not block the report")
+	assert.Equal(t, StatusFailing, report.Status)
+	assert.Equal(t, StatusFailing, report.Checks["repository"].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["repository"].Error)
+	assert.Equal(t, StatusOK, report.Checks["backup"].Status)
+}
+
+func TestLiveness(t *testing.T) {
+	checker := NewChecker()
+	checker.Add("repository", func(context.Context) error { return errors.New("unreachable") })
+	checker.Shutdown()
+
+	assert.Equal(t, StatusOK, checker.Liveness().Status)
+}
@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -8,8 +9,14 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"os"
+	"strconv"
+	"strings"
 )
 
+// sourceDir — директория с SQL-файлами миграций.
+const sourceDir = "internal/app/migration/sql"
+
 // Run применяет SQL-миграции к указанной PostgreSQL базе данных.
 //
 // Метод:
@@ -27,6 +34,20 @@ import (
 //   - error: nil, если миграции успешно применены или уже были применены ранее,
 //     иначе — соответствующую ошибку.
 func Run(dataSourceName string) error {
+	return RunFrom(dataSourceName, sourceDir)
+}
+
+// RunFrom применяет SQL-миграции из директории dir; используется, когда рабочая директория
+// процесса не совпадает с корнем проекта (например, в тестах хранилища PostgreSQL).
+//
+// Параметры:
+//   - dataSourceName: строка подключения к PostgreSQL (DSN).
+//   - dir: директория с SQL-файлами миграций.
+//
+// Возвращает:
+//   - error: nil, если миграции успешно применены или уже были применены ранее,
+//     иначе — соответствующую ошибку.
+func RunFrom(dataSourceName, dir string) error {
 	db, err := sql.Open("pgx", dataSourceName)
 	if err != nil {
 		return fmt.Errorf("migration.run: oppening conection - %w", err)
@@ -39,7 +60,7 @@ func Run(dataSourceName string) error {
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
-		"file://internal/app/migration/sql",
+		"file://"+dir,
 		"postgres",
 		driver,
 	)
@@ -53,3 +74,57 @@ func Run(dataSourceName string) error {
 	logger.Log.Info("migration.run: all sql scripts applied successfully")
 	return nil
 }
+
+// Check проверяет, что к базе данных применены все миграции из директории sourceDir
+// и последняя из них не прервана (не отмечена как dirty).
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - db: пул подключений к базе данных.
+//
+// Возвращает:
+//   - error: nil, если схема актуальна, иначе — описание расхождения или ошибку запроса.
+func Check(ctx context.Context, db *sql.DB) error {
+	latest, err := latestVersion()
+	if err != nil {
+		return fmt.Errorf("migration.check: %w", err)
+	}
+	var version uint64
+	var dirty bool
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("migration.check: no migrations applied, expected version %d", latest)
+	}
+	if err != nil {
+		return fmt.Errorf("migration.check: reading schema version - %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration.check: migration %d is dirty", version)
+	}
+	if version < latest {
+		return fmt.Errorf("migration.check: schema version %d, expected %d", version, latest)
+	}
+	return nil
+}
+
+// latestVersion возвращает наибольшую версию миграции в директории sourceDir.
+// Версия — числовой префикс имени файла до "_", например 6 для 000006_user_links.up.sql.
+func latestVersion() (uint64, error) {
+	entries, err := os.ReadDir(sourceDir)
+	if err != nil {
+		return 0, fmt.Errorf("reading migrations - %w", err)
+	}
+	var latest uint64
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".up.sql") {
+			continue
+		}
+		prefix, _, _ := strings.Cut(entry.Name(), "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parsing migration version of %s - %w", entry.Name(), err)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
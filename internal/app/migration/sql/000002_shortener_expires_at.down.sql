@@ -0,0 +1,3 @@
+DROP INDEX shortener_expires_at_index;
+
+ALTER TABLE shortener DROP COLUMN expires_at;
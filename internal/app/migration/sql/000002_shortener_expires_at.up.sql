@@ -0,0 +1,3 @@
+ALTER TABLE shortener ADD COLUMN expires_at TIMESTAMPTZ;
+
+CREATE INDEX shortener_expires_at_index ON shortener (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP TABLE link_click_sources;
+
+DROP TABLE link_visitors_daily;
+
+DROP TABLE link_clicks_daily;
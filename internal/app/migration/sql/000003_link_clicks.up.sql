@@ -0,0 +1,21 @@
+CREATE TABLE link_clicks_daily (
+    short_url VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url, day)
+);
+
+CREATE TABLE link_visitors_daily (
+    short_url VARCHAR(255) NOT NULL,
+    day DATE NOT NULL,
+    ip_hash VARCHAR(64) NOT NULL,
+    PRIMARY KEY (short_url, day, ip_hash)
+);
+
+CREATE TABLE link_click_sources (
+    short_url VARCHAR(255) NOT NULL,
+    dimension VARCHAR(16) NOT NULL,
+    value TEXT NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (short_url, dimension, value)
+);
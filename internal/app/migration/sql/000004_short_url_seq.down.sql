@@ -0,0 +1 @@
+DROP SEQUENCE short_url_seq;
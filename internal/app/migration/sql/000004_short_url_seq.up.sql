@@ -0,0 +1 @@
+CREATE SEQUENCE short_url_seq;
@@ -0,0 +1 @@
+DROP INDEX unique_short_url_index;
@@ -0,0 +1,9 @@
+-- Ранее short_url не был уникальным: при коллизии кодов у разных full_url ссылка
+-- открывала произвольную из записей. Такие записи (кроме самой ранней) получают новый код.
+UPDATE shortener s
+SET short_url = s.short_url || '-' || s.id
+WHERE EXISTS (
+    SELECT 1 FROM shortener o WHERE o.short_url = s.short_url AND o.id < s.id
+);
+
+CREATE UNIQUE INDEX unique_short_url_index ON shortener (short_url);
@@ -0,0 +1,4 @@
+DROP TABLE user_links;
+
+-- Откат невозможен, если после миграции один URL был сокращён под несколькими кодами.
+CREATE UNIQUE INDEX unique_full_url_index ON shortener (full_url);
@@ -0,0 +1,19 @@
+-- Коды не делятся между пользователями: каждый пользователь получает свой код, а у одного
+-- пользователя для URL может быть несколько кодов (алиасы, ссылки со сроком жизни, новый код
+-- вместо удалённого или отключённого), поэтому full_url больше не уникален.
+-- Владельцы кодов хранятся в user_links; существующие владельцы переносятся из shortener.user_id.
+DROP INDEX unique_full_url_index;
+
+CREATE TABLE user_links (
+    user_id VARCHAR(255) NOT NULL,
+    short_url VARCHAR(255) NOT NULL REFERENCES shortener (short_url) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, short_url)
+);
+
+CREATE INDEX user_links_short_url_index ON user_links (short_url);
+
+INSERT INTO user_links (user_id, short_url, created_at)
+SELECT user_id, short_url, created_at
+FROM shortener
+WHERE user_id IS NOT NULL AND NOT is_deleted;
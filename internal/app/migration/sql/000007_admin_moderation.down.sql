@@ -0,0 +1,3 @@
+DROP TABLE admin_audit_log;
+
+ALTER TABLE shortener DROP COLUMN is_disabled;
@@ -0,0 +1,13 @@
+ALTER TABLE shortener ADD COLUMN is_disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE admin_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(32) NOT NULL,
+    actor VARCHAR(255) NOT NULL DEFAULT '',
+    target TEXT NOT NULL,
+    short_urls TEXT[] NOT NULL DEFAULT '{}',
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX admin_audit_log_created_at_index ON admin_audit_log (created_at);
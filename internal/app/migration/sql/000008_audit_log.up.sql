@@ -0,0 +1,13 @@
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    client_ip VARCHAR(64) NOT NULL DEFAULT '',
+    short_urls TEXT[] NOT NULL DEFAULT '{}',
+    original_urls TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX audit_log_user_id_created_at_index ON audit_log (user_id, created_at);
+CREATE INDEX audit_log_created_at_index ON audit_log (created_at);
@@ -0,0 +1,2 @@
+-- Откат невозможен, если после миграции были сохранены URL длиннее 255 символов.
+ALTER TABLE shortener ALTER COLUMN full_url TYPE VARCHAR(255);
@@ -0,0 +1,3 @@
+-- Длина оригинального URL ограничивается конфигурацией (URLMaxLength, по умолчанию 2048, 0 — без ограничения),
+-- поэтому колонка не должна обрезать более длинные URL.
+ALTER TABLE shortener ALTER COLUMN full_url TYPE TEXT;
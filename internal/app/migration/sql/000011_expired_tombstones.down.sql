@@ -0,0 +1,6 @@
+-- Надгробия истёкших ссылок удаляются: после отката очистка снова удаляет строки целиком.
+DELETE FROM shortener WHERE expires_at IS NOT NULL AND full_url = '';
+
+DROP INDEX shortener_expires_at_index;
+
+CREATE INDEX shortener_expires_at_index ON shortener (expires_at) WHERE expires_at IS NOT NULL;
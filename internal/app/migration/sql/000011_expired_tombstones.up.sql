@@ -0,0 +1,6 @@
+-- Очистка истёкших ссылок больше не удаляет строку: она остаётся надгробием без оригинального URL,
+-- чтобы код продолжал отвечать 410 Gone и не выдавался повторно. Индекс охватывает только
+-- ещё не очищенные ссылки, чтобы очистка не перебирала надгробия.
+DROP INDEX shortener_expires_at_index;
+
+CREATE INDEX shortener_expires_at_index ON shortener (expires_at) WHERE expires_at IS NOT NULL AND full_url <> '';
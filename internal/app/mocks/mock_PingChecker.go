@@ -5,6 +5,7 @@
 package mocks
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -34,16 +35,16 @@ func (m *MockPingChecker) EXPECT() *MockPingCheckerMockRecorder {
 }
 
 // Ping mocks base method.
-func (m *MockPingChecker) Ping() (bool, error) {
+func (m *MockPingChecker) Ping(arg0 context.Context) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PingDatabase")
+	ret := m.ctrl.Call(m, "PingDatabase", arg0)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Ping indicates an expected call of Ping.
-func (mr *MockPingCheckerMockRecorder) Ping() *gomock.Call {
+func (mr *MockPingCheckerMockRecorder) Ping(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingDatabase", reflect.TypeOf((*MockPingChecker)(nil).Ping))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PingDatabase", reflect.TypeOf((*MockPingChecker)(nil).Ping), arg0)
 }
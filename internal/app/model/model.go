@@ -1,16 +1,62 @@
 package model
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Ограничения на пользовательский алиас короткой ссылки.
+const (
+	// MinAliasLength — минимальная длина алиаса.
+	MinAliasLength = 3
+	// MaxAliasLength — максимальная длина алиаса.
+	MaxAliasLength = 64
+)
+
+// Ошибки валидации алиаса.
+var (
+	// ErrInvalidAlias — алиас содержит недопустимые символы или имеет недопустимую длину.
+	ErrInvalidAlias = fmt.Errorf("alias must be %d-%d characters long and contain only letters, digits, '-' or '_'",
+		MinAliasLength, MaxAliasLength)
+	// ErrReservedAlias — алиас совпадает с зарезервированным путём роутера.
+	ErrReservedAlias = errors.New("alias is reserved")
+	// ErrInvalidExpiration — срок жизни ссылки задан некорректно.
+	ErrInvalidExpiration = errors.New("invalid expiration")
+)
+
+// aliasPattern — допустимый набор символов алиаса (URL-safe, без кодирования).
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// reservedAliases — первые сегменты путей, занятые маршрутами из route.Create.
+// ".well-known" не проходит и проверку aliasPattern, но перечислен, чтобы список не зависел от неё.
+var reservedAliases = map[string]struct{}{
+	"api":         {},
+	"ping":        {},
+	"debug":       {},
+	"healthz":     {},
+	"readyz":      {},
+	"metrics":     {},
+	".well-known": {},
+}
 
 // CreateShortRequest — это модель запроса на создание короткой ссылки через JSON.
 //
 // Используется в хендлере `createWithJSON`.
 // Поле URL обязательно и должно быть корректным URL.
+// Поле Alias необязательно: если задано, используется вместо сгенерированного хэша.
+// Поля ExpiresAt и TTLSeconds необязательны и взаимоисключающие: они задают момент,
+// после которого ссылка перестаёт работать.
 type CreateShortRequest struct {
-	URL string `json:"url" validate:"required,url"`
+	URL        string     `json:"url" validate:"required,url"`
+	Alias      string     `json:"alias,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64      `json:"ttl_seconds,omitempty"`
 }
 
-// Validate проверяет, что поле URL не пустое.
+// Validate проверяет, что поле URL не пустое, а алиас и срок жизни (если заданы) корректны.
 //
 // Возвращает:
 //   - error: nil, если валидация успешна,
@@ -19,6 +65,79 @@ func (req *CreateShortRequest) Validate() error {
 	if req.URL == "" {
 		return errors.New("url is required")
 	}
+	if req.Alias != "" {
+		if err := ValidateAlias(req.Alias); err != nil {
+			return err
+		}
+	}
+	return ValidateExpiration(req.ExpiresAt, req.TTLSeconds, time.Now())
+}
+
+// Expiration возвращает момент истечения ссылки или nil, если ссылка бессрочная.
+//
+// Параметры:
+//   - now: текущее время, от которого отсчитывается TTLSeconds.
+func (req *CreateShortRequest) Expiration(now time.Time) *time.Time {
+	return expiration(req.ExpiresAt, req.TTLSeconds, now)
+}
+
+// ValidateAlias проверяет пользовательский алиас короткой ссылки.
+//
+// Алиас должен:
+// - иметь длину от MinAliasLength до MaxAliasLength символов,
+// - состоять только из латинских букв, цифр, '-' и '_',
+// - не совпадать с зарезервированными путями (api, ping, debug).
+//
+// Параметры:
+//   - alias: проверяемый алиас.
+//
+// Возвращает:
+//   - error: nil, если алиас допустим, иначе — ErrInvalidAlias или ErrReservedAlias.
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength || !aliasPattern.MatchString(alias) {
+		return ErrInvalidAlias
+	}
+	if _, reserved := reservedAliases[strings.ToLower(alias)]; reserved {
+		return fmt.Errorf("%w: %s", ErrReservedAlias, alias)
+	}
+	return nil
+}
+
+// ValidateExpiration проверяет параметры срока жизни ссылки.
+//
+// Допускается задать либо абсолютный момент истечения, либо TTL в секундах, но не оба сразу.
+// Момент истечения должен быть в будущем, TTL — положительным.
+//
+// Параметры:
+//   - expiresAt: абсолютный момент истечения (может быть nil).
+//   - ttlSeconds: время жизни в секундах (0 — не задано).
+//   - now: текущее время.
+//
+// Возвращает:
+//   - error: nil, если параметры корректны, иначе — ошибку, оборачивающую ErrInvalidExpiration.
+func ValidateExpiration(expiresAt *time.Time, ttlSeconds int64, now time.Time) error {
+	if expiresAt != nil && ttlSeconds != 0 {
+		return fmt.Errorf("%w: expires_at and ttl_seconds are mutually exclusive", ErrInvalidExpiration)
+	}
+	if ttlSeconds < 0 {
+		return fmt.Errorf("%w: ttl_seconds must be positive", ErrInvalidExpiration)
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidExpiration)
+	}
+	return nil
+}
+
+// expiration вычисляет момент истечения ссылки по провалидированным параметрам.
+func expiration(expiresAt *time.Time, ttlSeconds int64, now time.Time) *time.Time {
+	if expiresAt != nil {
+		t := expiresAt.UTC()
+		return &t
+	}
+	if ttlSeconds > 0 {
+		t := now.Add(time.Duration(ttlSeconds) * time.Second).UTC()
+		return &t
+	}
 	return nil
 }
 
@@ -33,10 +152,40 @@ type CreateShortResponse struct {
 //
 // Содержит:
 //   - CorrelationID: уникальный идентификатор элемента запроса,
-//   - OriginalURL: оригинальный URL, который нужно сократить.
+//   - OriginalURL: оригинальный URL, который нужно сократить,
+//   - Alias: необязательный пользовательский алиас,
+//   - ExpiresAt, TTLSeconds: необязательный срок жизни ссылки.
 type CreateShortRequestBatchItemRequest struct {
-	CorrelationID string `json:"correlation_id" validate:"required,correlation_id"`
-	OriginalURL   string `json:"original_url" validate:"required,original_url"`
+	CorrelationID string     `json:"correlation_id" validate:"required,correlation_id"`
+	OriginalURL   string     `json:"original_url" validate:"required,original_url"`
+	Alias         string     `json:"alias,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds    int64      `json:"ttl_seconds,omitempty"`
+}
+
+// Validate проверяет алиас и срок жизни элемента пакета, если они заданы.
+//
+// Возвращает:
+//   - error: nil, если валидация успешна,
+//     иначе — ошибку с описанием проблемы.
+func (item *CreateShortRequestBatchItemRequest) Validate() error {
+	if item.Alias != "" {
+		if err := ValidateAlias(item.Alias); err != nil {
+			return fmt.Errorf("correlation_id %s: %w", item.CorrelationID, err)
+		}
+	}
+	if err := ValidateExpiration(item.ExpiresAt, item.TTLSeconds, time.Now()); err != nil {
+		return fmt.Errorf("correlation_id %s: %w", item.CorrelationID, err)
+	}
+	return nil
+}
+
+// Expiration возвращает момент истечения ссылки или nil, если ссылка бессрочная.
+//
+// Параметры:
+//   - now: текущее время, от которого отсчитывается TTLSeconds.
+func (item *CreateShortRequestBatchItemRequest) Expiration(now time.Time) *time.Time {
+	return expiration(item.ExpiresAt, item.TTLSeconds, now)
 }
 
 // CreateShortRequestBatchItemResponse — элемент ответа при пакетном создании коротких ссылок.
@@ -66,9 +215,354 @@ type FindURLByUserIDResponse struct {
 // Поля:
 //   - OriginalURL: оригинальный URL.
 //   - ShortURL: сгенерированный короткий URL.
-//   - HashURL: хэш, использованный для генерации короткой ссылки.
+//   - HashURL: хэш, использованный для генерации короткой ссылки (или алиас).
+//   - Alias: пользовательский алиас; если задан, HashURL совпадает с ним.
+//   - ExpiresAt: момент истечения ссылки; nil — ссылка бессрочная.
 type CreateShortDTO struct {
 	OriginalURL string
 	ShortURL    string
 	HashURL     string
+	Alias       string
+	ExpiresAt   *time.Time
+}
+
+// ClickEvent — событие перехода по короткой ссылке, собираемое для аналитики.
+//
+// Поля:
+//   - HashURL: хэш-ключ короткой ссылки.
+//   - Timestamp: момент перехода.
+//   - Referrer: значение заголовка Referer (может быть пустым).
+//   - UserAgent: значение заголовка User-Agent (может быть пустым).
+//   - IPHash: хэш IP-адреса клиента; сам адрес не сохраняется.
+type ClickEvent struct {
+	HashURL   string
+	Timestamp time.Time
+	Referrer  string
+	UserAgent string
+	IPHash    string
+}
+
+// MaxClickSources — максимальное количество источников (referrer, user agent),
+// возвращаемых в статистике ссылки.
+const MaxClickSources = 10
+
+// ClickDateLayout — формат даты в дневной гистограмме переходов.
+const ClickDateLayout = "2006-01-02"
+
+// DailyClicksResponse — количество переходов по ссылке за один день (UTC).
+type DailyClicksResponse struct {
+	Date           string `json:"date"`
+	Clicks         int64  `json:"clicks"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// ClickSourceResponse — количество переходов с определённым значением источника (referrer или user agent).
+type ClickSourceResponse struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// LinkStatsResponse — модель ответа со статистикой переходов по короткой ссылке.
+//
+// Содержит:
+//   - ShortURL: короткий URL,
+//   - TotalClicks: общее количество переходов,
+//   - UniqueVisitors: количество уникальных посетителей (по хэшу IP),
+//   - Daily: гистограмма переходов по дням в порядке возрастания даты,
+//   - Referrers, UserAgents: переходы в разрезе источников в порядке убывания.
+type LinkStatsResponse struct {
+	ShortURL       string                `json:"short_url"`
+	TotalClicks    int64                 `json:"total_clicks"`
+	UniqueVisitors int64                 `json:"unique_visitors"`
+	Daily          []DailyClicksResponse `json:"daily"`
+	Referrers      []ClickSourceResponse `json:"referrers"`
+	UserAgents     []ClickSourceResponse `json:"user_agents"`
+}
+
+// BackupRecoverySummary — итоги восстановления InMemory-хранилища из файла бэкапа при запуске.
+//
+// Содержит:
+//   - SnapshotRecords, TailRecords: количество записей, прочитанных из снимка и журнала,
+//   - Restored: количество применённых записей,
+//   - Skipped: количество повреждённых записей, перенесённых в карантинный файл,
+//   - TruncatedBytes: размер отрезанной недописанной последней строки журнала,
+//   - QuarantineFile: путь к карантинному файлу (пустой, если повреждённых записей нет),
+//   - RecoveredAt, DurationMs: момент завершения и длительность восстановления.
+type BackupRecoverySummary struct {
+	SnapshotRecords int64     `json:"snapshot_records"`
+	TailRecords     int64     `json:"tail_records"`
+	Restored        int64     `json:"restored"`
+	Skipped         int64     `json:"skipped"`
+	TruncatedBytes  int64     `json:"truncated_bytes"`
+	QuarantineFile  string    `json:"quarantine_file,omitempty"`
+	RecoveredAt     time.Time `json:"recovered_at"`
+	DurationMs      int64     `json:"duration_ms"`
+}
+
+// DeletionRequest — запрос пользователя на удаление его коротких ссылок.
+//
+// Сериализуется в JSON при сохранении очереди удаления в файл.
+//
+// RequestID и ClientIP описывают запрос, поставивший удаление в очередь, и попадают
+// в журнал изменяющих операций, когда удаление выполнено.
+type DeletionRequest struct {
+	UserID    string   `json:"user_id"`
+	HashURLs  []string `json:"hash_urls"`
+	RequestID string   `json:"request_id,omitempty"`
+	ClientIP  string   `json:"client_ip,omitempty"`
+}
+
+// DeletionQueueStats — состояние очереди фонового удаления ссылок.
+//
+// Содержит:
+//   - Pending: количество кодов, ожидающих удаления,
+//   - InFlight: количество кодов, удаляемых в данный момент,
+//   - Capacity: максимальное количество кодов в очереди,
+//   - Deleted: количество кодов, обработанных с момента запуска,
+//   - Retries: количество повторных попыток удаления,
+//   - Failed: количество пакетов, не удалённых после всех попыток и возвращённых в очередь,
+//   - Dropped: количество запросов, отброшенных после нескольких неудачных возвратов в очередь,
+//   - Rejected: количество запросов, отклонённых из-за переполнения очереди.
+type DeletionQueueStats struct {
+	Pending  int64 `json:"pending"`
+	InFlight int64 `json:"in_flight"`
+	Capacity int64 `json:"capacity"`
+	Deleted  int64 `json:"deleted"`
+	Retries  int64 `json:"retries"`
+	Failed   int64 `json:"failed"`
+	Dropped  int64 `json:"dropped"`
+	Rejected int64 `json:"rejected"`
+}
+
+// CacheStats — статистика кэша коротких ссылок.
+//
+// Содержит:
+//   - Hits: количество запросов, обслуженных из кэша,
+//   - Misses: количество запросов, переданных хранилищу,
+//   - HitRatio: доля попаданий среди всех запросов (0, если запросов не было).
+type CacheStats struct {
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// HealthCheckResult — результат одной проверки готовности сервиса.
+//
+// Содержит:
+//   - Status: "ok" или "failing",
+//   - LatencyMs: длительность проверки в миллисекундах,
+//   - Error: текст ошибки (пустой для успешной проверки).
+type HealthCheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport — ответ эндпоинтов /healthz и /readyz.
+//
+// Содержит:
+//   - Status: итоговое состояние: "ok", "failing" или "shutting_down",
+//   - Checks: результаты проверок по их именам (пустой для /healthz).
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks,omitempty"`
+}
+
+// URLRejectionResponse — тело ответа 422 Unprocessable Entity на URL, не прошедший проверки безопасности.
+//
+// Содержит:
+//   - Code: код причины, например "scheme_not_allowed" или "private_address",
+//   - Message: описание причины,
+//   - URL: отклонённый URL,
+//   - CorrelationID: идентификатор элемента пакетного запроса (пустой для одиночного).
+type URLRejectionResponse struct {
+	Code          string `json:"code"`
+	Message       string `json:"message"`
+	URL           string `json:"url"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// AdminLinkFilter — условия поиска ссылок в служебном API.
+//
+// Содержит:
+//   - HashURL: точный код короткой ссылки,
+//   - URL: подстрока оригинального URL (без учёта регистра),
+//   - UserID: идентификатор владельца ссылки,
+//   - Limit: максимальное количество найденных ссылок.
+type AdminLinkFilter struct {
+	HashURL string
+	URL     string
+	UserID  string
+	Limit   int
+}
+
+// AdminLink — ссылка в результатах поиска служебного API.
+//
+// Содержит:
+//   - HashURL, ShortURL, OriginalURL: код, короткая и оригинальная ссылки,
+//   - Owners: идентификаторы пользователей, сокративших URL,
+//   - Deleted: ссылка удалена владельцами,
+//   - Disabled: ссылка отключена администратором,
+//   - ExpiresAt: момент истечения срока жизни (nil — бессрочная).
+type AdminLink struct {
+	HashURL     string     `json:"hash"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	Owners      []string   `json:"owners"`
+	Deleted     bool       `json:"deleted"`
+	Disabled    bool       `json:"disabled"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// AdminLinksRequest — запрос на отключение или включение ссылок.
+//
+// Пример запроса:
+//
+//	{"short_urls": ["abc", "def"], "reason": "phishing"}
+type AdminLinksRequest struct {
+	HashURLs []string `json:"short_urls"`
+	Reason   string   `json:"reason"`
+}
+
+// AdminTakedownRequest — запрос на отключение всех ссылок, ведущих на домен.
+//
+// Пример запроса:
+//
+//	{"domain": "example.com", "reason": "malware"}
+type AdminTakedownRequest struct {
+	Domain string `json:"domain"`
+	Reason string `json:"reason"`
+}
+
+// AdminAuditEntry — запись журнала действий администраторов.
+//
+// Содержит:
+//   - Action: действие: "disable", "enable" или "takedown_domain",
+//   - Actor: имя администратора из заголовка X-Admin-Actor (пустое, если не передано),
+//   - Target: объект действия: коды ссылок через запятую или домен,
+//   - HashURLs: коды ссылок, состояние которых изменилось,
+//   - Reason: причина, указанная администратором,
+//   - CreatedAt: момент действия.
+type AdminAuditEntry struct {
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor,omitempty"`
+	Target    string    `json:"target"`
+	HashURLs  []string  `json:"short_urls"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Типы событий журнала изменяющих операций (AuditEvent.Type).
+const (
+	// AuditLinkCreated — пользователь сократил URL.
+	AuditLinkCreated = "link.created"
+	// AuditLinksBatchCreated — пользователь сократил пакет URL.
+	AuditLinksBatchCreated = "link.batch_created"
+	// AuditDeleteRequested — пользователь запросил удаление ссылок.
+	AuditDeleteRequested = "link.delete_requested"
+	// AuditDeleteApplied — запрошенное удаление ссылок выполнено.
+	AuditDeleteApplied = "link.delete_applied"
+)
+
+// AuditEvent — событие журнала изменяющих операций.
+//
+// Содержит:
+//   - Type: тип события, например AuditLinkCreated,
+//   - UserID: идентификатор пользователя, выполнившего операцию,
+//   - RequestID: идентификатор запроса (заголовок X-Request-ID),
+//   - ClientIP: IP-адрес клиента,
+//   - HashURLs: коды затронутых ссылок,
+//   - OriginalURLs: оригинальные URL созданных ссылок в порядке HashURLs (пусто для удаления),
+//   - Timestamp: момент операции.
+type AuditEvent struct {
+	Type         string    `json:"type"`
+	UserID       string    `json:"user_id"`
+	RequestID    string    `json:"request_id,omitempty"`
+	ClientIP     string    `json:"client_ip,omitempty"`
+	HashURLs     []string  `json:"short_urls"`
+	OriginalURLs []string  `json:"original_urls,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// AuditFilter — условия поиска событий журнала изменяющих операций.
+//
+// Содержит:
+//   - UserID: идентификатор пользователя (пустой — все пользователи),
+//   - From: начало интервала включительно (нулевое — без ограничения),
+//   - To: конец интервала не включительно (нулевое — без ограничения),
+//   - Limit: максимальное количество событий.
+type AuditFilter struct {
+	UserID string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
+
+// Match проверяет, что событие удовлетворяет условиям фильтра (кроме Limit).
+func (f AuditFilter) Match(event AuditEvent) bool {
+	if f.UserID != "" && event.UserID != f.UserID {
+		return false
+	}
+	if !f.From.IsZero() && event.Timestamp.Before(f.From) {
+		return false
+	}
+	return f.To.IsZero() || event.Timestamp.Before(f.To)
+}
+
+// APIKey — долгоживущий ключ API пользователя. Сам ключ не хранится: по нему вычисляется Hash.
+//
+// Содержит:
+//   - ID: идентификатор ключа,
+//   - UserID: идентификатор владельца,
+//   - Name: название ключа, заданное пользователем,
+//   - Prefix: начало ключа, по которому пользователь отличает свои ключи,
+//   - Hash: отпечаток ключа (см. security.HashAPIKey),
+//   - CreatedAt: момент создания,
+//   - RevokedAt: момент отзыва (nil — ключ действует).
+type APIKey struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"hash"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest — запрос на создание ключа API.
+//
+// Пример запроса:
+//
+//	{"name": "ci"}
+type CreateAPIKeyRequest struct {
+	Name string `json:"name"`
+}
+
+// APIKeyResponse — описание ключа API в ответах; ни ключ, ни его отпечаток не возвращаются.
+//
+// Пример ответа:
+//
+//	{"id": "6f1c...", "name": "ci", "prefix": "shk_Xb3f9Q", "created_at": "2024-01-01T00:00:00Z"}
+type APIKeyResponse struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Response формирует описание ключа API для ответа.
+func (k APIKey) Response() APIKeyResponse {
+	return APIKeyResponse{ID: k.ID, Name: k.Name, Prefix: k.Prefix, CreatedAt: k.CreatedAt, RevokedAt: k.RevokedAt}
+}
+
+// CreateAPIKeyResponse — ответ на создание ключа API. Key возвращается только в этом ответе.
+//
+// Пример ответа:
+//
+//	{"id": "6f1c...", "name": "ci", "prefix": "shk_Xb3f9Q", "created_at": "2024-01-01T00:00:00Z",
+//	 "key": "shk_Xb3f9Q..."}
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
 }
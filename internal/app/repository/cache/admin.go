@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/model"
+)
+
+// ErrAdminUnsupported — ошибка операций служебного API, если хранилище их не поддерживает.
+var ErrAdminUnsupported = errors.New("repository doesn't support admin operations")
+
+// SearchLinks передаёт поиск ссылок хранилищу.
+func (r *Repository) SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.AdminLink, error) {
+	if r.admin == nil {
+		return nil, ErrAdminUnsupported
+	}
+	return r.admin.SearchLinks(ctx, filter)
+}
+
+// SetLinksDisabled отключает или включает ссылки в хранилище и сбрасывает их записи в кэше.
+func (r *Repository) SetLinksDisabled(ctx context.Context, hashURLs []string, disabled bool) ([]string, error) {
+	if r.admin == nil {
+		return nil, ErrAdminUnsupported
+	}
+	changed, err := r.admin.SetLinksDisabled(ctx, hashURLs, disabled)
+	r.invalidate(ctx, hashURLs...)
+	return changed, err
+}
+
+// DisableLinksByDomain отключает ссылки на домен в хранилище и сбрасывает записи отключённых ссылок в кэше.
+func (r *Repository) DisableLinksByDomain(ctx context.Context, domain string) ([]string, error) {
+	if r.admin == nil {
+		return nil, ErrAdminUnsupported
+	}
+	changed, err := r.admin.DisableLinksByDomain(ctx, domain)
+	r.invalidate(ctx, changed...)
+	return changed, err
+}
+
+// SaveAuditEntry передаёт запись журнала действий администраторов хранилищу.
+func (r *Repository) SaveAuditEntry(ctx context.Context, entry model.AdminAuditEntry) error {
+	if r.admin == nil {
+		return ErrAdminUnsupported
+	}
+	return r.admin.SaveAuditEntry(ctx, entry)
+}
+
+// FindAuditEntries передаёт чтение журнала действий администраторов хранилищу.
+func (r *Repository) FindAuditEntries(ctx context.Context, limit int) ([]model.AdminAuditEntry, error) {
+	if r.admin == nil {
+		return nil, ErrAdminUnsupported
+	}
+	return r.admin.FindAuditEntries(ctx, limit)
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/model"
+	"time"
+)
+
+// ErrAPIKeysUnsupported — ошибка операций с ключами API, если хранилище их не поддерживает.
+var ErrAPIKeysUnsupported = errors.New("repository doesn't support api keys")
+
+// SaveAPIKey передаёт сохранение ключа API хранилищу.
+func (r *Repository) SaveAPIKey(ctx context.Context, key model.APIKey) error {
+	if r.apiKeys == nil {
+		return ErrAPIKeysUnsupported
+	}
+	return r.apiKeys.SaveAPIKey(ctx, key)
+}
+
+// FindAPIKeysByUserID передаёт чтение ключей API пользователя хранилищу.
+func (r *Repository) FindAPIKeysByUserID(ctx context.Context, userID string) ([]model.APIKey, error) {
+	if r.apiKeys == nil {
+		return nil, ErrAPIKeysUnsupported
+	}
+	return r.apiKeys.FindAPIKeysByUserID(ctx, userID)
+}
+
+// FindAPIKeyByHash передаёт поиск ключа API хранилищу. Ключи не кэшируются,
+// чтобы отзыв ключа действовал сразу на всех экземплярах сервиса.
+func (r *Repository) FindAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	if r.apiKeys == nil {
+		return model.APIKey{}, ErrAPIKeysUnsupported
+	}
+	return r.apiKeys.FindAPIKeyByHash(ctx, hash)
+}
+
+// RevokeAPIKey передаёт отзыв ключа API хранилищу.
+func (r *Repository) RevokeAPIKey(ctx context.Context, id, userID string, revokedAt time.Time) (model.APIKey, error) {
+	if r.apiKeys == nil {
+		return model.APIKey{}, ErrAPIKeysUnsupported
+	}
+	return r.apiKeys.RevokeAPIKey(ctx, id, userID, revokedAt)
+}
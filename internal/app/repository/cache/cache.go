@@ -0,0 +1,276 @@
+// Package cache содержит кэширующий декоратор хранилища коротких ссылок.
+//
+// Декоратор реализует repository.Repository и repository.AdminRepository и кэширует результаты
+// FindByHash — самой частой операции (редиректа): оригинальные URL и признаки удаления, истечения
+// и отключения ссылки. Остальные операции передаются хранилищу, а изменяющие ссылки операции
+// сбрасывают их записи в кэше.
+//
+// Кэш хранится в Store: во внутрипроцессном LRU (NewLRUStore) или в Redis (NewRedisStore).
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"go.uber.org/zap"
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+)
+
+// Состояния ссылки в записи кэша.
+const (
+	// StatusActive — ссылка действует, в записи хранится оригинальный URL.
+	StatusActive = "active"
+	// StatusDeleted — ссылка удалена (repository.ErrRecordDeleted).
+	StatusDeleted = "deleted"
+	// StatusExpired — срок жизни ссылки истёк (repository.ErrRecordExpired).
+	StatusExpired = "expired"
+	// StatusDisabled — ссылка отключена администратором (repository.ErrRecordDisabled).
+	StatusDisabled = "disabled"
+)
+
+// defaultTTL — время жизни записи кэша, если при создании хранилища задано неположительное значение.
+const defaultTTL = time.Minute
+
+// generationShards — количество счётчиков поколений, между которыми распределяются коды ссылок.
+const generationShards = 256
+
+// Entry — запись кэша для одного кода короткой ссылки.
+type Entry struct {
+	Status      string    // Состояние ссылки: StatusActive, StatusDeleted, StatusExpired или StatusDisabled
+	OriginalURL string    // Оригинальный URL; только для StatusActive
+	ExpiresAt   time.Time // Момент истечения ссылки, до которого запись можно хранить; нулевой — без ограничения
+}
+
+// Store — хранилище записей кэша.
+//
+// Записи хранятся не дольше ttl, заданного при создании хранилища, и не дольше Entry.ExpiresAt.
+// Entry.ExpiresAt используется только при сохранении: Get его не заполняет.
+type Store interface {
+	// Get возвращает запись по коду; bool — false, если записи нет.
+	Get(ctx context.Context, hashURL string) (Entry, bool, error)
+	// Set сохраняет запись.
+	Set(ctx context.Context, hashURL string, entry Entry) error
+	// Delete удаляет записи по кодам.
+	Delete(ctx context.Context, hashURLs ...string) error
+}
+
+// Repository — кэширующий декоратор repository.Repository.
+//
+// Кэшируются найденные ссылки и признаки удаления/истечения; промахи (ссылка не найдена)
+// не кэшируются, чтобы новые ссылки становились доступны сразу. Сохранение и удаление
+// ссылок, а также очистка истёкших ссылок сбрасывают их записи. Если хранилище реализует
+// repository.LinkExpiryFinder, запись действующей ссылки хранится не дольше её срока жизни;
+// иначе ссылка может перенаправлять после истечения не дольше времени жизни записи.
+//
+// Заполнение кэша после промаха защищено от гонки с инвалидацией: если, пока ссылка читалась
+// из хранилища, её запись была сброшена, прочитанное значение может быть устаревшим и в кэше
+// не остаётся. Для этого инвалидация увеличивает счётчик поколения кода (счётчики общие для
+// кодов одного сегмента), а заполнение сравнивает его до чтения и после записи в кэш.
+// Защита действует в пределах процесса: инвалидации других экземпляров сервиса ограничены TTL.
+//
+// Ошибки Store не прерывают запрос: они логируются, и запрос выполняется хранилищем.
+type Repository struct {
+	repository.Repository                             // Хранилище, к которому обращается кэш
+	admin                 repository.AdminRepository  // Операции служебного API хранилища; nil, если не поддерживаются
+	apiKeys               repository.APIKeyRepository // Хранилище ключей API; nil, если не поддерживается
+	expiry                repository.LinkExpiryFinder // Поиск ссылок со сроком жизни; nil, если не поддерживается
+	store                 Store                       // Хранилище записей кэша
+
+	generations [generationShards]atomic.Uint64 // Счётчики поколений сегментов кодов; увеличиваются при инвалидации
+
+	hits   atomic.Int64 // Количество попаданий в кэш
+	misses atomic.Int64 // Количество промахов кэша
+}
+
+// FindByHash находит оригинальный URL по коду, сначала обращаясь к кэшу.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURL: хэш-ключ короткой ссылки.
+//
+// Возвращает:
+//   - string: оригинальный URL.
+//   - error: nil, если найдено; repository.ErrRecordDeleted, repository.ErrRecordExpired или
+//     repository.ErrRecordDisabled, если ссылка удалена, истекла или отключена; иначе — ошибку хранилища.
+func (r *Repository) FindByHash(ctx context.Context, hashURL string) (string, error) {
+	entry, found, err := r.store.Get(ctx, hashURL)
+	if err != nil {
+		logger.FromContext(ctx).Warn("couldn't read link from cache", zap.String("hashURL", hashURL), zap.Error(err))
+	}
+	if found {
+		r.hits.Add(1)
+		return entry.result()
+	}
+	r.misses.Add(1)
+
+	generation := r.generation(hashURL).Load()
+	fullURL, expiresAt, err := r.find(ctx, hashURL)
+	switch {
+	case err == nil:
+		entry := Entry{Status: StatusActive, OriginalURL: fullURL}
+		if expiresAt != nil {
+			entry.ExpiresAt = *expiresAt
+		}
+		r.fill(ctx, hashURL, entry, generation)
+	case errors.Is(err, repository.ErrRecordDeleted):
+		r.fill(ctx, hashURL, Entry{Status: StatusDeleted}, generation)
+	case errors.Is(err, repository.ErrRecordExpired):
+		r.fill(ctx, hashURL, Entry{Status: StatusExpired}, generation)
+	case errors.Is(err, repository.ErrRecordDisabled):
+		r.fill(ctx, hashURL, Entry{Status: StatusDisabled}, generation)
+	}
+	return fullURL, err
+}
+
+// Save сохраняет ссылку в хранилище и сбрасывает её запись в кэше.
+func (r *Repository) Save(ctx context.Context, urlHash string, fullURL string, userID string) error {
+	err := r.Repository.Save(ctx, urlHash, fullURL, userID)
+	r.invalidate(ctx, urlHash)
+	return err
+}
+
+// SaveLink сохраняет ссылку в хранилище и сбрасывает её запись в кэше.
+// Хранилище может вернуть существующий код пользователя, поэтому сбрасываются записи обоих кодов.
+func (r *Repository) SaveLink(ctx context.Context, link model.CreateShortDTO, userID string) (string, error) {
+	storedHash, err := r.Repository.SaveLink(ctx, link, userID)
+	r.invalidate(ctx, link.HashURL, storedHash)
+	return storedHash, err
+}
+
+// SaveAll сохраняет пакет ссылок в хранилище и сбрасывает их записи в кэше.
+func (r *Repository) SaveAll(ctx context.Context, batch map[string]model.CreateShortDTO, userID string) error {
+	err := r.Repository.SaveAll(ctx, batch, userID)
+	hashURLs := make([]string, 0, len(batch))
+	for _, item := range batch {
+		hashURLs = append(hashURLs, item.HashURL)
+	}
+	r.invalidate(ctx, hashURLs...)
+	return err
+}
+
+// DeleteAll удаляет ссылки пользователя в хранилище и сбрасывает их записи в кэше.
+func (r *Repository) DeleteAll(ctx context.Context, shortURLs []string, userID string) error {
+	err := r.Repository.DeleteAll(ctx, shortURLs, userID)
+	r.invalidate(ctx, shortURLs...)
+	return err
+}
+
+// DeleteExpired удаляет истёкшие ссылки в хранилище и сбрасывает их записи в кэше.
+func (r *Repository) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	deleted, err := r.Repository.DeleteExpired(ctx, now)
+	r.invalidate(ctx, deleted...)
+	return deleted, err
+}
+
+// DeleteBatch выполняет пакет запросов на удаление и сбрасывает записи затронутых ссылок в кэше.
+func (r *Repository) DeleteBatch(ctx context.Context, requests []model.DeletionRequest) error {
+	err := r.Repository.DeleteBatch(ctx, requests)
+	for _, request := range requests {
+		r.invalidate(ctx, request.HashURLs...)
+	}
+	return err
+}
+
+// Stats возвращает статистику попаданий в кэш с момента запуска.
+func (r *Repository) Stats() model.CacheStats {
+	stats := model.CacheStats{Hits: r.hits.Load(), Misses: r.misses.Load()}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// Close закрывает хранилище и хранилище записей кэша, если они поддерживают закрытие.
+func (r *Repository) Close() error {
+	var errs []error
+	if closer, ok := r.Repository.(interface{ Close() error }); ok {
+		errs = append(errs, closer.Close())
+	}
+	if closer, ok := r.store.(interface{ Close() error }); ok {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// find находит ссылку в хранилище вместе с моментом её истечения, если хранилище его сообщает.
+func (r *Repository) find(ctx context.Context, hashURL string) (string, *time.Time, error) {
+	if r.expiry != nil {
+		return r.expiry.FindByHashWithExpiry(ctx, hashURL)
+	}
+	fullURL, err := r.Repository.FindByHash(ctx, hashURL)
+	return fullURL, nil, err
+}
+
+// fill сохраняет в кэше запись, прочитанную из хранилища при поколении generation; ошибка логируется.
+// Если поколение кода изменилось до записи, запись не сохраняется, а если во время записи —
+// удаляется: инвалидация могла произойти после чтения, и запись может быть устаревшей.
+func (r *Repository) fill(ctx context.Context, hashURL string, entry Entry, generation uint64) {
+	counter := r.generation(hashURL)
+	if counter.Load() != generation {
+		return
+	}
+	if err := r.store.Set(ctx, hashURL, entry); err != nil {
+		logger.FromContext(ctx).Warn("couldn't write link to cache", zap.String("hashURL", hashURL), zap.Error(err))
+		return
+	}
+	if counter.Load() != generation {
+		r.invalidate(ctx, hashURL)
+	}
+}
+
+// generation возвращает счётчик поколения сегмента, к которому относится код.
+func (r *Repository) generation(hashURL string) *atomic.Uint64 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(hashURL))
+	return &r.generations[h.Sum32()%generationShards]
+}
+
+// invalidate сбрасывает записи кэша для кодов; ошибка логируется.
+// Поколения кодов увеличиваются до сброса, чтобы выполняющиеся заполнения кэша не сохранили устаревшие записи.
+// Сброс выполняется и при отменённом контексте запроса, так как хранилище уже могло измениться.
+func (r *Repository) invalidate(ctx context.Context, hashURLs ...string) {
+	if len(hashURLs) == 0 {
+		return
+	}
+	for _, hashURL := range hashURLs {
+		r.generation(hashURL).Add(1)
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+	defer cancel()
+	if err := r.store.Delete(ctx, hashURLs...); err != nil {
+		logger.FromContext(ctx).Error("couldn't invalidate cached links", zap.Strings("hashURLs", hashURLs), zap.Error(err))
+	}
+}
+
+// result преобразует запись кэша в результат FindByHash.
+func (e Entry) result() (string, error) {
+	switch e.Status {
+	case StatusDeleted:
+		return "", repository.ErrRecordDeleted
+	case StatusExpired:
+		return "", repository.ErrRecordExpired
+	case StatusDisabled:
+		return "", repository.ErrRecordDisabled
+	default:
+		return e.OriginalURL, nil
+	}
+}
+
+// NewRepository создаёт кэширующий декоратор хранилища.
+//
+// Параметры:
+//   - r: хранилище коротких ссылок.
+//   - store: хранилище записей кэша.
+//
+// Возвращает:
+//   - *Repository: хранилище с кэшем.
+func NewRepository(r repository.Repository, store Store) *Repository {
+	admin, _ := r.(repository.AdminRepository)
+	apiKeys, _ := r.(repository.APIKeyRepository)
+	expiry, _ := r.(repository.LinkExpiryFinder)
+	return &Repository{Repository: r, admin: admin, apiKeys: apiKeys, expiry: expiry, store: store}
+}
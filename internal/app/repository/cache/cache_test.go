@@ -0,0 +1,347 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/app/repository/inmemory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// countingRepository — хранилище, считающее обращения к FindByHash.
+type countingRepository struct {
+	repository.Repository
+	finds int
+}
+
+func (c *countingRepository) FindByHash(ctx context.Context, hashURL string) (string, error) {
+	c.finds++
+	return c.Repository.FindByHash(ctx, hashURL)
+}
+
+func (c *countingRepository) FindByHashWithExpiry(ctx context.Context, hashURL string) (string, *time.Time, error) {
+	c.finds++
+	return c.Repository.(repository.LinkExpiryFinder).FindByHashWithExpiry(ctx, hashURL)
+}
+
+func newBackend(t *testing.T) *countingRepository {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	repo := inmemory.NewInMemoryRepository(cfg)
+	t.Cleanup(func() {
+		_ = repo.Close()
+	})
+	return &countingRepository{Repository: repo}
+}
+
+func newRedisStore(t *testing.T, ttl time.Duration) (*RedisStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	store, err := NewRedisStore("redis://"+server.Addr()+"/0", ttl)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+	return store, server
+}
+
+func TestRepositoryCachesLinks(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"lru": func(t *testing.T) Store {
+			return NewLRUStore(100, time.Minute)
+		},
+		"redis": func(t *testing.T) Store {
+			store, _ := newRedisStore(t, time.Minute)
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := newBackend(t)
+			repo := NewRepository(backend, newStore(t))
+			_, err := repo.SaveLink(ctx, model.CreateShortDTO{HashURL: "abc", OriginalURL: "https://ya.ru"}, "user")
+			require.NoError(t, err)
+
+			for i := 0; i < 3; i++ {
+				fullURL, findErr := repo.FindByHash(ctx, "abc")
+				require.NoError(t, findErr)
+				assert.Equal(t, "https://ya.ru", fullURL)
+			}
+			assert.Equal(t, 1, backend.finds, "repeated lookups must be served from the cache")
+
+			_, err = repo.FindByHash(ctx, "missing")
+			require.Error(t, err)
+			_, err = repo.FindByHash(ctx, "missing")
+			require.Error(t, err)
+			assert.Equal(t, 3, backend.finds, "missing links must not be cached")
+
+			require.NoError(t, repo.DeleteAll(ctx, []string{"abc"}, "user"))
+			for i := 0; i < 2; i++ {
+				_, err = repo.FindByHash(ctx, "abc")
+				require.ErrorIs(t, err, repository.ErrRecordDeleted)
+			}
+			assert.Equal(t, 4, backend.finds, "deletion must invalidate the link and cache the tombstone")
+
+			stats := repo.Stats()
+			assert.Equal(t, int64(3), stats.Hits)
+			assert.Equal(t, int64(4), stats.Misses)
+			assert.InDelta(t, 3.0/7.0, stats.HitRatio, 1e-9)
+		})
+	}
+}
+
+func TestRepositoryInvalidatesOnSave(t *testing.T) {
+	ctx := context.Background()
+	backend := newBackend(t)
+	repo := NewRepository(backend, NewLRUStore(100, time.Minute))
+	_, err := repo.SaveLink(ctx, model.CreateShortDTO{HashURL: "abc", OriginalURL: "https://ya.ru"}, "user")
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteBatch(ctx, []model.DeletionRequest{{UserID: "user", HashURLs: []string{"abc"}}}))
+	_, err = repo.FindByHash(ctx, "abc")
+	require.ErrorIs(t, err, repository.ErrRecordDeleted)
+
+	// Удалённая ссылка не восстанавливается: её код остаётся занятым, а повторное сокращение создаёт новый код.
+	_, err = repo.SaveLink(ctx, model.CreateShortDTO{HashURL: "abc", OriginalURL: "https://ya.ru"}, "user")
+	require.ErrorIs(t, err, repository.ErrHashCollision)
+	_, err = repo.FindByHash(ctx, "abc")
+	require.ErrorIs(t, err, repository.ErrRecordDeleted)
+	storedHash, err := repo.SaveLink(ctx, model.CreateShortDTO{HashURL: "abd", OriginalURL: "https://ya.ru"}, "user")
+	require.NoError(t, err)
+	fullURL, err := repo.FindByHash(ctx, storedHash)
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", fullURL)
+}
+
+func TestLRUStoreLimits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	store := NewLRUStore(2, time.Minute)
+	store.now = func() time.Time { return now }
+
+	require.NoError(t, store.Set(ctx, "a", Entry{Status: StatusActive, OriginalURL: "https://a.ru"}))
+	require.NoError(t, store.Set(ctx, "b", Entry{Status: StatusActive, OriginalURL: "https://b.ru"}))
+	_, found, _ := store.Get(ctx, "a")
+	require.True(t, found)
+	require.NoError(t, store.Set(ctx, "c", Entry{Status: StatusDeleted}))
+
+	_, found, _ = store.Get(ctx, "b")
+	assert.False(t, found, "the least recently used entry must be evicted")
+	assert.Equal(t, 2, store.Len())
+
+	now = now.Add(time.Minute)
+	_, found, _ = store.Get(ctx, "a")
+	assert.False(t, found, "expired entries must not be returned")
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestRedisStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	store, server := newRedisStore(t, time.Minute)
+	require.NoError(t, store.Set(ctx, "a", Entry{Status: StatusActive, OriginalURL: "https://a.ru/?q=1|2"}))
+
+	entry, found, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, Entry{Status: StatusActive, OriginalURL: "https://a.ru/?q=1|2"}, entry)
+
+	server.FastForward(time.Minute)
+	_, found, err = store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestStoresCapTTLAtLinkExpiry(t *testing.T) {
+	ctx := context.Background()
+	t.Run("lru", func(t *testing.T) {
+		now := time.Now()
+		store := NewLRUStore(10, time.Minute)
+		store.now = func() time.Time { return now }
+		require.NoError(t, store.Set(ctx, "a", Entry{Status: StatusActive, OriginalURL: "https://a.ru", ExpiresAt: now.Add(10 * time.Second)}))
+		_, found, _ := store.Get(ctx, "a")
+		require.True(t, found)
+		now = now.Add(10 * time.Second)
+		_, found, _ = store.Get(ctx, "a")
+		assert.False(t, found, "the entry must not outlive the link")
+	})
+	t.Run("redis", func(t *testing.T) {
+		store, server := newRedisStore(t, time.Minute)
+		require.NoError(t, store.Set(ctx, "a", Entry{Status: StatusActive, OriginalURL: "https://a.ru", ExpiresAt: time.Now().Add(10 * time.Second)}))
+		assert.LessOrEqual(t, server.TTL(redisKeyPrefix+"a"), 10*time.Second, "the entry must not outlive the link")
+		require.NoError(t, store.Set(ctx, "b", Entry{Status: StatusActive, OriginalURL: "https://b.ru", ExpiresAt: time.Now().Add(-time.Second)}))
+		assert.False(t, server.Exists(redisKeyPrefix+"b"), "an expired link must not be cached")
+	})
+}
+
+func TestStoresReplaceZeroTTL(t *testing.T) {
+	ctx := context.Background()
+	// Нулевое время жизни в обоих хранилищах означает одно и то же: время жизни по умолчанию.
+	lru := NewLRUStore(10, 0)
+	require.NoError(t, lru.Set(ctx, "a", Entry{Status: StatusDeleted}))
+	_, found, _ := lru.Get(ctx, "a")
+	assert.True(t, found, "the entry must not expire immediately")
+
+	redisStore, server := newRedisStore(t, 0)
+	require.NoError(t, redisStore.Set(ctx, "a", Entry{Status: StatusDeleted}))
+	assert.Equal(t, defaultTTL, server.TTL(redisKeyPrefix+"a"), "the entry must not be stored forever")
+}
+
+func TestRepositoryCapsTTLAtLinkExpiry(t *testing.T) {
+	ctx := context.Background()
+	backend := newBackend(t)
+	now := time.Now()
+	store := NewLRUStore(100, 2*time.Hour)
+	store.now = func() time.Time { return now }
+	repo := NewRepository(backend, store)
+	expiresAt := now.Add(time.Hour)
+	_, err := repo.SaveLink(ctx, model.CreateShortDTO{HashURL: "abc", OriginalURL: "https://ya.ru", ExpiresAt: &expiresAt}, "user")
+	require.NoError(t, err)
+	_, err = repo.FindByHash(ctx, "abc")
+	require.NoError(t, err)
+	_, found, _ := store.Get(ctx, "abc")
+	require.True(t, found)
+
+	now = expiresAt
+	_, found, _ = store.Get(ctx, "abc")
+	assert.False(t, found, "the entry must expire together with the link")
+}
+
+func TestRepositoryInvalidatesOnDeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	backend := newBackend(t)
+	repo := NewRepository(backend, NewLRUStore(100, time.Minute))
+	past := time.Now().Add(-time.Minute)
+	_, err := repo.SaveLink(ctx, model.CreateShortDTO{HashURL: "abc", OriginalURL: "https://ya.ru", ExpiresAt: &past}, "user")
+	require.NoError(t, err)
+	_, err = repo.FindByHash(ctx, "abc")
+	require.ErrorIs(t, err, repository.ErrRecordExpired)
+
+	deleted, err := repo.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"abc"}, deleted)
+	_, _ = repo.FindByHash(ctx, "abc")
+	assert.Equal(t, 2, backend.finds, "the sweep must invalidate the cached expired link")
+}
+
+// pausingRepository — хранилище, которое после чтения ссылки ждёт сигнала release, сообщив о чтении в read.
+type pausingRepository struct {
+	*countingRepository
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (p *pausingRepository) FindByHashWithExpiry(ctx context.Context, hashURL string) (string, *time.Time, error) {
+	fullURL, expiresAt, err := p.countingRepository.FindByHashWithExpiry(ctx, hashURL)
+	p.read <- struct{}{}
+	<-p.release
+	return fullURL, expiresAt, err
+}
+
+func TestRepositoryDoesNotCacheStaleMiss(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"lru": func(t *testing.T) Store {
+			return NewLRUStore(100, time.Minute)
+		},
+		"redis": func(t *testing.T) Store {
+			store, _ := newRedisStore(t, time.Minute)
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := &pausingRepository{countingRepository: newBackend(t), read: make(chan struct{}), release: make(chan struct{})}
+			repo := NewRepository(backend, newStore(t))
+			_, err := backend.SaveLink(ctx, model.CreateShortDTO{HashURL: "abc", OriginalURL: "https://ya.ru"}, "user")
+			require.NoError(t, err)
+
+			// Промах читает действующую ссылку, а удаление завершается до того, как промах заполнит кэш.
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, _ = repo.FindByHash(ctx, "abc")
+			}()
+			<-backend.read
+			require.NoError(t, repo.DeleteAll(ctx, []string{"abc"}, "user"))
+			close(backend.release)
+			<-done
+
+			go func() {
+				for range backend.read {
+				}
+			}()
+			_, err = repo.FindByHash(ctx, "abc")
+			require.ErrorIs(t, err, repository.ErrRecordDeleted, "a stale active entry must not be cached after the deletion")
+			close(backend.read)
+		})
+	}
+}
+
+func TestRepositorySurvivesRedisFailure(t *testing.T) {
+	ctx := context.Background()
+	backend := newBackend(t)
+	store, server := newRedisStore(t, time.Minute)
+	repo := NewRepository(backend, store)
+	_, err := repo.SaveLink(ctx, model.CreateShortDTO{HashURL: "abc", OriginalURL: "https://ya.ru"}, "user")
+	require.NoError(t, err)
+
+	server.Close()
+	fullURL, err := repo.FindByHash(ctx, "abc")
+	require.NoError(t, err, "cache errors must fall back to the storage")
+	assert.Equal(t, "https://ya.ru", fullURL)
+}
+
+func TestRepositoryDisabledLinks(t *testing.T) {
+	stores := map[string]func(t *testing.T) Store{
+		"lru": func(t *testing.T) Store {
+			return NewLRUStore(100, time.Minute)
+		},
+		"redis": func(t *testing.T) Store {
+			store, _ := newRedisStore(t, time.Minute)
+			return store
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := newBackend(t)
+			admin := backend.Repository.(repository.AdminRepository)
+			repo := NewRepository(struct {
+				*countingRepository
+				repository.AdminRepository
+			}{backend, admin}, newStore(t))
+			_, err := repo.SaveLink(ctx, model.CreateShortDTO{HashURL: "abc", OriginalURL: "https://evil.com"}, "user")
+			require.NoError(t, err)
+			_, err = repo.FindByHash(ctx, "abc")
+			require.NoError(t, err)
+
+			changed, err := repo.DisableLinksByDomain(ctx, "evil.com")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"abc"}, changed)
+			for i := 0; i < 2; i++ {
+				_, err = repo.FindByHash(ctx, "abc")
+				require.ErrorIs(t, err, repository.ErrRecordDisabled)
+			}
+			assert.Equal(t, 2, backend.finds, "disabling must invalidate the link and cache the disabled state")
+
+			_, err = repo.SetLinksDisabled(ctx, []string{"abc"}, false)
+			require.NoError(t, err)
+			fullURL, err := repo.FindByHash(ctx, "abc")
+			require.NoError(t, err)
+			assert.Equal(t, "https://evil.com", fullURL)
+			assert.Equal(t, 3, backend.finds)
+		})
+	}
+}
+
+func TestRepositoryWithoutAdminSupport(t *testing.T) {
+	repo := NewRepository(newBackend(t), NewLRUStore(100, time.Minute))
+
+	_, err := repo.SearchLinks(context.Background(), model.AdminLinkFilter{HashURL: "abc"})
+
+	assert.ErrorIs(t, err, ErrAdminUnsupported)
+}
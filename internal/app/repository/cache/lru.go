@@ -0,0 +1,114 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// lruItem — элемент списка LRU.
+type lruItem struct {
+	hashURL   string
+	entry     Entry
+	expiresAt time.Time
+}
+
+// LRUStore — внутрипроцессное хранилище записей кэша с вытеснением давно не использованных записей.
+type LRUStore struct {
+	mu      sync.Mutex               // Защищает поля ниже
+	size    int                      // Максимальное количество записей
+	ttl     time.Duration            // Время жизни записи
+	order   *list.List               // Записи от недавно использованных к давно не использованным
+	entries map[string]*list.Element // Код → элемент order
+	now     func() time.Time         // Источник текущего времени
+}
+
+// Get возвращает запись по коду и отмечает её как недавно использованную.
+// Устаревшая запись удаляется и не возвращается.
+func (s *LRUStore) Get(_ context.Context, hashURL string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.entries[hashURL]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	item := element.Value.(*lruItem)
+	if !s.now().Before(item.expiresAt) {
+		s.remove(element)
+		return Entry{}, false, nil
+	}
+	s.order.MoveToFront(element)
+	return item.entry, true, nil
+}
+
+// Set сохраняет запись до истечения ttl или entry.ExpiresAt, если он наступает раньше;
+// при превышении размера вытесняется давно не использованная запись.
+func (s *LRUStore) Set(_ context.Context, hashURL string, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiresAt := s.now().Add(s.ttl)
+	if !entry.ExpiresAt.IsZero() && entry.ExpiresAt.Before(expiresAt) {
+		expiresAt = entry.ExpiresAt
+	}
+	if element, ok := s.entries[hashURL]; ok {
+		item := element.Value.(*lruItem)
+		item.entry, item.expiresAt = entry, expiresAt
+		s.order.MoveToFront(element)
+		return nil
+	}
+	s.entries[hashURL] = s.order.PushFront(&lruItem{hashURL: hashURL, entry: entry, expiresAt: expiresAt})
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Delete удаляет записи по кодам.
+func (s *LRUStore) Delete(_ context.Context, hashURLs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, hashURL := range hashURLs {
+		if element, ok := s.entries[hashURL]; ok {
+			s.remove(element)
+		}
+	}
+	return nil
+}
+
+// Len возвращает количество записей, включая устаревшие, но ещё не удалённые.
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// remove удаляет элемент. Вызывающий код должен удерживать s.mu.
+func (s *LRUStore) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*lruItem).hashURL)
+}
+
+// NewLRUStore создаёт внутрипроцессное хранилище записей кэша.
+//
+// Параметры:
+//   - size: максимальное количество записей; неположительное значение заменяется на 1.
+//   - ttl: время жизни записи; неположительное значение заменяется на минуту.
+//
+// Возвращает:
+//   - *LRUStore: пустое хранилище.
+func NewLRUStore(size int, ttl time.Duration) *LRUStore {
+	if size <= 0 {
+		size = 1
+	}
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &LRUStore{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
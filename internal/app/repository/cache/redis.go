@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+// redisKeyPrefix — префикс ключей записей кэша в Redis.
+const redisKeyPrefix = "shortener:link:"
+
+// Значения записей в Redis: состояние ссылки, для действующей — с оригинальным URL после разделителя.
+const redisValueSeparator = "|"
+
+// RedisStore — хранилище записей кэша в Redis, общее для нескольких экземпляров сервиса.
+type RedisStore struct {
+	client *redis.Client // Клиент Redis
+	ttl    time.Duration // Время жизни записи
+}
+
+// Get возвращает запись по коду.
+func (s *RedisStore) Get(ctx context.Context, hashURL string) (Entry, bool, error) {
+	value, err := s.client.Get(ctx, redisKeyPrefix+hashURL).Result()
+	if errors.Is(err, redis.Nil) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("cache.redis.get: %w", err)
+	}
+	status, originalURL, _ := strings.Cut(value, redisValueSeparator)
+	switch status {
+	case StatusActive, StatusDeleted, StatusExpired, StatusDisabled:
+		return Entry{Status: status, OriginalURL: originalURL}, true, nil
+	default:
+		return Entry{}, false, fmt.Errorf("cache.redis.get: unknown link status %q", status)
+	}
+}
+
+// Set сохраняет запись со временем жизни ttl, но не дольше entry.ExpiresAt.
+// Запись, срок которой уже наступил, не сохраняется.
+func (s *RedisStore) Set(ctx context.Context, hashURL string, entry Entry) error {
+	ttl := s.ttl
+	if !entry.ExpiresAt.IsZero() {
+		ttl = min(ttl, time.Until(entry.ExpiresAt))
+	}
+	if ttl < time.Millisecond {
+		return nil
+	}
+	value := entry.Status + redisValueSeparator + entry.OriginalURL
+	if err := s.client.Set(ctx, redisKeyPrefix+hashURL, value, ttl).Err(); err != nil {
+		return fmt.Errorf("cache.redis.set: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет записи по кодам.
+func (s *RedisStore) Delete(ctx context.Context, hashURLs ...string) error {
+	keys := make([]string, len(hashURLs))
+	for i, hashURL := range hashURLs {
+		keys[i] = redisKeyPrefix + hashURL
+	}
+	if err := s.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("cache.redis.delete: %w", err)
+	}
+	return nil
+}
+
+// Close закрывает подключения к Redis.
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
+
+// NewRedisStore создаёт хранилище записей кэша в Redis.
+//
+// Параметры:
+//   - redisURL: адрес Redis в формате redis://[user:password@]host:port[/db].
+//   - ttl: время жизни записи; неположительное значение заменяется на минуту
+//     (без этого Redis хранил бы записи бессрочно).
+//
+// Возвращает:
+//   - *RedisStore: хранилище, готовое к использованию.
+//   - error: nil, если успешно, иначе — ошибку разбора адреса.
+func NewRedisStore(redisURL string, ttl time.Duration) (*RedisStore, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("cache.newRedisStore: %w", err)
+	}
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &RedisStore{client: redis.NewClient(options), ttl: ttl}, nil
+}
@@ -0,0 +1,225 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SearchLinks ищет ссылки, удовлетворяющие всем заданным условиям фильтра.
+// Ссылки возвращаются в порядке кодов.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - filter: условия поиска; пустые условия не применяются.
+//
+// Возвращает:
+//   - []model.AdminLink: найденные ссылки (не больше filter.Limit).
+//   - error: всегда nil.
+func (r *Repository) SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.AdminLink, error) {
+	defer metrics.ObserveStorage(storageBackend, "SearchLinks", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".SearchLinks")
+	defer span.End()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hashURLs := make([]string, 0)
+	switch {
+	case filter.HashURL != "":
+		if _, exists := r.urlBucket[filter.HashURL]; exists {
+			hashURLs = append(hashURLs, filter.HashURL)
+		}
+	case filter.UserID != "":
+		for hashURL := range r.userBucket[filter.UserID] {
+			hashURLs = append(hashURLs, hashURL)
+		}
+	default:
+		for hashURL := range r.urlBucket {
+			hashURLs = append(hashURLs, hashURL)
+		}
+	}
+	slices.Sort(hashURLs)
+
+	searchedURL := strings.ToLower(filter.URL)
+	result := make([]model.AdminLink, 0)
+	for _, hashURL := range hashURLs {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		originalURL := r.urlBucket[hashURL]
+		if searchedURL != "" && !strings.Contains(strings.ToLower(originalURL), searchedURL) {
+			continue
+		}
+		if _, owned := r.owners[hashURL][filter.UserID]; filter.UserID != "" && !owned {
+			continue
+		}
+		result = append(result, r.adminLink(hashURL))
+	}
+	return result, nil
+}
+
+// SetLinksDisabled отключает или снова включает ссылки и записывает изменения в бэкап.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURLs: коды ссылок.
+//   - disabled: true — отключить, false — включить.
+//
+// Возвращает:
+//   - []string: коды ссылок, состояние которых изменилось.
+//   - error: nil, если успешно; иначе — ошибку записи в бэкап.
+func (r *Repository) SetLinksDisabled(ctx context.Context, hashURLs []string, disabled bool) ([]string, error) {
+	defer metrics.ObserveStorage(storageBackend, "SetLinksDisabled", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".SetLinksDisabled")
+	defer span.End()
+	r.mu.Lock()
+	changed := make([]string, 0, len(hashURLs))
+	pending := make([]<-chan error, 0, len(hashURLs))
+	for _, hashURL := range hashURLs {
+		if !r.setDisabled(hashURL, disabled) {
+			continue
+		}
+		changed = append(changed, hashURL)
+		pending = append(pending, r.bkp.enqueueDisabled(hashURL, disabled))
+	}
+	r.mu.Unlock()
+	if err := waitBackup(pending); err != nil {
+		return changed, fmt.Errorf("inmemory.repository.setLinksDisabled: %w", err)
+	}
+	return changed, nil
+}
+
+// DisableLinksByDomain отключает все ссылки, ведущие на домен или его поддомены,
+// и записывает изменения в бэкап.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - domain: домен в нижнем регистре.
+//
+// Возвращает:
+//   - []string: коды отключённых ссылок в порядке возрастания.
+//   - error: nil, если успешно; иначе — ошибку записи в бэкап.
+func (r *Repository) DisableLinksByDomain(ctx context.Context, domain string) ([]string, error) {
+	defer metrics.ObserveStorage(storageBackend, "DisableLinksByDomain", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".DisableLinksByDomain")
+	defer span.End()
+	r.mu.Lock()
+	changed := make([]string, 0)
+	for hashURL, originalURL := range r.urlBucket {
+		if repository.MatchesDomain(originalURL, domain) && r.setDisabled(hashURL, true) {
+			changed = append(changed, hashURL)
+		}
+	}
+	slices.Sort(changed)
+	pending := make([]<-chan error, 0, len(changed))
+	for _, hashURL := range changed {
+		pending = append(pending, r.bkp.enqueueDisabled(hashURL, true))
+	}
+	r.mu.Unlock()
+	if err := waitBackup(pending); err != nil {
+		return changed, fmt.Errorf("inmemory.repository.disableLinksByDomain: %w", err)
+	}
+	return changed, nil
+}
+
+// SaveAuditEntry сохраняет запись журнала действий администраторов в памяти и в бэкапе.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - entry: запись журнала.
+//
+// Возвращает:
+//   - error: nil, если успешно; иначе — ошибку записи в бэкап.
+func (r *Repository) SaveAuditEntry(ctx context.Context, entry model.AdminAuditEntry) error {
+	defer metrics.ObserveStorage(storageBackend, "SaveAuditEntry", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".SaveAuditEntry")
+	defer span.End()
+	r.mu.Lock()
+	r.audit = append(r.audit, entry)
+	result := r.bkp.enqueueAudit(entry)
+	r.mu.Unlock()
+	if err := <-result; err != nil {
+		return fmt.Errorf("inmemory.repository.saveAuditEntry: %w", err)
+	}
+	return nil
+}
+
+// FindAuditEntries возвращает последние записи журнала действий администраторов, начиная с новых.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - limit: максимальное количество записей; неположительное значение — без ограничения.
+//
+// Возвращает:
+//   - []model.AdminAuditEntry: записи журнала.
+//   - error: всегда nil.
+func (r *Repository) FindAuditEntries(ctx context.Context, limit int) ([]model.AdminAuditEntry, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindAuditEntries", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".FindAuditEntries")
+	defer span.End()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]model.AdminAuditEntry, 0)
+	for i := len(r.audit) - 1; i >= 0 && (limit <= 0 || len(result) < limit); i-- {
+		result = append(result, r.audit[i])
+	}
+	return result, nil
+}
+
+// setDisabled меняет признак отключения существующей ссылки.
+// Вызывающий код должен удерживать r.mu на запись.
+//
+// Возвращает:
+//   - bool: true, если состояние ссылки изменилось.
+func (r *Repository) setDisabled(hashURL string, disabled bool) bool {
+	if _, exists := r.urlBucket[hashURL]; !exists {
+		return false
+	}
+	_, wasDisabled := r.disabled[hashURL]
+	if wasDisabled == disabled {
+		return false
+	}
+	if disabled {
+		r.disabled[hashURL] = struct{}{}
+	} else {
+		delete(r.disabled, hashURL)
+	}
+	return true
+}
+
+// adminLink формирует описание ссылки для служебного API.
+// Вызывающий код должен удерживать r.mu.
+func (r *Repository) adminLink(hashURL string) model.AdminLink {
+	link := model.AdminLink{
+		HashURL:     hashURL,
+		ShortURL:    fmt.Sprintf("%s/%s", r.baseShortURL, hashURL),
+		OriginalURL: r.urlBucket[hashURL],
+		Owners:      make([]string, 0, len(r.owners[hashURL])),
+	}
+	for userID := range r.owners[hashURL] {
+		link.Owners = append(link.Owners, userID)
+	}
+	slices.Sort(link.Owners)
+	_, link.Deleted = r.deleted[hashURL]
+	_, link.Disabled = r.disabled[hashURL]
+	if expiresAt, ok := r.expiryBucket[hashURL]; ok {
+		link.ExpiresAt = &expiresAt
+	}
+	return link
+}
+
+// waitBackup дожидается записи событий в бэкап и возвращает первую ошибку записи.
+func waitBackup(pending []<-chan error) error {
+	var firstErr error
+	for _, result := range pending {
+		if err := <-result; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,132 @@
+package inmemory
+
+import (
+	"context"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SaveAPIKey сохраняет новый ключ API в памяти и в бэкапе.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - key: ключ с заполненными ID, UserID, Hash и CreatedAt.
+//
+// Возвращает:
+//   - error: nil, если успешно; иначе — ошибку записи в бэкап.
+func (r *Repository) SaveAPIKey(ctx context.Context, key model.APIKey) error {
+	defer metrics.ObserveStorage(storageBackend, "SaveAPIKey", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".SaveAPIKey")
+	defer span.End()
+	r.mu.Lock()
+	r.putAPIKey(key)
+	result := r.bkp.enqueueAPIKey(key)
+	r.mu.Unlock()
+	if err := <-result; err != nil {
+		return fmt.Errorf("inmemory.repository.saveAPIKey: %w", err)
+	}
+	return nil
+}
+
+// FindAPIKeysByUserID возвращает ключи API пользователя, включая отозванные, в порядке создания.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - []model.APIKey: ключи пользователя.
+//   - error: всегда nil.
+func (r *Repository) FindAPIKeysByUserID(ctx context.Context, userID string) ([]model.APIKey, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindAPIKeysByUserID", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".FindAPIKeysByUserID")
+	defer span.End()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]model.APIKey, 0)
+	for _, key := range r.apiKeys {
+		if key.UserID == userID {
+			result = append(result, *key)
+		}
+	}
+	slices.SortFunc(result, func(a, b model.APIKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return result, nil
+}
+
+// FindAPIKeyByHash находит ключ API по отпечатку.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hash: отпечаток ключа.
+//
+// Возвращает:
+//   - model.APIKey: найденный ключ (в том числе отозванный).
+//   - error: nil, если найдено; repository.ErrAPIKeyNotFound, если ключа нет.
+func (r *Repository) FindAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindAPIKeyByHash", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".FindAPIKeyByHash")
+	defer span.End()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	id, exists := r.apiKeyHashes[hash]
+	if !exists {
+		return model.APIKey{}, repository.ErrAPIKeyNotFound
+	}
+	return *r.apiKeys[id], nil
+}
+
+// RevokeAPIKey отзывает ключ API пользователя и записывает отзыв в бэкап.
+// Повторный отзыв не меняет момент отзыва и не пишет в бэкап.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - id: идентификатор ключа.
+//   - userID: идентификатор владельца.
+//   - revokedAt: момент отзыва.
+//
+// Возвращает:
+//   - model.APIKey: отозванный ключ.
+//   - error: nil, если успешно; repository.ErrAPIKeyNotFound, если у пользователя нет такого ключа;
+//     иначе — ошибку записи в бэкап.
+func (r *Repository) RevokeAPIKey(ctx context.Context, id, userID string, revokedAt time.Time) (model.APIKey, error) {
+	defer metrics.ObserveStorage(storageBackend, "RevokeAPIKey", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".RevokeAPIKey")
+	defer span.End()
+	r.mu.Lock()
+	key, exists := r.apiKeys[id]
+	if !exists || key.UserID != userID {
+		r.mu.Unlock()
+		return model.APIKey{}, repository.ErrAPIKeyNotFound
+	}
+	if key.RevokedAt != nil {
+		revoked := *key
+		r.mu.Unlock()
+		return revoked, nil
+	}
+	key.RevokedAt = &revokedAt
+	revoked := *key
+	result := r.bkp.enqueueAPIKey(revoked)
+	r.mu.Unlock()
+	if err := <-result; err != nil {
+		return revoked, fmt.Errorf("inmemory.repository.revokeAPIKey: %w", err)
+	}
+	return revoked, nil
+}
+
+// putAPIKey добавляет ключ API или заменяет сохранённое состояние ключа с тем же ID.
+// Вызывающий код должен удерживать r.mu на запись.
+func (r *Repository) putAPIKey(key model.APIKey) {
+	r.apiKeys[key.ID] = &key
+	r.apiKeyHashes[key.Hash] = key.ID
+}
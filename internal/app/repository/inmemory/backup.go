@@ -2,104 +2,425 @@ package inmemory
 
 import (
 	"bufio"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
 	"github.com/faust8888/shortener/internal/middleware/logger"
 	"go.uber.org/zap"
 	"os"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// backupQueueSize — ёмкость очереди событий, ожидающих записи в файл бэкапа.
+const backupQueueSize = 1024
+
+// ErrBackupClosed — ошибка, возникающая при попытке записи в закрытый бэкап.
+var ErrBackupClosed = errors.New("backup is closed")
+
 // Backup — это утилита для сохранения и восстановления коротких ссылок в файл.
 // Используется как простое решение для персистентности данных.
+//
+// Запись в файл выполняет единственная фоновая горутина: события ставятся в очередь,
+// а горутина пишет их пачками в порядке постановки и сбрасывает буфер один раз на пачку.
+// Это позволяет безопасно писать в бэкап из конкурентных запросов.
+//
+// Сброс данных на диск (fsync) определяется режимом syncMode:
+//   - config.BackupSyncAlways: fsync выполняется один раз на пачку (group commit), и запросы пачки
+//     завершаются только после него;
+//   - config.BackupSyncInterval: fsync выполняется по таймеру, если с прошлого fsync были записи;
+//   - config.BackupSyncNever: fsync не выполняется.
+//
+// Данные хранятся в двух файлах: сжатом снимке (path + snapshotSuffix) и журнале
+// событий после снимка (path). Compact заменяет оба файла атомарно через временный файл и rename.
 type Backup struct {
-	file    *os.File       // Открытый файл для хранения бэкапа
-	writer  *bufio.Writer  // Писатель для записи данных в файл
-	scanner *bufio.Scanner // Сканер для чтения данных из файла
+	path         string        // Путь к журналу событий
+	snapshotPath string        // Путь к сжатому снимку
+	file         *os.File      // Открытый файл журнала событий
+	writer       *bufio.Writer // Писатель для записи данных в журнал; используется только горутиной run
+	tailRecords  atomic.Int64  // Количество событий в журнале после последнего снимка
+	syncMode     string        // Режим fsync журнала
+	syncInterval time.Duration // Период fsync в режиме config.BackupSyncInterval
+	dirty        bool          // Есть записи, не сброшенные на диск; используется только горутиной run
+
+	mu     sync.Mutex         // Защищает queue и closed
+	queue  chan backupRequest // Очередь событий на запись
+	closed bool               // Признак закрытого бэкапа
+	done   chan struct{}      // Закрывается по завершении горутины run
 }
 
-// Write записывает событие создания короткой ссылки в файл бэкапа.
+// backupRequest — событие, ожидающее записи, или запрос на сжатие, и канал для результата.
+type backupRequest struct {
+	event    *CreateShortBackupEvent   // Событие для записи в журнал
+	compact  bool                      // Признак запроса на сжатие
+	snapshot []*CreateShortBackupEvent // Содержимое нового снимка для запроса на сжатие
+	result   chan error
+}
+
+// Write записывает событие создания короткой ссылки в файл бэкапа и дожидается записи.
 //
 // Параметры:
 //   - urlHash: хэш-ключ (короткий URL)
 //   - fullURL: оригинальный URL
 //   - userID: идентификатор пользователя
+//   - expiresAt: момент истечения ссылки (nil — бессрочная)
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку.
+func (p *Backup) Write(urlHash, fullURL, userID string, expiresAt *time.Time) error {
+	return <-p.enqueueCreation(urlHash, fullURL, userID, expiresAt)
+}
+
+// WriteDeletion записывает в файл бэкапа событие удаления (tombstone) короткой ссылки и дожидается записи.
+//
+// Параметры:
+//   - urlHash: хэш-ключ (короткий URL)
+//   - userID: идентификатор пользователя, удалившего ссылку
 //
 // Возвращает:
 //   - error: nil, если успешно, иначе — ошибку.
-func (p *Backup) Write(urlHash, fullURL, userID string) error {
-	backupEvent := &CreateShortBackupEvent{
+func (p *Backup) WriteDeletion(urlHash, userID string) error {
+	return <-p.enqueueDeletion(urlHash, userID)
+}
+
+// Close дожидается записи всех событий из очереди, останавливает фоновую горутину и закрывает файл.
+// Повторный вызов ничего не делает.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку закрытия файла.
+func (p *Backup) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	<-p.done
+	return p.file.Close()
+}
+
+// enqueueCreation ставит в очередь событие создания ссылки.
+// Порядок записи совпадает с порядком вызовов.
+//
+// Возвращает:
+//   - <-chan error: канал, в который будет передан результат записи.
+func (p *Backup) enqueueCreation(urlHash, fullURL, userID string, expiresAt *time.Time) <-chan error {
+	return p.enqueue(&CreateShortBackupEvent{
 		ShortURL:    urlHash,
 		OriginalURL: fullURL,
 		UserID:      userID,
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// enqueueDeletion ставит в очередь событие удаления ссылки.
+//
+// Возвращает:
+//   - <-chan error: канал, в который будет передан результат записи.
+func (p *Backup) enqueueDeletion(urlHash, userID string) <-chan error {
+	return p.enqueue(&CreateShortBackupEvent{
+		ShortURL: urlHash,
+		UserID:   userID,
+		Deleted:  true,
+	})
+}
+
+// enqueueDisabled ставит в очередь событие отключения или включения ссылки администратором.
+//
+// Возвращает:
+//   - <-chan error: канал, в который будет передан результат записи.
+func (p *Backup) enqueueDisabled(urlHash string, disabled bool) <-chan error {
+	return p.enqueue(&CreateShortBackupEvent{
+		ShortURL: urlHash,
+		Disabled: &disabled,
+	})
+}
+
+// enqueueAudit ставит в очередь запись журнала действий администраторов.
+//
+// Возвращает:
+//   - <-chan error: канал, в который будет передан результат записи.
+func (p *Backup) enqueueAudit(entry model.AdminAuditEntry) <-chan error {
+	return p.enqueue(&CreateShortBackupEvent{Audit: &entry})
+}
+
+// enqueueAPIKey ставит в очередь событие создания или отзыва ключа API.
+//
+// Возвращает:
+//   - <-chan error: канал, в который будет передан результат записи.
+func (p *Backup) enqueueAPIKey(key model.APIKey) <-chan error {
+	return p.enqueue(&CreateShortBackupEvent{APIKey: &key})
+}
+
+// enqueue ставит событие в очередь фоновой горутины.
+func (p *Backup) enqueue(event *CreateShortBackupEvent) <-chan error {
+	return p.send(backupRequest{event: event})
+}
+
+// send ставит запрос в очередь фоновой горутины и возвращает канал для результата.
+// Для nil-бэкапа (файл не удалось открыть) запрос отбрасывается без ошибки.
+func (p *Backup) send(req backupRequest) <-chan error {
+	req.result = make(chan error, 1)
+	if p == nil {
+		req.result <- nil
+		return req.result
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		req.result <- ErrBackupClosed
+		return req.result
 	}
-	data, err := json.Marshal(&backupEvent)
+	p.queue <- req
+	return req.result
+}
+
+// run — фоновая горутина, единственный писатель файла бэкапа.
+//
+// Забирает из очереди все доступные события, записывает их и сбрасывает буфер,
+// после чего сообщает результат каждому ожидающему. В режиме config.BackupSyncInterval
+// также периодически выполняет fsync. Перед завершением сбрасывает на диск все записи.
+func (p *Backup) run() {
+	defer close(p.done)
+	var tick <-chan time.Time
+	if p.syncMode == config.BackupSyncInterval {
+		ticker := time.NewTicker(p.syncInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	batch := make([]backupRequest, 0, backupQueueSize)
+	for {
+		select {
+		case req, ok := <-p.queue:
+			if !ok {
+				p.syncIfDirty()
+				return
+			}
+			batch = append(batch[:0], req)
+		collect:
+			for len(batch) < backupQueueSize {
+				select {
+				case next, ok := <-p.queue:
+					if !ok {
+						break collect
+					}
+					batch = append(batch, next)
+				default:
+					break collect
+				}
+			}
+			p.writeBatch(batch)
+		case <-tick:
+			p.syncIfDirty()
+		}
+	}
+}
+
+// syncIfDirty выполняет fsync журнала, если с прошлого fsync были записи.
+// В режиме config.BackupSyncNever ничего не делает.
+func (p *Backup) syncIfDirty() {
+	if !p.dirty || p.syncMode == config.BackupSyncNever {
+		return
+	}
+	if err := p.file.Sync(); err != nil {
+		logger.Log.Error("failed to sync a backup file", zap.Error(err))
+		return
+	}
+	p.dirty = false
+}
+
+// writeBatch записывает пачку событий и сбрасывает буфер.
+// Результат (в том числе ошибка записи) сообщается всем событиям пачки.
+// Запрос на сжатие выполняется после записи всех предшествующих ему событий.
+func (p *Backup) writeBatch(batch []backupRequest) {
+	pending := make([]backupRequest, 0, len(batch))
+	for _, req := range batch {
+		if req.compact {
+			p.flushPending(pending)
+			pending = pending[:0]
+			req.result <- p.compact(req.snapshot)
+			continue
+		}
+		pending = append(pending, req)
+	}
+	p.flushPending(pending)
+}
+
+// flushPending записывает события в журнал, сбрасывает буфер и сообщает результат ожидающим.
+// В режиме config.BackupSyncAlways перед ответом выполняет fsync — один на всю пачку.
+func (p *Backup) flushPending(pending []backupRequest) {
+	if len(pending) == 0 {
+		return
+	}
+	var err error
+	for _, req := range pending {
+		if err = writeEvent(p.writer, req.event); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = p.writer.Flush()
+	}
+	if err == nil {
+		p.tailRecords.Add(int64(len(pending)))
+		p.dirty = true
+		if p.syncMode == config.BackupSyncAlways {
+			if err = p.file.Sync(); err != nil {
+				err = fmt.Errorf("failed to sync a backup file: %w", err)
+			} else {
+				p.dirty = false
+			}
+		}
+	}
+	for _, req := range pending {
+		req.result <- err
+	}
+}
+
+// writeEvent сериализует событие в конверт с контрольной суммой и дописывает его в буфер отдельной строкой.
+func writeEvent(w *bufio.Writer, backupEvent *CreateShortBackupEvent) error {
+	data, err := encodeRecord(backupEvent)
 	if err != nil {
 		return fmt.Errorf("failed to marshal a backup event: %w", err)
 	}
-	if _, err = p.writer.Write(data); err != nil {
+	if _, err = w.Write(data); err != nil {
 		return fmt.Errorf("failed to write data for a backup: %w", err)
 	}
-	if err = p.writer.WriteByte('\n'); err != nil {
+	if err = w.WriteByte('\n'); err != nil {
 		return fmt.Errorf("failed to write new line symbol for a backup: %w", err)
 	}
-	return p.writer.Flush()
+	return nil
 }
 
-// RecoverTo восстанавливает данные из файла бэкапа в указанное хранилище.
+// RecoverTo восстанавливает данные из снимка и журнала событий, передавая события в apply.
+// Сначала применяется снимок (если он есть), затем журнал событий после него.
+// Повторное применение событий безопасно, поэтому журнал, уже вошедший в снимок, не портит данные.
 //
-// Параметры:
-//   - bucket: карта для хранения пар shortURL → originalURL
-//   - userBucket: карта пользовательских ссылок
-func (p *Backup) RecoverTo(bucket map[string]string, userBucket map[string]map[string]struct{}) {
-	for p.scanner.Scan() {
-		event := CreateShortBackupEvent{}
-		err := json.Unmarshal(p.scanner.Bytes(), &event)
-		if err != nil {
-			logger.Log.Error("failed to unmarshal a backup event", zap.Error(err))
-		} else {
-			logger.Log.Info("recovering backup event", zap.Any("event", event))
-		}
-		bucket[event.ShortURL] = event.OriginalURL
-		if _, exists := userBucket[event.UserID]; exists {
-			userBucket[event.UserID][event.ShortURL] = struct{}{}
-		} else {
-			userBucket[event.UserID] = make(map[string]struct{})
-			userBucket[event.UserID][event.ShortURL] = struct{}{}
-		}
+// Записи с неверной контрольной суммой или неразбираемые записи не применяются, а переносятся
+// в карантинный файл (path + quarantineSuffix). Недописанная последняя строка журнала
+// (обрыв записи при сбое) отрезается от файла.
+//
+// Параметр:
+//   - apply: функция, применяющая событие к хранилищу.
+//
+// Возвращает:
+//   - model.BackupRecoverySummary: итоги восстановления.
+func (p *Backup) RecoverTo(apply func(event CreateShortBackupEvent)) model.BackupRecoverySummary {
+	started := time.Now()
+	r := &recovery{
+		quarantinePath: p.path + quarantineSuffix,
+		apply:          apply,
+	}
+	defer r.close()
+
+	snapshot, err := os.Open(p.snapshotPath)
+	if err == nil {
+		r.summary.SnapshotRecords = r.recoverFile(snapshot, p.snapshotPath, false)
+		_ = snapshot.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		logger.Log.Error("failed to open a backup snapshot", zap.Error(err))
+	}
+	r.summary.TailRecords = r.recoverFile(p.file, p.path, true)
+	p.tailRecords.Store(r.summary.TailRecords)
+
+	r.summary.RecoveredAt = time.Now()
+	r.summary.DurationMs = time.Since(started).Milliseconds()
+	if r.summary.Skipped > 0 {
+		r.summary.QuarantineFile = r.quarantinePath
 	}
+	logRecoverySummary(r.summary)
+	return r.summary
 }
 
-// NewBackup создаёт новый экземпляр Backup на основе указанного файла.
+// CheckWritable проверяет, что бэкап открыт и файл журнала доступен на запись.
 //
-// Параметр:
+// Возвращает:
+//   - error: nil, если в бэкап можно писать; ErrBackupClosed, если бэкап закрыт; иначе — ошибку открытия файла.
+func (p *Backup) CheckWritable() error {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return ErrBackupClosed
+	}
+	file, err := os.OpenFile(p.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("backup is not writable: %w", err)
+	}
+	return file.Close()
+}
+
+// NewBackup создаёт новый экземпляр Backup на основе указанного файла
+// и запускает фоновую горутину записи. RecoverTo нужно вызывать до первой записи.
+// Неизвестный режим fsync заменяется на config.BackupSyncAlways как самый надёжный.
+//
+// Параметры:
 //   - filename: имя файла для хранения бэкапа.
+//   - syncMode: режим fsync (config.BackupSyncAlways, config.BackupSyncInterval или config.BackupSyncNever).
+//   - syncInterval: период fsync в режиме config.BackupSyncInterval.
 //
 // Возвращает:
 //   - *Backup: готовый к использованию объект бэкапа.
 //   - error: nil, если успешно, иначе — ошибку.
-func NewBackup(filename string) (*Backup, error) {
+func NewBackup(filename, syncMode string, syncInterval time.Duration) (*Backup, error) {
+	switch syncMode {
+	case config.BackupSyncAlways, config.BackupSyncNever:
+	case config.BackupSyncInterval:
+		if syncInterval <= 0 {
+			return nil, fmt.Errorf("invalid backup sync interval: %s", syncInterval)
+		}
+	default:
+		logger.Log.Warn("unknown backup sync mode, falling back to always", zap.String("syncMode", syncMode))
+		syncMode = config.BackupSyncAlways
+	}
 	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
 		return nil, fmt.Errorf("can't open file: %w", err)
 	}
 
-	return &Backup{
-		file:    file,
-		writer:  bufio.NewWriter(file),
-		scanner: bufio.NewScanner(file),
-	}, nil
+	bkp := &Backup{
+		path:         filename,
+		snapshotPath: filename + snapshotSuffix,
+		file:         file,
+		writer:       bufio.NewWriter(file),
+		syncMode:     syncMode,
+		syncInterval: syncInterval,
+		queue:        make(chan backupRequest, backupQueueSize),
+		done:         make(chan struct{}),
+	}
+	go bkp.run()
+	return bkp, nil
 }
 
-// CreateShortBackupEvent — модель события, представляющего создание короткой ссылки.
-// Хранит информацию о коротком URL, оригинальном URL и пользователе.
+// CreateShortBackupEvent — модель события, представляющего создание или удаление короткой ссылки.
+// Хранит информацию о коротком URL, оригинальном URL, пользователе и сроке жизни ссылки.
+// Для события удаления (Deleted = true) заполняются только ShortURL и UserID,
+// для события отключения или включения ссылки администратором — только ShortURL и Disabled,
+// для надгробия истёкшей ссылки (Expired = true, только в снимке) — только ShortURL и Expired,
+// для записи журнала действий администраторов — только Audit,
+// для создания или отзыва ключа API — только APIKey.
 type CreateShortBackupEvent struct {
-	ShortURL    string `json:"short_url" validate:"required,short_url"`
-	OriginalURL string `json:"original_url" validate:"required,original_url"`
-	UserID      string `json:"user_id" validate:"required,user_id"`
+	ShortURL    string                 `json:"short_url" validate:"required,short_url"`
+	OriginalURL string                 `json:"original_url" validate:"required,original_url"`
+	UserID      string                 `json:"user_id" validate:"required,user_id"`
+	ExpiresAt   *time.Time             `json:"expires_at,omitempty"`
+	Deleted     bool                   `json:"deleted,omitempty"`
+	Expired     bool                   `json:"expired,omitempty"`
+	Disabled    *bool                  `json:"disabled,omitempty"`
+	Audit       *model.AdminAuditEntry `json:"audit,omitempty"`
+	APIKey      *model.APIKey          `json:"api_key,omitempty"`
 }
 
 // String возвращает строковое представление события.
 func (e CreateShortBackupEvent) String() string {
-	return fmt.Sprintf(`{"ShortURL": "%s", "OriginalURL": "%s", "UserID": "%s"}`,
-		e.ShortURL, e.OriginalURL, e.UserID)
+	return fmt.Sprintf(`{"ShortURL": "%s", "OriginalURL": "%s", "UserID": "%s", "Deleted": %t}`,
+		e.ShortURL, e.OriginalURL, e.UserID, e.Deleted)
 }
@@ -0,0 +1,217 @@
+package inmemory
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"go.uber.org/zap"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+// recordVersion — текущая версия формата записи бэкапа.
+//
+// Версия 0 — исходный формат без конверта (событие в виде JSON), поддерживается только для чтения.
+// Версия 1 — событие в конверте с контрольной суммой CRC-32 (IEEE) от поля data.
+const recordVersion = 1
+
+// quarantineSuffix — суффикс, добавляемый к пути журнала для получения пути к карантинному файлу.
+const quarantineSuffix = ".quarantine"
+
+// Ошибки разбора записей бэкапа.
+var (
+	errChecksumMismatch   = errors.New("checksum mismatch")
+	errUnsupportedVersion = errors.New("unsupported record version")
+	errInvalidEvent       = errors.New("invalid backup event")
+	errTornRecord         = errors.New("torn record at the end of the file")
+)
+
+// recordEnvelope — конверт записи бэкапа: версия формата, контрольная сумма и само событие.
+type recordEnvelope struct {
+	Version  int             `json:"v"`
+	Checksum uint32          `json:"crc"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// quarantineRecord — запись карантинного файла с исходными байтами повреждённой записи.
+type quarantineRecord struct {
+	Source        string    `json:"source"`
+	Offset        int64     `json:"offset"`
+	Reason        string    `json:"reason"`
+	Record        string    `json:"record"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// encodeRecord сериализует событие в конверт текущей версии.
+func encodeRecord(event *CreateShortBackupEvent) ([]byte, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&recordEnvelope{
+		Version:  recordVersion,
+		Checksum: crc32.ChecksumIEEE(data),
+		Data:     data,
+	})
+}
+
+// decodeRecord разбирает строку бэкапа, проверяя версию и контрольную сумму.
+// Строки в исходном формате без конверта принимаются как версия 0.
+func decodeRecord(line []byte) (CreateShortBackupEvent, error) {
+	var envelope recordEnvelope
+	if err := json.Unmarshal(line, &envelope); err != nil {
+		return CreateShortBackupEvent{}, fmt.Errorf("unmarshal record: %w", err)
+	}
+	data := []byte(envelope.Data)
+	switch {
+	case envelope.Version == 0 && envelope.Data == nil:
+		data = line
+	case envelope.Version == recordVersion:
+		if crc32.ChecksumIEEE(data) != envelope.Checksum {
+			return CreateShortBackupEvent{}, errChecksumMismatch
+		}
+	default:
+		return CreateShortBackupEvent{}, fmt.Errorf("%w: %d", errUnsupportedVersion, envelope.Version)
+	}
+
+	var event CreateShortBackupEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return CreateShortBackupEvent{}, fmt.Errorf("unmarshal event: %w", err)
+	}
+	if event.APIKey != nil && (event.APIKey.ID == "" || event.APIKey.UserID == "" || event.APIKey.Hash == "") {
+		return CreateShortBackupEvent{}, errInvalidEvent
+	}
+	if event.Audit == nil && event.APIKey == nil && (event.ShortURL == "" || (!event.Deleted && !event.Expired && event.Disabled == nil && event.OriginalURL == "")) {
+		return CreateShortBackupEvent{}, errInvalidEvent
+	}
+	return event, nil
+}
+
+// recovery — состояние одного восстановления: итоги и открытый по требованию карантинный файл.
+type recovery struct {
+	apply          func(event CreateShortBackupEvent) // Применяет событие к хранилищу
+	summary        model.BackupRecoverySummary        // Итоги восстановления
+	quarantinePath string                             // Путь к карантинному файлу
+	quarantine     *os.File                           // Карантинный файл; nil, пока не понадобится
+}
+
+// recoverFile читает записи файла с начала и применяет корректные к хранилищу.
+//
+// Повреждённые записи переносятся в карантин. Если truncateTorn = true, последняя строка
+// без перевода строки считается оборванной записью: если её контрольная сумма верна,
+// запись применяется и дописывается перевод строки, иначе она переносится в карантин,
+// а файл усекается до конца последней целой записи.
+//
+// Возвращает:
+//   - int64: количество прочитанных записей.
+func (r *recovery) recoverFile(file *os.File, source string, truncateTorn bool) int64 {
+	reader := bufio.NewReader(file)
+	var records, offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			logger.Log.Error("failed to read a backup file", zap.String("path", source), zap.Error(err))
+			break
+		}
+		lineOffset := offset
+		offset += int64(len(line))
+		torn := errors.Is(err, io.EOF)
+		trimmed := bytes.TrimSpace(line)
+		if len(trimmed) == 0 {
+			if torn {
+				break
+			}
+			continue
+		}
+
+		records++
+		event, decodeErr := decodeRecord(trimmed)
+		switch {
+		case decodeErr == nil && torn && truncateTorn:
+			r.apply(event)
+			r.summary.Restored++
+			if _, writeErr := file.Write([]byte{'\n'}); writeErr != nil {
+				logger.Log.Error("failed to complete a torn backup record", zap.String("path", source), zap.Error(writeErr))
+			}
+		case decodeErr == nil:
+			r.apply(event)
+			r.summary.Restored++
+		case torn && truncateTorn:
+			r.skip(source, lineOffset, line, fmt.Errorf("%w: %w", errTornRecord, decodeErr))
+			if truncateErr := file.Truncate(lineOffset); truncateErr != nil {
+				logger.Log.Error("failed to truncate a torn backup record", zap.String("path", source), zap.Error(truncateErr))
+			} else {
+				r.summary.TruncatedBytes += int64(len(line))
+			}
+		default:
+			r.skip(source, lineOffset, line, decodeErr)
+		}
+		if torn {
+			break
+		}
+	}
+	return records
+}
+
+// skip переносит повреждённую запись в карантинный файл и учитывает её в итогах.
+func (r *recovery) skip(source string, offset int64, line []byte, reason error) {
+	r.summary.Skipped++
+	logger.Log.Warn("skipping corrupted backup record",
+		zap.String("path", source), zap.Int64("offset", offset), zap.Error(reason))
+	if r.quarantine == nil {
+		file, err := os.OpenFile(r.quarantinePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			logger.Log.Error("failed to open a quarantine file", zap.Error(err))
+			return
+		}
+		r.quarantine = file
+	}
+	data, err := json.Marshal(&quarantineRecord{
+		Source:        source,
+		Offset:        offset,
+		Reason:        reason.Error(),
+		Record:        string(bytes.TrimRight(line, "\n")),
+		QuarantinedAt: time.Now(),
+	})
+	if err == nil {
+		_, err = r.quarantine.Write(append(data, '\n'))
+	}
+	if err != nil {
+		logger.Log.Error("failed to write a quarantine record", zap.Error(err))
+	}
+}
+
+// close закрывает карантинный файл, если он был открыт.
+func (r *recovery) close() {
+	if r.quarantine == nil {
+		return
+	}
+	if err := r.quarantine.Sync(); err != nil {
+		logger.Log.Error("failed to sync a quarantine file", zap.Error(err))
+	}
+	_ = r.quarantine.Close()
+}
+
+// logRecoverySummary выводит итоги восстановления в лог; при наличии пропущенных записей — с уровнем Warn.
+func logRecoverySummary(summary model.BackupRecoverySummary) {
+	fields := []zap.Field{
+		zap.Int64("snapshotRecords", summary.SnapshotRecords),
+		zap.Int64("tailRecords", summary.TailRecords),
+		zap.Int64("restored", summary.Restored),
+		zap.Int64("skipped", summary.Skipped),
+		zap.Int64("truncatedBytes", summary.TruncatedBytes),
+		zap.Int64("durationMs", summary.DurationMs),
+	}
+	if summary.Skipped > 0 {
+		logger.Log.Warn("backup recovered with corrupted records",
+			append(fields, zap.String("quarantineFile", summary.QuarantineFile))...)
+		return
+	}
+	logger.Log.Info("backup recovered", fields...)
+}
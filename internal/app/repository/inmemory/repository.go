@@ -1,77 +1,203 @@
 package inmemory
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/faust8888/shortener/internal/app/config"
 	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
 	"github.com/faust8888/shortener/internal/middleware/logger"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
 	"go.uber.org/zap"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// storageBackend — значение метки backend в метриках операций хранилища.
+const storageBackend = "inmemory"
+
 // Repository — это реализация интерфейса repository.Repository на основе map.
 // Поддерживает:
 // - хранение пар shortURL → fullURL,
-// - хранение ссылок по пользователю,
+// - хранение ссылок по пользователю (у каждого пользователя свой код для URL),
+// - срок жизни ссылок,
+// - удаление ссылок владельцем (ссылка удаляется, когда у неё не остаётся владельцев),
+// - отключение ссылок администратором и журнал действий администраторов,
+// - ключи API пользователей,
+// - агрегированную статистику переходов (не сохраняется в бэкап),
 // - бэкап данных в файл.
+//
+// Операции выполняются в памяти, поэтому контекст запроса в методах не используется.
+//
+// Repository безопасен для конкурентного использования: карты ссылок защищены r.mu,
+// статистика — отдельным r.statsMu, а запись в файл выполняет фоновая горутина Backup.
+// События бэкапа ставятся в очередь под r.mu (поэтому порядок записей совпадает
+// с порядком изменений), а ожидание записи происходит уже после освобождения блокировки.
 type Repository struct {
+	mu           sync.RWMutex                   // Защищает карты ссылок от конкурентного доступа
 	urlBucket    map[string]string              // Карта коротких URL → оригинальные URL
+	urlIndex     map[string]map[string]string   // Карта пользователь → оригинальный URL → его бессрочный короткий URL
 	userBucket   map[string]map[string]struct{} // Карта пользовательских ссылок
+	owners       map[string]map[string]struct{} // Карта коротких URL → владельцы
+	expiryBucket map[string]time.Time           // Карта коротких URL → момент истечения
+	deleted      map[string]struct{}            // Множество удалённых коротких URL
+	expired      map[string]struct{}            // Множество коротких URL истёкших ссылок, удалённых очисткой (надгробия)
+	disabled     map[string]struct{}            // Множество коротких URL, отключённых администратором
+	audit        []model.AdminAuditEntry        // Журнал действий администраторов в порядке добавления
+	apiKeys      map[string]*model.APIKey       // Карта идентификаторов ключей API → ключи
+	apiKeyHashes map[string]string              // Карта отпечатков ключей API → идентификаторы ключей
+	statsMu      sync.Mutex                     // Защищает statsBucket
+	statsBucket  map[string]*linkStats          // Карта коротких URL → статистика переходов
 	bkp          *Backup                        // Утилита для сохранения данных
+	recovery     model.BackupRecoverySummary    // Итоги восстановления из бэкапа при запуске
+	sequence     atomic.Int64                   // Последовательность для генерации кодов (стратегия counter)
 	baseShortURL string                         // Базовый URL для формирования полного адреса
 }
 
 // Save сохраняет одну пару (hashURL -> fullURL) для указанного пользователя.
 //
 // Параметры:
+//   - ctx: контекст запроса.
 //   - urlHash: хэш-ключ для короткой ссылки.
 //   - fullURL: оригинальный URL.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
-//   - error: nil, если успешно, иначе — ошибку.
-func (r *Repository) Save(urlHash string, fullURL string, userID string) error {
-	if _, exists := r.urlBucket[urlHash]; !exists {
-		r.urlBucket[urlHash] = fullURL
-	}
-	if _, exists := r.userBucket[userID]; !exists {
-		r.userBucket[userID] = make(map[string]struct{})
+//   - error: nil, если успешно; repository.ErrURLAlreadyShortened, если пользователь уже сократил URL;
+//     *repository.HashCollisionError, если код занят другим URL.
+func (r *Repository) Save(ctx context.Context, urlHash string, fullURL string, userID string) error {
+	_, err := r.SaveLink(ctx, model.CreateShortDTO{HashURL: urlHash, OriginalURL: fullURL}, userID)
+	return err
+}
+
+// SaveLink сохраняет ссылку с алиасом и/или сроком жизни.
+//
+// Коды не делятся между пользователями. Если пользователь уже сокращал URL без алиаса,
+// его код действует (не удалён, не отключён и бессрочен), а новая ссылка тоже бессрочна,
+// возвращается этот код. Иначе ссылка сохраняется под новым кодом.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - link: DTO с данными о ссылке.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - string: ключ, под которым хранится ссылка.
+//   - error: nil, если успешно; repository.ErrAliasAlreadyTaken, если алиас уже занят;
+//     repository.ErrURLAlreadyShortened, если пользователь уже сократил URL (вместе с существующим ключом);
+//     *repository.HashCollisionError, если код уже занят.
+func (r *Repository) SaveLink(ctx context.Context, link model.CreateShortDTO, userID string) (string, error) {
+	defer metrics.ObserveStorage(storageBackend, "SaveLink", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".SaveLink")
+	defer span.End()
+	r.mu.Lock()
+	if link.Alias != "" {
+		if r.occupied(link.Alias) {
+			r.mu.Unlock()
+			return "", repository.ErrAliasAlreadyTaken
+		}
+	} else if existingHash, ok := r.reusableHash(link, userID); ok {
+		r.mu.Unlock()
+		return existingHash, repository.ErrURLAlreadyShortened
 	}
-	err := r.bkp.Write(urlHash, fullURL, userID)
-	if err != nil {
-		logger.Log.Error("backup writing failed", zap.Error(err))
+	if err := r.checkCollision(link.HashURL); err != nil {
+		r.mu.Unlock()
+		return "", fmt.Errorf("inmemory.repository.saveLink: %w", err)
 	}
-	r.userBucket[userID][urlHash] = struct{}{}
-	return nil
+	pending := r.save(link, userID)
+	r.mu.Unlock()
+	logBackupErrors(pending)
+	return link.HashURL, nil
 }
 
 // FindByHash находит оригинальный URL по его хэш-ключу.
 //
-// Параметр:
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURL: хэш-ключ короткой ссылки.
+//
+// Возвращает:
+//   - string: оригинальный URL.
+//   - error: nil, если найдено; repository.ErrRecordDeleted, если ссылка удалена;
+//     repository.ErrRecordDisabled, если ссылка отключена администратором;
+//     repository.ErrRecordExpired, если срок жизни истёк; иначе — ошибку.
+func (r *Repository) FindByHash(ctx context.Context, hashURL string) (string, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindByHash", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".FindByHash")
+	defer span.End()
+	fullURL, _, err := r.find(hashURL)
+	return fullURL, err
+}
+
+// FindByHashWithExpiry находит оригинальный URL и момент истечения ссылки по её хэш-ключу.
+//
+// Параметры:
+//   - ctx: контекст запроса.
 //   - hashURL: хэш-ключ короткой ссылки.
 //
 // Возвращает:
 //   - string: оригинальный URL.
-//   - error: nil, если найдено, иначе — ошибку.
-func (r *Repository) FindByHash(hashURL string) (string, error) {
-	if fullURL, exists := r.urlBucket[hashURL]; exists {
-		return fullURL, nil
+//   - *time.Time: момент истечения; nil — ссылка бессрочная.
+//   - error: те же ошибки, что и у FindByHash.
+func (r *Repository) FindByHashWithExpiry(ctx context.Context, hashURL string) (string, *time.Time, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindByHashWithExpiry", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".FindByHashWithExpiry")
+	defer span.End()
+	return r.find(hashURL)
+}
+
+// find находит действующую ссылку по коду и её момент истечения.
+func (r *Repository) find(hashURL string) (string, *time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	fullURL, exists := r.urlBucket[hashURL]
+	if !exists {
+		if _, isExpired := r.expired[hashURL]; isExpired {
+			return "", nil, repository.ErrRecordExpired
+		}
+		return "", nil, fmt.Errorf("%w for %s", repository.ErrRecordNotFound, hashURL)
+	}
+	if _, isDeleted := r.deleted[hashURL]; isDeleted {
+		return "", nil, repository.ErrRecordDeleted
+	}
+	if _, isDisabled := r.disabled[hashURL]; isDisabled {
+		return "", nil, repository.ErrRecordDisabled
 	}
-	return "", fmt.Errorf("short url not found for %s", hashURL)
+	expiresAt, expiring := r.expiryBucket[hashURL]
+	if !expiring {
+		return fullURL, nil, nil
+	}
+	if !expiresAt.After(time.Now()) {
+		return "", nil, repository.ErrRecordExpired
+	}
+	return fullURL, &expiresAt, nil
 }
 
 // FindAllByUserID возвращает все короткие ссылки, принадлежащие пользователю.
 //
-// Параметр:
+// Параметры:
+//   - ctx: контекст запроса.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
 //   - []model.FindURLByUserIDResponse: список ссылок пользователя.
 //   - error: nil, если успешно, иначе — ошибку.
-func (r *Repository) FindAllByUserID(userID string) ([]model.FindURLByUserIDResponse, error) {
+func (r *Repository) FindAllByUserID(ctx context.Context, userID string) ([]model.FindURLByUserIDResponse, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindAllByUserID", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".FindAllByUserID")
+	defer span.End()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	shortURLs := r.userBucket[userID]
 	result := make([]model.FindURLByUserIDResponse, 0)
 	for shortURL := range shortURLs {
-		originalURL := r.urlBucket[shortURL]
+		originalURL, exists := r.urlBucket[shortURL]
+		if !exists {
+			continue
+		}
 		result = append(result, model.FindURLByUserIDResponse{
 			OriginalURL: originalURL,
 			ShortURL:    fmt.Sprintf("%s/%s", r.baseShortURL, shortURL),
@@ -81,49 +207,486 @@ func (r *Repository) FindAllByUserID(userID string) ([]model.FindURLByUserIDResp
 }
 
 // SaveAll сохраняет несколько ссылок за один раз (пакетная операция).
+// Перед сохранением проверяет, что все алиасы пакета свободны и не повторяются,
+// а коды не заняты (в том числе внутри пакета).
+// Бессрочные элементы без алиаса получают действующий код пользователя для того же URL (как в SaveLink)
+// или код другого такого же элемента пакета: HashURL и ShortURL таких элементов в batch заменяются.
 //
 // Параметры:
+//   - ctx: контекст запроса.
 //   - batch: карта хэшей и DTO с данными о ссылках.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
-//   - error: nil, если успешно, иначе — ошибку.
-func (r *Repository) SaveAll(batch map[string]model.CreateShortDTO, userID string) error {
+//   - error: nil, если успешно; repository.ErrAliasAlreadyTaken, если алиас занят;
+//     *repository.HashCollisionError, если код уже занят; иначе — ошибку.
+func (r *Repository) SaveAll(ctx context.Context, batch map[string]model.CreateShortDTO, userID string) error {
+	defer metrics.ObserveStorage(storageBackend, "SaveAll", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".SaveAll")
+	defer span.End()
+	r.mu.Lock()
+	requestedAliases := make(map[string]struct{})
 	for _, batchItem := range batch {
-		err := r.Save(batchItem.HashURL, batchItem.OriginalURL, userID)
+		if batchItem.Alias == "" {
+			continue
+		}
+		_, duplicated := requestedAliases[batchItem.Alias]
+		if r.occupied(batchItem.Alias) || duplicated {
+			r.mu.Unlock()
+			return fmt.Errorf("inmemory.repository.saveAll: %w: %s", repository.ErrAliasAlreadyTaken, batchItem.Alias)
+		}
+		requestedAliases[batchItem.Alias] = struct{}{}
+	}
+	requestedHashes := make(map[string]struct{}, len(batch))
+	requestedURLs := make(map[string]string, len(batch))
+	reused := make(map[string]struct{})
+	for correlationID, batchItem := range batch {
+		shareable := batchItem.Alias == "" && batchItem.ExpiresAt == nil
+		if shareable {
+			existingHash, ok := r.reusableHash(batchItem, userID)
+			if !ok {
+				existingHash, ok = requestedURLs[batchItem.OriginalURL]
+			}
+			if ok {
+				batchItem.HashURL = existingHash
+				batchItem.ShortURL = fmt.Sprintf("%s/%s", r.baseShortURL, existingHash)
+				batch[correlationID] = batchItem
+				reused[correlationID] = struct{}{}
+				continue
+			}
+		}
+		err := r.checkCollision(batchItem.HashURL)
+		if _, requested := requestedHashes[batchItem.HashURL]; err == nil && requested {
+			err = &repository.HashCollisionError{HashURL: batchItem.HashURL}
+		}
 		if err != nil {
+			r.mu.Unlock()
 			return fmt.Errorf("inmemory.repository.saveAll: %w", err)
 		}
+		requestedHashes[batchItem.HashURL] = struct{}{}
+		if shareable {
+			requestedURLs[batchItem.OriginalURL] = batchItem.HashURL
+		}
+	}
+	pending := make([]<-chan error, 0, len(batch))
+	for correlationID, batchItem := range batch {
+		if _, ok := reused[correlationID]; ok {
+			continue
+		}
+		pending = append(pending, r.save(batchItem, userID))
 	}
+	r.mu.Unlock()
+	logBackupErrors(pending...)
 	return nil
 }
 
+// NextID возвращает следующее значение последовательности для генерации кодов.
+//
+// Последовательность не сохраняется в бэкап: после перезапуска она продолжается с количества
+// восстановленных ссылок, а возможные совпадения с существующими кодами отсекает генератор
+// через ExistsByHash.
+//
+// Параметр:
+//   - ctx: контекст запроса.
+//
+// Возвращает:
+//   - int64: следующее значение последовательности.
+//   - error: всегда nil.
+func (r *Repository) NextID(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStorage(storageBackend, "NextID", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".NextID")
+	defer span.End()
+	return r.sequence.Add(1), nil
+}
+
+// ExistsByHash проверяет, используется ли уже код короткой ссылки (в том числе удалённой).
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURL: код короткой ссылки.
+//
+// Возвращает:
+//   - bool: true, если код занят.
+//   - error: всегда nil.
+func (r *Repository) ExistsByHash(ctx context.Context, hashURL string) (bool, error) {
+	defer metrics.ObserveStorage(storageBackend, "ExistsByHash", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".ExistsByHash")
+	defer span.End()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.occupied(hashURL), nil
+}
+
 // Ping проверяет доступность хранилища.
 //
 // Всегда возвращает true и nil, так как InMemory-реализация всегда доступна.
 //
+// Параметр:
+//   - ctx: контекст запроса.
+//
 // Возвращает:
 //   - bool: true, если хранилище доступно.
 //   - error: nil, если всё в порядке.
-func (r *Repository) Ping() (bool, error) {
+func (r *Repository) Ping(ctx context.Context) (bool, error) {
+	defer metrics.ObserveStorage(storageBackend, "Ping", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".Ping")
+	defer span.End()
 	return true, nil
 }
 
-// DeleteAll асинхронно удаляет несколько коротких ссылок пользователя.
+// DeleteAll удаляет несколько коротких ссылок пользователя.
 //
-// Для InMemory-реализации пока не используется.
-// Реализация удаления может быть добавлена при необходимости.
+// У каждой ссылки удаляется связь с пользователем; ссылка, у которой не осталось владельцев,
+// помечается удалённой. Ссылки, не принадлежащие пользователю, пропускаются.
+// Для каждой удалённой связи в бэкап пишется событие удаления (tombstone),
+// чтобы удаление сохранялось после перезапуска.
 //
 // Параметры:
+//   - ctx: контекст запроса.
 //   - shortURLs: список идентификаторов (хэшей) ссылок для удаления.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
-//   - error: nil, так как удаление пока не реализовано.
-func (r *Repository) DeleteAll(shortURLs []string, userID string) error {
+//   - error: nil, если успешно; иначе — ошибку записи в бэкап.
+func (r *Repository) DeleteAll(ctx context.Context, shortURLs []string, userID string) error {
+	return r.DeleteBatch(ctx, []model.DeletionRequest{{UserID: userID, HashURLs: shortURLs}})
+}
+
+// DeleteBatch выполняет запросы на удаление нескольких пользователей под одной блокировкой.
+// Семантика для каждого запроса такая же, как у DeleteAll.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - requests: запросы на удаление.
+//
+// Возвращает:
+//   - error: nil, если успешно; иначе — ошибку записи в бэкап.
+func (r *Repository) DeleteBatch(ctx context.Context, requests []model.DeletionRequest) error {
+	defer metrics.ObserveStorage(storageBackend, "DeleteBatch", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".DeleteBatch")
+	defer span.End()
+	r.mu.Lock()
+	pending := make([]<-chan error, 0, len(requests))
+	for _, request := range requests {
+		for _, hashURL := range request.HashURLs {
+			if !r.unlink(hashURL, request.UserID) {
+				continue
+			}
+			pending = append(pending, r.bkp.enqueueDeletion(hashURL, request.UserID))
+		}
+	}
+	r.mu.Unlock()
+	for _, result := range pending {
+		if err := <-result; err != nil {
+			return fmt.Errorf("inmemory.repository.deleteBatch: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired удаляет из памяти ссылки, срок жизни которых истёк к моменту now, вместе с их
+// владельцами и статистикой. Код ссылки остаётся надгробием: FindByHash продолжает возвращать
+// repository.ErrRecordExpired, а код не выдаётся повторно.
+//
+// Файл бэкапа не изменяется: при восстановлении истёкшие ссылки
+// будут снова помечены сроком жизни и удалены при следующей очистке.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - now: момент времени, с которым сравнивается срок жизни.
+//
+// Возвращает:
+//   - []string: коды удалённых ссылок.
+//   - error: всегда nil.
+func (r *Repository) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	defer metrics.ObserveStorage(storageBackend, "DeleteExpired", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".DeleteExpired")
+	defer span.End()
+	r.mu.Lock()
+	expired := make([]string, 0)
+	for hashURL, expiresAt := range r.expiryBucket {
+		if expiresAt.After(now) {
+			continue
+		}
+		r.expire(hashURL)
+		expired = append(expired, hashURL)
+	}
+	r.mu.Unlock()
+
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	for _, hashURL := range expired {
+		delete(r.statsBucket, hashURL)
+	}
+	return expired, nil
+}
+
+// SaveClicks добавляет пакет событий переходов в статистику, хранящуюся в памяти.
+// События для неизвестных ссылок пропускаются.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - clicks: события переходов.
+//
+// Возвращает:
+//   - error: всегда nil.
+func (r *Repository) SaveClicks(ctx context.Context, clicks []model.ClickEvent) error {
+	defer metrics.ObserveStorage(storageBackend, "SaveClicks", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".SaveClicks")
+	defer span.End()
+	known := make([]model.ClickEvent, 0, len(clicks))
+	r.mu.RLock()
+	for _, click := range clicks {
+		if _, exists := r.urlBucket[click.HashURL]; exists {
+			known = append(known, click)
+		}
+	}
+	r.mu.RUnlock()
+
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	for _, click := range known {
+		stats, exists := r.statsBucket[click.HashURL]
+		if !exists {
+			stats = newLinkStats()
+			r.statsBucket[click.HashURL] = stats
+		}
+		stats.add(click)
+	}
+	return nil
+}
+
+// FindStatsByHash возвращает статистику переходов по ссылке, если пользователь — её единственный владелец.
+//
+// Коды не делятся между пользователями, но у ссылок из бэкапа, созданного до этого, владельцев может быть
+// несколько: переходы по такой ссылке нельзя разделить между ними, поэтому её статистика не выдаётся никому.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURL: хэш-ключ короткой ссылки.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - model.LinkStatsResponse: статистика переходов.
+//   - error: nil, если успешно; repository.ErrNotOwner, если ссылка чужая или у неё несколько владельцев;
+//     иначе — ошибку.
+func (r *Repository) FindStatsByHash(ctx context.Context, hashURL, userID string) (model.LinkStatsResponse, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindStatsByHash", time.Now())
+	_, span := tracing.Start(ctx, storageBackend+".FindStatsByHash")
+	defer span.End()
+	r.mu.RLock()
+	_, exists := r.urlBucket[hashURL]
+	_, owned := r.userBucket[userID][hashURL]
+	owned = owned && len(r.owners[hashURL]) == 1
+	r.mu.RUnlock()
+	if !exists {
+		return model.LinkStatsResponse{}, fmt.Errorf("%w for %s", repository.ErrRecordNotFound, hashURL)
+	}
+	if !owned {
+		return model.LinkStatsResponse{}, repository.ErrNotOwner
+	}
+
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	stats, exists := r.statsBucket[hashURL]
+	if !exists {
+		return newLinkStats().response(), nil
+	}
+	return stats.response(), nil
+}
+
+// RecoverySummary возвращает итоги восстановления хранилища из бэкапа при запуске.
+func (r *Repository) RecoverySummary() model.BackupRecoverySummary {
+	return r.recovery
+}
+
+// CheckBackup проверяет, что файл бэкапа открыт и доступен на запись.
+//
+// Параметр:
+//   - ctx: контекст запроса (не используется, нужен для совместимости с health.Check).
+//
+// Возвращает:
+//   - error: nil, если в бэкап можно писать, иначе — причину, по которой ссылки не сохранятся после перезапуска.
+func (r *Repository) CheckBackup(_ context.Context) error {
+	if r.bkp == nil {
+		return errors.New("backup file is not opened")
+	}
+	return r.bkp.CheckWritable()
+}
+
+// Close дожидается записи всех событий в бэкап и закрывает файл.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку закрытия файла.
+func (r *Repository) Close() error {
+	return r.bkp.Close()
+}
+
+// save сохраняет ссылку в карты и ставит событие в очередь бэкапа.
+// Вызывающий код должен удерживать r.mu на запись и дождаться результата записи после её освобождения.
+func (r *Repository) save(link model.CreateShortDTO, userID string) <-chan error {
+	r.link(link.HashURL, link.OriginalURL, userID, link.ExpiresAt)
+	return r.bkp.enqueueCreation(link.HashURL, link.OriginalURL, userID, link.ExpiresAt)
+}
+
+// link добавляет ссылку (если её ещё нет) и делает пользователя её владельцем.
+// Удалённая ссылка, у которой появился владелец, снова становится доступной: SaveLink так не делает,
+// но такие события могут встретиться в бэкапах, записанных до того, как коды перестали делиться.
+// Пустой userID добавляет ссылку без владельца (так в снимке хранятся удалённые ссылки).
+// Вызывающий код должен удерживать r.mu на запись.
+func (r *Repository) link(hashURL, originalURL, userID string, expiresAt *time.Time) {
+	if _, exists := r.urlBucket[hashURL]; !exists {
+		r.urlBucket[hashURL] = originalURL
+		if expiresAt != nil {
+			r.expiryBucket[hashURL] = *expiresAt
+		}
+	}
+	if userID == "" {
+		return
+	}
+	delete(r.deleted, hashURL)
+	if _, expiring := r.expiryBucket[hashURL]; !expiring {
+		if _, indexed := r.urlIndex[userID]; !indexed {
+			r.urlIndex[userID] = make(map[string]string)
+		}
+		if current, ok := r.urlIndex[userID][originalURL]; !ok || !r.isLive(current) {
+			r.urlIndex[userID][originalURL] = hashURL
+		}
+	}
+	if _, exists := r.userBucket[userID]; !exists {
+		r.userBucket[userID] = make(map[string]struct{})
+	}
+	r.userBucket[userID][hashURL] = struct{}{}
+	if _, exists := r.owners[hashURL]; !exists {
+		r.owners[hashURL] = make(map[string]struct{})
+	}
+	r.owners[hashURL][userID] = struct{}{}
+}
+
+// unlink удаляет связь ссылки с пользователем; ссылка без владельцев помечается удалённой.
+// Вызывающий код должен удерживать r.mu на запись.
+//
+// Возвращает:
+//   - bool: true, если пользователь был владельцем ссылки.
+func (r *Repository) unlink(hashURL, userID string) bool {
+	if _, owned := r.userBucket[userID][hashURL]; !owned {
+		return false
+	}
+	delete(r.userBucket[userID], hashURL)
+	delete(r.owners[hashURL], userID)
+	if originalURL := r.urlBucket[hashURL]; r.urlIndex[userID][originalURL] == hashURL {
+		delete(r.urlIndex[userID], originalURL)
+	}
+	if len(r.owners[hashURL]) == 0 {
+		delete(r.owners, hashURL)
+		r.deleted[hashURL] = struct{}{}
+	}
+	return true
+}
+
+// expire удаляет ссылку вместе с владельцами и оставляет вместо неё надгробие.
+// Статистика переходов удаляется вызывающим кодом под r.statsMu.
+// Вызывающий код должен удерживать r.mu на запись.
+func (r *Repository) expire(hashURL string) {
+	for userID := range r.owners[hashURL] {
+		delete(r.userBucket[userID], hashURL)
+	}
+	delete(r.owners, hashURL)
+	delete(r.urlBucket, hashURL)
+	delete(r.expiryBucket, hashURL)
+	delete(r.deleted, hashURL)
+	delete(r.disabled, hashURL)
+	r.expired[hashURL] = struct{}{}
+}
+
+// applyEvent применяет событие бэкапа при восстановлении.
+//
+// Событие удаления с пользователем удаляет его связь со ссылкой (как DeleteAll),
+// событие удаления без пользователя (из снимка) помечает ссылку удалённой,
+// событие истечения (из снимка) оставляет надгробие истёкшей ссылки.
+// События отключения ссылки и журнала администраторов применяются как SetLinksDisabled и SaveAuditEntry,
+// событие ключа API заменяет ранее сохранённое состояние ключа.
+func (r *Repository) applyEvent(event CreateShortBackupEvent) {
+	switch {
+	case event.Audit != nil:
+		r.audit = append(r.audit, *event.Audit)
+		return
+	case event.APIKey != nil:
+		r.putAPIKey(*event.APIKey)
+		return
+	case event.Disabled != nil:
+		r.setDisabled(event.ShortURL, *event.Disabled)
+		return
+	case event.Expired:
+		r.expire(event.ShortURL)
+		return
+	}
+	if !event.Deleted {
+		r.link(event.ShortURL, event.OriginalURL, event.UserID, event.ExpiresAt)
+		return
+	}
+	if event.UserID != "" {
+		r.unlink(event.ShortURL, event.UserID)
+		return
+	}
+	if _, exists := r.urlBucket[event.ShortURL]; exists {
+		r.deleted[event.ShortURL] = struct{}{}
+	}
+}
+
+// checkCollision проверяет, что код ещё не занят: ни другим URL, ни ссылкой другого пользователя
+// или удалённой, отключённой либо истёкшей ссылкой на тот же URL, ни надгробием истёкшей ссылки.
+// Вызывающий код должен удерживать r.mu.
+//
+// Возвращает:
+//   - error: nil, если код свободен; иначе — *repository.HashCollisionError.
+func (r *Repository) checkCollision(hashURL string) error {
+	if r.occupied(hashURL) {
+		return &repository.HashCollisionError{HashURL: hashURL}
+	}
 	return nil
 }
 
+// occupied сообщает, что код занят ссылкой или надгробием истёкшей ссылки.
+// Вызывающий код должен удерживать r.mu.
+func (r *Repository) occupied(hashURL string) bool {
+	_, exists := r.urlBucket[hashURL]
+	_, isExpired := r.expired[hashURL]
+	return exists || isExpired
+}
+
+// reusableHash возвращает код, под которым пользователь уже сократил URL ссылки, если его можно вернуть
+// повторно: новая ссылка без алиаса и бессрочна, а прежняя действует (см. isLive).
+// Вызывающий код должен удерживать r.mu.
+func (r *Repository) reusableHash(link model.CreateShortDTO, userID string) (string, bool) {
+	if link.Alias != "" || link.ExpiresAt != nil || userID == "" {
+		return "", false
+	}
+	hashURL, ok := r.urlIndex[userID][link.OriginalURL]
+	if !ok || !r.isLive(hashURL) {
+		return "", false
+	}
+	return hashURL, true
+}
+
+// isLive сообщает, что ссылка не удалена, не отключена администратором и бессрочна.
+// Вызывающий код должен удерживать r.mu.
+func (r *Repository) isLive(hashURL string) bool {
+	_, isDeleted := r.deleted[hashURL]
+	_, isDisabled := r.disabled[hashURL]
+	_, expiring := r.expiryBucket[hashURL]
+	return !isDeleted && !isDisabled && !expiring
+}
+
+// logBackupErrors дожидается записи событий в бэкап и логирует ошибки записи.
+func logBackupErrors(pending ...<-chan error) {
+	for _, result := range pending {
+		if err := <-result; err != nil {
+			logger.Log.Error("backup writing failed", zap.Error(err))
+		}
+	}
+}
+
 // NewInMemoryRepository создаёт новый экземпляр InMemory-репозитория.
 // Если указан путь к файлу бэкапа — восстанавливает данные из него.
 //
@@ -133,18 +696,34 @@ func (r *Repository) DeleteAll(shortURLs []string, userID string) error {
 // Возвращает:
 //   - *Repository: готовый к использованию репозиторий.
 func NewInMemoryRepository(cfg *config.Config) *Repository {
-	urlBucket := make(map[string]string)
-	userBucket := make(map[string]map[string]struct{})
-	bkp, err := NewBackup(cfg.StorageFilePath)
+	r := &Repository{
+		urlBucket:    make(map[string]string),
+		urlIndex:     make(map[string]map[string]string),
+		userBucket:   make(map[string]map[string]struct{}),
+		owners:       make(map[string]map[string]struct{}),
+		expiryBucket: make(map[string]time.Time),
+		deleted:      make(map[string]struct{}),
+		expired:      make(map[string]struct{}),
+		disabled:     make(map[string]struct{}),
+		apiKeys:      make(map[string]*model.APIKey),
+		apiKeyHashes: make(map[string]string),
+		statsBucket:  make(map[string]*linkStats),
+		baseShortURL: cfg.BaseShortURL,
+	}
+	bkp, err := NewBackup(cfg.StorageFilePath, cfg.BackupSyncMode, cfg.BackupSyncInterval)
 	if err != nil {
 		logger.Log.Error("create backup failed", zap.Error(err))
 	} else {
-		bkp.RecoverTo(urlBucket, userBucket)
+		r.recovery = bkp.RecoverTo(r.applyEvent)
 	}
-	return &Repository{
-		urlBucket:    urlBucket,
-		userBucket:   userBucket,
-		bkp:          bkp,
-		baseShortURL: cfg.BaseShortURL,
+	r.bkp = bkp
+	r.sequence.Store(int64(len(r.urlBucket)))
+	// Повреждённые записи уже перенесены в карантин: переписываем бэкап без них,
+	// чтобы они не попадали в карантин повторно при каждом запуске.
+	if r.recovery.Skipped > 0 {
+		if err = r.Compact(); err != nil {
+			logger.Log.Error("couldn't compact backup after recovery", zap.Error(err))
+		}
 	}
+	return r
 }
@@ -1,10 +1,19 @@
 package inmemory
 
 import (
+	"context"
+	"fmt"
 	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestInMemoryStorageFindByHashURLAndSave(t *testing.T) {
@@ -37,8 +46,8 @@ func TestInMemoryStorageFindByHashURLAndSave(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			s := NewInMemoryRepository(config.Create())
 
-			s.Save(tt.urlHashForSaving, tt.fullURL, tt.userID)
-			returnedFullURL, err := s.FindByHash(tt.urlHashForSearching)
+			s.Save(context.Background(), tt.urlHashForSaving, tt.fullURL, tt.userID)
+			returnedFullURL, err := s.FindByHash(context.Background(), tt.urlHashForSearching)
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -49,3 +58,678 @@ func TestInMemoryStorageFindByHashURLAndSave(t *testing.T) {
 		})
 	}
 }
+
+func TestInMemoryStorageExpiredLinks(t *testing.T) {
+	s := NewInMemoryRepository(&config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt")})
+	suffix := time.Now().UnixNano()
+	expiredHash := fmt.Sprintf("expired%d", suffix)
+	aliveHash := fmt.Sprintf("alive%d", suffix)
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	_, err := s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: expiredHash, OriginalURL: "https://yandex.ru/expired", ExpiresAt: &past}, "12345")
+	require.NoError(t, err)
+	_, err = s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: aliveHash, OriginalURL: "https://yandex.ru/alive", ExpiresAt: &future}, "12345")
+	require.NoError(t, err)
+
+	_, err = s.FindByHash(context.Background(), expiredHash)
+	require.ErrorIs(t, err, repository.ErrRecordExpired)
+	fullURL, err := s.FindByHash(context.Background(), aliveHash)
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru/alive", fullURL)
+
+	deleted, err := s.DeleteExpired(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []string{expiredHash}, deleted)
+
+	// После очистки код остаётся надгробием: ссылка по-прежнему истёкшая, а код не выдаётся повторно.
+	_, err = s.FindByHash(context.Background(), expiredHash)
+	require.ErrorIs(t, err, repository.ErrRecordExpired)
+	_, err = s.FindByHash(context.Background(), aliveHash)
+	require.NoError(t, err)
+	urls, err := s.FindAllByUserID(context.Background(), "12345")
+	require.NoError(t, err)
+	assert.Len(t, urls, 1, "an expired link must be removed from the user links")
+	_, err = s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: expiredHash, OriginalURL: "https://yandex.ru/other"}, "12345")
+	require.ErrorIs(t, err, repository.ErrHashCollision)
+	exists, err := s.ExistsByHash(context.Background(), expiredHash)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	deleted, err = s.DeleteExpired(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, deleted, "a tombstone must not be swept again")
+}
+
+func TestInMemoryStorageClickStats(t *testing.T) {
+	s := NewInMemoryRepository(config.Create())
+	hashURL := fmt.Sprintf("stats%d", time.Now().UnixNano())
+	_, err := s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: hashURL, OriginalURL: "https://yandex.ru/" + hashURL}, "owner")
+	require.NoError(t, err)
+
+	day1 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	err = s.SaveClicks(context.Background(), []model.ClickEvent{
+		{HashURL: hashURL, Timestamp: day2, Referrer: "https://ya.ru/", UserAgent: "curl", IPHash: "a"},
+		{HashURL: hashURL, Timestamp: day1, Referrer: "https://ya.ru/", UserAgent: "firefox", IPHash: "a"},
+		{HashURL: hashURL, Timestamp: day1, UserAgent: "curl", IPHash: "b"},
+		{HashURL: "unknown_hash", Timestamp: day1, IPHash: "c"},
+	})
+	require.NoError(t, err)
+
+	stats, err := s.FindStatsByHash(context.Background(), hashURL, "owner")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	assert.Equal(t, []model.DailyClicksResponse{
+		{Date: "2024-01-01", Clicks: 2, UniqueVisitors: 2},
+		{Date: "2024-01-02", Clicks: 1, UniqueVisitors: 1},
+	}, stats.Daily)
+	assert.Equal(t, []model.ClickSourceResponse{{Value: "https://ya.ru/", Clicks: 2}}, stats.Referrers)
+	assert.Equal(t, []model.ClickSourceResponse{{Value: "curl", Clicks: 2}, {Value: "firefox", Clicks: 1}}, stats.UserAgents)
+
+	_, err = s.FindStatsByHash(context.Background(), hashURL, "stranger")
+	require.ErrorIs(t, err, repository.ErrNotOwner)
+	_, err = s.FindStatsByHash(context.Background(), "unknown_hash", "owner")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, repository.ErrNotOwner)
+}
+
+func TestInMemoryStorageDeleteAndRecover(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt")}
+	s := NewInMemoryRepository(cfg)
+	require.NoError(t, s.Save(context.Background(), "deleted", "https://yandex.ru/deleted", "owner"))
+	require.NoError(t, s.Save(context.Background(), "alive", "https://yandex.ru/alive", "owner"))
+
+	require.NoError(t, s.DeleteAll(context.Background(), []string{"deleted", "alive"}, "stranger"))
+	_, err := s.FindByHash(context.Background(), "alive")
+	require.NoError(t, err, "link must not be deleted by another user")
+
+	require.NoError(t, s.DeleteAll(context.Background(), []string{"deleted", "unknown"}, "owner"))
+	_, err = s.FindByHash(context.Background(), "deleted")
+	require.ErrorIs(t, err, repository.ErrRecordDeleted)
+
+	recovered := NewInMemoryRepository(cfg)
+	_, err = recovered.FindByHash(context.Background(), "deleted")
+	require.ErrorIs(t, err, repository.ErrRecordDeleted, "deletion must survive a restart")
+	fullURL, err := recovered.FindByHash(context.Background(), "alive")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru/alive", fullURL)
+}
+
+func TestInMemoryStorageConcurrentAccess(t *testing.T) {
+	const (
+		workers          = 16
+		linksPerWorker   = 200
+		deletedPerWorker = 50
+	)
+	cfg := &config.Config{
+		StorageFilePath:    filepath.Join(t.TempDir(), "storage.txt"),
+		BaseShortURL:       "http://localhost",
+		BackupSyncMode:     config.BackupSyncInterval,
+		BackupSyncInterval: 10 * time.Millisecond,
+	}
+	s := NewInMemoryRepository(cfg)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			userID := fmt.Sprintf("user%d", w)
+			for i := 0; i < linksPerWorker; i++ {
+				hashURL := fmt.Sprintf("hash-%d-%d", w, i)
+				assert.NoError(t, s.Save(context.Background(), hashURL, "https://yandex.ru/"+hashURL, userID))
+				_, err := s.FindByHash(context.Background(), hashURL)
+				assert.NoError(t, err)
+				_, _ = s.FindByHash(context.Background(), fmt.Sprintf("hash-%d-%d", (w+1)%workers, i))
+				_, err = s.FindAllByUserID(context.Background(), userID)
+				assert.NoError(t, err)
+				assert.NoError(t, s.SaveClicks(context.Background(), []model.ClickEvent{{HashURL: hashURL, Timestamp: time.Now(), IPHash: userID}}))
+				if i < deletedPerWorker {
+					assert.NoError(t, s.DeleteAll(context.Background(), []string{hashURL}, userID))
+				}
+			}
+		}(w)
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < linksPerWorker; i++ {
+				_, _ = s.FindAllByUserID(context.Background(), fmt.Sprintf("user%d", w))
+				_, _ = s.FindStatsByHash(context.Background(), fmt.Sprintf("hash-%d-%d", w, i), fmt.Sprintf("user%d", w))
+				_, _ = s.DeleteExpired(context.Background(), time.Now())
+				_ = s.DeleteAll(context.Background(), []string{fmt.Sprintf("hash-%d-%d", w, i)}, "stranger")
+			}
+		}(w)
+	}
+	wg.Wait()
+	require.NoError(t, s.Close())
+
+	recovered := NewInMemoryRepository(cfg)
+	defer func() {
+		_ = recovered.Close()
+	}()
+	for w := 0; w < workers; w++ {
+		urls, err := recovered.FindAllByUserID(context.Background(), fmt.Sprintf("user%d", w))
+		require.NoError(t, err)
+		require.Len(t, urls, linksPerWorker-deletedPerWorker, "deleted links must leave the owner's list")
+		for i := 0; i < linksPerWorker; i++ {
+			_, err = recovered.FindByHash(context.Background(), fmt.Sprintf("hash-%d-%d", w, i))
+			if i < deletedPerWorker {
+				require.ErrorIs(t, err, repository.ErrRecordDeleted)
+			} else {
+				require.NoError(t, err)
+			}
+		}
+	}
+}
+
+func TestBackupWriteAfterClose(t *testing.T) {
+	bkp, err := NewBackup(filepath.Join(t.TempDir(), "storage.txt"), config.BackupSyncNever, 0)
+	require.NoError(t, err)
+	require.NoError(t, bkp.Write("hash", "https://yandex.ru", "user", nil))
+	require.NoError(t, bkp.CheckWritable())
+	require.NoError(t, bkp.Close())
+	require.NoError(t, bkp.Close())
+	require.ErrorIs(t, bkp.Write("hash2", "https://yandex.ru/2", "user", nil), ErrBackupClosed)
+	require.ErrorIs(t, bkp.CheckWritable(), ErrBackupClosed)
+}
+
+func TestBackupSyncModes(t *testing.T) {
+	const (
+		writers         = 8
+		eventsPerWriter = 50
+	)
+	tests := []struct {
+		name         string
+		syncMode     string
+		syncInterval time.Duration
+		wantErr      bool
+	}{
+		{name: "Always", syncMode: config.BackupSyncAlways},
+		{name: "Interval", syncMode: config.BackupSyncInterval, syncInterval: 5 * time.Millisecond},
+		{name: "Never", syncMode: config.BackupSyncNever},
+		{name: "Unknown mode falls back to always", syncMode: "sometimes"},
+		{name: "Interval without period", syncMode: config.BackupSyncInterval, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "storage.txt")
+			bkp, err := NewBackup(path, test.syncMode, test.syncInterval)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < eventsPerWriter; i++ {
+						hashURL := fmt.Sprintf("hash-%d-%d", w, i)
+						assert.NoError(t, bkp.Write(hashURL, "https://yandex.ru/"+hashURL, "user", nil))
+					}
+				}(w)
+			}
+			wg.Wait()
+			require.NoError(t, bkp.Close())
+
+			recovered := NewInMemoryRepository(&config.Config{StorageFilePath: path, BackupSyncMode: config.BackupSyncNever})
+			defer func() {
+				_ = recovered.Close()
+			}()
+			assert.Equal(t, int64(writers*eventsPerWriter), recovered.RecoverySummary().Restored)
+		})
+	}
+}
+
+func TestInMemoryStorageCompaction(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	s := NewInMemoryRepository(cfg)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.Save(context.Background(), fmt.Sprintf("kept%d", i), "https://yandex.ru/kept", fmt.Sprintf("user%d", i)))
+	}
+	require.NoError(t, s.Save(context.Background(), "deleted", "https://yandex.ru/deleted", "owner"))
+	require.NoError(t, s.DeleteAll(context.Background(), []string{"deleted"}, "owner"))
+	past := time.Now().Add(-time.Minute)
+	_, err := s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: "expired", OriginalURL: "https://yandex.ru/expired", ExpiresAt: &past}, "owner")
+	require.NoError(t, err)
+	_, err = s.DeleteExpired(context.Background(), time.Now())
+	require.NoError(t, err)
+	// Истёкшая ссылка, ещё не удалённая очисткой, тоже записывается в снимок только надгробием.
+	_, err = s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: "stale", OriginalURL: "https://yandex.ru/stale", ExpiresAt: &past}, "stale-owner")
+	require.NoError(t, err)
+	require.Equal(t, int64(14), s.bkp.TailRecords())
+
+	oldTail, err := os.ReadFile(cfg.StorageFilePath)
+	require.NoError(t, err)
+	require.NoError(t, s.Compact())
+	assert.Equal(t, int64(0), s.bkp.TailRecords())
+
+	tail, err := os.ReadFile(cfg.StorageFilePath)
+	require.NoError(t, err)
+	assert.Empty(t, tail, "tail log must be reset after compaction")
+	snapshot, err := os.ReadFile(cfg.StorageFilePath + snapshotSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, 14, strings.Count(string(snapshot), "\n"), "snapshot must contain kept for each owner, deleted and its tombstone, and tombstones of expired links")
+	assert.NotContains(t, string(snapshot), "yandex.ru/stale", "expired links must be written without the original URL")
+	assert.NotContains(t, string(snapshot), "yandex.ru/expired", "expired links must be written without the original URL")
+
+	require.NoError(t, s.Save(context.Background(), "after", "https://yandex.ru/after", "owner"))
+	require.NoError(t, s.Close())
+
+	assertRecovered := func(t *testing.T, wantURLs int) {
+		recovered := NewInMemoryRepository(cfg)
+		defer func() {
+			_ = recovered.Close()
+		}()
+		for _, hashURL := range []string{"kept0", "kept9", "after"} {
+			_, err := recovered.FindByHash(context.Background(), hashURL)
+			require.NoError(t, err, hashURL)
+		}
+		_, err := recovered.FindByHash(context.Background(), "deleted")
+		require.ErrorIs(t, err, repository.ErrRecordDeleted)
+		for _, hashURL := range []string{"expired", "stale"} {
+			_, err = recovered.FindByHash(context.Background(), hashURL)
+			require.ErrorIs(t, err, repository.ErrRecordExpired, hashURL)
+		}
+		urls, err := recovered.FindAllByUserID(context.Background(), "owner")
+		require.NoError(t, err)
+		assert.Len(t, urls, wantURLs)
+		urls, err = recovered.FindAllByUserID(context.Background(), "user9")
+		require.NoError(t, err)
+		assert.Len(t, urls, 1)
+	}
+	t.Run("Snapshot and tail", func(t *testing.T) {
+		assertRecovered(t, 1)
+	})
+
+	// Сбой между записью снимка и сбросом журнала: старый журнал применяется поверх снимка,
+	// истёкшая ссылка возвращается, но остаётся истёкшей до следующей очистки.
+	tail, err = os.ReadFile(cfg.StorageFilePath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(cfg.StorageFilePath, append(oldTail, tail...), 0666))
+	t.Run("Crash before tail reset", func(t *testing.T) {
+		assertRecovered(t, 2)
+	})
+}
+
+func TestInMemoryStoragePerUserLinks(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	s := NewInMemoryRepository(cfg)
+	save := func(hashURL, userID string, expiresAt *time.Time) (string, error) {
+		return s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: hashURL, OriginalURL: "https://yandex.ru/shared", ExpiresAt: expiresAt}, userID)
+	}
+	_, err := save("alice", "alice", nil)
+	require.NoError(t, err)
+
+	storedHash, err := save("bob", "bob", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", storedHash, "another user must get their own code")
+	storedHash, err = save("bob2", "bob", nil)
+	require.ErrorIs(t, err, repository.ErrURLAlreadyShortened)
+	assert.Equal(t, "bob", storedHash, "a live code must be reused")
+	_, err = save("alice", "carol", nil)
+	require.ErrorIs(t, err, repository.ErrHashCollision, "a code of another user must not be shared")
+
+	t.Run("Expiring link gets a new code", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		storedHash, err := save("bob-ttl", "bob", &future)
+		require.NoError(t, err)
+		assert.Equal(t, "bob-ttl", storedHash, "the requested TTL must not be dropped")
+		storedHash, err = save("bob-ttl2", "bob", nil)
+		require.ErrorIs(t, err, repository.ErrURLAlreadyShortened)
+		assert.Equal(t, "bob", storedHash, "an expiring code must not replace the permanent one")
+	})
+	t.Run("Deleted link gets a new code", func(t *testing.T) {
+		require.NoError(t, s.DeleteAll(context.Background(), []string{"bob"}, "bob"))
+		storedHash, err := save("bob-new", "bob", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "bob-new", storedHash)
+		_, err = s.FindByHash(context.Background(), "bob")
+		require.ErrorIs(t, err, repository.ErrRecordDeleted, "a deleted link must not be restored")
+	})
+	t.Run("Disabled link gets a new code", func(t *testing.T) {
+		_, err := s.SetLinksDisabled(context.Background(), []string{"bob-new"}, true)
+		require.NoError(t, err)
+		storedHash, err := save("bob-enabled", "bob", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "bob-enabled", storedHash)
+		_, err = s.FindByHash(context.Background(), "bob-new")
+		require.ErrorIs(t, err, repository.ErrRecordDisabled, "a disabled link must stay disabled")
+	})
+	t.Run("Expired link gets a new code", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		_, err := save("dave-expired", "dave", &past)
+		require.NoError(t, err)
+		storedHash, err := save("dave", "dave", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "dave", storedHash)
+		_, err = s.FindByHash(context.Background(), "dave-expired")
+		require.ErrorIs(t, err, repository.ErrRecordExpired)
+	})
+	t.Run("Batch", func(t *testing.T) {
+		batch := map[string]model.CreateShortDTO{
+			"1": {HashURL: "carol1", OriginalURL: "https://yandex.ru/shared", ShortURL: "http://localhost/carol1"},
+			"2": {HashURL: "carol2", OriginalURL: "https://yandex.ru/shared", ShortURL: "http://localhost/carol2"},
+			"3": {HashURL: "alice2", OriginalURL: "https://yandex.ru/shared"},
+		}
+		require.NoError(t, s.SaveAll(context.Background(), map[string]model.CreateShortDTO{"1": batch["3"]}, "alice"))
+		exists, err := s.ExistsByHash(context.Background(), "alice2")
+		require.NoError(t, err)
+		assert.False(t, exists, "the live code of the user must be reused")
+
+		delete(batch, "3")
+		require.NoError(t, s.SaveAll(context.Background(), batch, "carol"))
+		assert.Equal(t, batch["1"].HashURL, batch["2"].HashURL, "duplicates inside the batch must get one code")
+		assert.Equal(t, batch["1"].ShortURL, batch["2"].ShortURL)
+		urls, err := s.FindAllByUserID(context.Background(), "carol")
+		require.NoError(t, err)
+		assert.Len(t, urls, 1)
+	})
+
+	err = s.SaveClicks(context.Background(), []model.ClickEvent{{HashURL: "alice", Timestamp: time.Now(), IPHash: "a"}})
+	require.NoError(t, err)
+	stats, err := s.FindStatsByHash(context.Background(), "alice", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+	stats, err = s.FindStatsByHash(context.Background(), "bob-enabled", "bob")
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks, "clicks on the code of another user must not be visible")
+	_, err = s.FindStatsByHash(context.Background(), "alice", "bob")
+	require.ErrorIs(t, err, repository.ErrNotOwner)
+	require.NoError(t, s.Close())
+
+	recovered := NewInMemoryRepository(cfg)
+	defer func() {
+		_ = recovered.Close()
+	}()
+	for userID, want := range map[string]int{"alice": 1, "bob": 3, "carol": 1, "dave": 2} {
+		storedHash, err := recovered.SaveLink(context.Background(), model.CreateShortDTO{HashURL: "again", OriginalURL: "https://yandex.ru/shared"}, userID)
+		require.ErrorIs(t, err, repository.ErrURLAlreadyShortened, userID)
+		assert.NotEqual(t, "again", storedHash, userID)
+		urls, err := recovered.FindAllByUserID(context.Background(), userID)
+		require.NoError(t, err)
+		assert.Len(t, urls, want, userID)
+	}
+}
+
+func TestInMemoryStorageLegacySharedLinks(t *testing.T) {
+	// Бэкап, записанный до того, как коды перестали делиться: у ссылки два владельца.
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	var content strings.Builder
+	for _, userID := range []string{"alice", "bob"} {
+		data, err := encodeRecord(&CreateShortBackupEvent{ShortURL: "shared", OriginalURL: "https://yandex.ru/shared", UserID: userID})
+		require.NoError(t, err)
+		content.Write(data)
+		content.WriteString("\n")
+	}
+	require.NoError(t, os.WriteFile(cfg.StorageFilePath, []byte(content.String()), 0666))
+	s := NewInMemoryRepository(cfg)
+	defer func() {
+		_ = s.Close()
+	}()
+
+	for _, userID := range []string{"alice", "bob"} {
+		_, err := s.FindStatsByHash(context.Background(), "shared", userID)
+		require.ErrorIs(t, err, repository.ErrNotOwner, "clicks of a shared code must not be visible to its owners")
+	}
+	require.NoError(t, s.DeleteAll(context.Background(), []string{"shared"}, "bob"))
+	_, err := s.FindByHash(context.Background(), "shared")
+	require.NoError(t, err, "link must stay alive while it has owners")
+	_, err = s.FindStatsByHash(context.Background(), "shared", "alice")
+	require.NoError(t, err, "the sole owner must see the stats")
+}
+
+func TestInMemoryStorageRecoverCorruptedBackup(t *testing.T) {
+	validRecord := func(hashURL string) string {
+		data, err := encodeRecord(&CreateShortBackupEvent{ShortURL: hashURL, OriginalURL: "https://yandex.ru/" + hashURL, UserID: "owner"})
+		require.NoError(t, err)
+		return string(data)
+	}
+	corrupted := strings.Replace(validRecord("corrupted"), "corrupted", "c0rrupted", 1)
+
+	tests := []struct {
+		name          string
+		content       string
+		wantFound     []string
+		wantNotFound  []string
+		wantSkipped   int64
+		wantTruncated bool
+		wantTail      string
+	}{
+		{
+			name:        "Legacy records without envelope are restored",
+			content:     `{"uuid":"1","short_url":"legacy","original_url":"https://yandex.ru/legacy","user_id":"owner"}` + "\n",
+			wantFound:   []string{"legacy"},
+			wantSkipped: 0,
+		},
+		{
+			name:         "Record with wrong checksum is quarantined",
+			content:      validRecord("first") + "\n" + corrupted + "\n" + validRecord("second") + "\n",
+			wantFound:    []string{"first", "second"},
+			wantNotFound: []string{"corrupted", "c0rrupted"},
+			wantSkipped:  1,
+		},
+		{
+			name:          "Torn record at the end is truncated",
+			content:       validRecord("first") + "\n" + validRecord("torn")[:20],
+			wantFound:     []string{"first"},
+			wantNotFound:  []string{"torn"},
+			wantSkipped:   1,
+			wantTruncated: true,
+		},
+		{
+			name:      "Complete record without trailing newline is restored",
+			content:   validRecord("first") + "\n" + validRecord("last"),
+			wantFound: []string{"first", "last"},
+			wantTail:  validRecord("first") + "\n" + validRecord("last") + "\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+			require.NoError(t, os.WriteFile(cfg.StorageFilePath, []byte(test.content), 0666))
+
+			s := NewInMemoryRepository(cfg)
+			defer func() {
+				_ = s.Close()
+			}()
+			for _, hashURL := range test.wantFound {
+				_, err := s.FindByHash(context.Background(), hashURL)
+				assert.NoError(t, err, hashURL)
+			}
+			for _, hashURL := range test.wantNotFound {
+				_, err := s.FindByHash(context.Background(), hashURL)
+				assert.Error(t, err, hashURL)
+			}
+
+			summary := s.RecoverySummary()
+			assert.Equal(t, int64(len(test.wantFound)), summary.Restored)
+			assert.Equal(t, test.wantSkipped, summary.Skipped)
+			assert.Equal(t, test.wantTruncated, summary.TruncatedBytes > 0)
+			if test.wantSkipped > 0 {
+				assert.Equal(t, cfg.StorageFilePath+quarantineSuffix, summary.QuarantineFile)
+				quarantine, err := os.ReadFile(summary.QuarantineFile)
+				require.NoError(t, err)
+				assert.Equal(t, int(test.wantSkipped), strings.Count(string(quarantine), "\n"))
+			} else {
+				assert.Empty(t, summary.QuarantineFile)
+			}
+			if test.wantTail != "" {
+				tail, err := os.ReadFile(cfg.StorageFilePath)
+				require.NoError(t, err)
+				assert.Equal(t, test.wantTail, string(tail))
+			}
+
+			// Новые записи должны дописываться после восстановленных, не склеиваясь с ними.
+			require.NoError(t, s.Save(context.Background(), "after", "https://yandex.ru/after", "owner"))
+			require.NoError(t, s.Close())
+			recovered := NewInMemoryRepository(cfg)
+			defer func() {
+				_ = recovered.Close()
+			}()
+			assert.Equal(t, int64(0), recovered.RecoverySummary().Skipped)
+			_, err := recovered.FindByHash(context.Background(), "after")
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestInMemoryStorageHashCollision(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	s := NewInMemoryRepository(cfg)
+	defer func() {
+		_ = s.Close()
+	}()
+	require.NoError(t, s.Save(context.Background(), "code", "https://yandex.ru/first", "user1"))
+
+	t.Run("Same URL of another user", func(t *testing.T) {
+		err := s.Save(context.Background(), "code", "https://yandex.ru/first", "user2")
+		require.ErrorIs(t, err, repository.ErrHashCollision, "codes must not be shared between users")
+		storedHash, err := s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: "code", OriginalURL: "https://yandex.ru/first"}, "user1")
+		require.ErrorIs(t, err, repository.ErrURLAlreadyShortened)
+		assert.Equal(t, "code", storedHash)
+	})
+	t.Run("Save with another URL", func(t *testing.T) {
+		err := s.Save(context.Background(), "code", "https://yandex.ru/second", "user2")
+		require.ErrorIs(t, err, repository.ErrHashCollision)
+	})
+	t.Run("SaveLink with another URL", func(t *testing.T) {
+		_, err := s.SaveLink(context.Background(), model.CreateShortDTO{HashURL: "code", OriginalURL: "https://yandex.ru/second"}, "user2")
+		var collision *repository.HashCollisionError
+		require.ErrorAs(t, err, &collision)
+		assert.Equal(t, "code", collision.HashURL)
+	})
+	t.Run("SaveAll with a collision inside the batch", func(t *testing.T) {
+		err := s.SaveAll(context.Background(), map[string]model.CreateShortDTO{
+			"1": {HashURL: "batch", OriginalURL: "https://yandex.ru/third"},
+			"2": {HashURL: "batch", OriginalURL: "https://yandex.ru/fourth"},
+		}, "user2")
+		require.ErrorIs(t, err, repository.ErrHashCollision)
+		exists, err := s.ExistsByHash(context.Background(), "batch")
+		require.NoError(t, err)
+		assert.False(t, exists, "batch must not be saved partially")
+	})
+
+	found, err := s.FindByHash(context.Background(), "code")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru/first", found)
+}
+
+func TestInMemoryStorageAdmin(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	s := NewInMemoryRepository(cfg)
+	for hashURL, originalURL := range map[string]string{
+		"root":   "https://evil.com",
+		"sub":    "https://login.EVIL.com/path",
+		"query":  "https://good.com/?next=evil.com",
+		"suffix": "https://notevil.com",
+	} {
+		require.NoError(t, s.Save(context.Background(), hashURL, originalURL, "alice"))
+	}
+	require.NoError(t, s.Save(context.Background(), "bob-sub", "https://login.EVIL.com/path", "bob"))
+
+	links, err := s.SearchLinks(context.Background(), model.AdminLinkFilter{URL: "EVIL.COM", UserID: "bob"})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "bob-sub", links[0].HashURL)
+	assert.Equal(t, "http://localhost/bob-sub", links[0].ShortURL)
+	assert.Equal(t, []string{"bob"}, links[0].Owners)
+	links, err = s.SearchLinks(context.Background(), model.AdminLinkFilter{URL: "evil.com", Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, links, 2)
+
+	changed, err := s.DisableLinksByDomain(context.Background(), "evil.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob-sub", "root", "sub"}, changed)
+	_, err = s.FindByHash(context.Background(), "sub")
+	require.ErrorIs(t, err, repository.ErrRecordDisabled)
+	_, err = s.FindByHash(context.Background(), "query")
+	require.NoError(t, err, "a domain in the query must not match")
+
+	changed, err = s.SetLinksDisabled(context.Background(), []string{"root", "missing"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"root"}, changed)
+	changed, err = s.SetLinksDisabled(context.Background(), []string{"root"}, false)
+	require.NoError(t, err)
+	assert.Empty(t, changed, "an enabled link must not be reported as changed")
+
+	for _, action := range []string{"first", "second"} {
+		require.NoError(t, s.SaveAuditEntry(context.Background(), model.AdminAuditEntry{Action: action, Target: "sub", CreatedAt: time.Now()}))
+	}
+	require.NoError(t, s.Close())
+
+	assertRecovered := func(r *Repository) {
+		_, err := r.FindByHash(context.Background(), "sub")
+		require.ErrorIs(t, err, repository.ErrRecordDisabled)
+		_, err = r.FindByHash(context.Background(), "root")
+		require.NoError(t, err)
+		entries, err := r.FindAuditEntries(context.Background(), 1)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "second", entries[0].Action, "the newest entry must go first")
+	}
+	recovered := NewInMemoryRepository(cfg)
+	assertRecovered(recovered)
+	require.NoError(t, recovered.Compact())
+	require.NoError(t, recovered.Close())
+
+	compacted := NewInMemoryRepository(cfg)
+	defer func() {
+		_ = compacted.Close()
+	}()
+	assertRecovered(compacted)
+}
+
+func TestInMemoryStorageAPIKeys(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	s := NewInMemoryRepository(cfg)
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"second", "first", "other"} {
+		userID := "alice"
+		if id == "other" {
+			userID = "bob"
+		}
+		require.NoError(t, s.SaveAPIKey(context.Background(), model.APIKey{
+			ID: id, UserID: userID, Name: id, Hash: "hash-" + id, CreatedAt: createdAt.Add(-time.Duration(i) * time.Hour),
+		}))
+	}
+
+	_, err := s.RevokeAPIKey(context.Background(), "other", "alice", time.Now())
+	require.ErrorIs(t, err, repository.ErrAPIKeyNotFound, "a key of another user must not be revoked")
+	revokedAt := createdAt.Add(time.Hour)
+	revoked, err := s.RevokeAPIKey(context.Background(), "second", "alice", revokedAt)
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	revoked, err = s.RevokeAPIKey(context.Background(), "second", "alice", revokedAt.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, revokedAt, *revoked.RevokedAt, "a repeated revocation must keep the first moment")
+	require.NoError(t, s.Close())
+
+	assertRecovered := func(r *Repository) {
+		keys, err := r.FindAPIKeysByUserID(context.Background(), "alice")
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, "first", keys[0].ID, "keys must be ordered by creation time")
+		assert.Nil(t, keys[0].RevokedAt)
+		require.NotNil(t, keys[1].RevokedAt)
+		assert.True(t, revokedAt.Equal(*keys[1].RevokedAt))
+		key, err := r.FindAPIKeyByHash(context.Background(), "hash-other")
+		require.NoError(t, err)
+		assert.Equal(t, "bob", key.UserID)
+		_, err = r.FindAPIKeyByHash(context.Background(), "missing")
+		require.ErrorIs(t, err, repository.ErrAPIKeyNotFound)
+	}
+	recovered := NewInMemoryRepository(cfg)
+	assertRecovered(recovered)
+	require.NoError(t, recovered.Compact())
+	require.NoError(t, recovered.Close())
+
+	compacted := NewInMemoryRepository(cfg)
+	defer func() {
+		_ = compacted.Close()
+	}()
+	assertRecovered(compacted)
+}
@@ -0,0 +1,259 @@
+package inmemory
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotSuffix — суффикс, добавляемый к пути журнала для получения пути к снимку.
+const snapshotSuffix = ".snapshot"
+
+// enqueueCompaction ставит в очередь запрос на сжатие бэкапа.
+// Запрос выполняется после записи всех событий, поставленных в очередь раньше него.
+//
+// Параметр:
+//   - snapshot: события, полностью описывающие текущее состояние хранилища.
+//
+// Возвращает:
+//   - <-chan error: канал, в который будет передан результат сжатия.
+func (p *Backup) enqueueCompaction(snapshot []*CreateShortBackupEvent) <-chan error {
+	return p.send(backupRequest{compact: true, snapshot: snapshot})
+}
+
+// TailRecords возвращает количество событий, записанных в журнал после последнего снимка.
+func (p *Backup) TailRecords() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.tailRecords.Load()
+}
+
+// compact атомарно записывает новый снимок и заменяет журнал событий пустым.
+// Вызывается только из горутины run.
+//
+// Порядок действий обеспечивает целостность при аварийном завершении на любом шаге:
+// снимок сначала пишется во временный файл и переименовывается, и лишь затем журнал
+// заменяется пустым. Если процесс упадёт между этими шагами, при восстановлении
+// старый журнал будет применён поверх нового снимка, что безопасно.
+func (p *Backup) compact(snapshot []*CreateShortBackupEvent) error {
+	if err := p.writer.Flush(); err != nil {
+		return fmt.Errorf("backup.compact: flush tail: %w", err)
+	}
+	err := writeFileAtomically(p.snapshotPath, func(w *bufio.Writer) error {
+		for _, event := range snapshot {
+			if err := writeEvent(w, event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("backup.compact: write snapshot: %w", err)
+	}
+	if err = writeFileAtomically(p.path, nil); err != nil {
+		return fmt.Errorf("backup.compact: reset tail: %w", err)
+	}
+	file, err := os.OpenFile(p.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return fmt.Errorf("backup.compact: reopen tail: %w", err)
+	}
+	_ = p.file.Close()
+	p.file = file
+	p.writer.Reset(file)
+	p.tailRecords.Store(0)
+	p.dirty = false
+	return nil
+}
+
+// writeFileAtomically записывает файл через временный файл в том же каталоге,
+// fsync и rename, так что по пути path всегда лежит либо старая, либо полностью новая версия.
+//
+// Параметры:
+//   - path: путь к итоговому файлу.
+//   - write: функция, записывающая содержимое; nil — пустой файл.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку.
+func writeFileAtomically(path string, write func(w *bufio.Writer) error) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	w := bufio.NewWriter(tmp)
+	if write != nil {
+		if err = write(w); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("flush temp file: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("sync temp file: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+// syncDir сбрасывает на диск метаданные каталога, чтобы rename пережил сбой питания.
+// Ошибки игнорируются: не все платформы поддерживают fsync каталога.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
+
+// Compact записывает сжатый снимок текущего состояния хранилища и начинает новый журнал событий.
+//
+// В снимок попадает по одному событию на каждую пару (ссылка, владелец), событие без владельца
+// для ссылок, у которых владельцев не осталось, событие удаления без пользователя
+// на каждую удалённую ссылку, событие отключения на каждую отключённую ссылку и записи
+// журнала администраторов; дубли отбрасываются. Истёкшие ссылки, в том числе ещё не удалённые
+// очисткой, записываются только надгробиями, без оригинального URL и владельцев.
+// На время сжатия изменения хранилища блокируются.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) Compact() error {
+	r.mu.RLock()
+	result := r.bkp.enqueueCompaction(r.snapshotEvents())
+	err := <-result
+	r.mu.RUnlock()
+	return err
+}
+
+// snapshotEvents формирует события, полностью описывающие текущее состояние хранилища.
+// Истёкшие ссылки записываются надгробиями, как если бы очистка уже была выполнена.
+// Вызывающий код должен удерживать r.mu.
+func (r *Repository) snapshotEvents() []*CreateShortBackupEvent {
+	now := time.Now()
+	expired := func(hashURL string) bool {
+		expiry, ok := r.expiryBucket[hashURL]
+		return ok && !expiry.After(now)
+	}
+	events := make([]*CreateShortBackupEvent, 0, len(r.urlBucket)+len(r.deleted)+len(r.expired))
+	for hashURL, originalURL := range r.urlBucket {
+		if expired(hashURL) {
+			events = append(events, &CreateShortBackupEvent{ShortURL: hashURL, Expired: true})
+			continue
+		}
+		var expiresAt *time.Time
+		if expiry, ok := r.expiryBucket[hashURL]; ok {
+			expiresAt = &expiry
+		}
+		if len(r.owners[hashURL]) == 0 {
+			events = append(events, &CreateShortBackupEvent{ShortURL: hashURL, OriginalURL: originalURL, ExpiresAt: expiresAt})
+			continue
+		}
+		for userID := range r.owners[hashURL] {
+			events = append(events, &CreateShortBackupEvent{
+				ShortURL: hashURL, OriginalURL: originalURL, UserID: userID, ExpiresAt: expiresAt,
+			})
+		}
+	}
+	for hashURL := range r.expired {
+		if _, exists := r.urlBucket[hashURL]; !exists {
+			events = append(events, &CreateShortBackupEvent{ShortURL: hashURL, Expired: true})
+		}
+	}
+	for hashURL := range r.deleted {
+		if expired(hashURL) {
+			continue
+		}
+		events = append(events, &CreateShortBackupEvent{ShortURL: hashURL, Deleted: true})
+	}
+	disabled := true
+	for hashURL := range r.disabled {
+		if expired(hashURL) {
+			continue
+		}
+		events = append(events, &CreateShortBackupEvent{ShortURL: hashURL, Disabled: &disabled})
+	}
+	for i := range r.audit {
+		events = append(events, &CreateShortBackupEvent{Audit: &r.audit[i]})
+	}
+	for _, key := range r.apiKeys {
+		events = append(events, &CreateShortBackupEvent{APIKey: key})
+	}
+	return events
+}
+
+// Compactor — фоновая задача, периодически сжимающая файл бэкапа InMemory-репозитория.
+type Compactor struct {
+	repository *Repository   // Репозиторий, бэкап которого сжимается
+	interval   time.Duration // Период проверки необходимости сжатия
+	threshold  int64         // Минимальное количество событий в журнале для запуска сжатия
+}
+
+// Run периодически сжимает бэкап и блокируется до отмены контекста.
+//
+// Сжатие выполняется, только если в журнале накопилось не меньше threshold событий.
+// Ошибки сжатия логируются и не прерывают работу.
+//
+// Параметры:
+//   - ctx: контекст, при отмене которого задача завершается.
+//
+// Возвращает:
+//   - error: всегда nil, чтобы корректно завершаться в составе errgroup.
+func (c *Compactor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Log.Info("backup compactor stopped")
+			return nil
+		case <-ticker.C:
+			c.compactIfNeeded()
+		}
+	}
+}
+
+// compactIfNeeded выполняет сжатие, если журнал событий превысил порог.
+func (c *Compactor) compactIfNeeded() {
+	tail := c.repository.bkp.TailRecords()
+	if tail < c.threshold {
+		return
+	}
+	if err := c.repository.Compact(); err != nil {
+		logger.Log.Error("couldn't compact backup", zap.Error(err))
+		return
+	}
+	logger.Log.Info("compacted backup", zap.Int64("tailRecords", tail))
+}
+
+// NewCompactor создаёт фоновую задачу сжатия бэкапа.
+//
+// Параметры:
+//   - r: InMemory-репозиторий.
+//   - interval: период проверки необходимости сжатия.
+//   - threshold: минимальное количество событий в журнале для запуска сжатия.
+//
+// Возвращает:
+//   - *Compactor: готовая к запуску задача.
+func NewCompactor(r *Repository, interval time.Duration, threshold int64) *Compactor {
+	return &Compactor{repository: r, interval: interval, threshold: threshold}
+}
@@ -0,0 +1,93 @@
+package inmemory
+
+import (
+	"github.com/faust8888/shortener/internal/app/model"
+	"sort"
+)
+
+// linkStats — агрегированная статистика переходов по одной короткой ссылке.
+type linkStats struct {
+	total      int64                  // Общее количество переходов
+	visitors   map[string]struct{}    // Хэши IP всех посетителей
+	daily      map[string]*dailyStats // Дата (UTC) → статистика за день
+	referrers  map[string]int64       // Referrer → количество переходов
+	userAgents map[string]int64       // User-Agent → количество переходов
+}
+
+// dailyStats — статистика переходов по ссылке за один день.
+type dailyStats struct {
+	clicks   int64               // Количество переходов
+	visitors map[string]struct{} // Хэши IP посетителей за день
+}
+
+// newLinkStats создаёт пустую статистику ссылки.
+func newLinkStats() *linkStats {
+	return &linkStats{
+		visitors:   make(map[string]struct{}),
+		daily:      make(map[string]*dailyStats),
+		referrers:  make(map[string]int64),
+		userAgents: make(map[string]int64),
+	}
+}
+
+// add учитывает одно событие перехода.
+func (s *linkStats) add(click model.ClickEvent) {
+	s.total++
+	day := click.Timestamp.UTC().Format(model.ClickDateLayout)
+	d, exists := s.daily[day]
+	if !exists {
+		d = &dailyStats{visitors: make(map[string]struct{})}
+		s.daily[day] = d
+	}
+	d.clicks++
+	if click.IPHash != "" {
+		s.visitors[click.IPHash] = struct{}{}
+		d.visitors[click.IPHash] = struct{}{}
+	}
+	if click.Referrer != "" {
+		s.referrers[click.Referrer]++
+	}
+	if click.UserAgent != "" {
+		s.userAgents[click.UserAgent]++
+	}
+}
+
+// response формирует модель ответа по накопленной статистике.
+func (s *linkStats) response() model.LinkStatsResponse {
+	resp := model.LinkStatsResponse{
+		TotalClicks:    s.total,
+		UniqueVisitors: int64(len(s.visitors)),
+		Daily:          make([]model.DailyClicksResponse, 0, len(s.daily)),
+		Referrers:      topClickSources(s.referrers),
+		UserAgents:     topClickSources(s.userAgents),
+	}
+	for day, d := range s.daily {
+		resp.Daily = append(resp.Daily, model.DailyClicksResponse{
+			Date:           day,
+			Clicks:         d.clicks,
+			UniqueVisitors: int64(len(d.visitors)),
+		})
+	}
+	sort.Slice(resp.Daily, func(i, j int) bool {
+		return resp.Daily[i].Date < resp.Daily[j].Date
+	})
+	return resp
+}
+
+// topClickSources возвращает не более model.MaxClickSources источников в порядке убывания количества переходов.
+func topClickSources(sources map[string]int64) []model.ClickSourceResponse {
+	result := make([]model.ClickSourceResponse, 0, len(sources))
+	for value, clicks := range sources {
+		result = append(result, model.ClickSourceResponse{Value: value, Clicks: clicks})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Clicks != result[j].Clicks {
+			return result[i].Clicks > result[j].Clicks
+		}
+		return result[i].Value < result[j].Value
+	})
+	if len(result) > model.MaxClickSources {
+		result = result[:model.MaxClickSources]
+	}
+	return result
+}
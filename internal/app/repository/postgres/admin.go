@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"github.com/lib/pq"
+	"time"
+)
+
+// SearchLinks ищет ссылки, удовлетворяющие всем заданным условиям фильтра.
+// Подстрока URL ищется без учёта регистра; ссылки возвращаются в порядке создания.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - filter: условия поиска; пустые условия не применяются.
+//
+// Возвращает:
+//   - []model.AdminLink: найденные ссылки (не больше filter.Limit).
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.AdminLink, error) {
+	defer metrics.ObserveStorage(storageBackend, "SearchLinks", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".SearchLinks")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	query := `
+        SELECT s.short_url, s.full_url, s.is_deleted, s.is_disabled, s.expires_at,
+               ARRAY(SELECT l.user_id FROM user_links l WHERE l.short_url = s.short_url ORDER BY l.user_id)
+        FROM shortener s
+        WHERE ($1 = '' OR s.short_url = $1)
+          AND ($2 = '' OR strpos(lower(s.full_url), lower($2)) > 0)
+          AND ($3 = '' OR EXISTS (SELECT 1 FROM user_links l WHERE l.short_url = s.short_url AND l.user_id = $3))
+        ORDER BY s.id
+        LIMIT NULLIF($4, 0)
+    `
+	rows, err := r.db.QueryContext(ctx, query, filter.HashURL, filter.URL, filter.UserID, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.repository.SearchLinks: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]model.AdminLink, 0)
+	for rows.Next() {
+		var link model.AdminLink
+		if err = rows.Scan(&link.HashURL, &link.OriginalURL, &link.Deleted, &link.Disabled, &link.ExpiresAt,
+			pq.Array(&link.Owners)); err != nil {
+			return nil, fmt.Errorf("postgres.repository.SearchLinks: failed to scan row: %w", err)
+		}
+		link.ShortURL = fmt.Sprintf("%s/%s", r.baseShortURL, link.HashURL)
+		result = append(result, link)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres.repository.SearchLinks: error during row iteration: %w", err)
+	}
+	return result, nil
+}
+
+// SetLinksDisabled отключает или снова включает ссылки (флаг is_disabled).
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURLs: коды ссылок.
+//   - disabled: true — отключить, false — включить.
+//
+// Возвращает:
+//   - []string: коды ссылок, состояние которых изменилось.
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) SetLinksDisabled(ctx context.Context, hashURLs []string, disabled bool) ([]string, error) {
+	defer metrics.ObserveStorage(storageBackend, "SetLinksDisabled", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".SetLinksDisabled")
+	defer span.End()
+	if len(hashURLs) == 0 {
+		return []string{}, nil
+	}
+	ctx, cancel := r.withBulkTimeout(ctx)
+	defer cancel()
+	changed, err := r.updateDisabled(ctx, hashURLs, disabled)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.repository.SetLinksDisabled: %w", err)
+	}
+	return changed, nil
+}
+
+// DisableLinksByDomain отключает все ссылки, ведущие на домен или его поддомены.
+//
+// Кандидаты отбираются по вхождению домена в full_url, а хост каждого из них проверяется
+// repository.MatchesDomain, чтобы домен в пути или параметрах URL не приводил к отключению.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - domain: домен в нижнем регистре.
+//
+// Возвращает:
+//   - []string: коды отключённых ссылок.
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) DisableLinksByDomain(ctx context.Context, domain string) ([]string, error) {
+	defer metrics.ObserveStorage(storageBackend, "DisableLinksByDomain", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".DisableLinksByDomain")
+	defer span.End()
+	ctx, cancel := r.withBulkTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT short_url, full_url FROM shortener WHERE NOT is_disabled AND strpos(lower(full_url), $1) > 0", domain)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.repository.DisableLinksByDomain: %w", err)
+	}
+	defer rows.Close()
+
+	matched := make([]string, 0)
+	for rows.Next() {
+		var hashURL, fullURL string
+		if err = rows.Scan(&hashURL, &fullURL); err != nil {
+			return nil, fmt.Errorf("postgres.repository.DisableLinksByDomain: failed to scan row: %w", err)
+		}
+		if repository.MatchesDomain(fullURL, domain) {
+			matched = append(matched, hashURL)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres.repository.DisableLinksByDomain: error during row iteration: %w", err)
+	}
+	if len(matched) == 0 {
+		return matched, nil
+	}
+	changed, err := r.updateDisabled(ctx, matched, true)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.repository.DisableLinksByDomain: %w", err)
+	}
+	return changed, nil
+}
+
+// SaveAuditEntry сохраняет запись журнала действий администраторов в таблицу admin_audit_log.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - entry: запись журнала.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) SaveAuditEntry(ctx context.Context, entry model.AdminAuditEntry) error {
+	defer metrics.ObserveStorage(storageBackend, "SaveAuditEntry", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".SaveAuditEntry")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO admin_audit_log (action, actor, target, short_urls, reason, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, entry.Action, entry.Actor, entry.Target, pq.Array(entry.HashURLs), entry.Reason, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("postgres.repository.SaveAuditEntry: %w", err)
+	}
+	return nil
+}
+
+// FindAuditEntries возвращает последние записи журнала действий администраторов, начиная с новых.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - limit: максимальное количество записей; неположительное значение — без ограничения.
+//
+// Возвращает:
+//   - []model.AdminAuditEntry: записи журнала.
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) FindAuditEntries(ctx context.Context, limit int) ([]model.AdminAuditEntry, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindAuditEntries", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".FindAuditEntries")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT action, actor, target, short_urls, reason, created_at
+        FROM admin_audit_log
+        ORDER BY id DESC
+        LIMIT NULLIF($1, 0)
+    `, max(limit, 0))
+	if err != nil {
+		return nil, fmt.Errorf("postgres.repository.FindAuditEntries: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]model.AdminAuditEntry, 0)
+	for rows.Next() {
+		var entry model.AdminAuditEntry
+		if err = rows.Scan(&entry.Action, &entry.Actor, &entry.Target, pq.Array(&entry.HashURLs),
+			&entry.Reason, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("postgres.repository.FindAuditEntries: failed to scan row: %w", err)
+		}
+		result = append(result, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres.repository.FindAuditEntries: error during row iteration: %w", err)
+	}
+	return result, nil
+}
+
+// updateDisabled меняет флаг is_disabled ссылок, у которых он отличается от нужного.
+//
+// Возвращает:
+//   - []string: коды ссылок, состояние которых изменилось.
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) updateDisabled(ctx context.Context, hashURLs []string, disabled bool) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        UPDATE shortener SET is_disabled = $2
+        WHERE short_url = ANY($1::text[]) AND is_disabled <> $2
+        RETURNING short_url
+    `, pq.Array(hashURLs), disabled)
+	if err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+	defer rows.Close()
+
+	changed := make([]string, 0, len(hashURLs))
+	for rows.Next() {
+		var hashURL string
+		if err = rows.Scan(&hashURL); err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+		changed = append(changed, hashURL)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate: %w", err)
+	}
+	return changed, nil
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"time"
+)
+
+// apiKeyColumns — столбцы таблицы api_keys в порядке полей, которые заполняет scanAPIKey.
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, created_at, revoked_at"
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// SaveAPIKey сохраняет новый ключ API.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - key: ключ с заполненными ID, UserID, Hash и CreatedAt.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) SaveAPIKey(ctx context.Context, key model.APIKey) error {
+	defer metrics.ObserveStorage(storageBackend, "SaveAPIKey", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".SaveAPIKey")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO api_keys (`+apiKeyColumns+`)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, key.ID, key.UserID, key.Name, key.Prefix, key.Hash, key.CreatedAt, key.RevokedAt)
+	if err != nil {
+		return fmt.Errorf("postgres.repository.SaveAPIKey: %w", err)
+	}
+	return nil
+}
+
+// FindAPIKeysByUserID возвращает ключи API пользователя, включая отозванные, в порядке создания.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - []model.APIKey: ключи пользователя.
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) FindAPIKeysByUserID(ctx context.Context, userID string) ([]model.APIKey, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindAPIKeysByUserID", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".FindAPIKeysByUserID")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+apiKeyColumns+`
+        FROM api_keys
+        WHERE user_id = $1
+        ORDER BY created_at, id
+    `, userID)
+	if err != nil {
+		return nil, fmt.Errorf("postgres.repository.FindAPIKeysByUserID: %w", err)
+	}
+	defer rows.Close()
+
+	result := make([]model.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("postgres.repository.FindAPIKeysByUserID: failed to scan row: %w", err)
+		}
+		result = append(result, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres.repository.FindAPIKeysByUserID: error during row iteration: %w", err)
+	}
+	return result, nil
+}
+
+// FindAPIKeyByHash находит ключ API по отпечатку.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hash: отпечаток ключа.
+//
+// Возвращает:
+//   - model.APIKey: найденный ключ (в том числе отозванный).
+//   - error: nil, если найдено; repository.ErrAPIKeyNotFound, если ключа нет; иначе — ошибку.
+func (r *Repository) FindAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindAPIKeyByHash", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".FindAPIKeyByHash")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, `
+        SELECT `+apiKeyColumns+`
+        FROM api_keys
+        WHERE key_hash = $1
+    `, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.APIKey{}, repository.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return model.APIKey{}, fmt.Errorf("postgres.repository.FindAPIKeyByHash: %w", err)
+	}
+	return key, nil
+}
+
+// RevokeAPIKey отзывает ключ API пользователя. Повторный отзыв не меняет момент отзыва.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - id: идентификатор ключа.
+//   - userID: идентификатор владельца.
+//   - revokedAt: момент отзыва.
+//
+// Возвращает:
+//   - model.APIKey: отозванный ключ.
+//   - error: nil, если успешно; repository.ErrAPIKeyNotFound, если у пользователя нет такого ключа;
+//     иначе — ошибку.
+func (r *Repository) RevokeAPIKey(ctx context.Context, id, userID string, revokedAt time.Time) (model.APIKey, error) {
+	defer metrics.ObserveStorage(storageBackend, "RevokeAPIKey", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".RevokeAPIKey")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, `
+        UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $3)
+        WHERE id = $1 AND user_id = $2
+        RETURNING `+apiKeyColumns, id, userID, revokedAt))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.APIKey{}, repository.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return model.APIKey{}, fmt.Errorf("postgres.repository.RevokeAPIKey: %w", err)
+	}
+	return key, nil
+}
+
+// scanAPIKey читает ключ API из строки результата со столбцами apiKeyColumns.
+func scanAPIKey(row rowScanner) (model.APIKey, error) {
+	var key model.APIKey
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &key.CreatedAt, &key.RevokedAt)
+	return key, err
+}
@@ -7,100 +7,205 @@ import (
 	"fmt"
 	"github.com/faust8888/shortener/internal/app/config"
 	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/lib/pq"
+	"sort"
 	"time"
 )
 
-// ErrUniqueIndexConstraint — ошибка, возникающая при попытке дублирования записи по full_url.
-var ErrUniqueIndexConstraint = errors.New("full_url unique index constraint violation")
+// ErrUniqueIndexConstraint — ошибка, возникающая при повторном сокращении URL тем же пользователем.
+//
+// Deprecated: используйте repository.ErrURLAlreadyShortened.
+var ErrUniqueIndexConstraint = repository.ErrURLAlreadyShortened
+
+const (
+	// defaultQueryTimeout — таймаут одного запроса, если он не задан в конфигурации.
+	defaultQueryTimeout = time.Second
+	// bulkTimeoutFactor — во сколько раз таймаут пакетных операций больше таймаута одного запроса.
+	bulkTimeoutFactor = 5
+)
 
-// ErrRecordWasMarkedAsDeleted — ошибка, указывающая, что запись была помечена как удалённая.
-var ErrRecordWasMarkedAsDeleted = errors.New("is_deleted is true for the record")
+// storageBackend — значение метки backend в метриках операций хранилища.
+const storageBackend = "postgres"
+
+const (
+	// uniqueViolationCode — код ошибки PostgreSQL при нарушении уникального индекса.
+	uniqueViolationCode = "23505"
+	// shortURLUniqueIndex — имя уникального индекса по short_url.
+	shortURLUniqueIndex = "unique_short_url_index"
+)
 
 // Repository — реализация repository.Repository на основе PostgreSQL.
 // Используется для хранения, поиска и удаления коротких ссылок в БД.
 type Repository struct {
-	db           *sql.DB // Подключение к базе данных
-	baseShortURL string  // Базовый URL для формирования полного адреса
+	db           *sql.DB       // Подключение к базе данных
+	baseShortURL string        // Базовый URL для формирования полного адреса
+	queryTimeout time.Duration // Таймаут одного запроса
 }
 
 // Save сохраняет одну пару (hashURL -> fullURL) для указанного пользователя.
 //
-// Если запись уже существует — возвращает ErrUniqueIndexConstraint.
+// Если пользователь уже сокращал URL — возвращает repository.ErrURLAlreadyShortened,
+// если код уже занят — *repository.HashCollisionError.
 //
 // Параметры:
+//   - ctx: контекст запроса.
 //   - urlHash: хэш-ключ для короткой ссылки.
 //   - fullURL: оригинальный URL.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
 //   - error: nil, если успешно, иначе — ошибку.
-func (r *Repository) Save(urlHash string, fullURL string, userID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (r *Repository) Save(ctx context.Context, urlHash string, fullURL string, userID string) error {
+	_, err := r.SaveLink(ctx, model.CreateShortDTO{HashURL: urlHash, OriginalURL: fullURL}, userID)
+	return err
+}
+
+// SaveLink сохраняет ссылку с алиасом и/или сроком жизни.
+//
+// Выполняется в транзакции: если задан алиас, сначала проверяется, что он свободен, затем вставляется запись
+// и пользователь становится владельцем ссылки. Коды не делятся между пользователями: существующий код
+// возвращается, только если этот же пользователь уже сокращал full_url и код действует
+// (не удалён, не отключён и бессрочен), а новая ссылка тоже бессрочна и без алиаса. Иначе создаётся новый код.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - link: DTO с данными о ссылке.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - string: ключ, под которым хранится ссылка.
+//   - error: nil, если успешно; repository.ErrAliasAlreadyTaken, если алиас занят;
+//     repository.ErrURLAlreadyShortened, если пользователь уже сокращал URL;
+//     *repository.HashCollisionError, если код уже занят; иначе — ошибку.
+func (r *Repository) SaveLink(ctx context.Context, link model.CreateShortDTO, userID string) (string, error) {
+	defer metrics.ObserveStorage(storageBackend, "SaveLink", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".SaveLink")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
-	res, err := r.db.ExecContext(ctx,
-		"INSERT INTO shortener (short_url, full_url, user_id) VALUES ($1, $2, $3) ON CONFLICT (full_url) DO NOTHING", urlHash, fullURL, userID)
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("repository.postgres.save: %w", err)
+		return "", fmt.Errorf("postgres.repository.saveLink.begin - %w", err)
 	}
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return ErrUniqueIndexConstraint
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	storedHash, linked, err := saveUserLink(ctx, tx, link, userID)
+	if err != nil {
+		return "", fmt.Errorf("postgres.repository.saveLink: %w", err)
 	}
-	return nil
+	if err = tx.Commit(); err != nil {
+		return "", fmt.Errorf("postgres.repository.saveLink.commit - %w", err)
+	}
+	if !linked {
+		return storedHash, repository.ErrURLAlreadyShortened
+	}
+	return storedHash, nil
 }
 
 // FindByHash находит оригинальный URL по его хэш-ключу.
 //
-// Также проверяет флаг is_deleted — если он установлен, возвращает ErrRecordWasMarkedAsDeleted.
+// Также проверяет флаг is_deleted — если он установлен, возвращает repository.ErrRecordDeleted,
+// флаг is_disabled — если он установлен, возвращает repository.ErrRecordDisabled,
+// и срок жизни — если он истёк, возвращает repository.ErrRecordExpired.
 //
-// Параметр:
+// Параметры:
+//   - ctx: контекст запроса.
 //   - hash: хэш-ключ короткой ссылки.
 //
 // Возвращает:
 //   - string: оригинальный URL.
-//   - error: nil, если найдено и не удалено, иначе — соответствующую ошибку.
-func (r *Repository) FindByHash(hash string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+//   - error: nil, если найдено, не удалено и не истекло, иначе — соответствующую ошибку.
+func (r *Repository) FindByHash(ctx context.Context, hash string) (string, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindByHash", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".FindByHash")
+	defer span.End()
+	fullURL, _, err := r.find(ctx, hash)
+	return fullURL, err
+}
+
+// FindByHashWithExpiry находит оригинальный URL и момент истечения ссылки по её хэш-ключу.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hash: хэш-ключ короткой ссылки.
+//
+// Возвращает:
+//   - string: оригинальный URL.
+//   - *time.Time: момент истечения; nil — ссылка бессрочная.
+//   - error: те же ошибки, что и у FindByHash.
+func (r *Repository) FindByHashWithExpiry(ctx context.Context, hash string) (string, *time.Time, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindByHashWithExpiry", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".FindByHashWithExpiry")
+	defer span.End()
+	return r.find(ctx, hash)
+}
+
+// find находит действующую ссылку по коду и её момент истечения.
+func (r *Repository) find(ctx context.Context, hash string) (string, *time.Time, error) {
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 	query := `
-        SELECT full_url, is_deleted
+        SELECT full_url, is_deleted, is_disabled, expires_at, COALESCE(expires_at <= now(), false)
         FROM shortener
         WHERE short_url = $1
     `
 	var fullURL string
 	var isDeleted bool
-	err := r.db.QueryRowContext(ctx, query, hash).Scan(&fullURL, &isDeleted)
+	var isDisabled bool
+	var expiresAt sql.NullTime
+	var isExpired bool
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(&fullURL, &isDeleted, &isDisabled, &expiresAt, &isExpired)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", fmt.Errorf("short url not found for %s", hash)
+			return "", nil, fmt.Errorf("%w for %s", repository.ErrRecordNotFound, hash)
 		}
-		return "", fmt.Errorf("failed to find short url by hash: %v", err)
+		return "", nil, fmt.Errorf("failed to find short url by hash: %v", err)
 	}
 	if isDeleted {
-		return "", ErrRecordWasMarkedAsDeleted
+		return "", nil, repository.ErrRecordDeleted
+	}
+	if isDisabled {
+		return "", nil, repository.ErrRecordDisabled
 	}
-	return fullURL, nil
+	if isExpired {
+		return "", nil, repository.ErrRecordExpired
+	}
+	if !expiresAt.Valid {
+		return fullURL, nil, nil
+	}
+	return fullURL, &expiresAt.Time, nil
 }
 
 // FindAllByUserID возвращает все короткие ссылки, принадлежащие пользователю.
 //
 // Формирует полные URL на основе baseShortURL.
 //
-// Параметр:
+// Параметры:
+//   - ctx: контекст запроса.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
 //   - []model.FindURLByUserIDResponse: список ссылок пользователя.
 //   - error: nil, если успешно, иначе — ошибку.
-func (r *Repository) FindAllByUserID(userID string) ([]model.FindURLByUserIDResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (r *Repository) FindAllByUserID(ctx context.Context, userID string) ([]model.FindURLByUserIDResponse, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindAllByUserID", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".FindAllByUserID")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 	query := `
-        SELECT full_url, short_url
-        FROM shortener
-        WHERE user_id = $1
+        SELECT s.full_url, s.short_url
+        FROM user_links l
+        JOIN shortener s ON s.short_url = l.short_url
+        WHERE l.user_id = $1
+        ORDER BY l.created_at
     `
 	rows, err := r.db.QueryContext(ctx, query, userID)
 	if err != nil {
@@ -127,70 +232,234 @@ func (r *Repository) FindAllByUserID(userID string) ([]model.FindURLByUserIDResp
 
 // SaveAll сохраняет несколько ссылок за один раз (пакетная операция).
 // Выполняется в транзакции: если одна операция провалится — всё откатывается.
+// Для элементов с алиасом предварительно проверяется, что алиас свободен.
+// Бессрочные элементы без алиаса с URL, которые пользователь уже сокращал, получают его действующий ключ
+// (как в SaveLink): HashURL и ShortURL таких элементов в batch заменяются.
+// Элементы сохраняются в порядке URL, чтобы параллельные пакеты брали блокировки URL
+// в одном порядке и не взаимоблокировались.
 //
 // Параметры:
+//   - ctx: контекст запроса.
 //   - batch: карта хэшей и DTO с данными о ссылках.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
-//   - error: nil, если успешно, иначе — ошибку.
-func (r *Repository) SaveAll(batch map[string]model.CreateShortDTO, userID string) error {
-	tx, err := r.db.Begin()
+//   - error: nil, если успешно; repository.ErrAliasAlreadyTaken, если алиас занят;
+//     *repository.HashCollisionError, если код уже занят; иначе — ошибку.
+func (r *Repository) SaveAll(ctx context.Context, batch map[string]model.CreateShortDTO, userID string) error {
+	defer metrics.ObserveStorage(storageBackend, "SaveAll", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".SaveAll")
+	defer span.End()
+	ctx, cancel := r.withBulkTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("postgres.repository.saveAll.begin - %w", err)
 	}
-	for _, batchItem := range batch {
-		_, err = tx.ExecContext(context.Background(),
-			"INSERT INTO shortener (short_url, full_url, user_id) VALUES ($1, $2, $3)", batchItem.HashURL, batchItem.OriginalURL, userID)
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	correlationIDs := make([]string, 0, len(batch))
+	for correlationID := range batch {
+		correlationIDs = append(correlationIDs, correlationID)
+	}
+	sort.Slice(correlationIDs, func(i, j int) bool {
+		return batch[correlationIDs[i]].OriginalURL < batch[correlationIDs[j]].OriginalURL
+	})
+	for _, correlationID := range correlationIDs {
+		batchItem := batch[correlationID]
+		storedHash, _, err := saveUserLink(ctx, tx, batchItem, userID)
 		if err != nil {
-			rollbackErr := tx.Rollback()
-			if rollbackErr != nil {
-				return fmt.Errorf("postgres.repository.saveAll.rollback - %w", rollbackErr)
-			}
-			return fmt.Errorf("postgres.repository.saveAll.insert: %w", err)
+			return fmt.Errorf("postgres.repository.saveAll: %w", err)
+		}
+		if storedHash != batchItem.HashURL {
+			batchItem.HashURL = storedHash
+			batchItem.ShortURL = fmt.Sprintf("%s/%s", r.baseShortURL, storedHash)
+			batch[correlationID] = batchItem
 		}
 	}
-	err = tx.Commit()
-	if err != nil {
+	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("postgres.repository.saveAll.commit - %w", err)
 	}
 	return nil
 }
 
-// DeleteAll асинхронно удаляет несколько коротких ссылок пользователя.
-// Метит их как удалённые (is_deleted = true).
+// DeleteAll удаляет несколько коротких ссылок пользователя.
+//
+// Удаляет связи пользователя со ссылками; ссылки, у которых не осталось других владельцев,
+// помечаются удалёнными (is_deleted = true).
 //
 // Параметры:
+//   - ctx: контекст запроса.
 //   - shortURLs: список идентификаторов (хэшей) ссылок для удаления.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
 //   - error: nil, если запрос успешен, иначе — ошибку.
-func (r *Repository) DeleteAll(shortURLs []string, userID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (r *Repository) DeleteAll(ctx context.Context, shortURLs []string, userID string) error {
+	return r.DeleteBatch(ctx, []model.DeletionRequest{{UserID: userID, HashURLs: shortURLs}})
+}
+
+// DeleteBatch выполняет запросы на удаление нескольких пользователей одним запросом.
+//
+// Пары (пользователь, код) передаются двумя массивами одинаковой длины: связи удаляются,
+// а ссылки, у которых не осталось других владельцев, помечаются удалёнными (is_deleted = true).
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - requests: запросы на удаление.
+//
+// Возвращает:
+//   - error: nil, если запрос успешен, иначе — ошибку.
+func (r *Repository) DeleteBatch(ctx context.Context, requests []model.DeletionRequest) error {
+	defer metrics.ObserveStorage(storageBackend, "DeleteBatch", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".DeleteBatch")
+	defer span.End()
+	userIDs := make([]string, 0, len(requests))
+	shortURLs := make([]string, 0, len(requests))
+	for _, request := range requests {
+		for _, hashURL := range request.HashURLs {
+			userIDs = append(userIDs, request.UserID)
+			shortURLs = append(shortURLs, hashURL)
+		}
+	}
+	if len(shortURLs) == 0 {
+		return nil
+	}
+	ctx, cancel := r.withBulkTimeout(ctx)
 	defer cancel()
 	query := `
-        UPDATE shortener SET is_deleted = true 
-        WHERE user_id = $1 AND short_url = ANY($2::text[])
+        WITH requested AS (
+            SELECT * FROM unnest($1::text[], $2::text[]) AS r(user_id, short_url)
+        ), unlinked AS (
+            DELETE FROM user_links l USING requested q
+            WHERE l.user_id = q.user_id AND l.short_url = q.short_url
+            RETURNING l.short_url
+        )
+        UPDATE shortener s SET is_deleted = true
+        WHERE s.short_url IN (SELECT short_url FROM unlinked)
+          AND NOT EXISTS (
+              SELECT 1 FROM user_links l
+              WHERE l.short_url = s.short_url
+                AND NOT EXISTS (SELECT 1 FROM requested q WHERE q.user_id = l.user_id AND q.short_url = l.short_url)
+          )
     `
-	rows, err := r.db.QueryContext(ctx, query, userID, pq.Array(shortURLs))
+	if _, err := r.db.ExecContext(ctx, query, pq.Array(userIDs), pq.Array(shortURLs)); err != nil {
+		return fmt.Errorf("postgres.repository.DeleteBatch: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired очищает ссылки, срок жизни которых истёк к моменту now: удаляет их оригинальные URL,
+// связи с пользователями и статистику переходов. Строка ссылки остаётся надгробием, поэтому
+// FindByHash продолжает возвращать repository.ErrRecordExpired, а код не выдаётся повторно.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - now: момент времени, с которым сравнивается срок жизни.
+//
+// Возвращает:
+//   - []string: коды очищенных ссылок.
+//   - error: nil, если запрос успешен, иначе — ошибку.
+func (r *Repository) DeleteExpired(ctx context.Context, now time.Time) ([]string, error) {
+	defer metrics.ObserveStorage(storageBackend, "DeleteExpired", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".DeleteExpired")
+	defer span.End()
+	ctx, cancel := r.withBulkTimeout(ctx)
+	defer cancel()
+	query := `
+        WITH expired AS (
+            UPDATE shortener SET full_url = ''
+            WHERE expires_at IS NOT NULL AND expires_at <= $1 AND full_url <> ''
+            RETURNING short_url
+        ), owners AS (
+            DELETE FROM user_links WHERE short_url IN (SELECT short_url FROM expired)
+        ), daily AS (
+            DELETE FROM link_clicks_daily WHERE short_url IN (SELECT short_url FROM expired)
+        ), visitors AS (
+            DELETE FROM link_visitors_daily WHERE short_url IN (SELECT short_url FROM expired)
+        ), sources AS (
+            DELETE FROM link_click_sources WHERE short_url IN (SELECT short_url FROM expired)
+        )
+        SELECT short_url FROM expired
+    `
+	rows, err := r.db.QueryContext(ctx, query, now)
 	if err != nil {
-		return fmt.Errorf("postgres.repository.DeleteAll: %w", err)
+		return nil, fmt.Errorf("postgres.repository.DeleteExpired: %w", err)
+	}
+	defer rows.Close()
+	deleted := make([]string, 0)
+	for rows.Next() {
+		var hashURL string
+		if err = rows.Scan(&hashURL); err != nil {
+			return nil, fmt.Errorf("postgres.repository.DeleteExpired: %w", err)
+		}
+		deleted = append(deleted, hashURL)
 	}
 	if err = rows.Err(); err != nil {
-		return fmt.Errorf("postgres.repository.DeleteAll: error during row iteration: %w", err)
+		return nil, fmt.Errorf("postgres.repository.DeleteExpired: %w", err)
 	}
-	defer rows.Close()
-	return nil
+	return deleted, nil
+}
+
+// NextID возвращает следующее значение последовательности short_url_seq для генерации кодов.
+//
+// Параметр:
+//   - ctx: контекст запроса.
+//
+// Возвращает:
+//   - int64: следующее значение последовательности.
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) NextID(ctx context.Context) (int64, error) {
+	defer metrics.ObserveStorage(storageBackend, "NextID", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".NextID")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var id int64
+	if err := r.db.QueryRowContext(ctx, "SELECT nextval('short_url_seq')").Scan(&id); err != nil {
+		return 0, fmt.Errorf("postgres.repository.NextID: %w", err)
+	}
+	return id, nil
+}
+
+// ExistsByHash проверяет, используется ли уже код короткой ссылки (в том числе удалённой).
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURL: код короткой ссылки.
+//
+// Возвращает:
+//   - bool: true, если код занят.
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) ExistsByHash(ctx context.Context, hashURL string) (bool, error) {
+	defer metrics.ObserveStorage(storageBackend, "ExistsByHash", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".ExistsByHash")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var exists bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM shortener WHERE short_url = $1)", hashURL).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("postgres.repository.ExistsByHash: %w", err)
+	}
+	return exists, nil
 }
 
 // Ping проверяет доступность хранилища.
 //
+// Параметр:
+//   - ctx: контекст запроса.
+//
 // Возвращает:
 //   - bool: true, если база доступна.
 //   - error: nil, если всё в порядке, иначе — ошибку.
-func (r *Repository) Ping() (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+func (r *Repository) Ping(ctx context.Context) (bool, error) {
+	defer metrics.ObserveStorage(storageBackend, "Ping", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".Ping")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
 	defer cancel()
 	if err := r.db.PingContext(ctx); err != nil {
 		return false, fmt.Errorf("couldn't ping the PostgreSQL server: %s", err.Error())
@@ -198,6 +467,127 @@ func (r *Repository) Ping() (bool, error) {
 	return true, nil
 }
 
+// DB возвращает пул подключений к базе данных, например для сбора его статистики.
+func (r *Repository) DB() *sql.DB {
+	return r.db
+}
+
+// withTimeout ограничивает контекст запроса таймаутом одного запроса к базе данных.
+func (r *Repository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, r.queryTimeout)
+}
+
+// withBulkTimeout ограничивает контекст пакетной операции (транзакции из нескольких запросов).
+func (r *Repository) withBulkTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, bulkTimeoutFactor*r.queryTimeout)
+}
+
+// checkAliasIsFree проверяет в рамках транзакции, что алиас ещё не используется как short_url.
+//
+// Возвращает:
+//   - error: nil, если алиас свободен; repository.ErrAliasAlreadyTaken, если занят; иначе — ошибку.
+func checkAliasIsFree(ctx context.Context, tx *sql.Tx, alias string) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM shortener WHERE short_url = $1)", alias).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check alias: %w", err)
+	}
+	if exists {
+		return fmt.Errorf("%w: %s", repository.ErrAliasAlreadyTaken, alias)
+	}
+	return nil
+}
+
+// saveUserLink в рамках транзакции сохраняет ссылку и делает пользователя её владельцем.
+//
+// Если пользователь уже сокращал full_url и его код можно вернуть повторно (см. findReusableLink),
+// а новая ссылка бессрочна и без алиаса, новая запись не создаётся. Ссылка с алиасом всегда
+// сохраняется отдельной записью рядом с уже существующими кодами того же URL.
+//
+// Возвращает:
+//   - string: ключ, под которым хранится ссылка.
+//   - bool: true, если создана новая ссылка; false, если возвращён существующий код пользователя.
+//   - error: repository.ErrAliasAlreadyTaken, если алиас занят;
+//     *repository.HashCollisionError, если код уже занят; иначе — ошибку.
+func saveUserLink(ctx context.Context, tx *sql.Tx, link model.CreateShortDTO, userID string) (string, bool, error) {
+	if link.Alias != "" {
+		if err := checkAliasIsFree(ctx, tx, link.Alias); err != nil {
+			return "", false, err
+		}
+	}
+	if link.Alias == "" && link.ExpiresAt == nil && userID != "" {
+		existingHash, found, err := findReusableLink(ctx, tx, link.OriginalURL, userID)
+		if err != nil {
+			return "", false, err
+		}
+		if found {
+			return existingHash, false, nil
+		}
+	}
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO shortener (short_url, full_url, user_id, expires_at) VALUES ($1, $2, $3, $4)",
+		link.HashURL, link.OriginalURL, userID, link.ExpiresAt)
+	if err != nil {
+		return "", false, fmt.Errorf("insert: %w", wrapCollision(err, link))
+	}
+	if userID == "" {
+		return link.HashURL, true, nil
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_links (user_id, short_url) VALUES ($1, $2)", userID, link.HashURL)
+	if err != nil {
+		return "", false, fmt.Errorf("link user: %w", err)
+	}
+	return link.HashURL, true, nil
+}
+
+// findReusableLink ищет код, под которым пользователь уже сократил fullURL и который можно вернуть
+// повторно: ссылка не удалена, не отключена администратором и бессрочна.
+//
+// Перед поиском берётся транзакционная advisory-блокировка пары (пользователь, URL), поэтому
+// параллельные запросы одного пользователя с тем же URL не создают ему два кода.
+//
+// Возвращает:
+//   - string: найденный код.
+//   - bool: true, если код найден.
+//   - error: nil, если успешно, иначе — ошибку.
+func findReusableLink(ctx context.Context, tx *sql.Tx, fullURL, userID string) (string, bool, error) {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtextextended($1::text || ' ' || $2::text, 0))", userID, fullURL)
+	if err != nil {
+		return "", false, fmt.Errorf("lock user url: %w", err)
+	}
+	var hashURL string
+	err = tx.QueryRowContext(ctx, `
+        SELECT s.short_url
+        FROM user_links l
+        JOIN shortener s ON s.short_url = l.short_url
+        WHERE l.user_id = $1 AND s.full_url = $2
+          AND NOT s.is_deleted AND NOT s.is_disabled AND s.expires_at IS NULL
+        ORDER BY l.created_at
+        LIMIT 1
+    `, userID, fullURL).Scan(&hashURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("find reusable link: %w", err)
+	}
+	return hashURL, true, nil
+}
+
+// wrapCollision заменяет ошибку нарушения уникального индекса по short_url на *repository.HashCollisionError,
+// а для ссылки с алиасом — на repository.ErrAliasAlreadyTaken: параллельный запрос мог занять алиас
+// уже после проверки checkAliasIsFree. Остальные ошибки возвращаются без изменений.
+func wrapCollision(err error, link model.CreateShortDTO) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolationCode || pgErr.ConstraintName != shortURLUniqueIndex {
+		return err
+	}
+	if link.Alias != "" {
+		return fmt.Errorf("%w: %s", repository.ErrAliasAlreadyTaken, link.Alias)
+	}
+	return &repository.HashCollisionError{HashURL: link.HashURL}
+}
+
 // NewPostgresRepository создаёт новый экземпляр Repository, подключаясь к PostgreSQL.
 //
 // Паникует, если не может установить соединение.
@@ -212,8 +602,13 @@ func NewPostgresRepository(cfg *config.Config) *Repository {
 	if err != nil {
 		panic(err)
 	}
+	queryTimeout := cfg.QueryTimeout
+	if queryTimeout <= 0 {
+		queryTimeout = defaultQueryTimeout
+	}
 	return &Repository{
 		db:           db,
 		baseShortURL: cfg.BaseShortURL,
+		queryTimeout: queryTimeout,
 	}
 }
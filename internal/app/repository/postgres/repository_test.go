@@ -0,0 +1,234 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/migration"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestRepository подключается к базе из TEST_DATABASE_DSN и применяет миграции;
+// без переменной окружения тест пропускается.
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	require.NoError(t, migration.RunFrom(dsn, filepath.Join("..", "..", "migration", "sql")))
+	r := NewPostgresRepository(&config.Config{DataSourceName: dsn, BaseShortURL: "http://localhost", QueryTimeout: 5 * time.Second})
+	t.Cleanup(func() {
+		_ = r.db.Close()
+	})
+	return r
+}
+
+// uniqueName возвращает имя, не пересекающееся с данными других запусков теста в общей базе.
+func uniqueName(name string) string {
+	return fmt.Sprintf("%s%d", name, time.Now().UnixNano())
+}
+
+func TestPostgresStoragePerUserLinks(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+	fullURL := "https://yandex.ru/" + uniqueName("shared")
+	alice, bob, dave := uniqueName("alice"), uniqueName("bob"), uniqueName("dave")
+	save := func(hashURL, userID string, expiresAt *time.Time) (string, error) {
+		return r.SaveLink(ctx, model.CreateShortDTO{HashURL: hashURL, OriginalURL: fullURL, ExpiresAt: expiresAt}, userID)
+	}
+	aliceHash, bobHash := uniqueName("a"), uniqueName("b")
+	_, err := save(aliceHash, alice, nil)
+	require.NoError(t, err)
+
+	storedHash, err := save(bobHash, bob, nil)
+	require.NoError(t, err)
+	assert.Equal(t, bobHash, storedHash, "another user must get their own code")
+	storedHash, err = save(uniqueName("b"), bob, nil)
+	require.ErrorIs(t, err, repository.ErrURLAlreadyShortened)
+	assert.Equal(t, bobHash, storedHash, "a live code must be reused")
+	_, err = save(aliceHash, bob, nil)
+	require.ErrorIs(t, err, repository.ErrHashCollision, "a code of another user must not be shared")
+
+	t.Run("Expiring link gets a new code", func(t *testing.T) {
+		future := time.Now().Add(time.Hour)
+		ttlHash := uniqueName("ttl")
+		storedHash, err := save(ttlHash, bob, &future)
+		require.NoError(t, err)
+		assert.Equal(t, ttlHash, storedHash, "the requested TTL must not be dropped")
+		storedHash, err = save(uniqueName("b"), bob, nil)
+		require.ErrorIs(t, err, repository.ErrURLAlreadyShortened)
+		assert.Equal(t, bobHash, storedHash, "an expiring code must not replace the permanent one")
+	})
+	t.Run("Deleted link gets a new code", func(t *testing.T) {
+		require.NoError(t, r.DeleteAll(ctx, []string{bobHash}, bob))
+		newHash := uniqueName("b")
+		storedHash, err := save(newHash, bob, nil)
+		require.NoError(t, err)
+		assert.Equal(t, newHash, storedHash)
+		_, err = r.FindByHash(ctx, bobHash)
+		require.ErrorIs(t, err, repository.ErrRecordDeleted, "a deleted link must not be restored")
+		bobHash = newHash
+	})
+	t.Run("Disabled link gets a new code", func(t *testing.T) {
+		_, err := r.SetLinksDisabled(ctx, []string{bobHash}, true)
+		require.NoError(t, err)
+		newHash := uniqueName("b")
+		storedHash, err := save(newHash, bob, nil)
+		require.NoError(t, err)
+		assert.Equal(t, newHash, storedHash)
+		_, err = r.FindByHash(ctx, bobHash)
+		require.ErrorIs(t, err, repository.ErrRecordDisabled, "a disabled link must stay disabled")
+		bobHash = newHash
+	})
+	t.Run("Expired link gets a new code", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		expiredHash, newHash := uniqueName("expired"), uniqueName("d")
+		_, err := save(expiredHash, dave, &past)
+		require.NoError(t, err)
+		storedHash, err := save(newHash, dave, nil)
+		require.NoError(t, err)
+		assert.Equal(t, newHash, storedHash)
+		_, err = r.FindByHash(ctx, expiredHash)
+		require.ErrorIs(t, err, repository.ErrRecordExpired)
+	})
+
+	require.NoError(t, r.SaveClicks(ctx, []model.ClickEvent{{HashURL: aliceHash, Timestamp: time.Now(), IPHash: "a"}}))
+	stats, err := r.FindStatsByHash(ctx, aliceHash, alice)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.TotalClicks)
+	stats, err = r.FindStatsByHash(ctx, bobHash, bob)
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks, "clicks on the code of another user must not be visible")
+	_, err = r.FindStatsByHash(ctx, aliceHash, bob)
+	require.ErrorIs(t, err, repository.ErrNotOwner)
+}
+
+func TestPostgresStorageBatchPerUserLinks(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+	fullURL := "https://yandex.ru/" + uniqueName("batch")
+	alice, carol := uniqueName("alice"), uniqueName("carol")
+	aliceHash := uniqueName("a")
+	_, err := r.SaveLink(ctx, model.CreateShortDTO{HashURL: aliceHash, OriginalURL: fullURL}, alice)
+	require.NoError(t, err)
+
+	batch := map[string]model.CreateShortDTO{
+		"1": {HashURL: uniqueName("c"), OriginalURL: fullURL},
+		"2": {HashURL: uniqueName("c"), OriginalURL: fullURL},
+	}
+	require.NoError(t, r.SaveAll(ctx, batch, carol))
+	assert.Equal(t, batch["1"].HashURL, batch["2"].HashURL, "duplicates inside the batch must get one code")
+	assert.NotEqual(t, aliceHash, batch["1"].HashURL, "a code of another user must not be shared")
+	urls, err := r.FindAllByUserID(ctx, carol)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1)
+}
+
+func TestPostgresStorageConcurrentAlias(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+	alias := uniqueName("alias")
+	const requests = 8
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Запросы одновременно проходят checkAliasIsFree, и проигравших останавливает уникальный индекс.
+			_, errs[i] = r.SaveLink(ctx, model.CreateShortDTO{
+				HashURL:     alias,
+				Alias:       alias,
+				OriginalURL: fmt.Sprintf("https://yandex.ru/%d", i),
+			}, uniqueName(fmt.Sprintf("user%d-", i)))
+		}()
+	}
+	wg.Wait()
+
+	saved := 0
+	for _, err := range errs {
+		if err == nil {
+			saved++
+			continue
+		}
+		assert.ErrorIs(t, err, repository.ErrAliasAlreadyTaken, "the unique index violation must be reported as a taken alias")
+	}
+	assert.Equal(t, 1, saved)
+}
+
+func TestPostgresStorageLongURL(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+	// URL длиннее прежнего ограничения колонки full_url (VARCHAR(255)), но в пределах URLMaxLength.
+	fullURL := "https://yandex.ru/" + uniqueName("long") + "/" + strings.Repeat("a", 300)
+	hashURL := uniqueName("long")
+	_, err := r.SaveLink(ctx, model.CreateShortDTO{HashURL: hashURL, OriginalURL: fullURL}, uniqueName("user"))
+	require.NoError(t, err)
+	found, err := r.FindByHash(ctx, hashURL)
+	require.NoError(t, err)
+	assert.Equal(t, fullURL, found)
+}
+
+func TestPostgresStorageExpiredTombstones(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+	userID, hashURL := uniqueName("user"), uniqueName("expired")
+	past := time.Now().Add(-time.Minute)
+	_, err := r.SaveLink(ctx, model.CreateShortDTO{HashURL: hashURL, OriginalURL: "https://yandex.ru/" + hashURL, ExpiresAt: &past}, userID)
+	require.NoError(t, err)
+
+	swept, err := r.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Contains(t, swept, hashURL)
+
+	// После очистки код остаётся надгробием: ссылка по-прежнему истёкшая, а код не выдаётся повторно.
+	_, err = r.FindByHash(ctx, hashURL)
+	require.ErrorIs(t, err, repository.ErrRecordExpired)
+	urls, err := r.FindAllByUserID(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, urls, "an expired link must be removed from the user links")
+	_, err = r.SaveLink(ctx, model.CreateShortDTO{HashURL: hashURL, OriginalURL: "https://yandex.ru/other"}, userID)
+	require.ErrorIs(t, err, repository.ErrHashCollision)
+	swept, err = r.DeleteExpired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.NotContains(t, swept, hashURL, "a tombstone must not be swept again")
+}
+
+func TestPostgresStorageClickStats(t *testing.T) {
+	r := newTestRepository(t)
+	ctx := context.Background()
+	userID, hashURL := uniqueName("user"), uniqueName("stats")
+	_, err := r.SaveLink(ctx, model.CreateShortDTO{HashURL: hashURL, OriginalURL: "https://yandex.ru/" + hashURL}, userID)
+	require.NoError(t, err)
+
+	day1 := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	// Повторяющиеся ключи внутри пакета суммируются, а не конфликтуют в одном UPSERT.
+	clicks := []model.ClickEvent{
+		{HashURL: hashURL, Timestamp: day1, IPHash: "a", Referrer: "https://ya.ru/", UserAgent: "test"},
+		{HashURL: hashURL, Timestamp: day1, IPHash: "a", Referrer: "https://ya.ru/", UserAgent: "test"},
+		{HashURL: hashURL, Timestamp: day2, IPHash: "b", UserAgent: "test"},
+	}
+	require.NoError(t, r.SaveClicks(ctx, clicks))
+	require.NoError(t, r.SaveClicks(ctx, clicks[:1]))
+
+	stats, err := r.FindStatsByHash(ctx, hashURL, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.TotalClicks)
+	assert.Equal(t, int64(2), stats.UniqueVisitors)
+	require.Len(t, stats.Daily, 2)
+	assert.Equal(t, int64(3), stats.Daily[0].Clicks)
+	assert.Equal(t, int64(1), stats.Daily[0].UniqueVisitors)
+	assert.Equal(t, []model.ClickSourceResponse{{Value: "https://ya.ru/", Clicks: 3}}, stats.Referrers)
+	assert.Equal(t, []model.ClickSourceResponse{{Value: "test", Clicks: 4}}, stats.UserAgents)
+}
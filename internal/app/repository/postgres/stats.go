@@ -0,0 +1,282 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"github.com/lib/pq"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Измерения таблицы link_click_sources.
+const (
+	sourceDimensionReferrer  = "referrer"
+	sourceDimensionUserAgent = "user_agent"
+)
+
+// clickDay — ключ дневного счётчика переходов.
+type clickDay struct {
+	hashURL string
+	day     string
+}
+
+// clickVisitor — посетитель ссылки за день.
+type clickVisitor struct {
+	clickDay
+	ipHash string
+}
+
+// clickSource — ключ счётчика переходов по значению источника.
+type clickSource struct {
+	hashURL   string
+	dimension string
+	value     string
+}
+
+// SaveClicks добавляет пакет событий переходов в агрегированные таблицы статистики.
+//
+// События сначала суммируются в памяти, а затем каждая таблица обновляется одним запросом
+// с unnest: счётчики по дням и источникам увеличиваются через UPSERT, хэши IP посетителей
+// сохраняются один раз за день. Все запросы выполняются в одной транзакции, а строки записываются
+// в порядке ключей, чтобы параллельные транзакции блокировали их в одном порядке.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - clicks: события переходов.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку.
+func (r *Repository) SaveClicks(ctx context.Context, clicks []model.ClickEvent) error {
+	defer metrics.ObserveStorage(storageBackend, "SaveClicks", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".SaveClicks")
+	defer span.End()
+	if len(clicks) == 0 {
+		return nil
+	}
+	daily := make(map[clickDay]int64)
+	visitors := make(map[clickVisitor]struct{})
+	sources := make(map[clickSource]int64)
+	for _, click := range clicks {
+		day := clickDay{hashURL: click.HashURL, day: click.Timestamp.UTC().Format(model.ClickDateLayout)}
+		daily[day]++
+		if click.IPHash != "" {
+			visitors[clickVisitor{clickDay: day, ipHash: click.IPHash}] = struct{}{}
+		}
+		if click.Referrer != "" {
+			sources[clickSource{hashURL: click.HashURL, dimension: sourceDimensionReferrer, value: click.Referrer}]++
+		}
+		if click.UserAgent != "" {
+			sources[clickSource{hashURL: click.HashURL, dimension: sourceDimensionUserAgent, value: click.UserAgent}]++
+		}
+	}
+
+	ctx, cancel := r.withBulkTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("postgres.repository.saveClicks.begin - %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err = saveDailyClicks(ctx, tx, daily); err != nil {
+		return fmt.Errorf("postgres.repository.saveClicks.daily: %w", err)
+	}
+	if err = saveVisitors(ctx, tx, visitors); err != nil {
+		return fmt.Errorf("postgres.repository.saveClicks.visitors: %w", err)
+	}
+	if err = saveClickSources(ctx, tx, sources); err != nil {
+		return fmt.Errorf("postgres.repository.saveClicks.sources: %w", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("postgres.repository.saveClicks.commit - %w", err)
+	}
+	return nil
+}
+
+// FindStatsByHash возвращает статистику переходов по ссылке, если пользователь — её единственный владелец.
+//
+// Коды не делятся между пользователями, но у ссылок, созданных до этого, владельцев может быть несколько:
+// переходы по такой ссылке нельзя разделить между ними, поэтому её статистика не выдаётся никому.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURL: хэш-ключ короткой ссылки.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - model.LinkStatsResponse: статистика переходов.
+//   - error: nil, если успешно; repository.ErrNotOwner, если ссылка чужая или у неё несколько владельцев;
+//     иначе — ошибку.
+func (r *Repository) FindStatsByHash(ctx context.Context, hashURL, userID string) (model.LinkStatsResponse, error) {
+	defer metrics.ObserveStorage(storageBackend, "FindStatsByHash", time.Now())
+	ctx, span := tracing.Start(ctx, storageBackend+".FindStatsByHash")
+	defer span.End()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	var owned bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS(SELECT 1 FROM user_links WHERE short_url = s.short_url AND user_id = $2)
+           AND NOT EXISTS(SELECT 1 FROM user_links WHERE short_url = s.short_url AND user_id <> $2)
+        FROM shortener s
+        WHERE s.short_url = $1
+    `, hashURL, userID).Scan(&owned)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.LinkStatsResponse{}, fmt.Errorf("%w for %s", repository.ErrRecordNotFound, hashURL)
+		}
+		return model.LinkStatsResponse{}, fmt.Errorf("postgres.repository.FindStatsByHash.owner: %w", err)
+	}
+	if !owned {
+		return model.LinkStatsResponse{}, repository.ErrNotOwner
+	}
+
+	stats := model.LinkStatsResponse{}
+	stats.Daily, err = r.findDailyClicks(ctx, hashURL)
+	if err != nil {
+		return model.LinkStatsResponse{}, fmt.Errorf("postgres.repository.FindStatsByHash.daily: %w", err)
+	}
+	for _, day := range stats.Daily {
+		stats.TotalClicks += day.Clicks
+	}
+	err = r.db.QueryRowContext(ctx,
+		"SELECT COUNT(DISTINCT ip_hash) FROM link_visitors_daily WHERE short_url = $1", hashURL).Scan(&stats.UniqueVisitors)
+	if err != nil {
+		return model.LinkStatsResponse{}, fmt.Errorf("postgres.repository.FindStatsByHash.visitors: %w", err)
+	}
+	stats.Referrers, err = r.findClickSources(ctx, hashURL, sourceDimensionReferrer)
+	if err != nil {
+		return model.LinkStatsResponse{}, fmt.Errorf("postgres.repository.FindStatsByHash.referrers: %w", err)
+	}
+	stats.UserAgents, err = r.findClickSources(ctx, hashURL, sourceDimensionUserAgent)
+	if err != nil {
+		return model.LinkStatsResponse{}, fmt.Errorf("postgres.repository.FindStatsByHash.userAgents: %w", err)
+	}
+	return stats, nil
+}
+
+// findDailyClicks возвращает гистограмму переходов по дням в порядке возрастания даты.
+func (r *Repository) findDailyClicks(ctx context.Context, hashURL string) ([]model.DailyClicksResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT d.day, d.clicks, COUNT(v.ip_hash)
+        FROM link_clicks_daily d
+        LEFT JOIN link_visitors_daily v ON v.short_url = d.short_url AND v.day = d.day
+        WHERE d.short_url = $1
+        GROUP BY d.day, d.clicks
+        ORDER BY d.day
+    `, hashURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]model.DailyClicksResponse, 0)
+	for rows.Next() {
+		var day time.Time
+		var resp model.DailyClicksResponse
+		if err = rows.Scan(&day, &resp.Clicks, &resp.UniqueVisitors); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		resp.Date = day.Format(model.ClickDateLayout)
+		result = append(result, resp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+	return result, nil
+}
+
+// findClickSources возвращает не более model.MaxClickSources источников указанного измерения
+// в порядке убывания количества переходов.
+func (r *Repository) findClickSources(ctx context.Context, hashURL, dimension string) ([]model.ClickSourceResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT value, clicks
+        FROM link_click_sources
+        WHERE short_url = $1 AND dimension = $2
+        ORDER BY clicks DESC, value
+        LIMIT $3
+    `, hashURL, dimension, model.MaxClickSources)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]model.ClickSourceResponse, 0)
+	for rows.Next() {
+		var resp model.ClickSourceResponse
+		if err = rows.Scan(&resp.Value, &resp.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		result = append(result, resp)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+	return result, nil
+}
+
+// saveDailyClicks увеличивает дневные счётчики переходов.
+func saveDailyClicks(ctx context.Context, tx *sql.Tx, daily map[clickDay]int64) error {
+	keys := slices.SortedFunc(maps.Keys(daily), func(a, b clickDay) int {
+		return cmp.Or(cmp.Compare(a.hashURL, b.hashURL), cmp.Compare(a.day, b.day))
+	})
+	hashURLs, days, counts := make([]string, len(keys)), make([]string, len(keys)), make([]int64, len(keys))
+	for i, key := range keys {
+		hashURLs[i], days[i], counts[i] = key.hashURL, key.day, daily[key]
+	}
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO link_clicks_daily (short_url, day, clicks)
+        SELECT short_url, day::date, clicks FROM unnest($1::text[], $2::text[], $3::bigint[]) AS c(short_url, day, clicks)
+        ON CONFLICT (short_url, day) DO UPDATE SET clicks = link_clicks_daily.clicks + EXCLUDED.clicks
+    `, pq.Array(hashURLs), pq.Array(days), pq.Array(counts))
+	return err
+}
+
+// saveVisitors сохраняет хэши IP посетителей, ещё не учтённых за день.
+func saveVisitors(ctx context.Context, tx *sql.Tx, visitors map[clickVisitor]struct{}) error {
+	if len(visitors) == 0 {
+		return nil
+	}
+	keys := slices.SortedFunc(maps.Keys(visitors), func(a, b clickVisitor) int {
+		return cmp.Or(cmp.Compare(a.hashURL, b.hashURL), cmp.Compare(a.day, b.day), cmp.Compare(a.ipHash, b.ipHash))
+	})
+	hashURLs, days, ipHashes := make([]string, len(keys)), make([]string, len(keys)), make([]string, len(keys))
+	for i, key := range keys {
+		hashURLs[i], days[i], ipHashes[i] = key.hashURL, key.day, key.ipHash
+	}
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO link_visitors_daily (short_url, day, ip_hash)
+        SELECT short_url, day::date, ip_hash FROM unnest($1::text[], $2::text[], $3::text[]) AS v(short_url, day, ip_hash)
+        ON CONFLICT DO NOTHING
+    `, pq.Array(hashURLs), pq.Array(days), pq.Array(ipHashes))
+	return err
+}
+
+// saveClickSources увеличивает счётчики переходов по значениям источников.
+func saveClickSources(ctx context.Context, tx *sql.Tx, sources map[clickSource]int64) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	keys := slices.SortedFunc(maps.Keys(sources), func(a, b clickSource) int {
+		return cmp.Or(cmp.Compare(a.hashURL, b.hashURL), cmp.Compare(a.dimension, b.dimension), cmp.Compare(a.value, b.value))
+	})
+	hashURLs, dimensions, values := make([]string, len(keys)), make([]string, len(keys)), make([]string, len(keys))
+	counts := make([]int64, len(keys))
+	for i, key := range keys {
+		hashURLs[i], dimensions[i], values[i], counts[i] = key.hashURL, key.dimension, key.value, sources[key]
+	}
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO link_click_sources (short_url, dimension, value, clicks)
+        SELECT * FROM unnest($1::text[], $2::text[], $3::text[], $4::bigint[])
+        ON CONFLICT (short_url, dimension, value) DO UPDATE SET clicks = link_click_sources.clicks + EXCLUDED.clicks
+    `, pq.Array(hashURLs), pq.Array(dimensions), pq.Array(values), pq.Array(counts))
+	return err
+}
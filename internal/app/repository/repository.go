@@ -1,65 +1,373 @@
 package repository
 
-import "github.com/faust8888/shortener/internal/app/model"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/faust8888/shortener/internal/app/model"
+)
+
+// ErrAliasAlreadyTaken — ошибка, возникающая при попытке сохранить ссылку под алиасом,
+// который уже занят другой записью.
+var ErrAliasAlreadyTaken = errors.New("alias is already taken")
+
+// ErrURLAlreadyShortened — ошибка, указывающая, что пользователь уже сокращал этот URL.
+// Возвращается вместе с существующим ключом короткой ссылки.
+var ErrURLAlreadyShortened = errors.New("url is already shortened by the user")
+
+// ErrRecordNotFound — ошибка, указывающая, что ссылки с таким кодом нет.
+var ErrRecordNotFound = errors.New("short url not found")
+
+// ErrRecordDeleted — ошибка, указывающая, что ссылка была удалена владельцем.
+var ErrRecordDeleted = errors.New("short url has been deleted")
+
+// ErrRecordExpired — ошибка, указывающая, что срок жизни ссылки истёк.
+var ErrRecordExpired = errors.New("short url has expired")
+
+// ErrRecordDisabled — ошибка, указывающая, что ссылка отключена администратором.
+var ErrRecordDisabled = errors.New("short url has been disabled by an administrator")
+
+// ErrNotOwner — ошибка, указывающая, что ссылка принадлежит другому пользователю.
+var ErrNotOwner = errors.New("short url belongs to another user")
+
+// ErrHashCollision — ошибка, указывающая, что сгенерированный код уже занят ссылкой на другой URL.
+var ErrHashCollision = errors.New("short url is already used for another url")
+
+// ErrAPIKeyNotFound — ошибка, указывающая, что ключ API не найден или принадлежит другому пользователю.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// HashCollisionError — ошибка коллизии с указанием занятого кода.
+// Удовлетворяет errors.Is(err, ErrHashCollision).
+type HashCollisionError struct {
+	HashURL string // Код, уже занятый другой ссылкой
+}
+
+// Error возвращает текст ошибки.
+func (e *HashCollisionError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrHashCollision, e.HashURL)
+}
+
+// Is сообщает, что ошибка соответствует ErrHashCollision.
+func (e *HashCollisionError) Is(target error) bool {
+	return target == ErrHashCollision
+}
 
 // Repository — это интерфейс, определяющий основные операции над хранилищем коротких ссылок.
 // Реализация может быть файловой, базой данных или в памяти.
+//
+// Все методы принимают контекст запроса: его отмена (разрыв соединения клиентом,
+// остановка сервера) прерывает обращение к хранилищу.
 type Repository interface {
 	// Save сохраняет одну пару (hashURL -> fullURL) для указанного пользователя.
 	//
 	// Параметры:
+	//   - ctx: контекст запроса.
 	//   - urlHash: хэш-ключ для короткой ссылки.
 	//   - fullURL: оригинальный URL.
 	//   - userID: идентификатор пользователя.
 	//
 	// Возвращает:
-	//   - error: nil, если успешно, иначе — ошибку.
-	Save(urlHash, fullURL, userID string) error
+	//   - error: nil, если успешно; ErrURLAlreadyShortened, если пользователь уже сокращал URL;
+	//     *HashCollisionError, если код уже занят; иначе — ошибку.
+	Save(ctx context.Context, urlHash, fullURL, userID string) error
+
+	// SaveLink сохраняет ссылку с дополнительными параметрами: алиасом и сроком жизни.
+	//
+	// Если link.Alias задан, ссылка сохраняется под ним только при условии, что алиас свободен.
+	// Коды не делятся между пользователями: новая ссылка не создаётся, только если этот же пользователь
+	// уже сокращал URL, его код действует (не удалён, не отключён и бессрочен), а новая ссылка
+	// бессрочна и без алиаса.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - link: DTO с данными о ссылке (HashURL, OriginalURL, Alias, ExpiresAt).
+	//   - userID: идентификатор пользователя.
+	//
+	// Возвращает:
+	//   - string: ключ, под которым хранится ссылка (HashURL или существующий ключ пользователя).
+	//   - error: nil, если успешно; ErrAliasAlreadyTaken, если алиас занят;
+	//     ErrURLAlreadyShortened, если пользователь уже сокращал URL (вместе с существующим ключом);
+	//     *HashCollisionError, если код уже занят; иначе — ошибку.
+	SaveLink(ctx context.Context, link model.CreateShortDTO, userID string) (string, error)
 
 	// FindByHash находит оригинальный URL по его хэш-ключу.
 	//
-	// Параметр:
+	// Параметры:
+	//   - ctx: контекст запроса.
 	//   - hashURL: хэш-ключ короткой ссылки.
 	//
 	// Возвращает:
 	//   - string: оригинальный URL.
-	//   - error: nil, если найдено, иначе — ошибку.
-	FindByHash(hashURL string) (string, error)
+	//   - error: nil, если найдено; ErrRecordDeleted, если ссылка удалена;
+	//     ErrRecordExpired, если срок жизни истёк; иначе — ошибку.
+	FindByHash(ctx context.Context, hashURL string) (string, error)
 
 	// FindAllByUserID возвращает все короткие ссылки, принадлежащие пользователю.
 	//
-	// Параметр:
+	// Параметры:
+	//   - ctx: контекст запроса.
 	//   - userID: идентификатор пользователя.
 	//
 	// Возвращает:
 	//   - []model.FindURLByUserIDResponse: список ссылок пользователя.
 	//   - error: nil, если успешно, иначе — ошибку.
-	FindAllByUserID(userID string) ([]model.FindURLByUserIDResponse, error)
+	FindAllByUserID(ctx context.Context, userID string) ([]model.FindURLByUserIDResponse, error)
 
 	// SaveAll сохраняет несколько ссылок за один раз (пакетная операция).
+	// Если хотя бы один алиас из пакета занят, пакет не сохраняется и возвращается ErrAliasAlreadyTaken;
+	// если код хотя бы одного элемента уже занят — *HashCollisionError.
+	// Бессрочные элементы без алиаса получают действующий ключ пользователя для того же URL, как в SaveLink
+	// (HashURL и ShortURL в batch заменяются).
 	//
 	// Параметры:
+	//   - ctx: контекст запроса.
 	//   - batch: карта хэшей и DTO с данными о ссылках.
 	//   - userID: идентификатор пользователя.
 	//
 	// Возвращает:
 	//   - error: nil, если успешно, иначе — ошибку.
-	SaveAll(batch map[string]model.CreateShortDTO, userID string) error
+	SaveAll(ctx context.Context, batch map[string]model.CreateShortDTO, userID string) error
 
-	// DeleteAll асинхронно удаляет несколько коротких ссылок пользователя.
+	// DeleteAll удаляет связи пользователя с несколькими короткими ссылками.
+	// Ссылки, у которых не осталось владельцев, помечаются удалёнными;
+	// ссылки, не принадлежащие пользователю, пропускаются.
 	//
 	// Параметры:
+	//   - ctx: контекст запроса.
 	//   - shortURLs: список идентификаторов (хэшей) ссылок для удаления.
 	//   - userID: идентификатор пользователя.
 	//
 	// Возвращает:
 	//   - error: nil, если запрос на удаление принят, иначе — ошибку.
-	DeleteAll(shortURLs []string, userID string) error
+	DeleteAll(ctx context.Context, shortURLs []string, userID string) error
+
+	// DeleteBatch выполняет за одну операцию запросы на удаление нескольких пользователей.
+	// Семантика для каждого запроса такая же, как у DeleteAll; повторное удаление безопасно.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - requests: запросы на удаление.
+	//
+	// Возвращает:
+	//   - error: nil, если успешно, иначе — ошибку.
+	DeleteBatch(ctx context.Context, requests []model.DeletionRequest) error
+
+	// DeleteExpired удаляет из хранилища ссылки, срок жизни которых истёк к моменту now, вместе с их
+	// владельцами и статистикой. Код остаётся надгробием: FindByHash продолжает возвращать
+	// ErrRecordExpired (410 Gone, а не 404), а сам код считается занятым.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - now: момент времени, с которым сравнивается срок жизни.
+	//
+	// Возвращает:
+	//   - []string: коды удалённых ссылок.
+	//   - error: nil, если успешно, иначе — ошибку.
+	DeleteExpired(ctx context.Context, now time.Time) ([]string, error)
+
+	// SaveClicks добавляет пакет событий переходов в агрегированную статистику.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - clicks: события переходов.
+	//
+	// Возвращает:
+	//   - error: nil, если успешно, иначе — ошибку.
+	SaveClicks(ctx context.Context, clicks []model.ClickEvent) error
+
+	// FindStatsByHash возвращает агрегированную статистику переходов по ссылке.
+	// Статистика доступна только владельцу ссылки.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - hashURL: хэш-ключ короткой ссылки.
+	//   - userID: идентификатор пользователя, запрашивающего статистику.
+	//
+	// Возвращает:
+	//   - model.LinkStatsResponse: статистика (ShortURL не заполняется).
+	//   - error: nil, если успешно; ErrNotOwner, если ссылка принадлежит другому пользователю;
+	//     иначе — ошибку (в том числе если ссылка не найдена).
+	FindStatsByHash(ctx context.Context, hashURL, userID string) (model.LinkStatsResponse, error)
+
+	// NextID возвращает следующее значение последовательности для генерации кодов (стратегия counter).
+	//
+	// Параметр:
+	//   - ctx: контекст запроса.
+	//
+	// Возвращает:
+	//   - int64: следующее значение последовательности.
+	//   - error: nil, если успешно, иначе — ошибку.
+	NextID(ctx context.Context) (int64, error)
+
+	// ExistsByHash проверяет, используется ли уже код короткой ссылки (в том числе удалённой).
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - hashURL: код короткой ссылки.
+	//
+	// Возвращает:
+	//   - bool: true, если код занят.
+	//   - error: nil, если успешно, иначе — ошибку.
+	ExistsByHash(ctx context.Context, hashURL string) (bool, error)
 
 	// Ping проверяет доступность хранилища.
 	//
+	// Параметр:
+	//   - ctx: контекст запроса.
+	//
 	// Возвращает:
 	//   - bool: true, если хранилище доступно.
 	//   - error: nil, если хранилище доступно, иначе — ошибку.
-	Ping() (bool, error)
+	Ping(ctx context.Context) (bool, error)
+}
+
+// LinkExpiryFinder — интерфейс хранилища, возвращающего вместе с оригинальным URL срок жизни ссылки.
+// Его использует кэш, чтобы запись действующей ссылки не пережила её срок жизни.
+type LinkExpiryFinder interface {
+	// FindByHashWithExpiry находит оригинальный URL и момент истечения ссылки по её хэш-ключу.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - hashURL: хэш-ключ короткой ссылки.
+	//
+	// Возвращает:
+	//   - string: оригинальный URL.
+	//   - *time.Time: момент истечения; nil — ссылка бессрочная.
+	//   - error: те же ошибки, что и у Repository.FindByHash.
+	FindByHashWithExpiry(ctx context.Context, hashURL string) (string, *time.Time, error)
+}
+
+// AdminRepository — интерфейс операций служебного API над хранилищем: поиска ссылок,
+// их отключения и журнала действий администраторов.
+//
+// Отключённая ссылка остаётся в хранилище со своими владельцами, но FindByHash возвращает для неё
+// ErrRecordDisabled, пока ссылку не включат снова.
+type AdminRepository interface {
+	// SearchLinks ищет ссылки, удовлетворяющие всем заданным условиям фильтра.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - filter: условия поиска; пустые условия не применяются.
+	//
+	// Возвращает:
+	//   - []model.AdminLink: найденные ссылки (не больше filter.Limit).
+	//   - error: nil, если успешно, иначе — ошибку.
+	SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.AdminLink, error)
+
+	// SetLinksDisabled отключает или снова включает ссылки.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - hashURLs: коды ссылок.
+	//   - disabled: true — отключить, false — включить.
+	//
+	// Возвращает:
+	//   - []string: коды ссылок, состояние которых изменилось (несуществующие и уже находящиеся
+	//     в нужном состоянии пропускаются).
+	//   - error: nil, если успешно, иначе — ошибку.
+	SetLinksDisabled(ctx context.Context, hashURLs []string, disabled bool) ([]string, error)
+
+	// DisableLinksByDomain отключает все ссылки, ведущие на домен или его поддомены.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - domain: домен в нижнем регистре, например "example.com".
+	//
+	// Возвращает:
+	//   - []string: коды отключённых ссылок.
+	//   - error: nil, если успешно, иначе — ошибку.
+	DisableLinksByDomain(ctx context.Context, domain string) ([]string, error)
+
+	// SaveAuditEntry сохраняет запись журнала действий администраторов.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - entry: запись журнала.
+	//
+	// Возвращает:
+	//   - error: nil, если успешно, иначе — ошибку.
+	SaveAuditEntry(ctx context.Context, entry model.AdminAuditEntry) error
+
+	// FindAuditEntries возвращает последние записи журнала действий администраторов, начиная с новых.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - limit: максимальное количество записей.
+	//
+	// Возвращает:
+	//   - []model.AdminAuditEntry: записи журнала.
+	//   - error: nil, если успешно, иначе — ошибку.
+	FindAuditEntries(ctx context.Context, limit int) ([]model.AdminAuditEntry, error)
+}
+
+// APIKeyRepository — интерфейс хранилища ключей API пользователей.
+//
+// Ключи не удаляются: отозванный ключ остаётся в хранилище с заполненным RevokedAt,
+// чтобы пользователь видел его в списке, а отпечаток не мог быть использован повторно.
+type APIKeyRepository interface {
+	// SaveAPIKey сохраняет новый ключ API.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - key: ключ с заполненными ID, UserID, Hash и CreatedAt.
+	//
+	// Возвращает:
+	//   - error: nil, если успешно, иначе — ошибку.
+	SaveAPIKey(ctx context.Context, key model.APIKey) error
+
+	// FindAPIKeysByUserID возвращает ключи API пользователя, включая отозванные, в порядке создания.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - userID: идентификатор пользователя.
+	//
+	// Возвращает:
+	//   - []model.APIKey: ключи пользователя.
+	//   - error: nil, если успешно, иначе — ошибку.
+	FindAPIKeysByUserID(ctx context.Context, userID string) ([]model.APIKey, error)
+
+	// FindAPIKeyByHash находит ключ API по отпечатку.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - hash: отпечаток ключа.
+	//
+	// Возвращает:
+	//   - model.APIKey: найденный ключ (в том числе отозванный).
+	//   - error: nil, если найдено; ErrAPIKeyNotFound, если ключа нет; иначе — ошибку.
+	FindAPIKeyByHash(ctx context.Context, hash string) (model.APIKey, error)
+
+	// RevokeAPIKey отзывает ключ API пользователя. Повторный отзыв не меняет момент отзыва.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - id: идентификатор ключа.
+	//   - userID: идентификатор владельца.
+	//   - revokedAt: момент отзыва.
+	//
+	// Возвращает:
+	//   - model.APIKey: отозванный ключ.
+	//   - error: nil, если успешно; ErrAPIKeyNotFound, если у пользователя нет такого ключа; иначе — ошибку.
+	RevokeAPIKey(ctx context.Context, id, userID string, revokedAt time.Time) (model.APIKey, error)
+}
+
+// MatchesDomain проверяет, что оригинальный URL ведёт на домен или один из его поддоменов.
+//
+// Параметры:
+//   - originalURL: оригинальный URL ссылки.
+//   - domain: домен в нижнем регистре без завершающей точки.
+//
+// Возвращает:
+//   - bool: true, если хост URL совпадает с доменом или оканчивается на "."+domain.
+func MatchesDomain(originalURL, domain string) bool {
+	parsedURL, err := url.Parse(originalURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(parsedURL.Hostname()), ".")
+	return host == domain || strings.HasSuffix(host, "."+domain)
 }
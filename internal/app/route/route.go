@@ -2,9 +2,19 @@ package route
 
 import (
 	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/rpc"
+	"github.com/faust8888/shortener/internal/app/rpc/proto"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/middleware/admin"
+	"github.com/faust8888/shortener/internal/middleware/auth"
 	"github.com/faust8888/shortener/internal/middleware/compress"
 	"github.com/faust8888/shortener/internal/middleware/logger"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/ratelimit"
+	"github.com/faust8888/shortener/internal/middleware/requestmeta"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
 	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
 	"net/http"
 	"net/http/pprof"
 )
@@ -15,36 +25,106 @@ type route interface {
 	CreateLink(res http.ResponseWriter, req *http.Request)
 	FindLinkByHash(res http.ResponseWriter, req *http.Request)
 	FindLinkByUserID(res http.ResponseWriter, req *http.Request)
+	FindLinkStats(res http.ResponseWriter, req *http.Request)
 	PingDatabase(res http.ResponseWriter, req *http.Request)
+	CheckLiveness(res http.ResponseWriter, req *http.Request)
+	CheckReadiness(res http.ResponseWriter, req *http.Request)
 	DeleteLink(res http.ResponseWriter, req *http.Request)
+	FindBackupRecovery(res http.ResponseWriter, req *http.Request)
+	FindDeletionQueue(res http.ResponseWriter, req *http.Request)
+	SearchLinks(res http.ResponseWriter, req *http.Request)
+	DisableLinks(res http.ResponseWriter, req *http.Request)
+	EnableLinks(res http.ResponseWriter, req *http.Request)
+	TakedownDomain(res http.ResponseWriter, req *http.Request)
+	FindAuditLog(res http.ResponseWriter, req *http.Request)
+	FindAuditEvents(res http.ResponseWriter, req *http.Request)
+	CreateAPIKey(res http.ResponseWriter, req *http.Request)
+	FindAPIKeys(res http.ResponseWriter, req *http.Request)
+	RevokeAPIKey(res http.ResponseWriter, req *http.Request)
+	FindSigningKeys(res http.ResponseWriter, req *http.Request)
 }
 
 // Create инициализирует HTTP-роутер на основе chi и регистрирует маршруты,
 // связывая каждый маршрут с соответствующим методом интерфейса route.
 //
+// Маршруты пользователя проходят через middleware аутентификации: с пометкой «выпуск токена»
+// анонимный клиент получает токен нового пользователя, с пометкой «требует токен» — 401 Unauthorized.
+//
 // Поддерживаемые маршруты:
-// - POST /api/shorten          → CreateLinkWithJSON
-// - POST /api/shorten/batch    → CreateLinkWithBatch
-// - POST /                     → Create
-// - GET /{hash}                → FindLinkByHash
-// - GET /api/user/urls         → FindLinkByUserID
+// - POST /api/shorten          → CreateLinkWithJSON (ограничение частоты создания, выпуск токена)
+// - POST /api/shorten/batch    → CreateLinkWithBatch (ограничение частоты создания, выпуск токена)
+// - POST /                     → Create (ограничение частоты создания, выпуск токена)
+// - GET /{hash}                → FindLinkByHash (ограничение частоты переходов)
+// - GET /api/user/urls         → FindLinkByUserID (выпуск токена)
+// - GET /api/user/urls/{hash}/stats → FindLinkStats (требует токен)
 // - GET /ping                  → PingDatabase
-// - DELETE /api/user/urls      → DeleteLink
+// - GET /healthz               → CheckLiveness
+// - GET /readyz                → CheckReadiness
+// - DELETE /api/user/urls      → DeleteLink (требует токен)
+// - POST /api/user/api-keys    → CreateAPIKey (требует JWT-токен, ключи API отклоняются)
+// - GET /api/user/api-keys     → FindAPIKeys (требует JWT-токен, ключи API отклоняются)
+// - DELETE /api/user/api-keys/{id} → RevokeAPIKey (требует JWT-токен, ключи API отклоняются)
+// - GET /.well-known/jwks.json → FindSigningKeys
+// - GET /api/admin/backup/recovery → FindBackupRecovery (требует административный токен)
+// - GET /api/admin/deletions/queue → FindDeletionQueue (требует административный токен)
+// - GET /api/admin/links          → SearchLinks (требует административный токен)
+// - POST /api/admin/links/disable → DisableLinks (требует административный токен)
+// - POST /api/admin/links/enable  → EnableLinks (требует административный токен)
+// - POST /api/admin/takedowns     → TakedownDomain (требует административный токен)
+// - GET /api/admin/audit          → FindAuditLog (требует административный токен)
+// - GET /api/admin/audit/events   → FindAuditEvents (требует административный токен)
+// - GET /metrics               → метрики в формате Prometheus (требует административный токен)
 // - /debug/pprof/*             → pprof (для профилирования)
 //
+// Параметры:
+//   - r: обработчики маршрутов.
+//   - users: middleware аутентификации пользователей; по их токенам также ограничивается частота запросов.
+//   - cfg: конфигурация приложения (административный токен, ограничения частоты запросов и т.п.).
+//
 // Возвращает:
 //   - *chi.Mux: готовый к использованию HTTP-роутер.
-func Create(r route) *chi.Mux {
+func Create(r route, users *auth.Middleware, cfg *config.Config) *chi.Mux {
 	router := chi.NewRouter()
+	router.Use(metrics.NewMiddleware)
+	router.Use(tracing.NewMiddleware)
 	router.Use(gzip.NewMiddleware)
 	router.Use(logger.NewMiddleware)
-	router.Post("/api/shorten", r.CreateLinkWithJSON)
-	router.Post("/api/shorten/batch", r.CreateLinkWithBatch)
-	router.Post("/", r.CreateLink)
-	router.Get("/{"+config.HashKeyURLQueryParam+"}", r.FindLinkByHash)
-	router.Get("/api/user/urls", r.FindLinkByUserID)
+	router.Use(requestmeta.NewMiddleware)
+	proxies := ratelimit.NewTrustedProxies(cfg.RateLimitTrustedProxies)
+	createLimit := ratelimit.NewMiddleware(
+		ratelimit.NewLimiter(ratelimit.BudgetCreate, cfg.RateLimitCreate, cfg.RateLimitCreateBurst), users, proxies)
+	redirectLimit := ratelimit.NewMiddleware(
+		ratelimit.NewLimiter(ratelimit.BudgetRedirect, cfg.RateLimitRedirect, cfg.RateLimitRedirectBurst), users, proxies)
+	router.With(createLimit, users.Issue).Post("/api/shorten", r.CreateLinkWithJSON)
+	router.With(createLimit, users.Issue).Post("/api/shorten/batch", r.CreateLinkWithBatch)
+	router.With(createLimit, users.Issue).Post("/", r.CreateLink)
+	router.With(redirectLimit).Get("/{"+config.HashKeyURLQueryParam+"}", r.FindLinkByHash)
+	router.With(users.Issue).Get("/api/user/urls", r.FindLinkByUserID)
+	router.With(users.Require).Get("/api/user/urls/{"+config.HashKeyURLQueryParam+"}/stats", r.FindLinkStats)
 	router.Get("/ping", r.PingDatabase)
-	router.Delete("/api/user/urls", r.DeleteLink)
+	router.Get("/healthz", r.CheckLiveness)
+	router.Get("/readyz", r.CheckReadiness)
+	router.With(users.Require).Delete("/api/user/urls", r.DeleteLink)
+	router.Route("/api/user/api-keys", func(keysRouter chi.Router) {
+		keysRouter.Use(users.RequireSession)
+		keysRouter.Post("/", r.CreateAPIKey)
+		keysRouter.Get("/", r.FindAPIKeys)
+		keysRouter.Delete("/{"+config.APIKeyIDURLParam+"}", r.RevokeAPIKey)
+	})
+	router.Get("/.well-known/jwks.json", r.FindSigningKeys)
+	adminOnly := admin.NewMiddleware(cfg.AdminToken)
+	router.Route("/api/admin", func(adminRouter chi.Router) {
+		adminRouter.Use(adminOnly)
+		adminRouter.Get("/backup/recovery", r.FindBackupRecovery)
+		adminRouter.Get("/deletions/queue", r.FindDeletionQueue)
+		adminRouter.Get("/links", r.SearchLinks)
+		adminRouter.Post("/links/disable", r.DisableLinks)
+		adminRouter.Post("/links/enable", r.EnableLinks)
+		adminRouter.Post("/takedowns", r.TakedownDomain)
+		adminRouter.Get("/audit", r.FindAuditLog)
+		adminRouter.Get("/audit/events", r.FindAuditEvents)
+	})
+	router.With(adminOnly).Handle("/metrics", metrics.Handler())
 	router.Get("/debug/pprof/*", pprof.Index)
 	router.Get("/debug/pprof/cmdline", pprof.Cmdline)
 	router.Get("/debug/pprof/profile", pprof.Profile)
@@ -52,3 +132,37 @@ func Create(r route) *chi.Mux {
 	router.Get("/debug/pprof/trace", pprof.Trace)
 	return router
 }
+
+// CreateGRPC инициализирует gRPC-сервер и регистрирует на нём сервис proto.Shortener.
+//
+// Серверу назначаются интерсепторы, аналогичные middleware HTTP-роутера:
+// - трассировка вызовов (контекст трассы из метаданных "traceparent"),
+// - логирование вызовов,
+// - сохранение идентификатора вызова (метаданные "x-request-id") и IP-адреса клиента для журнала операций,
+// - ограничение частоты создания ссылок и переходов по IP-адресу клиента (бюджеты отдельны от HTTP-роутера),
+// - извлечение userID из метаданных "authorization": JWT-токена или ключа API (или выпуск нового токена).
+//
+// Параметры:
+//   - s: реализация proto.ShortenerServer.
+//   - authenticator: сервис выпуска и проверки токенов.
+//   - apiKeys: сервис проверки ключей API; nil, если ключи API не поддерживаются.
+//   - cfg: конфигурация приложения (ограничения частоты запросов).
+//
+// Возвращает:
+//   - *grpc.Server: готовый к запуску gRPC-сервер.
+func CreateGRPC(s proto.ShortenerServer, authenticator *security.Authenticator, apiKeys auth.APIKeyVerifier, cfg *config.Config) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		tracing.NewUnaryInterceptor,
+		logger.NewUnaryInterceptor,
+		requestmeta.NewUnaryInterceptor,
+		ratelimit.NewUnaryInterceptor(
+			ratelimit.NewLimiter(ratelimit.BudgetCreate, cfg.RateLimitCreate, cfg.RateLimitCreateBurst),
+			proto.Shortener_Shorten_FullMethodName, proto.Shortener_ShortenURL_FullMethodName, proto.Shortener_BatchShorten_FullMethodName),
+		ratelimit.NewUnaryInterceptor(
+			ratelimit.NewLimiter(ratelimit.BudgetRedirect, cfg.RateLimitRedirect, cfg.RateLimitRedirectBurst),
+			proto.Shortener_Resolve_FullMethodName),
+		rpc.NewAuthInterceptor(authenticator, apiKeys),
+	))
+	proto.RegisterShortenerServer(server, s)
+	return server
+}
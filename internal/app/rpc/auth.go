@@ -0,0 +1,150 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/rpc/proto"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/middleware/auth"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// AuthorizationMetadataKey — ключ метаданных gRPC, в котором передаются учётные данные пользователя:
+// "Bearer <JWT-токен>", "Bearer shk_..." (ключ API) или JWT-токен без схемы, подписанный так же,
+// как значение куки security.AuthorizationTokenName.
+var AuthorizationMetadataKey = strings.ToLower(security.AuthorizationTokenName)
+
+// errAPIKeysUnsupported — ошибка проверки ключа API, если интерсептор создан без сервиса ключей.
+var errAPIKeysUnsupported = errors.New("api keys are not supported")
+
+// userIDContextKey — ключ контекста, под которым интерсептор сохраняет идентификатор пользователя.
+type userIDContextKey struct{}
+
+// tokenRequiredMethods — методы, для которых отсутствие токена является ошибкой (аналог 401 в HTTP API).
+var tokenRequiredMethods = map[string]struct{}{
+	proto.Shortener_DeleteURLs_FullMethodName: {},
+}
+
+// anonymousMethods — методы, которым не требуется идентификатор пользователя.
+var anonymousMethods = map[string]struct{}{
+	proto.Shortener_Resolve_FullMethodName: {},
+	proto.Shortener_Ping_FullMethodName:    {},
+}
+
+// NewAuthInterceptor возвращает унарный интерсептор, извлекающий идентификатор пользователя
+// из метаданных запроса.
+//
+// Поведение повторяет HTTP-обработчики:
+// - ключ API ("Bearer shk_...") проверяется сервисом ключей;
+// - из JWT-токена со схемой Bearer извлекается userID, токен не перевыпускается;
+// - из JWT-токена без схемы извлекается userID, а скоро истекающий или подписанный прежним ключом токен перевыпускается с тем же userID;
+// - если токена нет, выпускается новый;
+// - новый или перевыпущенный токен отправляется клиенту в заголовке ответа;
+// - для DeleteURLs отсутствие токена приводит к codes.Unauthenticated.
+//
+// Параметры:
+//   - authenticator: сервис выпуска и проверки токенов.
+//   - apiKeys: сервис проверки ключей API; nil, если ключи API не поддерживаются.
+//
+// Возвращает:
+//   - grpc.UnaryServerInterceptor: интерсептор для регистрации на сервере.
+func NewAuthInterceptor(authenticator *security.Authenticator, apiKeys auth.APIKeyVerifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := anonymousMethods[info.FullMethod]; ok {
+			return handler(ctx, req)
+		}
+		var session security.Session
+		var err error
+		token, bearer := getToken(ctx)
+		switch {
+		case token == "":
+			if _, ok := tokenRequiredMethods[info.FullMethod]; ok {
+				return nil, status.Error(codes.Unauthenticated, "unauthorized")
+			}
+			if session, err = authenticator.NewSession(); err != nil {
+				return nil, status.Errorf(codes.Internal, "build token: %s", err.Error())
+			}
+		case security.IsAPIKey(token):
+			if session.UserID, err = verifyAPIKey(ctx, apiKeys, token); err != nil {
+				return nil, err
+			}
+		case bearer:
+			_, span := tracing.Start(ctx, "security.UserID")
+			session.UserID, err = authenticator.UserID(token)
+			tracing.End(span, err)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+		default:
+			_, span := tracing.Start(ctx, "security.Authenticate")
+			session, err = authenticator.Authenticate(token)
+			tracing.End(span, err)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+		}
+		if session.Token != "" {
+			if err = grpc.SetHeader(ctx, metadata.Pairs(AuthorizationMetadataKey, session.Token)); err != nil {
+				return nil, status.Errorf(codes.Internal, "set header: %s", err.Error())
+			}
+		}
+		return handler(context.WithValue(ctx, userIDContextKey{}, session.UserID), req)
+	}
+}
+
+// verifyAPIKey проверяет ключ API сервисом ключей.
+//
+// Возвращает:
+//   - string: идентификатор владельца ключа.
+//   - error: nil, если ключ действителен; ошибку со статусом codes.Unauthenticated, если ключ
+//     недействителен или ключи не поддерживаются; иначе — ошибку со статусом codes.Internal.
+func verifyAPIKey(ctx context.Context, apiKeys auth.APIKeyVerifier, key string) (string, error) {
+	if apiKeys == nil {
+		return "", status.Error(codes.Unauthenticated, errAPIKeysUnsupported.Error())
+	}
+	userID, err := apiKeys.VerifyAPIKey(ctx, key)
+	if errors.Is(err, service.ErrInvalidAPIKey) {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "verify api key: %s", err.Error())
+	}
+	return userID, nil
+}
+
+// getToken извлекает учётные данные из входящих метаданных запроса.
+//
+// Возвращает:
+//   - string: токен или ключ API без схемы; пустая строка, если учётные данные не переданы.
+//   - bool: true, если значение передано со схемой Bearer.
+func getToken(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	values := md.Get(AuthorizationMetadataKey)
+	if len(values) == 0 {
+		return "", false
+	}
+	scheme, token, found := strings.Cut(values[0], " ")
+	if found && strings.EqualFold(scheme, security.BearerScheme) {
+		return strings.TrimSpace(token), true
+	}
+	return values[0], false
+}
+
+// userIDFromContext возвращает идентификатор пользователя, сохранённый интерсептором.
+func userIDFromContext(ctx context.Context) (string, error) {
+	userID, ok := ctx.Value(userIDContextKey{}).(string)
+	if !ok || userID == "" {
+		return "", fmt.Errorf("rpc: %w", security.ErrNoAuthorizationToken)
+	}
+	return userID, nil
+}
@@ -0,0 +1,270 @@
+// Package rpc содержит gRPC-реализацию API сервиса сокращения ссылок.
+//
+// Каждый метод повторяет поведение соответствующего HTTP-обработчика из пакета handler
+// и использует тот же сервис service.Shortener.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/app/rpc/proto"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/app/urlcheck"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"time"
+)
+
+// Server — реализация proto.ShortenerServer поверх бизнес-логики сервиса.
+type Server struct {
+	proto.UnimplementedShortenerServer
+	service     shortener
+	pingChecker pingChecker
+}
+
+type shortener interface {
+	Create(ctx context.Context, fullURL string, userID string) (string, error)
+	CreateWithJSON(ctx context.Context, req model.CreateShortRequest, userID string) (string, error)
+	CreateWithBatch(ctx context.Context, batch []model.CreateShortRequestBatchItemRequest, userID string) ([]model.CreateShortRequestBatchItemResponse, error)
+	FindByHash(ctx context.Context, hashURL string) (string, error)
+	FindAllByUserID(ctx context.Context, userID string) ([]model.FindURLByUserIDResponse, error)
+	DeleteAsync(ctx context.Context, ids []string, userID string) error
+}
+
+type pingChecker interface {
+	Ping(ctx context.Context) (bool, error)
+}
+
+// Shorten создаёт короткую ссылку, аналогично POST /.
+//
+// Возможные коды:
+// - codes.OK — ссылка создана или уже существовала (already_exists = true).
+// - codes.InvalidArgument — невалидный URL или URL не прошёл проверки безопасности.
+// - codes.Unauthenticated — недействительный токен.
+// - codes.Unavailable — хранилище не ответило вовремя.
+// - codes.Internal — внутренняя ошибка сервера.
+func (s *Server) Shorten(ctx context.Context, req *proto.ShortenRequest) (*proto.ShortenResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	shortURL, err := s.service.Create(ctx, req.GetUrl(), userID)
+	alreadyExists, err := createResult(err)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.ShortenResponse{ShortUrl: shortURL, AlreadyExists: alreadyExists}, nil
+}
+
+// ShortenURL создаёт короткую ссылку, аналогично POST /api/shorten.
+//
+// Возможные коды:
+// - codes.OK — ссылка создана или уже существовала (already_exists = true).
+// - codes.InvalidArgument — пустой, невалидный или не прошедший проверки безопасности URL, невалидный алиас или срок жизни.
+// - codes.AlreadyExists — алиас уже занят.
+// - codes.Unauthenticated — недействительный токен.
+// - codes.Unavailable — хранилище не ответило вовремя.
+// - codes.Internal — внутренняя ошибка сервера.
+func (s *Server) ShortenURL(ctx context.Context, req *proto.ShortenURLRequest) (*proto.ShortenURLResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	createRequest := model.CreateShortRequest{
+		URL:        req.GetUrl(),
+		Alias:      req.GetAlias(),
+		ExpiresAt:  timestampToTime(req.GetExpiresAt()),
+		TTLSeconds: req.GetTtlSeconds(),
+	}
+	if err = createRequest.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	shortURL, err := s.service.CreateWithJSON(ctx, createRequest, userID)
+	alreadyExists, err := createResult(err)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.ShortenURLResponse{Result: shortURL, AlreadyExists: alreadyExists}, nil
+}
+
+// BatchShorten создаёт несколько коротких ссылок, аналогично POST /api/shorten/batch.
+//
+// Возможные коды:
+// - codes.OK — ссылки созданы.
+// - codes.InvalidArgument — невалидный алиас или срок жизни, URL не прошёл проверки безопасности.
+// - codes.AlreadyExists — один из алиасов уже занят.
+// - codes.Unauthenticated — недействительный токен.
+// - codes.Unavailable — хранилище не ответило вовремя.
+// - codes.Internal — внутренняя ошибка сервера.
+func (s *Server) BatchShorten(ctx context.Context, req *proto.BatchShortenRequest) (*proto.BatchShortenResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	batchRequest := make([]model.CreateShortRequestBatchItemRequest, 0, len(req.GetItems()))
+	for _, item := range req.GetItems() {
+		batchRequest = append(batchRequest, model.CreateShortRequestBatchItemRequest{
+			CorrelationID: item.GetCorrelationId(),
+			OriginalURL:   item.GetOriginalUrl(),
+			Alias:         item.GetAlias(),
+			ExpiresAt:     timestampToTime(item.GetExpiresAt()),
+			TTLSeconds:    item.GetTtlSeconds(),
+		})
+	}
+	batchResponse, err := s.service.CreateWithBatch(ctx, batchRequest, userID)
+	if isInvalidArgument(err) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, repository.ErrAliasAlreadyTaken) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
+	if err != nil {
+		return nil, internalStatus(err)
+	}
+	resp := &proto.BatchShortenResponse{Items: make([]*proto.BatchShortenItemResponse, 0, len(batchResponse))}
+	for _, item := range batchResponse {
+		resp.Items = append(resp.Items, &proto.BatchShortenItemResponse{
+			CorrelationId: item.CorrelationID,
+			ShortUrl:      item.ShortURL,
+		})
+	}
+	return resp, nil
+}
+
+// Resolve возвращает оригинальный URL по хэшу, аналогично GET /{hash}.
+//
+// Возможные коды:
+// - codes.OK — ссылка найдена.
+// - codes.NotFound — ссылка не найдена.
+// - codes.FailedPrecondition — ссылка была удалена, отключена или истекла (аналог 410 Gone).
+// - codes.Unavailable — хранилище не ответило вовремя.
+// - codes.Internal — внутренняя ошибка сервера.
+func (s *Server) Resolve(ctx context.Context, req *proto.ResolveRequest) (*proto.ResolveResponse, error) {
+	fullURL, err := s.service.FindByHash(ctx, req.GetHash())
+	if errors.Is(err, repository.ErrRecordDeleted) || errors.Is(err, repository.ErrRecordExpired) || errors.Is(err, repository.ErrRecordDisabled) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if errors.Is(err, repository.ErrRecordNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, internalStatus(err)
+	}
+	return &proto.ResolveResponse{OriginalUrl: fullURL}, nil
+}
+
+// ListUserURLs возвращает все ссылки текущего пользователя, аналогично GET /api/user/urls.
+//
+// В отличие от HTTP API, отсутствие ссылок возвращается как пустой список, а не 204 No Content.
+//
+// Возможные коды:
+// - codes.OK — список ссылок возвращён.
+// - codes.Unauthenticated — недействительный токен.
+// - codes.Unavailable — хранилище не ответило вовремя.
+// - codes.Internal — внутренняя ошибка сервера.
+func (s *Server) ListUserURLs(ctx context.Context, _ *proto.ListUserURLsRequest) (*proto.ListUserURLsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	urls, err := s.service.FindAllByUserID(ctx, userID)
+	if err != nil {
+		return nil, internalStatus(err)
+	}
+	resp := &proto.ListUserURLsResponse{Urls: make([]*proto.UserURL, 0, len(urls))}
+	for _, u := range urls {
+		resp.Urls = append(resp.Urls, &proto.UserURL{ShortUrl: u.ShortURL, OriginalUrl: u.OriginalURL})
+	}
+	return resp, nil
+}
+
+// DeleteURLs асинхронно удаляет ссылки пользователя, аналогично DELETE /api/user/urls.
+//
+// Возможные коды:
+// - codes.OK — запрос принят на обработку.
+// - codes.Unauthenticated — токен отсутствует или недействителен.
+// - codes.Unavailable — сервис останавливается, очередь удаления переполнена или хранилище не ответило вовремя.
+// - codes.Internal — внутренняя ошибка сервера.
+func (s *Server) DeleteURLs(ctx context.Context, req *proto.DeleteURLsRequest) (*proto.DeleteURLsResponse, error) {
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err = s.service.DeleteAsync(ctx, req.GetHashes(), userID); err != nil {
+		if errors.Is(err, service.ErrShuttingDown) || errors.Is(err, service.ErrDeletionQueueFull) {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return nil, internalStatus(err)
+	}
+	return &proto.DeleteURLsResponse{}, nil
+}
+
+// Ping проверяет доступность хранилища, аналогично GET /ping.
+//
+// Возможные коды:
+// - codes.OK — хранилище доступно.
+// - codes.Unavailable — хранилище недоступно.
+func (s *Server) Ping(ctx context.Context, _ *proto.PingRequest) (*proto.PingResponse, error) {
+	if _, err := s.pingChecker.Ping(ctx); err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &proto.PingResponse{}, nil
+}
+
+// createResult преобразует ошибку создания ссылки в gRPC-статус, общий для Shorten и ShortenURL.
+//
+// Возвращает признак того, что ссылка уже существовала, и ошибку со статусом gRPC.
+func createResult(err error) (bool, error) {
+	if isInvalidArgument(err) {
+		return false, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.Is(err, repository.ErrAliasAlreadyTaken) {
+		return false, status.Error(codes.AlreadyExists, err.Error())
+	}
+	isUniqueConstraintViolation := errors.Is(err, repository.ErrURLAlreadyShortened)
+	if err != nil && !isUniqueConstraintViolation {
+		return false, internalStatus(err)
+	}
+	return isUniqueConstraintViolation, nil
+}
+
+// isInvalidArgument сообщает, что err — ошибка валидации URL, алиаса или срока жизни
+// либо отклонение URL проверками безопасности.
+func isInvalidArgument(err error) bool {
+	return errors.Is(err, security.ErrInvalidURL) || errors.Is(err, urlcheck.ErrRejected) ||
+		errors.Is(err, model.ErrInvalidAlias) || errors.Is(err, model.ErrReservedAlias) || errors.Is(err, model.ErrInvalidExpiration)
+}
+
+// internalStatus преобразует непредвиденную ошибку сервиса в gRPC-статус: истёкший таймаут
+// запроса к хранилищу — codes.Unavailable (запрос можно повторить), остальное — codes.Internal.
+func internalStatus(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// timestampToTime преобразует необязательный protobuf Timestamp во время; nil остаётся nil.
+func timestampToTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+// NewServer создаёт новый экземпляр gRPC-сервера сокращения ссылок.
+//
+// Параметры:
+//   - s: сервис с бизнес-логикой (обычно *service.Shortener).
+//   - pingChecker: реализация проверки доступности хранилища.
+//
+// Возвращает:
+//   - *Server: готовая к регистрации реализация proto.ShortenerServer.
+func NewServer(s shortener, pingChecker pingChecker) *Server {
+	return &Server{service: s, pingChecker: pingChecker}
+}
@@ -0,0 +1,272 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/app/repository/inmemory"
+	"github.com/faust8888/shortener/internal/app/rpc/proto"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/app/shortcode"
+	"github.com/faust8888/shortener/internal/app/urlcheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newTestAuthenticator создаёт Authenticator с ключом и параметрами токенов из конфигурации.
+func newTestAuthenticator(t *testing.T, cfg *config.Config) *security.Authenticator {
+	auth, err := security.NewAuthenticator(security.NewKeyring(cfg.AuthKey), security.AuthOptions{
+		TokenTTL:       cfg.AuthTokenTTL,
+		RefreshWindow:  cfg.AuthTokenRefreshWindow,
+		CookieSameSite: cfg.AuthCookieSameSite,
+	})
+	require.NoError(t, err)
+	return auth
+}
+
+func startTestServer(t *testing.T) proto.ShortenerClient {
+	client, _, _ := startTestServerWithAuth(t)
+	return client
+}
+
+// startTestServerWithAuth запускает сервер и возвращает клиента вместе с сервисами токенов и ключей API сервера.
+func startTestServerWithAuth(t *testing.T) (proto.ShortenerClient, *security.Authenticator, *service.APIKeys) {
+	cfg := config.Create()
+	repo := inmemory.NewInMemoryRepository(cfg)
+	generator, err := shortcode.NewGenerator(cfg.ShortCodeStrategy, cfg.ShortCodeLength, repo)
+	require.NoError(t, err)
+	deletions, err := service.NewDeletionQueue(repo, cfg.DeleteQueueCapacity, cfg.DeleteBatchSize, cfg.DeleteFlushInterval, cfg.DeleteWorkers, "")
+	require.NoError(t, err)
+	shortener := service.CreateShortener(repo, cfg.BaseShortURL, generator, nil, deletions, nil)
+	authenticator := newTestAuthenticator(t, cfg)
+	apiKeys := service.NewAPIKeys(repo)
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnaryInterceptor(NewAuthInterceptor(authenticator, apiKeys)))
+	proto.RegisterShortenerServer(server, NewServer(shortener, repo))
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return proto.NewShortenerClient(conn), authenticator, apiKeys
+}
+
+func TestShortenAndResolve(t *testing.T) {
+	client := startTestServer(t)
+	ctx := context.Background()
+
+	var header metadata.MD
+	shortenResponse, err := client.Shorten(ctx, &proto.ShortenRequest{Url: "https://yandex.ru/grpc"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Regexp(t, `^https?://localhost:\d+/[\w\-]+$`, shortenResponse.GetShortUrl())
+	require.NotEmpty(t, header.Get(AuthorizationMetadataKey))
+
+	parsedURL, _ := url.Parse(shortenResponse.GetShortUrl())
+	hash := strings.TrimPrefix(parsedURL.Path, "/")
+	resolveResponse, err := client.Resolve(ctx, &proto.ResolveRequest{Hash: hash})
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru/grpc", resolveResponse.GetOriginalUrl())
+
+	_, err = client.Resolve(ctx, &proto.ResolveRequest{Hash: "not_existing_hash"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestShortenURLValidation(t *testing.T) {
+	client := startTestServer(t)
+
+	_, err := client.ShortenURL(context.Background(), &proto.ShortenURLRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestBatchShortenAndListUserURLs(t *testing.T) {
+	client := startTestServer(t)
+
+	var header metadata.MD
+	batchResponse, err := client.BatchShorten(context.Background(), &proto.BatchShortenRequest{
+		Items: []*proto.BatchShortenItemRequest{
+			{CorrelationId: "1", OriginalUrl: "https://yandex.ru/batch/1"},
+			{CorrelationId: "2", OriginalUrl: "https://yandex.ru/batch/2"},
+		},
+	}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Len(t, batchResponse.GetItems(), 2)
+
+	token := header.Get(AuthorizationMetadataKey)
+	require.NotEmpty(t, token)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), AuthorizationMetadataKey, token[0])
+	listResponse, err := client.ListUserURLs(ctx, &proto.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, listResponse.GetUrls(), 2)
+}
+
+func TestDeleteURLsRequiresToken(t *testing.T) {
+	client := startTestServer(t)
+
+	_, err := client.DeleteURLs(context.Background(), &proto.DeleteURLsRequest{Hashes: []string{"abc"}})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestPing(t *testing.T) {
+	client := startTestServer(t)
+
+	_, err := client.Ping(context.Background(), &proto.PingRequest{})
+	assert.NoError(t, err)
+}
+
+func TestBearerTokenAuth(t *testing.T) {
+	client, authenticator, _ := startTestServerWithAuth(t)
+	session, err := authenticator.NewSession()
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), AuthorizationMetadataKey, "Bearer "+session.Token)
+
+	var header metadata.MD
+	_, err = client.Shorten(ctx, &proto.ShortenRequest{Url: "https://yandex.ru/bearer"}, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Empty(t, header.Get(AuthorizationMetadataKey), "a bearer token must not be reissued")
+	listResponse, err := client.ListUserURLs(ctx, &proto.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, listResponse.GetUrls(), 1)
+
+	// Недействительный токен со схемой Bearer отклоняется.
+	ctx = metadata.AppendToOutgoingContext(context.Background(), AuthorizationMetadataKey, "Bearer invalid")
+	_, err = client.ListUserURLs(ctx, &proto.ListUserURLsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	client, _, apiKeys := startTestServerWithAuth(t)
+	key, err := apiKeys.Create(context.Background(), "key-owner", model.CreateAPIKeyRequest{Name: "cli"})
+	require.NoError(t, err)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), AuthorizationMetadataKey, "Bearer "+key.Key)
+
+	_, err = client.Shorten(ctx, &proto.ShortenRequest{Url: "https://yandex.ru/api-key"})
+	require.NoError(t, err)
+	listResponse, err := client.ListUserURLs(ctx, &proto.ListUserURLsRequest{})
+	require.NoError(t, err)
+	assert.Len(t, listResponse.GetUrls(), 1)
+	_, err = client.DeleteURLs(ctx, &proto.DeleteURLsRequest{Hashes: []string{"abc"}})
+	assert.NoError(t, err, "an api key must be accepted where a token is required")
+
+	// Отозванный и неизвестный ключи отклоняются.
+	_, err = apiKeys.Revoke(context.Background(), key.ID, "key-owner")
+	require.NoError(t, err)
+	_, err = client.ListUserURLs(ctx, &proto.ListUserURLsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	ctx = metadata.AppendToOutgoingContext(context.Background(), AuthorizationMetadataKey, "Bearer shk_unknown")
+	_, err = client.ListUserURLs(ctx, &proto.ListUserURLsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+// failingShortener возвращает заданную ошибку из каждого метода сервиса.
+type failingShortener struct {
+	err error
+}
+
+func (f failingShortener) Create(context.Context, string, string) (string, error) {
+	return "", f.err
+}
+
+func (f failingShortener) CreateWithJSON(context.Context, model.CreateShortRequest, string) (string, error) {
+	return "", f.err
+}
+
+func (f failingShortener) CreateWithBatch(context.Context, []model.CreateShortRequestBatchItemRequest, string) ([]model.CreateShortRequestBatchItemResponse, error) {
+	return nil, f.err
+}
+
+func (f failingShortener) FindByHash(context.Context, string) (string, error) {
+	return "", f.err
+}
+
+func (f failingShortener) FindAllByUserID(context.Context, string) ([]model.FindURLByUserIDResponse, error) {
+	return nil, f.err
+}
+
+func (f failingShortener) DeleteAsync(context.Context, []string, string) error {
+	return f.err
+}
+
+func TestServerErrorCodes(t *testing.T) {
+	ctx := context.WithValue(context.Background(), userIDContextKey{}, "user")
+	tests := []struct {
+		name        string
+		err         error
+		wantCreate  codes.Code
+		wantResolve codes.Code
+		wantDelete  codes.Code
+	}{
+		{
+			name:        "Invalid URL",
+			err:         fmt.Errorf("hash for url: %w", security.ErrInvalidURL),
+			wantCreate:  codes.InvalidArgument,
+			wantResolve: codes.Internal,
+			wantDelete:  codes.Internal,
+		},
+		{
+			name:        "Rejected URL",
+			err:         fmt.Errorf("hash for url: %w", &urlcheck.Error{Code: urlcheck.CodePrivateAddress, URL: "http://127.0.0.1"}),
+			wantCreate:  codes.InvalidArgument,
+			wantResolve: codes.Internal,
+			wantDelete:  codes.Internal,
+		},
+		{
+			name:        "Unknown short URL",
+			err:         fmt.Errorf("find by hash: %w for x", repository.ErrRecordNotFound),
+			wantCreate:  codes.Internal,
+			wantResolve: codes.NotFound,
+			wantDelete:  codes.Internal,
+		},
+		{
+			name:        "Storage timeout",
+			err:         fmt.Errorf("saving data: %w", context.DeadlineExceeded),
+			wantCreate:  codes.Unavailable,
+			wantResolve: codes.Unavailable,
+			wantDelete:  codes.Unavailable,
+		},
+		{
+			name:        "Storage failure",
+			err:         errors.New("connection reset by peer"),
+			wantCreate:  codes.Internal,
+			wantResolve: codes.Internal,
+			wantDelete:  codes.Internal,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := NewServer(failingShortener{err: test.err}, nil)
+
+			_, err := server.Shorten(ctx, &proto.ShortenRequest{Url: "https://yandex.ru"})
+			assert.Equal(t, test.wantCreate, status.Code(err))
+			_, err = server.ShortenURL(ctx, &proto.ShortenURLRequest{Url: "https://yandex.ru"})
+			assert.Equal(t, test.wantCreate, status.Code(err))
+			_, err = server.BatchShorten(ctx, &proto.BatchShortenRequest{})
+			assert.Equal(t, test.wantCreate, status.Code(err))
+			_, err = server.Resolve(ctx, &proto.ResolveRequest{Hash: "x"})
+			assert.Equal(t, test.wantResolve, status.Code(err))
+			_, err = server.DeleteURLs(ctx, &proto.DeleteURLsRequest{Hashes: []string{"x"}})
+			assert.Equal(t, test.wantDelete, status.Code(err))
+		})
+	}
+}
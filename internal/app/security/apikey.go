@@ -0,0 +1,73 @@
+package security
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// APIKeyPrefix — префикс ключей API. По нему ключ отличается от JWT-токена
+	// в заголовке Authorization и узнаётся в конфигурационных файлах и логах.
+	APIKeyPrefix = "shk_"
+
+	// apiKeySize — количество случайных байтов ключа API.
+	apiKeySize = 32
+
+	// apiKeyVisibleLength — длина начала ключа, которое хранится открыто, чтобы пользователь
+	// мог отличить свои ключи в списке.
+	apiKeyVisibleLength = len(APIKeyPrefix) + 6
+)
+
+// GenerateAPIKey создаёт новый ключ API: APIKeyPrefix и 32 случайных байта в base64url.
+//
+// Возвращает:
+//   - string: ключ API; показывается пользователю один раз и не хранится.
+//   - error: nil, если успешно, иначе — ошибку.
+func GenerateAPIKey() (string, error) {
+	secret, err := generateRandom(apiKeySize)
+	if err != nil {
+		return "", fmt.Errorf("security.generateAPIKey: %w", err)
+	}
+	return APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashAPIKey возвращает отпечаток ключа API, под которым ключ хранится в репозитории.
+// Ключ содержит 256 случайных битов, поэтому соль и медленное хэширование не нужны.
+//
+// Параметры:
+//   - key: ключ API.
+//
+// Возвращает:
+//   - string: hex-представление SHA-256 ключа.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefixOf возвращает открыто хранимое начало ключа API.
+//
+// Параметры:
+//   - key: ключ API.
+//
+// Возвращает:
+//   - string: APIKeyPrefix и первые символы случайной части ключа.
+func APIKeyPrefixOf(key string) string {
+	if len(key) <= apiKeyVisibleLength {
+		return key
+	}
+	return key[:apiKeyVisibleLength]
+}
+
+// IsAPIKey сообщает, что токен из заголовка Authorization — ключ API, а не JWT-токен.
+//
+// Параметры:
+//   - token: значение токена.
+//
+// Возвращает:
+//   - bool: true, если токен начинается с APIKeyPrefix.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
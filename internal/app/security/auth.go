@@ -0,0 +1,215 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SameSite-режимы куки аутентификации в конфигурации.
+const (
+	// SameSiteLax — кука отправляется при переходах на сайт, но не в межсайтовых подзапросах.
+	SameSiteLax = "lax"
+	// SameSiteStrict — кука отправляется только в запросах с того же сайта.
+	SameSiteStrict = "strict"
+	// SameSiteNone — кука отправляется во всех запросах; браузеры требуют вместе с ней Secure.
+	SameSiteNone = "none"
+)
+
+// Session — результат аутентификации запроса.
+type Session struct {
+	// UserID — идентификатор пользователя.
+	UserID string
+	// Token — токен, который нужно передать клиенту: новый или перевыпущенный;
+	// пустая строка, если клиент может продолжать пользоваться прежним токеном.
+	Token string
+}
+
+// Authenticator выпускает и проверяет токены аутентификации пользователей.
+//
+// Токены продлеваются скользящим образом: если до истечения токена осталось меньше refreshWindow
+// или он подписан не активным ключом, или выпущен до появления iss и aud, Authenticate перевыпускает его
+// с тем же UserID.
+// Поэтому активный пользователь не теряет доступ к своим ссылкам ни по истечении срока токена,
+// ни при смене ключа подписи.
+type Authenticator struct {
+	keys          *Keyring      // Ключи подписи токенов
+	ttl           time.Duration // Время жизни токена и куки
+	refreshWindow time.Duration // Остаток срока жизни, при котором токен перевыпускается
+	cookie        http.Cookie   // Атрибуты куки с токеном
+}
+
+// NewSession выпускает токен для нового пользователя.
+//
+// Возвращает:
+//   - Session: идентификатор нового пользователя и его токен.
+//   - error: nil, если успешно, иначе — ошибку.
+func (a *Authenticator) NewSession() (Session, error) {
+	userID, err := generateUserID()
+	if err != nil {
+		return Session{}, fmt.Errorf("security.authenticator.newSession: generate new user id: %w", err)
+	}
+	token, err := a.issue(userID)
+	if err != nil {
+		return Session{}, fmt.Errorf("security.authenticator.newSession: %w", err)
+	}
+	return Session{UserID: userID, Token: token}, nil
+}
+
+// Authenticate проверяет токен и при необходимости перевыпускает его.
+//
+// Параметры:
+//   - token: строковое представление JWT-токена.
+//
+// Возвращает:
+//   - Session: идентификатор пользователя и, если токен перевыпущен, новый токен.
+//   - error: nil, если токен действителен, иначе — ошибку.
+func (a *Authenticator) Authenticate(token string) (Session, error) {
+	claims, activeKey, err := a.keys.Verify(token)
+	if err != nil {
+		return Session{}, fmt.Errorf("security.authenticator.authenticate: %w", err)
+	}
+	if claims.UserID == "" {
+		return Session{}, ErrNoAuthorizationToken
+	}
+	session := Session{UserID: claims.UserID}
+	if activeKey && !a.expiresSoon(claims) {
+		return session, nil
+	}
+	session.Token, err = a.issue(claims.UserID)
+	if err != nil {
+		return Session{}, fmt.Errorf("security.authenticator.authenticate: refresh: %w", err)
+	}
+	return session, nil
+}
+
+// UserID проверяет токен и возвращает идентификатор пользователя, не перевыпуская токен.
+//
+// Параметры:
+//   - token: строковое представление JWT-токена.
+//
+// Возвращает:
+//   - string: идентификатор пользователя.
+//   - error: nil, если токен действителен, иначе — ошибку.
+func (a *Authenticator) UserID(token string) (string, error) {
+	claims, _, err := a.keys.Verify(token)
+	if err != nil {
+		return "", fmt.Errorf("security.authenticator.userID: %w", err)
+	}
+	if claims.UserID == "" {
+		return "", ErrNoAuthorizationToken
+	}
+	return claims.UserID, nil
+}
+
+// Cookie возвращает куку с токеном и атрибутами из конфигурации; Max-Age равен времени жизни токена.
+//
+// Параметры:
+//   - token: токен аутентификации.
+//
+// Возвращает:
+//   - *http.Cookie: кука для http.SetCookie.
+func (a *Authenticator) Cookie(token string) *http.Cookie {
+	cookie := a.cookie
+	cookie.Value = token
+	return &cookie
+}
+
+// JWKS возвращает открытые ключи, которыми другие сервисы могут проверить токены.
+//
+// Возвращает:
+//   - JWKS: набор ключей; пустой, если токены подписываются HMAC.
+func (a *Authenticator) JWKS() JWKS {
+	return a.keys.JWKS()
+}
+
+// issue подписывает токен пользователя активным ключом.
+func (a *Authenticator) issue(userID string) (string, error) {
+	now := time.Now()
+	return a.keys.Sign(Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(a.ttl)),
+		},
+		UserID: userID,
+	})
+}
+
+// expiresSoon сообщает, что до истечения токена осталось меньше refreshWindow.
+// Токены без срока действия отклоняются при проверке (см. Keyring.Verify), поэтому exp всегда задан.
+func (a *Authenticator) expiresSoon(claims *Claims) bool {
+	return time.Until(claims.ExpiresAt.Time) < a.refreshWindow
+}
+
+// AuthOptions — параметры выпуска токенов и куки аутентификации.
+type AuthOptions struct {
+	// TokenTTL — время жизни токена и Max-Age куки.
+	TokenTTL time.Duration
+	// RefreshWindow — если до истечения токена осталось меньше этого времени, он перевыпускается.
+	RefreshWindow time.Duration
+	// CookieSecure — атрибут Secure куки.
+	CookieSecure bool
+	// CookieHTTPOnly — атрибут HttpOnly куки.
+	CookieHTTPOnly bool
+	// CookieSameSite — атрибут SameSite куки: SameSiteLax, SameSiteStrict или SameSiteNone.
+	CookieSameSite string
+	// CookiePath — атрибут Path куки.
+	CookiePath string
+}
+
+// parseSameSite разбирает значение атрибута SameSite из конфигурации.
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case SameSiteLax:
+		return http.SameSiteLaxMode, nil
+	case SameSiteStrict:
+		return http.SameSiteStrictMode, nil
+	case SameSiteNone:
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("unknown SameSite %q", value)
+	}
+}
+
+// NewAuthenticator создаёт Authenticator.
+//
+// Параметры:
+//   - keys: ключи подписи токенов.
+//   - options: параметры выпуска токенов и куки.
+//
+// Возвращает:
+//   - *Authenticator: готовый к использованию объект.
+//   - error: nil, если успешно; иначе — ошибку параметров: неположительное время жизни,
+//     окно продления не меньше времени жизни, неизвестный SameSite или SameSite=None без Secure.
+func NewAuthenticator(keys *Keyring, options AuthOptions) (*Authenticator, error) {
+	if options.TokenTTL <= 0 {
+		return nil, errors.New("security.newAuthenticator: token ttl must be positive")
+	}
+	if options.RefreshWindow < 0 || options.RefreshWindow >= options.TokenTTL {
+		return nil, errors.New("security.newAuthenticator: token refresh window must be shorter than token ttl")
+	}
+	sameSite, err := parseSameSite(options.CookieSameSite)
+	if err != nil {
+		return nil, fmt.Errorf("security.newAuthenticator: %w", err)
+	}
+	if sameSite == http.SameSiteNoneMode && !options.CookieSecure {
+		return nil, errors.New("security.newAuthenticator: SameSite=None cookie requires Secure")
+	}
+	return &Authenticator{
+		keys:          keys,
+		ttl:           options.TokenTTL,
+		refreshWindow: options.RefreshWindow,
+		cookie: http.Cookie{
+			Name:     AuthorizationTokenName,
+			Path:     options.CookiePath,
+			MaxAge:   int(options.TokenTTL.Seconds()),
+			Secure:   options.CookieSecure,
+			HttpOnly: options.CookieHTTPOnly,
+			SameSite: sameSite,
+		},
+	}, nil
+}
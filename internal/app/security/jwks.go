@@ -0,0 +1,59 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JWK — открытый ключ подписи токенов в формате JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Modulus   string `json:"n,omitempty"`
+	Exponent  string `json:"e,omitempty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// JWKS — набор открытых ключей, которыми другие сервисы проверяют токены сервиса.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS возвращает открытые ключи набора: активный и прежние. HMAC-секреты не публикуются,
+// поэтому при HS256 набор пуст.
+//
+// Возвращает:
+//   - JWKS: набор ключей для /.well-known/jwks.json.
+func (k *Keyring) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range append([]signingKey{k.active}, k.previous...) {
+		jwk, ok := key.jwk()
+		if ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// jwk представляет открытый ключ в формате JWK; false — ключ не публикуется.
+func (s signingKey) jwk() (JWK, bool) {
+	jwk := JWK{KeyID: s.id, Algorithm: s.method.Alg(), Use: "sig"}
+	switch public := s.verifyKey.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.Modulus = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.Exponent = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
@@ -0,0 +1,366 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"os"
+	"strings"
+)
+
+// KeyIDHeader — заголовок JWT с идентификатором ключа, которым подписан токен.
+const KeyIDHeader = "kid"
+
+// Алгоритмы подписи токенов в конфигурации; значения совпадают с заголовком alg.
+const (
+	// AlgorithmHS256 — HMAC-SHA256 с общим секретом AuthKey.
+	AlgorithmHS256 = "HS256"
+	// AlgorithmEdDSA — Ed25519; открытый ключ публикуется в JWKS.
+	AlgorithmEdDSA = "EdDSA"
+	// AlgorithmRS256 — RSA PKCS#1 v1.5 с SHA-256; открытый ключ публикуется в JWKS.
+	AlgorithmRS256 = "RS256"
+)
+
+var (
+	// ErrUnknownSigningKey — ошибка проверки токена, подписанного ключом, которого нет в наборе.
+	ErrUnknownSigningKey = errors.New("token is signed with an unknown key")
+	// ErrSigningMethodNotAllowed — ошибка проверки токена, алгоритм которого не совпадает с алгоритмом ключа:
+	// например, alg=none или HS256-токен с kid асимметричного ключа.
+	ErrSigningMethodNotAllowed = errors.New("token signing method is not allowed")
+	// ErrTokenWithoutExpiration — ошибка проверки токена без срока действия (exp): такой токен был бы бессрочным.
+	ErrTokenWithoutExpiration = errors.New("token has no expiration")
+)
+
+// signingKey — ключ подписи токенов с его идентификатором и алгоритмом.
+type signingKey struct {
+	id        string            // Идентификатор ключа, передаваемый в заголовке kid
+	method    jwt.SigningMethod // Единственный алгоритм, которым ключ подписывает и проверяет токены
+	signKey   interface{}       // Секрет HMAC или закрытый ключ; nil у ключей, которые только проверяют токены
+	verifyKey interface{}       // Секрет HMAC или открытый ключ
+}
+
+// public сообщает, что ключ асимметричный и его можно опубликовать в JWKS.
+func (s signingKey) public() bool {
+	_, hmac := s.method.(*jwt.SigningMethodHMAC)
+	return !hmac
+}
+
+// Keyring — набор ключей подписи токенов.
+//
+// Новые токены подписываются активным ключом, а в заголовок kid записывается его идентификатор.
+// Прежние ключи только проверяют выпущенные ими токены: это позволяет сменить AuthKey или перейти
+// с HS256 на асимметричную подпись, не разлогинивая пользователей, — их токены перевыпускаются
+// активным ключом при следующем запросе. Токены без kid, выпущенные до появления набора ключей,
+// проверяются по очереди всеми ключами с алгоритмом из их заголовка.
+//
+// Каждый ключ принимает токены только своего алгоритма, поэтому подменить alg в заголовке
+// (none, HS256 с открытым ключом в роли секрета) нельзя. Если заданы issuer и audience,
+// токен должен содержать их в claims iss и aud.
+type Keyring struct {
+	active      signingKey   // Ключ подписи новых токенов
+	previous    []signingKey // Ключи, которыми токены только проверяются
+	issuer      string       // Ожидаемый iss; пустая строка — не проверяется
+	audience    string       // Ожидаемый aud; пустая строка — не проверяется
+	allowLegacy bool         // Принимать HMAC-токены без iss и aud, выпущенные до их появления
+}
+
+// Sign подписывает claims активным ключом; пустые iss и aud заполняются значениями набора.
+//
+// Параметры:
+//   - claims: содержимое токена.
+//
+// Возвращает:
+//   - string: готовый токен с заголовком kid.
+//   - error: nil, если успешно, иначе — ошибку.
+func (k *Keyring) Sign(claims Claims) (string, error) {
+	if claims.Issuer == "" {
+		claims.Issuer = k.issuer
+	}
+	if len(claims.Audience) == 0 && k.audience != "" {
+		claims.Audience = jwt.ClaimStrings{k.audience}
+	}
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header[KeyIDHeader] = k.active.id
+	signed, err := token.SignedString(k.active.signKey)
+	if err != nil {
+		return "", fmt.Errorf("security.keyring.sign: %w", err)
+	}
+	return signed, nil
+}
+
+// Verify проверяет алгоритм, подпись, срок действия (exp обязателен), iat, nbf, iss и aud токена.
+//
+// Параметры:
+//   - token: строковое представление JWT-токена.
+//
+// Возвращает:
+//   - *Claims: содержимое токена.
+//   - bool: true, если токен подписан активным ключом, содержит его kid и все обязательные claims;
+//     иначе токен действителен, но его нужно перевыпустить.
+//   - error: nil, если токен действителен; ErrUnknownSigningKey, если kid не найден в наборе;
+//     ErrSigningMethodNotAllowed, если алгоритм токена не совпадает с алгоритмом ключа;
+//     ErrTokenWithoutExpiration, если в токене нет exp; jwt.ErrTokenUsedBeforeIssued, если iat в будущем;
+//     jwt.ErrTokenInvalidIssuer или jwt.ErrTokenInvalidAudience при чужих iss и aud; иначе — ошибку разбора.
+func (k *Keyring) Verify(token string) (*Claims, bool, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		return nil, false, fmt.Errorf("security.keyring.verify: %w", err)
+	}
+	alg := unverified.Method.Alg()
+	keys := append([]signingKey{k.active}, k.previous...)
+	kid, hasKID := unverified.Header[KeyIDHeader].(string)
+	if hasKID {
+		key, ok := k.find(kid)
+		if !ok {
+			return nil, false, fmt.Errorf("security.keyring.verify: %w", ErrUnknownSigningKey)
+		}
+		keys = []signingKey{key}
+	}
+	candidates := keys[:0:0]
+	for _, key := range keys {
+		if key.method.Alg() == alg {
+			candidates = append(candidates, key)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, false, fmt.Errorf("security.keyring.verify: %w: %s", ErrSigningMethodNotAllowed, alg)
+	}
+	for _, key := range candidates {
+		claims := &Claims{}
+		parser := jwt.NewParser(jwt.WithValidMethods([]string{key.method.Alg()}))
+		_, err = parser.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+			return key.verifyKey, nil
+		})
+		if err == nil {
+			legacy, err := k.verifyClaims(claims, key)
+			if err != nil {
+				return nil, false, fmt.Errorf("security.keyring.verify: %w", err)
+			}
+			return claims, hasKID && key.id == k.active.id && !legacy, nil
+		}
+		if !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+			break
+		}
+	}
+	return nil, false, fmt.Errorf("security.keyring.verify: %w", err)
+}
+
+// verifyClaims проверяет наличие exp, а также iss и aud токена с проверенной подписью.
+// Значения exp, iat и nbf, если они заданы, уже проверены парсером (jwt.RegisteredClaims.Valid),
+// но парсер jwt/v4 не умеет требовать exp, поэтому его наличие проверяется здесь.
+// Возвращает true для токена без iss и aud, принятого по allowLegacy.
+func (k *Keyring) verifyClaims(claims *Claims, key signingKey) (bool, error) {
+	if claims.ExpiresAt == nil {
+		return false, ErrTokenWithoutExpiration
+	}
+	if k.issuer == "" && k.audience == "" {
+		return false, nil
+	}
+	if claims.Issuer == "" && len(claims.Audience) == 0 && k.allowLegacy && !key.public() {
+		return true, nil
+	}
+	if k.issuer != "" && !claims.VerifyIssuer(k.issuer, true) {
+		return false, jwt.ErrTokenInvalidIssuer
+	}
+	if k.audience != "" && !claims.VerifyAudience(k.audience, true) {
+		return false, jwt.ErrTokenInvalidAudience
+	}
+	return false, nil
+}
+
+// find ищет ключ по идентификатору.
+func (k *Keyring) find(id string) (signingKey, bool) {
+	if k.active.id == id {
+		return k.active, true
+	}
+	for _, key := range k.previous {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return signingKey{}, false
+}
+
+// newSigningKey создаёт HMAC-ключ подписи; идентификатор — начало SHA-256 секрета,
+// поэтому он одинаков на всех экземплярах сервиса и не раскрывает сам секрет.
+func newSigningKey(secret string) signingKey {
+	sum := sha256.Sum256([]byte(secret))
+	return signingKey{
+		id:        hex.EncodeToString(sum[:8]),
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// newPublicKey создаёт асимметричный ключ, который только проверяет токены;
+// идентификатор — начало SHA-256 открытого ключа в DER.
+func newPublicKey(public crypto.PublicKey) (signingKey, error) {
+	var method jwt.SigningMethod
+	switch public.(type) {
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	default:
+		return signingKey{}, fmt.Errorf("unsupported public key type %T", public)
+	}
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("marshal public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return signingKey{id: hex.EncodeToString(sum[:8]), method: method, verifyKey: public}, nil
+}
+
+// loadPrivateKey читает закрытый ключ алгоритма algorithm из PEM-файла.
+func loadPrivateKey(algorithm, path string) (signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("read private key: %w", err)
+	}
+	var private crypto.Signer
+	switch algorithm {
+	case AlgorithmEdDSA:
+		key, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return signingKey{}, fmt.Errorf("parse Ed25519 private key %s: %w", path, err)
+		}
+		private = key.(ed25519.PrivateKey)
+	case AlgorithmRS256:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return signingKey{}, fmt.Errorf("parse RSA private key %s: %w", path, err)
+		}
+		if key.N.BitLen() < 2048 {
+			return signingKey{}, fmt.Errorf("RSA private key %s is shorter than 2048 bits", path)
+		}
+		private = key
+	default:
+		return signingKey{}, fmt.Errorf("algorithm %q does not use a private key", algorithm)
+	}
+	key, err := newPublicKey(private.Public())
+	if err != nil {
+		return signingKey{}, err
+	}
+	key.signKey = private
+	return key, nil
+}
+
+// loadPublicKey читает открытый ключ Ed25519 или RSA из PEM-файла.
+func loadPublicKey(path string) (signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return signingKey{}, fmt.Errorf("read public key: %w", err)
+	}
+	public, err := jwt.ParseEdPublicKeyFromPEM(data)
+	if err != nil {
+		public, err = jwt.ParseRSAPublicKeyFromPEM(data)
+	}
+	if err != nil {
+		return signingKey{}, fmt.Errorf("parse public key %s: %w", path, err)
+	}
+	return newPublicKey(public)
+}
+
+// NewKeyring создаёт набор HMAC-ключей подписи токенов без проверки iss и aud.
+//
+// Параметры:
+//   - active: ключ подписи новых токенов.
+//   - previous: прежние ключи, которыми токены только проверяются; пустые значения
+//     и повторы активного ключа пропускаются.
+//
+// Возвращает:
+//   - *Keyring: набор ключей.
+func NewKeyring(active string, previous ...string) *Keyring {
+	k := &Keyring{active: newSigningKey(active)}
+	k.addSecrets(active, previous)
+	return k
+}
+
+// addSecrets добавляет прежние HMAC-секреты, пропуская пустые значения и повторы skip.
+func (k *Keyring) addSecrets(skip string, secrets []string) {
+	for _, secret := range secrets {
+		secret = strings.TrimSpace(secret)
+		if secret == "" || secret == skip {
+			continue
+		}
+		k.previous = append(k.previous, newSigningKey(secret))
+	}
+}
+
+// KeyringOptions — параметры набора ключей подписи токенов.
+type KeyringOptions struct {
+	// Algorithm — алгоритм подписи новых токенов: AlgorithmHS256, AlgorithmEdDSA или AlgorithmRS256.
+	Algorithm string
+	// Secret — секрет HMAC. При HS256 им подписываются новые токены, при асимметричном алгоритме
+	// он только проверяет ранее выпущенные токены.
+	Secret string
+	// PreviousSecrets — прежние секреты HMAC, которыми токены только проверяются.
+	PreviousSecrets []string
+	// PrivateKeyFile — PEM-файл закрытого ключа для EdDSA (PKCS#8) или RS256 (PKCS#1 или PKCS#8).
+	PrivateKeyFile string
+	// PreviousPublicKeyFiles — PEM-файлы открытых ключей, которыми токены только проверяются
+	// и которые публикуются в JWKS до истечения выпущенных ими токенов.
+	PreviousPublicKeyFiles []string
+	// Issuer — значение claim iss выпускаемых токенов, обязательное при проверке.
+	Issuer string
+	// Audience — значение claim aud выпускаемых токенов, обязательное при проверке.
+	Audience string
+	// AllowLegacyTokens — принимать HMAC-токены без iss и aud, выпущенные до их появления;
+	// такие токены перевыпускаются при следующем запросе.
+	AllowLegacyTokens bool
+}
+
+// NewKeyringWithOptions создаёт набор ключей с заданным алгоритмом, iss и aud.
+//
+// Параметры:
+//   - options: алгоритм, секреты, файлы ключей и ожидаемые claims.
+//
+// Возвращает:
+//   - *Keyring: набор ключей.
+//   - error: nil, если успешно; иначе — ошибку: неизвестный алгоритм, отсутствующий ключ
+//     или файл ключа, который не читается или не разбирается.
+func NewKeyringWithOptions(options KeyringOptions) (*Keyring, error) {
+	k := &Keyring{issuer: options.Issuer, audience: options.Audience, allowLegacy: options.AllowLegacyTokens}
+	switch options.Algorithm {
+	case AlgorithmHS256, "":
+		if options.Secret == "" {
+			return nil, errors.New("security.newKeyringWithOptions: HS256 requires a secret")
+		}
+		k.active = newSigningKey(options.Secret)
+		k.addSecrets(options.Secret, options.PreviousSecrets)
+	case AlgorithmEdDSA, AlgorithmRS256:
+		if options.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("security.newKeyringWithOptions: %s requires a private key file", options.Algorithm)
+		}
+		active, err := loadPrivateKey(options.Algorithm, options.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("security.newKeyringWithOptions: %w", err)
+		}
+		k.active = active
+		k.addSecrets("", append([]string{options.Secret}, options.PreviousSecrets...))
+	default:
+		return nil, fmt.Errorf("security.newKeyringWithOptions: unknown signing algorithm %q", options.Algorithm)
+	}
+	for _, path := range options.PreviousPublicKeyFiles {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		key, err := loadPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("security.newKeyringWithOptions: %w", err)
+		}
+		if _, ok := k.find(key.id); !ok {
+			k.previous = append(k.previous, key)
+		}
+	}
+	return k, nil
+}
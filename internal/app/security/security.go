@@ -3,6 +3,7 @@ package security
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
@@ -12,22 +13,32 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
 // Константы, используемые в пакете.
 const (
-	// TokenExp — время жизни JWT-токена (по умолчанию 3 часа).
+	// TokenExp — время жизни JWT-токена, выпускаемого BuildToken. Сервис выпускает токены
+	// через Authenticator со временем жизни из конфигурации.
 	TokenExp = time.Hour * 3
 
 	// AuthorizationTokenName — имя куки, в которой хранится токен аутентификации.
 	AuthorizationTokenName = "Authorization"
+
+	// AuthorizationHeader — заголовок, в котором клиенты без кук передают токен или ключ API.
+	AuthorizationHeader = "Authorization"
+
+	// BearerScheme — схема аутентификации в заголовке AuthorizationHeader: "Bearer <токен>".
+	BearerScheme = "Bearer"
 )
 
 // Глобальные ошибки, используемые в пакете.
 var (
 	// ErrNoAuthorizationToken — ошибка, возникающая, когда токен отсутствует.
 	ErrNoAuthorizationToken = errors.New("authorization token is missed")
+	// ErrInvalidURL — ошибка, возникающая, когда URL не разбирается или не содержит схему и хост.
+	ErrInvalidURL = errors.New("invalid url")
 )
 
 // Claims — пользовательские claims для JWT-токена.
@@ -52,7 +63,23 @@ func GetToken(req *http.Request) string {
 	return ""
 }
 
-// GetUserID извлекает идентификатор пользователя из JWT-токена.
+// GetBearerToken извлекает токен из заголовка "Authorization: Bearer <токен>".
+// Название схемы сравнивается без учёта регистра.
+//
+// Параметры:
+//   - req: указатель на http.Request.
+//
+// Возвращает:
+//   - string: значение токена или пустую строку, если заголовка нет или в нём другая схема.
+func GetBearerToken(req *http.Request) string {
+	scheme, token, found := strings.Cut(req.Header.Get(AuthorizationHeader), " ")
+	if !found || !strings.EqualFold(scheme, BearerScheme) {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// GetUserID извлекает идентификатор пользователя из JWT-токена, подписанного одним ключом.
 //
 // Параметры:
 //   - token: строковое представление JWT-токена.
@@ -65,10 +92,7 @@ func GetUserID(token string, encodedKey string) (string, error) {
 	if token == "" {
 		return "", nil
 	}
-	claims := &Claims{}
-	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(encodedKey), nil
-	})
+	claims, _, err := NewKeyring(encodedKey).Verify(token)
 	if err != nil {
 		return "", fmt.Errorf("get user id: claims parsing: %w", err)
 	}
@@ -78,7 +102,8 @@ func GetUserID(token string, encodedKey string) (string, error) {
 	return claims.UserID, nil
 }
 
-// BuildToken создаёт новый JWT-токен с уникальным идентификатором пользователя.
+// BuildToken создаёт новый JWT-токен с уникальным идентификатором пользователя, сроком действия TokenExp
+// и заголовком kid ключа key.
 //
 // Параметры:
 //   - key: секретный ключ для подписи токена.
@@ -91,13 +116,12 @@ func BuildToken(key string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("generate new user id: %w", err)
 	}
-	tokenWithClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+	token, err := NewKeyring(key).Sign(Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp)),
 		},
 		UserID: userID,
 	})
-	token, err := tokenWithClaims.SignedString([]byte(key))
 	if err != nil {
 		return "", fmt.Errorf("sign token: %w", err)
 	}
@@ -113,12 +137,26 @@ func BuildToken(key string) (string, error) {
 //   - string: хэшированное представление URL (первые 10 символов).
 //   - error: nil, если URL корректный, иначе — ошибку.
 func CreateHashForURL(fullURL string) (string, error) {
-	if isInvalidURL(fullURL) {
-		return "", errors.New("invalid url")
+	if err := ValidateURL(fullURL); err != nil {
+		return "", err
 	}
 	return CreateHash(fullURL), nil
 }
 
+// ValidateURL проверяет, что URL корректен и содержит схему и хост.
+//
+// Параметры:
+//   - fullURL: URL для проверки.
+//
+// Возвращает:
+//   - error: nil, если URL корректный, иначе — ErrInvalidURL.
+func ValidateURL(fullURL string) error {
+	if isInvalidURL(fullURL) {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
 // CreateHash создаёт SHA256-хэш строки и возвращает его URL-safe представление.
 //
 // Параметры:
@@ -132,6 +170,24 @@ func CreateHash(key string) string {
 	return hashString[:10]
 }
 
+// HashIP создаёт HMAC-SHA256 IP-адреса клиента, чтобы хранить в статистике не сам адрес,
+// а только его необратимый отпечаток.
+//
+// Параметры:
+//   - ip: IP-адрес клиента.
+//   - key: секретный ключ (обычно ключ аутентификации).
+//
+// Возвращает:
+//   - string: hex-представление хэша или пустую строку, если адрес пустой.
+func HashIP(ip string, key string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 // encrypt шифрует строку с использованием AES-GCM.
 //
 // Параметры:
@@ -163,7 +219,9 @@ func encrypt(token string, key string) (string, error) {
 	return hex.EncodeToString(encryptedToken), nil
 }
 
-// generateUserID генерирует случайную строку длиной 16 байт, используемую как идентификатор пользователя.
+// generateUserID генерирует идентификатор пользователя — hex-представление 16 случайных байтов.
+// Идентификатор должен быть корректной UTF-8 строкой: иначе он изменится при сериализации claims в JSON
+// и не совпадёт с идентификатором, извлечённым из выпущенного токена.
 //
 // Возвращает:
 //   - string: случайная строка.
@@ -173,7 +231,7 @@ func generateUserID() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("generate random: %w", err)
 	}
-	return string(userID), nil
+	return hex.EncodeToString(userID), nil
 }
 
 // generateRandom генерирует случайную последовательность байтов заданной длины.
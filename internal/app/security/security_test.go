@@ -0,0 +1,433 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newClaims создаёт claims пользователя со сроком действия через expiresIn.
+func newClaims(userID string, expiresIn time.Duration) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn))},
+		UserID:           userID,
+	}
+}
+
+// newTestAuthenticator создаёт Authenticator со временем жизни токена 24 часа и окном продления 1 час.
+func newTestAuthenticator(t *testing.T, keys *Keyring) *Authenticator {
+	auth, err := NewAuthenticator(keys, AuthOptions{
+		TokenTTL:       24 * time.Hour,
+		RefreshWindow:  time.Hour,
+		CookieSecure:   true,
+		CookieHTTPOnly: true,
+		CookieSameSite: SameSiteStrict,
+		CookiePath:     "/",
+	})
+	require.NoError(t, err)
+	return auth
+}
+
+func TestKeyringVerify(t *testing.T) {
+	oldKeys := NewKeyring("old-key")
+	keys := NewKeyring("new-key", "old-key", "")
+
+	token, err := keys.Sign(newClaims("alice", time.Hour))
+	require.NoError(t, err)
+	claims, active, err := keys.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.UserID)
+	assert.True(t, active)
+
+	// Токен, подписанный прежним ключом, действителен, но требует перевыпуска.
+	oldToken, err := oldKeys.Sign(newClaims("bob", time.Hour))
+	require.NoError(t, err)
+	claims, active, err = keys.Verify(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", claims.UserID)
+	assert.False(t, active)
+
+	// После удаления ключа из набора его токены отклоняются.
+	_, _, err = NewKeyring("new-key").Verify(oldToken)
+	assert.ErrorIs(t, err, ErrUnknownSigningKey)
+
+	// Токен без kid проверяется всеми ключами.
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims("carol", time.Hour)).SignedString([]byte("old-key"))
+	require.NoError(t, err)
+	claims, active, err = keys.Verify(legacyToken)
+	require.NoError(t, err)
+	assert.Equal(t, "carol", claims.UserID)
+	assert.False(t, active)
+	_, _, err = NewKeyring("other-key").Verify(legacyToken)
+	assert.ErrorIs(t, err, jwt.ErrSignatureInvalid)
+
+	expiredToken, err := keys.Sign(newClaims("alice", -time.Minute))
+	require.NoError(t, err)
+	_, _, err = keys.Verify(expiredToken)
+	assert.ErrorIs(t, err, jwt.ErrTokenExpired)
+
+	// Токен без exp был бы бессрочным и отклоняется, в том числе без kid.
+	endlessToken, err := keys.Sign(Claims{UserID: "alice"})
+	require.NoError(t, err)
+	_, _, err = keys.Verify(endlessToken)
+	assert.ErrorIs(t, err, ErrTokenWithoutExpiration)
+	endlessToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: "alice"}).SignedString([]byte("new-key"))
+	require.NoError(t, err)
+	_, _, err = keys.Verify(endlessToken)
+	assert.ErrorIs(t, err, ErrTokenWithoutExpiration)
+
+	// Токен, выпущенный в будущем, отклоняется.
+	futureClaims := newClaims("alice", time.Hour)
+	futureClaims.IssuedAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	futureToken, err := keys.Sign(futureClaims)
+	require.NoError(t, err)
+	_, _, err = keys.Verify(futureToken)
+	assert.ErrorIs(t, err, jwt.ErrTokenUsedBeforeIssued)
+
+	_, _, err = keys.Verify("not-a-token")
+	assert.Error(t, err)
+}
+
+func TestAuthenticatorAuthenticate(t *testing.T) {
+	keys := NewKeyring("new-key", "old-key")
+	auth := newTestAuthenticator(t, keys)
+	freshToken, err := keys.Sign(newClaims("alice", 20*time.Hour))
+	require.NoError(t, err)
+	expiringToken, err := keys.Sign(newClaims("alice", 30*time.Minute))
+	require.NoError(t, err)
+	oldKeyToken, err := NewKeyring("old-key").Sign(newClaims("alice", 20*time.Hour))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		token       string
+		wantRefresh bool
+	}{
+		{name: "Fresh token is kept", token: freshToken},
+		{name: "Token expiring soon is refreshed", token: expiringToken, wantRefresh: true},
+		{name: "Token signed with a previous key is refreshed", token: oldKeyToken, wantRefresh: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, err := auth.Authenticate(test.token)
+			require.NoError(t, err)
+			assert.Equal(t, "alice", session.UserID)
+			if !test.wantRefresh {
+				assert.Empty(t, session.Token)
+				return
+			}
+			require.NotEmpty(t, session.Token)
+			claims, active, err := keys.Verify(session.Token)
+			require.NoError(t, err)
+			assert.True(t, active)
+			assert.Equal(t, "alice", claims.UserID, "the refreshed token must keep the user id")
+			assert.WithinDuration(t, time.Now().Add(24*time.Hour), claims.ExpiresAt.Time, time.Minute)
+		})
+	}
+
+	_, err = auth.Authenticate("not-a-token")
+	assert.Error(t, err)
+}
+
+func TestAuthenticatorNewSession(t *testing.T) {
+	auth := newTestAuthenticator(t, NewKeyring("key"))
+	session, err := auth.NewSession()
+	require.NoError(t, err)
+	userID, err := auth.UserID(session.Token)
+	require.NoError(t, err)
+	assert.Equal(t, session.UserID, userID)
+
+	cookie := auth.Cookie(session.Token)
+	assert.Equal(t, AuthorizationTokenName, cookie.Name)
+	assert.Equal(t, session.Token, cookie.Value)
+	assert.Equal(t, "/", cookie.Path)
+	assert.Equal(t, 24*60*60, cookie.MaxAge)
+	assert.True(t, cookie.Secure)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	tests := []struct {
+		name    string
+		options AuthOptions
+		wantErr bool
+	}{
+		{name: "Valid options", options: AuthOptions{TokenTTL: time.Hour, RefreshWindow: time.Minute, CookieSameSite: "Lax"}},
+		{name: "Zero ttl", options: AuthOptions{CookieSameSite: SameSiteLax}, wantErr: true},
+		{name: "Refresh window is not shorter than ttl", options: AuthOptions{TokenTTL: time.Hour, RefreshWindow: time.Hour, CookieSameSite: SameSiteLax}, wantErr: true},
+		{name: "Unknown SameSite", options: AuthOptions{TokenTTL: time.Hour, CookieSameSite: "loose"}, wantErr: true},
+		{name: "SameSite=None without Secure", options: AuthOptions{TokenTTL: time.Hour, CookieSameSite: SameSiteNone}, wantErr: true},
+		{name: "SameSite=None with Secure", options: AuthOptions{TokenTTL: time.Hour, CookieSameSite: SameSiteNone, CookieSecure: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewAuthenticator(NewKeyring("key"), test.options)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetBearerToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "Bearer token", header: "Bearer abc.def.ghi", want: "abc.def.ghi"},
+		{name: "Scheme is case-insensitive", header: "bearer abc", want: "abc"},
+		{name: "No header"},
+		{name: "Other scheme", header: "Basic dXNlcjpwYXNz"},
+		{name: "Scheme without token", header: "Bearer"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			require.NoError(t, err)
+			if test.header != "" {
+				req.Header.Set(AuthorizationHeader, test.header)
+			}
+			assert.Equal(t, test.want, GetBearerToken(req))
+		})
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, err := GenerateAPIKey()
+	require.NoError(t, err)
+	other, err := GenerateAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+	assert.True(t, IsAPIKey(key))
+	assert.Len(t, APIKeyPrefixOf(key), len(APIKeyPrefix)+6)
+	assert.Len(t, HashAPIKey(key), 64)
+	assert.NotEqual(t, HashAPIKey(key), HashAPIKey(other))
+
+	token, err := BuildToken("key")
+	require.NoError(t, err)
+	assert.False(t, IsAPIKey(token), "a JWT must not be taken for an api key")
+}
+
+// writePEM записывает блок PEM во временный файл теста и возвращает путь к нему.
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+	return path
+}
+
+// writeEd25519Key создаёт ключ Ed25519 и возвращает пути к закрытому и открытому ключам в PEM.
+func writeEd25519Key(t *testing.T) (string, string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	require.NoError(t, err)
+	return writePEM(t, "ed25519.pem", "PRIVATE KEY", privateDER), writePEM(t, "ed25519.pub.pem", "PUBLIC KEY", publicDER)
+}
+
+func TestKeyringAlgorithmPinning(t *testing.T) {
+	keys := NewKeyring("key")
+	claims := newClaims("alice", time.Hour)
+
+	// HS512 с тем же секретом и тем же kid не принимается: ключ проверяет только HS256.
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	token.Header[KeyIDHeader] = keys.active.id
+	signed, err := token.SignedString([]byte("key"))
+	require.NoError(t, err)
+	_, _, err = keys.Verify(signed)
+	assert.ErrorIs(t, err, ErrSigningMethodNotAllowed)
+
+	// Неподписанный токен без kid тоже отклоняется.
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, _, err = keys.Verify(unsigned)
+	assert.ErrorIs(t, err, ErrSigningMethodNotAllowed)
+
+	// HS256-токен, подписанный открытым ключом как секретом, не проверяется асимметричным ключом.
+	privateFile, publicFile := writeEd25519Key(t)
+	edKeys, err := NewKeyringWithOptions(KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: privateFile})
+	require.NoError(t, err)
+	publicPEM, err := os.ReadFile(publicFile)
+	require.NoError(t, err)
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	confused.Header[KeyIDHeader] = edKeys.active.id
+	signed, err = confused.SignedString(publicPEM)
+	require.NoError(t, err)
+	_, _, err = edKeys.Verify(signed)
+	assert.ErrorIs(t, err, ErrSigningMethodNotAllowed)
+}
+
+func TestKeyringClaims(t *testing.T) {
+	options := KeyringOptions{Secret: "key", Issuer: "shortener", Audience: "shortener"}
+	keys, err := NewKeyringWithOptions(options)
+	require.NoError(t, err)
+
+	token, err := keys.Sign(newClaims("alice", time.Hour))
+	require.NoError(t, err)
+	claims, active, err := keys.Verify(token)
+	require.NoError(t, err)
+	assert.True(t, active)
+	assert.Equal(t, "shortener", claims.Issuer)
+	assert.Equal(t, jwt.ClaimStrings{"shortener"}, claims.Audience)
+
+	foreign := newClaims("alice", time.Hour)
+	foreign.Issuer = "other"
+	token, err = keys.Sign(foreign)
+	require.NoError(t, err)
+	_, _, err = keys.Verify(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+
+	foreign = newClaims("alice", time.Hour)
+	foreign.Audience = jwt.ClaimStrings{"billing"}
+	token, err = keys.Sign(foreign)
+	require.NoError(t, err)
+	_, _, err = keys.Verify(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+
+	notYetValid := newClaims("alice", time.Hour)
+	notYetValid.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	token, err = keys.Sign(notYetValid)
+	require.NoError(t, err)
+	_, _, err = keys.Verify(token)
+	assert.ErrorIs(t, err, jwt.ErrTokenNotValidYet)
+
+	// Токен без iss и aud, выпущенный до их появления, принимается только с AllowLegacyTokens
+	// и требует перевыпуска.
+	legacyToken, err := NewKeyring("key").Sign(newClaims("bob", time.Hour))
+	require.NoError(t, err)
+	_, _, err = keys.Verify(legacyToken)
+	assert.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+	options.AllowLegacyTokens = true
+	keys, err = NewKeyringWithOptions(options)
+	require.NoError(t, err)
+	claims, active, err = keys.Verify(legacyToken)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", claims.UserID)
+	assert.False(t, active)
+
+	session, err := newTestAuthenticator(t, keys).Authenticate(legacyToken)
+	require.NoError(t, err)
+	require.NotEmpty(t, session.Token, "a legacy token must be refreshed")
+	claims, active, err = keys.Verify(session.Token)
+	require.NoError(t, err)
+	assert.True(t, active)
+	assert.Equal(t, "bob", claims.UserID)
+	require.NotNil(t, claims.NotBefore)
+}
+
+func TestKeyringAsymmetric(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaFile := writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	edPrivateFile, edPublicFile := writeEd25519Key(t)
+
+	tests := []struct {
+		name      string
+		algorithm string
+		keyFile   string
+		wantKty   string
+	}{
+		{name: "Ed25519", algorithm: AlgorithmEdDSA, keyFile: edPrivateFile, wantKty: "OKP"},
+		{name: "RSA", algorithm: AlgorithmRS256, keyFile: rsaFile, wantKty: "RSA"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys, err := NewKeyringWithOptions(KeyringOptions{
+				Algorithm:      test.algorithm,
+				Secret:         "old-key",
+				PrivateKeyFile: test.keyFile,
+				Issuer:         "shortener",
+				Audience:       "shortener",
+			})
+			require.NoError(t, err)
+			token, err := keys.Sign(newClaims("alice", time.Hour))
+			require.NoError(t, err)
+			claims, active, err := keys.Verify(token)
+			require.NoError(t, err)
+			assert.True(t, active)
+			assert.Equal(t, "alice", claims.UserID)
+
+			// Токены прежнего HMAC-ключа действительны и перевыпускаются новым ключом.
+			oldToken, err := NewKeyring("old-key").Sign(Claims{
+				RegisteredClaims: jwt.RegisteredClaims{
+					Issuer:    "shortener",
+					Audience:  jwt.ClaimStrings{"shortener"},
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				},
+				UserID: "bob",
+			})
+			require.NoError(t, err)
+			_, active, err = keys.Verify(oldToken)
+			require.NoError(t, err)
+			assert.False(t, active)
+
+			jwks := keys.JWKS()
+			require.Len(t, jwks.Keys, 1, "hmac secrets must not be published")
+			assert.Equal(t, test.wantKty, jwks.Keys[0].KeyType)
+			assert.Equal(t, test.algorithm, jwks.Keys[0].Algorithm)
+			assert.Equal(t, keys.active.id, jwks.Keys[0].KeyID)
+		})
+	}
+
+	// Прежний открытый ключ проверяет выпущенные им токены и остаётся в JWKS.
+	oldKeys, err := NewKeyringWithOptions(KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: edPrivateFile})
+	require.NoError(t, err)
+	oldToken, err := oldKeys.Sign(newClaims("carol", time.Hour))
+	require.NoError(t, err)
+	keys, err := NewKeyringWithOptions(KeyringOptions{
+		Algorithm:              AlgorithmRS256,
+		PrivateKeyFile:         rsaFile,
+		PreviousPublicKeyFiles: []string{edPublicFile, ""},
+	})
+	require.NoError(t, err)
+	claims, active, err := keys.Verify(oldToken)
+	require.NoError(t, err)
+	assert.Equal(t, "carol", claims.UserID)
+	assert.False(t, active)
+	assert.Len(t, keys.JWKS().Keys, 2)
+	assert.Empty(t, NewKeyring("key").JWKS().Keys)
+}
+
+func TestNewKeyringWithOptions(t *testing.T) {
+	edPrivateFile, edPublicFile := writeEd25519Key(t)
+	tests := []struct {
+		name    string
+		options KeyringOptions
+		wantErr bool
+	}{
+		{name: "HS256 by default", options: KeyringOptions{Secret: "key"}},
+		{name: "HS256 without secret", options: KeyringOptions{Algorithm: AlgorithmHS256}, wantErr: true},
+		{name: "EdDSA", options: KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: edPrivateFile}},
+		{name: "EdDSA without key file", options: KeyringOptions{Algorithm: AlgorithmEdDSA, Secret: "key"}, wantErr: true},
+		{name: "Missing key file", options: KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")}, wantErr: true},
+		{name: "Public key instead of private", options: KeyringOptions{Algorithm: AlgorithmEdDSA, PrivateKeyFile: edPublicFile}, wantErr: true},
+		{name: "Ed25519 key for RS256", options: KeyringOptions{Algorithm: AlgorithmRS256, PrivateKeyFile: edPrivateFile}, wantErr: true},
+		{name: "Unknown algorithm", options: KeyringOptions{Algorithm: "HS512", Secret: "key"}, wantErr: true},
+		{name: "Broken previous public key", options: KeyringOptions{Secret: "key", PreviousPublicKeyFiles: []string{edPrivateFile}}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewKeyringWithOptions(test.options)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
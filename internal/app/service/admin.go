@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// Действия администраторов в журнале (model.AdminAuditEntry.Action).
+const (
+	// AdminActionDisable — отключение ссылок.
+	AdminActionDisable = "disable"
+	// AdminActionEnable — включение ранее отключённых ссылок.
+	AdminActionEnable = "enable"
+	// AdminActionTakedownDomain — отключение всех ссылок на домен.
+	AdminActionTakedownDomain = "takedown_domain"
+)
+
+const (
+	// defaultAdminSearchLimit — количество найденных ссылок, если лимит не задан.
+	defaultAdminSearchLimit = 100
+	// maxAdminSearchLimit — максимальное количество найденных ссылок за один запрос.
+	maxAdminSearchLimit = 1000
+)
+
+// ErrEmptyAdminFilter — ошибка поиска ссылок без единого условия.
+var ErrEmptyAdminFilter = errors.New("at least one of hash, url or user is required")
+
+// ErrNoShortURLs — ошибка запроса на отключение или включение без кодов ссылок.
+var ErrNoShortURLs = errors.New("short_urls is required")
+
+// ErrInvalidDomain — ошибка запроса на отключение ссылок по некорректному домену.
+var ErrInvalidDomain = errors.New("invalid domain")
+
+// Moderator — сервис служебного API для модерации ссылок: поиска, отключения и включения ссылок
+// и отключения всех ссылок на домен. Каждое изменение записывается в журнал действий администраторов.
+type Moderator struct {
+	repository repository.AdminRepository // Хранилище ссылок и журнала действий
+	now        func() time.Time           // Источник текущего времени для записей журнала
+}
+
+// SearchLinks ищет ссылки по коду, подстроке URL и/или владельцу.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - filter: условия поиска; лимит по умолчанию — 100, максимальный — 1000.
+//
+// Возвращает:
+//   - []model.AdminLink: найденные ссылки.
+//   - error: nil, если успешно; ErrEmptyAdminFilter, если не задано ни одного условия; иначе — ошибку.
+func (m *Moderator) SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.AdminLink, error) {
+	ctx, span := tracing.Start(ctx, "Moderator.SearchLinks")
+	defer span.End()
+	if filter.HashURL == "" && filter.URL == "" && filter.UserID == "" {
+		return nil, ErrEmptyAdminFilter
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAdminSearchLimit
+	}
+	filter.Limit = min(filter.Limit, maxAdminSearchLimit)
+	links, err := m.repository.SearchLinks(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("moderator.searchLinks: %w", err)
+	}
+	return links, nil
+}
+
+// DisableLinks отключает ссылки: переход по ним возвращает 410 Gone, пока их не включат.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - req: коды ссылок и причина.
+//   - actor: имя администратора для журнала (может быть пустым).
+//
+// Возвращает:
+//   - model.AdminAuditEntry: запись журнала с кодами ссылок, состояние которых изменилось.
+//   - error: nil, если успешно; ErrNoShortURLs, если коды не переданы; иначе — ошибку.
+func (m *Moderator) DisableLinks(ctx context.Context, req model.AdminLinksRequest, actor string) (model.AdminAuditEntry, error) {
+	return m.setLinksDisabled(ctx, req, actor, true)
+}
+
+// EnableLinks снова включает отключённые ссылки.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - req: коды ссылок и причина.
+//   - actor: имя администратора для журнала (может быть пустым).
+//
+// Возвращает:
+//   - model.AdminAuditEntry: запись журнала с кодами ссылок, состояние которых изменилось.
+//   - error: nil, если успешно; ErrNoShortURLs, если коды не переданы; иначе — ошибку.
+func (m *Moderator) EnableLinks(ctx context.Context, req model.AdminLinksRequest, actor string) (model.AdminAuditEntry, error) {
+	return m.setLinksDisabled(ctx, req, actor, false)
+}
+
+// TakedownDomain отключает все существующие ссылки на домен и его поддомены.
+//
+// Новые ссылки на домен после этого создавать можно: чтобы запретить их, домен нужно
+// добавить в список запрещённых доменов (URLBlocklistFile).
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - req: домен и причина; домен должен состоять хотя бы из двух меток, чтобы случайно
+//     не отключить все ссылки доменной зоны.
+//   - actor: имя администратора для журнала (может быть пустым).
+//
+// Возвращает:
+//   - model.AdminAuditEntry: запись журнала с кодами отключённых ссылок.
+//   - error: nil, если успешно; ErrInvalidDomain, если домен некорректен; иначе — ошибку.
+func (m *Moderator) TakedownDomain(ctx context.Context, req model.AdminTakedownRequest, actor string) (model.AdminAuditEntry, error) {
+	ctx, span := tracing.Start(ctx, "Moderator.TakedownDomain")
+	defer span.End()
+	domain := normalizeDomain(req.Domain)
+	if domain == "" {
+		return model.AdminAuditEntry{}, fmt.Errorf("%w: %q", ErrInvalidDomain, req.Domain)
+	}
+	changed, err := m.repository.DisableLinksByDomain(ctx, domain)
+	if err != nil {
+		return model.AdminAuditEntry{}, fmt.Errorf("moderator.takedownDomain: %w", err)
+	}
+	return m.audit(ctx, model.AdminAuditEntry{
+		Action:   AdminActionTakedownDomain,
+		Actor:    actor,
+		Target:   domain,
+		HashURLs: changed,
+		Reason:   req.Reason,
+	})
+}
+
+// FindAuditLog возвращает последние записи журнала действий администраторов, начиная с новых.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - limit: максимальное количество записей; по умолчанию — 100, не больше 1000.
+//
+// Возвращает:
+//   - []model.AdminAuditEntry: записи журнала.
+//   - error: nil, если успешно, иначе — ошибку.
+func (m *Moderator) FindAuditLog(ctx context.Context, limit int) ([]model.AdminAuditEntry, error) {
+	ctx, span := tracing.Start(ctx, "Moderator.FindAuditLog")
+	defer span.End()
+	if limit <= 0 {
+		limit = defaultAdminSearchLimit
+	}
+	entries, err := m.repository.FindAuditEntries(ctx, min(limit, maxAdminSearchLimit))
+	if err != nil {
+		return nil, fmt.Errorf("moderator.findAuditLog: %w", err)
+	}
+	return entries, nil
+}
+
+// setLinksDisabled отключает или включает ссылки и записывает действие в журнал.
+func (m *Moderator) setLinksDisabled(ctx context.Context, req model.AdminLinksRequest, actor string, disabled bool) (model.AdminAuditEntry, error) {
+	action, spanName := AdminActionEnable, "Moderator.EnableLinks"
+	if disabled {
+		action, spanName = AdminActionDisable, "Moderator.DisableLinks"
+	}
+	ctx, span := tracing.Start(ctx, spanName)
+	defer span.End()
+	if len(req.HashURLs) == 0 {
+		return model.AdminAuditEntry{}, ErrNoShortURLs
+	}
+	changed, err := m.repository.SetLinksDisabled(ctx, req.HashURLs, disabled)
+	if err != nil {
+		return model.AdminAuditEntry{}, fmt.Errorf("moderator.%s: %w", action, err)
+	}
+	return m.audit(ctx, model.AdminAuditEntry{
+		Action:   action,
+		Actor:    actor,
+		Target:   strings.Join(req.HashURLs, ","),
+		HashURLs: changed,
+		Reason:   req.Reason,
+	})
+}
+
+// audit записывает действие в журнал и лог приложения.
+//
+// Возвращает:
+//   - model.AdminAuditEntry: сохранённая запись с моментом действия.
+//   - error: nil, если успешно, иначе — ошибку сохранения записи (само действие к этому моменту выполнено).
+func (m *Moderator) audit(ctx context.Context, entry model.AdminAuditEntry) (model.AdminAuditEntry, error) {
+	entry.CreatedAt = m.now().UTC()
+	logger.FromContext(ctx).Info("admin action",
+		zap.String("action", entry.Action),
+		zap.String("actor", entry.Actor),
+		zap.String("target", entry.Target),
+		zap.Int("affected", len(entry.HashURLs)),
+		zap.String("reason", entry.Reason))
+	if err := m.repository.SaveAuditEntry(ctx, entry); err != nil {
+		return entry, fmt.Errorf("moderator.audit: %w", err)
+	}
+	return entry, nil
+}
+
+// normalizeDomain приводит домен к нижнему регистру, убирает префикс "*." и завершающую точку.
+//
+// Возвращает:
+//   - string: домен или пустую строку, если он некорректен или состоит из одной метки.
+func normalizeDomain(domain string) string {
+	domain = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*."), ".")
+	if strings.ContainsAny(domain, "/:@ ") || !strings.Contains(domain, ".") {
+		return ""
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" {
+			return ""
+		}
+	}
+	return domain
+}
+
+// NewModerator создаёт сервис модерации ссылок.
+//
+// Параметр:
+//   - r: хранилище, поддерживающее операции служебного API.
+//
+// Возвращает:
+//   - *Moderator: готовый к использованию сервис.
+func NewModerator(r repository.AdminRepository) *Moderator {
+	return &Moderator{repository: r, now: time.Now}
+}
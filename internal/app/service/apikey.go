@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// maxAPIKeyNameLength — максимальная длина названия ключа API в символах.
+	maxAPIKeyNameLength = 100
+	// maxActiveAPIKeys — максимальное количество действующих ключей API одного пользователя.
+	maxActiveAPIKeys = 20
+)
+
+// ErrInvalidAPIKey — ошибка проверки неизвестного или отозванного ключа API.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// ErrAPIKeyNameTooLong — ошибка создания ключа API со слишком длинным названием.
+var ErrAPIKeyNameTooLong = fmt.Errorf("api key name must not be longer than %d characters", maxAPIKeyNameLength)
+
+// ErrTooManyAPIKeys — ошибка создания ключа API сверх лимита действующих ключей пользователя.
+var ErrTooManyAPIKeys = fmt.Errorf("user can't have more than %d active api keys", maxActiveAPIKeys)
+
+// APIKeys — сервис долгоживущих ключей API пользователей: создания, просмотра, отзыва и проверки.
+//
+// Ключ показывается пользователю только при создании, а в хранилище попадает его отпечаток
+// (см. security.HashAPIKey), поэтому утечка хранилища не раскрывает действующие ключи.
+type APIKeys struct {
+	repository repository.APIKeyRepository // Хранилище ключей API
+	now        func() time.Time            // Источник текущего времени для моментов создания и отзыва
+}
+
+// Create создаёт ключ API пользователя.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - userID: идентификатор владельца.
+//   - req: запрос с названием ключа.
+//
+// Возвращает:
+//   - model.CreateAPIKeyResponse: описание ключа и сам ключ.
+//   - error: nil, если успешно; ErrAPIKeyNameTooLong или ErrTooManyAPIKeys для недопустимого запроса;
+//     иначе — ошибку.
+func (s *APIKeys) Create(ctx context.Context, userID string, req model.CreateAPIKeyRequest) (model.CreateAPIKeyResponse, error) {
+	ctx, span := tracing.Start(ctx, "APIKeys.Create")
+	defer span.End()
+	name := strings.TrimSpace(req.Name)
+	if utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return model.CreateAPIKeyResponse{}, ErrAPIKeyNameTooLong
+	}
+	keys, err := s.repository.FindAPIKeysByUserID(ctx, userID)
+	if err != nil {
+		return model.CreateAPIKeyResponse{}, fmt.Errorf("apiKeys.create: %w", err)
+	}
+	active := 0
+	for _, key := range keys {
+		if key.RevokedAt == nil {
+			active++
+		}
+	}
+	if active >= maxActiveAPIKeys {
+		return model.CreateAPIKeyResponse{}, ErrTooManyAPIKeys
+	}
+
+	secret, err := security.GenerateAPIKey()
+	if err != nil {
+		return model.CreateAPIKeyResponse{}, fmt.Errorf("apiKeys.create: %w", err)
+	}
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return model.CreateAPIKeyResponse{}, fmt.Errorf("apiKeys.create: generate id: %w", err)
+	}
+	key := model.APIKey{
+		ID:        hex.EncodeToString(id),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    security.APIKeyPrefixOf(secret),
+		Hash:      security.HashAPIKey(secret),
+		CreatedAt: s.now().UTC(),
+	}
+	if err = s.repository.SaveAPIKey(ctx, key); err != nil {
+		return model.CreateAPIKeyResponse{}, fmt.Errorf("apiKeys.create: %w", err)
+	}
+	return model.CreateAPIKeyResponse{APIKeyResponse: key.Response(), Key: secret}, nil
+}
+
+// List возвращает ключи API пользователя, включая отозванные, в порядке создания.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - userID: идентификатор владельца.
+//
+// Возвращает:
+//   - []model.APIKeyResponse: описания ключей.
+//   - error: nil, если успешно, иначе — ошибку.
+func (s *APIKeys) List(ctx context.Context, userID string) ([]model.APIKeyResponse, error) {
+	ctx, span := tracing.Start(ctx, "APIKeys.List")
+	defer span.End()
+	keys, err := s.repository.FindAPIKeysByUserID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("apiKeys.list: %w", err)
+	}
+	result := make([]model.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key.Response())
+	}
+	return result, nil
+}
+
+// Revoke отзывает ключ API пользователя; запросы с отозванным ключом отклоняются.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - id: идентификатор ключа.
+//   - userID: идентификатор владельца.
+//
+// Возвращает:
+//   - model.APIKeyResponse: описание отозванного ключа.
+//   - error: nil, если успешно; repository.ErrAPIKeyNotFound, если у пользователя нет такого ключа;
+//     иначе — ошибку.
+func (s *APIKeys) Revoke(ctx context.Context, id, userID string) (model.APIKeyResponse, error) {
+	ctx, span := tracing.Start(ctx, "APIKeys.Revoke")
+	defer span.End()
+	key, err := s.repository.RevokeAPIKey(ctx, id, userID, s.now().UTC())
+	if err != nil {
+		return model.APIKeyResponse{}, fmt.Errorf("apiKeys.revoke: %w", err)
+	}
+	return key.Response(), nil
+}
+
+// VerifyAPIKey проверяет ключ API и возвращает идентификатор его владельца.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - key: ключ API из заголовка Authorization.
+//
+// Возвращает:
+//   - string: идентификатор владельца ключа.
+//   - error: nil, если ключ действует; ErrInvalidAPIKey, если ключ неизвестен или отозван; иначе — ошибку.
+func (s *APIKeys) VerifyAPIKey(ctx context.Context, key string) (string, error) {
+	ctx, span := tracing.Start(ctx, "APIKeys.VerifyAPIKey")
+	defer span.End()
+	stored, err := s.repository.FindAPIKeyByHash(ctx, security.HashAPIKey(key))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return "", ErrInvalidAPIKey
+	}
+	if err != nil {
+		return "", fmt.Errorf("apiKeys.verifyAPIKey: %w", err)
+	}
+	if stored.RevokedAt != nil {
+		return "", ErrInvalidAPIKey
+	}
+	return stored.UserID, nil
+}
+
+// NewAPIKeys создаёт сервис ключей API.
+//
+// Параметр:
+//   - r: хранилище, поддерживающее ключи API.
+//
+// Возвращает:
+//   - *APIKeys: готовый к использованию сервис.
+func NewAPIKeys(r repository.APIKeyRepository) *APIKeys {
+	return &APIKeys{repository: r, now: time.Now}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
+)
+
+// clickBatchSize — количество событий, при накоплении которого пакет сохраняется, не дожидаясь таймера.
+const clickBatchSize = 500
+
+// ClickCollector — асинхронный буферизированный сборщик событий переходов по коротким ссылкам.
+//
+// Record не блокирует обработчик редиректа: событие кладётся в буферизированный канал,
+// а фоновая задача Run пакетами сохраняет события в хранилище.
+// Если буфер переполнен, событие отбрасывается.
+type ClickCollector struct {
+	repository clickSaver            // Хранилище агрегированной статистики
+	events     chan model.ClickEvent // Буфер событий
+	interval   time.Duration         // Период сохранения накопленных событий
+	dropped    atomic.Int64          // Количество отброшенных событий
+}
+
+type clickSaver interface {
+	SaveClicks(ctx context.Context, clicks []model.ClickEvent) error
+}
+
+// Record ставит событие перехода в очередь на сохранение.
+//
+// Метод не блокируется: при переполненном буфере событие отбрасывается.
+//
+// Параметр:
+//   - event: событие перехода.
+func (c *ClickCollector) Record(event model.ClickEvent) {
+	select {
+	case c.events <- event:
+	default:
+		if c.dropped.Add(1)%clickBatchSize == 1 {
+			logger.Log.Warn("click buffer is full, dropping events", zap.Int64("dropped", c.dropped.Load()))
+		}
+	}
+}
+
+// Dropped возвращает количество событий, отброшенных из-за переполнения буфера.
+func (c *ClickCollector) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// Run сохраняет накопленные события каждые interval или при накоплении clickBatchSize событий
+// и блокируется до отмены контекста. Перед завершением сохраняет события, оставшиеся в буфере;
+// это сохранение не прерывается отменой ctx.
+//
+// Параметры:
+//   - ctx: контекст, при отмене которого сборщик завершается.
+//
+// Возвращает:
+//   - error: всегда nil, чтобы корректно завершаться в составе errgroup.
+func (c *ClickCollector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	batch := make([]model.ClickEvent, 0, clickBatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			batch = c.drain(batch)
+			c.flush(context.WithoutCancel(ctx), batch)
+			logger.Log.Info("click collector stopped")
+			return nil
+		case event := <-c.events:
+			batch = append(batch, event)
+			if len(batch) >= clickBatchSize {
+				batch = c.flush(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = c.flush(ctx, batch)
+		}
+	}
+}
+
+// drain забирает из буфера все оставшиеся события без ожидания.
+func (c *ClickCollector) drain(batch []model.ClickEvent) []model.ClickEvent {
+	for {
+		select {
+		case event := <-c.events:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+}
+
+// flush сохраняет пакет событий и возвращает пустой срез для следующего пакета.
+// Ошибки сохранения логируются, пакет при этом теряется.
+func (c *ClickCollector) flush(ctx context.Context, batch []model.ClickEvent) []model.ClickEvent {
+	if len(batch) == 0 {
+		return batch
+	}
+	if err := c.repository.SaveClicks(ctx, batch); err != nil {
+		logger.Log.Error("couldn't save clicks", zap.Int("count", len(batch)), zap.Error(err))
+	}
+	return batch[:0]
+}
+
+// NewClickCollector создаёт сборщик событий переходов.
+//
+// Параметры:
+//   - r: хранилище, поддерживающее сохранение статистики переходов.
+//   - bufferSize: ёмкость буфера событий.
+//   - interval: период сохранения накопленных событий.
+//
+// Возвращает:
+//   - *ClickCollector: готовый к запуску сборщик.
+func NewClickCollector(r clickSaver, bufferSize int, interval time.Duration) *ClickCollector {
+	return &ClickCollector{
+		repository: r,
+		events:     make(chan model.ClickEvent, bufferSize),
+		interval:   interval,
+	}
+}
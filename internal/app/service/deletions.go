@@ -0,0 +1,588 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Параметры повторных попыток удаления.
+const (
+	// deleteMaxRetries — количество повторных попыток удаления пакета после первой неудачи.
+	deleteMaxRetries = 5
+	// deleteRetryBaseDelay — задержка перед первой повторной попыткой; каждая следующая вдвое длиннее.
+	deleteRetryBaseDelay = 100 * time.Millisecond
+	// deleteMaxRequeues — количество возвратов запроса в очередь, после которого он отбрасывается.
+	deleteMaxRequeues = 3
+	// deleteJournalCompactRecords — количество выполненных запросов в журнале, после которого журнал сжимается.
+	deleteJournalCompactRecords = 1000
+	// deleteDrainTimeout — максимальное время выполнения оставшихся удалений при остановке.
+	deleteDrainTimeout = 5 * time.Second
+	// deleteSaturationPercent — заполненность очереди в процентах, начиная с которой сервис считается неготовым.
+	deleteSaturationPercent = 90
+)
+
+// ErrShuttingDown — ошибка, возникающая при попытке запустить фоновую операцию после начала остановки сервиса.
+var ErrShuttingDown = errors.New("service is shutting down")
+
+// ErrDeletionQueueFull — ошибка, возникающая, если в очереди удаления нет места для запроса.
+var ErrDeletionQueueFull = errors.New("deletion queue is full")
+
+// deletionStore — хранилище, поддерживающее пакетное удаление ссылок.
+type deletionStore interface {
+	DeleteBatch(ctx context.Context, requests []model.DeletionRequest) error
+}
+
+// deletionJob — запрос на удаление, ожидающий выполнения.
+type deletionJob struct {
+	seq      uint64                // Порядковый номер запроса в очереди
+	request  model.DeletionRequest // Запрос пользователя
+	requeues int                   // Количество возвратов в очередь после неудачного удаления
+}
+
+// journalRecord — запись журнала очереди удаления.
+//
+// Журнал только дописывается: постановка запроса в очередь добавляет запись с Seq и Request,
+// а выполнение или отбрасывание пакета — запись с Done, перечисляющую номера завершённых запросов.
+// Журнал из записей model.DeletionRequest без номеров (прежний формат) также читается.
+type journalRecord struct {
+	Seq     uint64                 `json:"seq,omitempty"`     // Порядковый номер запроса
+	Request *model.DeletionRequest `json:"request,omitempty"` // Поставленный в очередь запрос
+	Done    []uint64               `json:"done,omitempty"`    // Номера завершённых запросов
+}
+
+// DeletionQueue — ограниченная очередь фонового удаления ссылок.
+//
+// Запросы разных пользователей накапливаются и каждые interval (или при накоплении batchSize кодов)
+// объединяются в пакеты, каждый из которых удаляется одним запросом к хранилищу.
+// Пакеты обрабатываются пулом из workers обработчиков; неудачное удаление повторяется
+// с экспоненциальной задержкой, а после исчерпания попыток запросы пакета возвращаются в конец очереди.
+// Возвращённый запрос удаляется отдельным пакетом, чтобы не задерживать остальные; после
+// deleteMaxRequeues возвратов он отбрасывается.
+//
+// Если задан путь к журналу, невыполненные запросы сохраняются в файл и восстанавливаются
+// при следующем запуске, так что перезапуск сервиса не теряет удаления. Завершение запросов
+// дописывается в журнал, а сам журнал сжимается при накоплении deleteJournalCompactRecords
+// завершённых запросов и при остановке очереди.
+type DeletionQueue struct {
+	repository  deletionStore // Хранилище ссылок
+	capacity    int           // Максимальное количество кодов в очереди, включая удаляемые
+	batchSize   int           // Количество кодов, при накоплении которого пакет отправляется, не дожидаясь таймера
+	interval    time.Duration // Период отправки накопленных запросов
+	workers     int           // Количество параллельных обработчиков пакетов
+	journalPath string        // Путь к журналу невыполненных запросов; пустая строка — журнал отключён
+	retryDelay  time.Duration // Задержка перед первой повторной попыткой удаления
+
+	// onDeleted вызывается после выполнения пакета запросов; задаётся CreateShortener до запуска Run.
+	onDeleted func(ctx context.Context, requests []model.DeletionRequest)
+
+	mu       sync.Mutex             // Защищает поля ниже
+	pending  []deletionJob          // Запросы, ожидающие отправки, в порядке поступления
+	inFlight map[uint64]deletionJob // Запросы, удаляемые в данный момент
+	queued   int                    // Количество кодов в pending
+	running  int                    // Количество кодов в inFlight
+	nextSeq  uint64                 // Порядковый номер следующего запроса
+	closed   bool                   // true после начала остановки очереди
+	journal  *os.File               // Открытый на дозапись журнал
+	finished int                    // Количество завершённых запросов, записи которых остаются в журнале
+	notify   chan struct{}          // Сигнал о накоплении полного пакета
+
+	deleted  atomic.Int64 // Количество обработанных кодов
+	retries  atomic.Int64 // Количество повторных попыток
+	failed   atomic.Int64 // Количество пакетов, возвращённых в очередь после всех попыток
+	dropped  atomic.Int64 // Количество запросов, отброшенных после deleteMaxRequeues возвратов
+	rejected atomic.Int64 // Количество отклонённых запросов
+}
+
+// Enqueue ставит запрос на удаление ссылок пользователя в очередь.
+//
+// Если журнал включён, запрос записывается в него до возврата из метода.
+//
+// Параметр:
+//   - request: идентификатор пользователя, коды ссылок для удаления и сведения о запросе для журнала операций.
+//
+// Возвращает:
+//   - error: nil, если запрос принят; ErrShuttingDown, если очередь уже останавливается;
+//     ErrDeletionQueueFull, если в очереди нет места; иначе — ошибку записи журнала.
+func (q *DeletionQueue) Enqueue(request model.DeletionRequest) error {
+	size := len(request.HashURLs)
+	if size == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrShuttingDown
+	}
+	if q.queued+q.running+size > q.capacity {
+		q.rejected.Add(1)
+		return ErrDeletionQueueFull
+	}
+	job := deletionJob{seq: q.nextSeq, request: request}
+	if err := q.appendJournal(journalRecord{Seq: job.seq, Request: &job.request}, true); err != nil {
+		return fmt.Errorf("service.deletionQueue.enqueue: %w", err)
+	}
+	q.nextSeq++
+	q.pending = append(q.pending, job)
+	q.queued += size
+	if q.queued >= q.batchSize {
+		select {
+		case q.notify <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Stats возвращает текущее состояние очереди.
+func (q *DeletionQueue) Stats() model.DeletionQueueStats {
+	q.mu.Lock()
+	queued, running := q.queued, q.running
+	q.mu.Unlock()
+	return model.DeletionQueueStats{
+		Pending:  int64(queued),
+		InFlight: int64(running),
+		Capacity: int64(q.capacity),
+		Deleted:  q.deleted.Load(),
+		Retries:  q.retries.Load(),
+		Failed:   q.failed.Load(),
+		Dropped:  q.dropped.Load(),
+		Rejected: q.rejected.Load(),
+	}
+}
+
+// CheckCapacity проверяет, что очередь не переполнена: занято меньше deleteSaturationPercent её ёмкости.
+//
+// Параметр:
+//   - ctx: контекст запроса (не используется, нужен для совместимости с health.Check).
+//
+// Возвращает:
+//   - error: nil, если в очереди есть место, иначе — ошибку с текущей заполненностью.
+func (q *DeletionQueue) CheckCapacity(_ context.Context) error {
+	stats := q.Stats()
+	used := (stats.Pending + stats.InFlight) * 100 / stats.Capacity
+	if used >= deleteSaturationPercent {
+		return fmt.Errorf("deletion queue is %d%% full", used)
+	}
+	return nil
+}
+
+// Run отправляет накопленные запросы обработчикам и блокируется до отмены контекста.
+//
+// После отмены ctx новые запросы отклоняются, а оставшиеся выполняются не дольше
+// deleteDrainTimeout; это выполнение не прерывается отменой ctx. Запросы, которые
+// не удалось выполнить, остаются в журнале до следующего запуска.
+//
+// Параметры:
+//   - ctx: контекст, при отмене которого очередь останавливается.
+//
+// Возвращает:
+//   - error: всегда nil, чтобы корректно завершаться в составе errgroup.
+func (q *DeletionQueue) Run(ctx context.Context) error {
+	batches := make(chan []deletionJob, q.workers)
+	workCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				q.process(workCtx, batch)
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(q.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			q.mu.Lock()
+			q.closed = true
+			q.mu.Unlock()
+			timer := time.AfterFunc(deleteDrainTimeout, cancel)
+			for workCtx.Err() == nil {
+				batch := q.take()
+				if batch == nil {
+					break
+				}
+				batches <- batch
+			}
+			close(batches)
+			wg.Wait()
+			timer.Stop()
+			q.closeJournal()
+			logger.Log.Info("deletion queue stopped", zap.Int64("pending", q.Stats().Pending))
+			return nil
+		case <-ticker.C:
+			q.dispatch(batches, false)
+			q.compactJournal(deleteJournalCompactRecords)
+		case <-q.notify:
+			q.dispatch(batches, true)
+		}
+	}
+}
+
+// dispatch отправляет обработчикам столько пакетов, сколько они могут принять без ожидания.
+// При onlyFull отправляются только полные пакеты.
+func (q *DeletionQueue) dispatch(batches chan<- []deletionJob, onlyFull bool) {
+	for len(batches) < cap(batches) {
+		if onlyFull {
+			q.mu.Lock()
+			full := q.queued >= q.batchSize
+			q.mu.Unlock()
+			if !full {
+				return
+			}
+		}
+		batch := q.take()
+		if batch == nil {
+			return
+		}
+		batches <- batch
+	}
+}
+
+// take извлекает из очереди пакет запросов суммарно не более чем на batchSize кодов
+// (запрос большего размера образует пакет сам по себе) и помечает их как удаляемые.
+// Запрос, уже возвращавшийся в очередь, образует пакет сам по себе.
+// Возвращает nil, если очередь пуста.
+func (q *DeletionQueue) take() []deletionJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return nil
+	}
+	var size, n int
+	for n < len(q.pending) {
+		job := q.pending[n]
+		jobSize := len(job.request.HashURLs)
+		if n > 0 && (size+jobSize > q.batchSize || job.requeues > 0) {
+			break
+		}
+		size += jobSize
+		n++
+		if job.requeues > 0 {
+			break
+		}
+	}
+	batch := make([]deletionJob, n)
+	copy(batch, q.pending[:n])
+	q.pending = q.pending[n:]
+	q.queued -= size
+	q.running += size
+	for _, job := range batch {
+		q.inFlight[job.seq] = job
+	}
+	return batch
+}
+
+// process удаляет пакет, повторяя попытки с экспоненциальной задержкой.
+// Если все попытки неудачны или ctx отменён, пакет возвращается в очередь.
+func (q *DeletionQueue) process(ctx context.Context, batch []deletionJob) {
+	requests := make([]model.DeletionRequest, len(batch))
+	size := 0
+	for i, job := range batch {
+		requests[i] = job.request
+		size += len(job.request.HashURLs)
+	}
+	delay := q.retryDelay
+	for attempt := 0; ; attempt++ {
+		err := q.repository.DeleteBatch(ctx, requests)
+		if err == nil {
+			q.deleted.Add(int64(size))
+			q.complete(batch, size)
+			if q.onDeleted != nil {
+				q.onDeleted(ctx, requests)
+			}
+			return
+		}
+		if ctx.Err() != nil {
+			q.requeue(batch, false)
+			return
+		}
+		if attempt == deleteMaxRetries {
+			logger.Log.Error("couldn't delete URLs, returning them to the queue",
+				zap.Int("count", size), zap.Int("attempts", attempt+1), zap.Error(err))
+			q.failed.Add(1)
+			q.requeue(batch, true)
+			return
+		}
+		q.retries.Add(1)
+		logger.Log.Warn("couldn't delete URLs, retrying", zap.Int("count", size), zap.Duration("delay", delay), zap.Error(err))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		delay *= 2
+	}
+}
+
+// complete снимает выполненный пакет с учёта и отмечает его запросы в журнале как завершённые.
+func (q *DeletionQueue) complete(batch []deletionJob, size int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range batch {
+		delete(q.inFlight, job.seq)
+	}
+	q.running -= size
+	q.finish(batch)
+}
+
+// requeue возвращает невыполненный пакет в конец очереди.
+// При failed каждому запросу засчитывается возврат, а запросы, исчерпавшие deleteMaxRequeues
+// возвратов, отбрасываются.
+func (q *DeletionQueue) requeue(batch []deletionJob, failed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var dropped []deletionJob
+	for _, job := range batch {
+		delete(q.inFlight, job.seq)
+		size := len(job.request.HashURLs)
+		q.running -= size
+		if failed {
+			job.requeues++
+			if job.requeues > deleteMaxRequeues {
+				logger.Log.Error("dropping deletion request after too many failures",
+					zap.String("userID", job.request.UserID), zap.Strings("hashURLs", job.request.HashURLs),
+					zap.String("requestID", job.request.RequestID), zap.Int("requeues", deleteMaxRequeues))
+				q.dropped.Add(1)
+				dropped = append(dropped, job)
+				continue
+			}
+		}
+		q.queued += size
+		q.pending = append(q.pending, job)
+	}
+	q.finish(dropped)
+}
+
+// finish отмечает запросы в журнале как завершённые.
+// Запись не сбрасывается на диск: при её потере запросы будут повторены, а повторное удаление безопасно.
+// Вызывающий код должен удерживать q.mu.
+func (q *DeletionQueue) finish(jobs []deletionJob) {
+	if q.journal == nil || len(jobs) == 0 {
+		return
+	}
+	record := journalRecord{Done: make([]uint64, len(jobs))}
+	for i, job := range jobs {
+		record.Done[i] = job.seq
+	}
+	if err := q.appendJournal(record, false); err != nil {
+		logger.Log.Error("couldn't write deletion journal", zap.Error(err))
+		return
+	}
+	q.finished += len(jobs)
+}
+
+// appendJournal дописывает запись в журнал; при sync — сбрасывает её на диск.
+// Вызывающий код должен удерживать q.mu.
+func (q *DeletionQueue) appendJournal(record journalRecord, sync bool) error {
+	if q.journal == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal journal record: %w", err)
+	}
+	if _, err = q.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	if !sync {
+		return nil
+	}
+	if err = q.journal.Sync(); err != nil {
+		return fmt.Errorf("sync journal: %w", err)
+	}
+	return nil
+}
+
+// compactJournal переписывает журнал без завершённых запросов, если их накопилось не меньше threshold.
+func (q *DeletionQueue) compactJournal(threshold int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.journal == nil || q.finished == 0 || q.finished < threshold {
+		return
+	}
+	if err := q.rewriteJournal(); err != nil {
+		logger.Log.Error("couldn't compact deletion journal", zap.Error(err))
+	}
+}
+
+// rewriteJournal атомарно заменяет журнал списком невыполненных запросов.
+// Вызывающий код должен удерживать q.mu.
+func (q *DeletionQueue) rewriteJournal() error {
+	tmp, err := os.CreateTemp(filepath.Dir(q.journalPath), filepath.Base(q.journalPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, job := range q.unfinished() {
+		if err = encoder.Encode(journalRecord{Seq: job.seq, Request: &job.request}); err != nil {
+			_ = tmp.Close()
+			return fmt.Errorf("write temp file: %w", err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("flush temp file: %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("sync temp file: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), q.journalPath); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("rename temp file: %w", err)
+	}
+	if q.journal != nil {
+		_ = q.journal.Close()
+	}
+	q.journal = tmp
+	q.finished = 0
+	return nil
+}
+
+// unfinished возвращает удаляемые и ожидающие запросы в порядке поступления.
+// Вызывающий код должен удерживать q.mu.
+func (q *DeletionQueue) unfinished() []deletionJob {
+	jobs := make([]deletionJob, 0, len(q.inFlight)+len(q.pending))
+	for _, job := range q.inFlight {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].seq < jobs[j].seq
+	})
+	return append(jobs, q.pending...)
+}
+
+// closeJournal сжимает журнал и закрывает его файл.
+func (q *DeletionQueue) closeJournal() {
+	q.compactJournal(1)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.journal != nil {
+		_ = q.journal.Close()
+		q.journal = nil
+	}
+}
+
+// loadJournal восстанавливает невыполненные запросы из журнала, сжимает его и открывает на дозапись.
+func (q *DeletionQueue) loadJournal() error {
+	file, err := os.OpenFile(q.journalPath, os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return fmt.Errorf("open journal: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var jobs []deletionJob
+	done := make(map[uint64]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record journalRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			logger.Log.Warn("skipping corrupted deletion journal record", zap.Error(err))
+			continue
+		}
+		switch {
+		case len(record.Done) > 0:
+			for _, seq := range record.Done {
+				done[seq] = true
+			}
+		case record.Request != nil:
+			jobs = append(jobs, deletionJob{seq: record.Seq, request: *record.Request})
+			q.nextSeq = max(q.nextSeq, record.Seq+1)
+		default:
+			// Запись прежнего формата: запрос без номера.
+			var request model.DeletionRequest
+			if err = json.Unmarshal(scanner.Bytes(), &request); err == nil {
+				jobs = append(jobs, deletionJob{seq: q.nextSeq, request: request})
+				q.nextSeq++
+			}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("read journal: %w", err)
+	}
+	for _, job := range jobs {
+		if done[job.seq] || len(job.request.HashURLs) == 0 {
+			continue
+		}
+		q.pending = append(q.pending, job)
+		q.queued += len(job.request.HashURLs)
+	}
+	if err = q.rewriteJournal(); err != nil {
+		return fmt.Errorf("compact journal: %w", err)
+	}
+	if q.queued > 0 {
+		logger.Log.Info("restored pending deletions", zap.Int("requests", len(q.pending)), zap.Int("count", q.queued))
+	}
+	return nil
+}
+
+// NewDeletionQueue создаёт очередь фонового удаления ссылок.
+//
+// Неположительные batchSize и workers заменяются на 1, неположительный capacity — на batchSize,
+// неположительный interval — на секунду.
+// Запросы, восстановленные из журнала, принимаются независимо от capacity.
+//
+// Параметры:
+//   - r: хранилище, поддерживающее пакетное удаление.
+//   - capacity: максимальное количество кодов в очереди.
+//   - batchSize: количество кодов, удаляемых одним запросом к хранилищу.
+//   - interval: период отправки накопленных запросов.
+//   - workers: количество параллельных обработчиков.
+//   - journalPath: путь к журналу невыполненных запросов; пустая строка отключает журнал.
+//
+// Возвращает:
+//   - *DeletionQueue: готовая к запуску очередь.
+//   - error: nil, если успешно, иначе — ошибку чтения журнала.
+func NewDeletionQueue(r deletionStore, capacity, batchSize int, interval time.Duration, workers int, journalPath string) (*DeletionQueue, error) {
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	if capacity <= 0 {
+		capacity = batchSize
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	q := &DeletionQueue{
+		repository:  r,
+		capacity:    capacity,
+		batchSize:   batchSize,
+		interval:    interval,
+		workers:     workers,
+		journalPath: journalPath,
+		retryDelay:  deleteRetryBaseDelay,
+		inFlight:    make(map[uint64]deletionJob),
+		notify:      make(chan struct{}, 1),
+	}
+	if journalPath != "" {
+		if err := q.loadJournal(); err != nil {
+			return nil, fmt.Errorf("service.newDeletionQueue: %w", err)
+		}
+	}
+	return q, nil
+}
@@ -1,99 +1,240 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/faust8888/shortener/internal/app/model"
 	"github.com/faust8888/shortener/internal/app/repository"
-	"github.com/faust8888/shortener/internal/app/repository/postgres"
 	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/shortcode"
+	"github.com/faust8888/shortener/internal/app/urlcheck"
 	"github.com/faust8888/shortener/internal/middleware/logger"
+	"github.com/faust8888/shortener/internal/middleware/requestmeta"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
 	"go.uber.org/zap"
+	"time"
 )
 
+// maxCollisionRetries — количество повторных попыток сохранить ссылку с новым кодом при коллизии.
+const maxCollisionRetries = 5
+
+// URLValidator — проверка оригинального URL перед сокращением (см. urlcheck.Validator).
+type URLValidator interface {
+	Validate(rawURL string) error
+}
+
+// AuditRecorder — регистратор событий журнала изменяющих операций (см. audit.Recorder).
+type AuditRecorder interface {
+	// Record записывает событие операции пользователя, недолго ожидая места в буфере.
+	Record(ctx context.Context, event model.AuditEvent)
+	// RecordNoWait записывает событие фоновой задачи, отбрасывая его, если буфер заполнен.
+	RecordNoWait(ctx context.Context, event model.AuditEvent)
+}
+
 // Shortener — это основной сервис приложения, реализующий бизнес-логику для работы с короткими ссылками.
-// Содержит зависимости от репозитория и базового URL.
+// Содержит зависимости от репозитория, генератора кодов, проверки URL, очереди удаления, журнала и базового URL.
+//
+// Создание ссылок, запрос на удаление и выполненное удаление записываются в журнал изменяющих операций
+// вместе с идентификатором запроса и IP-адресом клиента из контекста (см. requestmeta).
 type Shortener struct {
 	repository   repository.Repository // Интерфейс хранилища для операций над данными
+	generator    shortcode.Generator   // Генератор кодов коротких ссылок
+	validator    URLValidator          // Проверка оригинальных URL; nil — только проверка схемы и хоста
+	deletions    *DeletionQueue        // Очередь фонового удаления ссылок; nil — удаление синхронное
+	auditor      AuditRecorder         // Журнал изменяющих операций; nil — журнал не ведётся
 	baseShortURL string                // Базовый URL для формирования полного адреса короткой ссылки
 }
 
 // Create создаёт новую короткую ссылку на основе оригинального URL.
 //
+// У каждого пользователя свой код: если этот же пользователь уже сокращал URL и его ссылка действует,
+// возвращается она вместе с repository.ErrURLAlreadyShortened. Если сгенерированный код уже занят
+// (другим URL, ссылкой другого пользователя или удалённой ссылкой), ссылка сохраняется под кодом,
+// полученным из URL со случайной солью (см. generateCode).
+//
 // Параметры:
+//   - ctx: контекст запроса.
 //   - fullURL: оригинальный URL.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
 //   - string: готовая короткая ссылка.
-//   - error: nil, если успешно, иначе — ошибку.
-func (s *Shortener) Create(fullURL, userID string) (string, error) {
-	urlHash, err := security.CreateHashForURL(fullURL)
-	if err != nil {
+//   - error: nil, если успешно; *urlcheck.Error, если URL не прошёл проверки; иначе — ошибку.
+func (s *Shortener) Create(ctx context.Context, fullURL, userID string) (string, error) {
+	ctx, span := tracing.Start(ctx, "Shortener.Create")
+	defer span.End()
+	if err := s.validateURL(fullURL); err != nil {
 		return "", fmt.Errorf("hash for url: %w", err)
 	}
-	err = s.repository.Save(urlHash, fullURL, userID)
-	if err != nil && !errors.Is(err, postgres.ErrUniqueIndexConstraint) {
+	storedHash, err := s.saveLink(ctx, model.CreateShortDTO{OriginalURL: fullURL}, userID)
+	if err != nil && !errors.Is(err, repository.ErrURLAlreadyShortened) {
 		return "", fmt.Errorf("saving data: %w", err)
 	}
-	shortURL := fmt.Sprintf("%s/%s", s.baseShortURL, urlHash)
-	logger.Log.Info("created short URL", zap.String("shortUrl", shortURL), zap.String("fullUrl", fullURL))
+	if err == nil {
+		s.audit(ctx, model.AuditEvent{Type: model.AuditLinkCreated, UserID: userID,
+			HashURLs: []string{storedHash}, OriginalURLs: []string{fullURL}})
+	}
+	shortURL := fmt.Sprintf("%s/%s", s.baseShortURL, storedHash)
+	logger.FromContext(ctx).Info("created short URL", zap.String("shortUrl", shortURL), zap.String("fullUrl", fullURL))
+	return shortURL, err
+}
+
+// CreateWithJSON создаёт новую короткую ссылку по JSON-запросу.
+//
+// Если в запросе задан алиас, он используется вместо сгенерированного кода.
+// Если задан срок жизни (expires_at или ttl_seconds), ссылка перестаёт работать после его истечения.
+// Запрос должен быть предварительно провалидирован (model.CreateShortRequest.Validate).
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - req: запрос на создание короткой ссылки.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - string: готовая короткая ссылка.
+//   - error: nil, если успешно; *urlcheck.Error, если URL не прошёл проверки;
+//     repository.ErrAliasAlreadyTaken, если алиас занят;
+//     repository.ErrURLAlreadyShortened, если пользователь уже сокращал URL; иначе — ошибку.
+func (s *Shortener) CreateWithJSON(ctx context.Context, req model.CreateShortRequest, userID string) (string, error) {
+	ctx, span := tracing.Start(ctx, "Shortener.CreateWithJSON")
+	defer span.End()
+	if err := s.validateURL(req.URL); err != nil {
+		return "", fmt.Errorf("hash for url: %w", err)
+	}
+	if req.Alias != "" {
+		if err := model.ValidateAlias(req.Alias); err != nil {
+			return "", fmt.Errorf("alias: %w", err)
+		}
+	}
+	storedHash, err := s.saveLink(ctx, model.CreateShortDTO{
+		OriginalURL: req.URL,
+		Alias:       req.Alias,
+		ExpiresAt:   req.Expiration(time.Now()),
+	}, userID)
+	if err != nil && !errors.Is(err, repository.ErrURLAlreadyShortened) {
+		return "", fmt.Errorf("saving data: %w", err)
+	}
+	if err == nil {
+		s.audit(ctx, model.AuditEvent{Type: model.AuditLinkCreated, UserID: userID,
+			HashURLs: []string{storedHash}, OriginalURLs: []string{req.URL}})
+	}
+	shortURL := fmt.Sprintf("%s/%s", s.baseShortURL, storedHash)
+	logger.FromContext(ctx).Info("created short URL", zap.String("shortUrl", shortURL), zap.String("fullUrl", req.URL))
 	return shortURL, err
 }
 
 // FindByHash находит оригинальный URL по его хэш-ключу.
 //
 // Параметры:
+//   - ctx: контекст запроса.
 //   - hashURL: хэш-ключ короткой ссылки.
 //
 // Возвращает:
 //   - string: оригинальный URL.
 //   - error: nil, если найдено, иначе — ошибку.
-func (s *Shortener) FindByHash(hashURL string) (string, error) {
-	foundURL, err := s.repository.FindByHash(hashURL)
+func (s *Shortener) FindByHash(ctx context.Context, hashURL string) (string, error) {
+	ctx, span := tracing.Start(ctx, "Shortener.FindByHash")
+	defer span.End()
+	foundURL, err := s.repository.FindByHash(ctx, hashURL)
 	if err != nil {
-		logger.Log.Error("couldn't find short URL", zap.Error(err))
+		logger.FromContext(ctx).Error("couldn't find short URL", zap.Error(err))
 		return "", fmt.Errorf("find by hash: %w", err)
 	}
-	logger.Log.Info("found short URL", zap.String("hashURL", hashURL))
+	logger.FromContext(ctx).Info("found short URL", zap.String("hashURL", hashURL))
 	return foundURL, nil
 }
 
 // FindAllByUserID возвращает все короткие ссылки, принадлежащие пользователю.
 //
-// Параметр:
+// Параметры:
+//   - ctx: контекст запроса.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
 //   - []model.FindURLByUserIDResponse: список ссылок пользователя.
 //   - error: nil, если успешно, иначе — ошибку.
-func (s *Shortener) FindAllByUserID(userID string) ([]model.FindURLByUserIDResponse, error) {
-	r, err := s.repository.FindAllByUserID(userID)
+func (s *Shortener) FindAllByUserID(ctx context.Context, userID string) ([]model.FindURLByUserIDResponse, error) {
+	ctx, span := tracing.Start(ctx, "Shortener.FindAllByUserID")
+	defer span.End()
+	r, err := s.repository.FindAllByUserID(ctx, userID)
 	if err != nil {
 		return []model.FindURLByUserIDResponse{}, fmt.Errorf("find all by user id (%s): %w", userID, err)
 	}
 	return r, nil
 }
 
+// FindStats возвращает статистику переходов по короткой ссылке пользователя.
+//
+// Параметры:
+//   - ctx: контекст запроса.
+//   - hashURL: хэш-ключ короткой ссылки.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - model.LinkStatsResponse: статистика переходов с заполненным полным коротким URL.
+//   - error: nil, если успешно; repository.ErrNotOwner, если ссылка чужая; иначе — ошибку.
+func (s *Shortener) FindStats(ctx context.Context, hashURL, userID string) (model.LinkStatsResponse, error) {
+	ctx, span := tracing.Start(ctx, "Shortener.FindStats")
+	defer span.End()
+	stats, err := s.repository.FindStatsByHash(ctx, hashURL, userID)
+	if err != nil {
+		return model.LinkStatsResponse{}, fmt.Errorf("find stats by hash: %w", err)
+	}
+	stats.ShortURL = fmt.Sprintf("%s/%s", s.baseShortURL, hashURL)
+	return stats, nil
+}
+
 // CreateWithBatch создаёт несколько коротких ссылок за один раз (пакетная операция).
 //
+// Элементы с алиасом сохраняются под ним, остальные — под сгенерированным кодом.
+// При коллизии кода элемент получает новый код из URL с солью, и пакет сохраняется повторно.
+//
 // Параметры:
-//   - batch: массив элементов запроса с correlation_id и original_url.
+//   - ctx: контекст запроса.
+//   - batch: массив элементов запроса с correlation_id, original_url и необязательным alias.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
 //   - []model.CreateShortRequestBatchItemResponse: массив результатов с correlation_id и short_url.
-//   - error: nil, если успешно, иначе — ошибку.
-func (s *Shortener) CreateWithBatch(batch []model.CreateShortRequestBatchItemRequest, userID string) ([]model.CreateShortRequestBatchItemResponse, error) {
-	logger.Log.Info("creating short URLs with batch", zap.Int("size", len(batch)))
-	batchMap := s.createBatchMap(batch)
-	err := s.repository.SaveAll(batchMap, userID)
+//   - error: nil, если успешно; *urlcheck.Error с correlation_id, если URL элемента не прошёл проверки;
+//     иначе — ошибку.
+func (s *Shortener) CreateWithBatch(ctx context.Context, batch []model.CreateShortRequestBatchItemRequest, userID string) ([]model.CreateShortRequestBatchItemResponse, error) {
+	ctx, span := tracing.Start(ctx, "Shortener.CreateWithBatch")
+	defer span.End()
+	logger.FromContext(ctx).Info("creating short URLs with batch", zap.Int("size", len(batch)))
+	for _, batchItem := range batch {
+		if err := batchItem.Validate(); err != nil {
+			return nil, fmt.Errorf("service.createWithBatch: %w", err)
+		}
+		if err := s.validateURL(batchItem.OriginalURL); err != nil {
+			var rejected *urlcheck.Error
+			if errors.As(err, &rejected) {
+				err = rejected.WithCorrelationID(batchItem.CorrelationID)
+			}
+			return nil, fmt.Errorf("service.createWithBatch: %w", err)
+		}
+	}
+	batchMap, err := s.createBatchMap(ctx, batch)
 	if err != nil {
 		return nil, fmt.Errorf("service.createWithBatch: %w", err)
 	}
+	if err = s.saveBatch(ctx, batchMap, userID); err != nil {
+		return nil, fmt.Errorf("service.createWithBatch: %w", err)
+	}
+	created := model.AuditEvent{Type: model.AuditLinksBatchCreated, UserID: userID,
+		HashURLs: make([]string, 0, len(batch)), OriginalURLs: make([]string, 0, len(batch))}
+	for _, batchItem := range batch {
+		item := batchMap[batchItem.CorrelationID]
+		created.HashURLs = append(created.HashURLs, item.HashURL)
+		created.OriginalURLs = append(created.OriginalURLs, item.OriginalURL)
+	}
+	s.audit(ctx, created)
 	var result = make([]model.CreateShortRequestBatchItemResponse, 0)
 	for correlationID, value := range batchMap {
-		logger.Log.Info("created",
+		logger.FromContext(ctx).Info("created",
 			zap.String("correlationID", correlationID),
 			zap.String("shortURL", value.ShortURL),
 			zap.String("originalURL", value.OriginalURL))
@@ -109,34 +250,210 @@ func (s *Shortener) CreateWithBatch(batch []model.CreateShortRequestBatchItemReq
 // DeleteAsync удаляет несколько коротких ссылок асинхронно.
 //
 // Принимает список идентификаторов (хэшей) и идентификатор пользователя.
-// Операция выполняется в отдельной горутине.
+// Запрос ставится в очередь удаления (см. DeletionQueue) и выполняется в фоне
+// вместе с запросами других пользователей; отмена ctx на него не влияет.
+// В журнал записываются и постановка запроса в очередь, и его выполнение.
+// Если сервис создан без очереди удаления, ссылки удаляются синхронно в рамках ctx.
 //
 // Параметры:
+//   - ctx: контекст запроса с идентификатором запроса и IP-адресом клиента.
 //   - ids: список идентификаторов (хэшей) ссылок для удаления.
 //   - userID: идентификатор пользователя.
 //
 // Возвращает:
-//   - error: всегда nil, так как ошибка внутри горутины не возвращается.
-func (s *Shortener) DeleteAsync(ids []string, userID string) error {
-	go func(ids []string) {
-		err := s.repository.DeleteAll(ids, userID)
-		if err != nil {
-			logger.Log.Info("couldn't delete URLs")
-		}
-	}(ids)
+//   - error: nil, если удаление запущено; ErrShuttingDown, если сервис уже останавливается;
+//     ErrDeletionQueueFull, если очередь переполнена; иначе — ошибку.
+func (s *Shortener) DeleteAsync(ctx context.Context, ids []string, userID string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	meta := requestmeta.FromContext(ctx)
+	request := model.DeletionRequest{UserID: userID, HashURLs: ids, RequestID: meta.RequestID, ClientIP: meta.ClientIP}
+	if s.deletions == nil {
+		return s.deleteSync(ctx, request)
+	}
+	if err := s.deletions.Enqueue(request); err != nil {
+		return err
+	}
+	s.audit(ctx, model.AuditEvent{Type: model.AuditDeleteRequested, UserID: userID, HashURLs: ids})
 	return nil
 }
 
+// deleteSync удаляет ссылки без очереди удаления и записывает в журнал запрос и его выполнение.
+func (s *Shortener) deleteSync(ctx context.Context, request model.DeletionRequest) error {
+	s.audit(ctx, model.AuditEvent{Type: model.AuditDeleteRequested, UserID: request.UserID, HashURLs: request.HashURLs})
+	requests := []model.DeletionRequest{request}
+	if err := s.repository.DeleteBatch(ctx, requests); err != nil {
+		return fmt.Errorf("service.deleteSync: %w", err)
+	}
+	s.auditDeletions(ctx, requests)
+	return nil
+}
+
+// DeletionQueueStats возвращает состояние очереди фонового удаления ссылок;
+// нулевое значение, если сервис создан без очереди.
+func (s *Shortener) DeletionQueueStats() model.DeletionQueueStats {
+	if s.deletions == nil {
+		return model.DeletionQueueStats{}
+	}
+	return s.deletions.Stats()
+}
+
 // CreateShortener инициализирует и возвращает новый экземпляр сервиса Shortener.
 //
 // Параметры:
 //   - s: реализация интерфейса repository.Repository.
 //   - baseShortURL: базовый URL для формирования полных адресов коротких ссылок.
+//   - generator: генератор кодов коротких ссылок.
+//   - validator: проверка оригинальных URL; nil — только проверка наличия схемы и хоста.
+//   - deletions: очередь фонового удаления ссылок (её Run запускается вызывающим кодом); nil — ссылки удаляются синхронно.
+//   - auditor: журнал изменяющих операций; nil — журнал не ведётся.
 //
 // Возвращает:
 //   - *Shortener: готовый к использованию объект сервиса.
-func CreateShortener(s repository.Repository, baseShortURL string) *Shortener {
-	return &Shortener{repository: s, baseShortURL: baseShortURL}
+func CreateShortener(s repository.Repository, baseShortURL string, generator shortcode.Generator, validator URLValidator, deletions *DeletionQueue, auditor AuditRecorder) *Shortener {
+	shortener := &Shortener{
+		repository:   s,
+		generator:    generator,
+		validator:    validator,
+		deletions:    deletions,
+		auditor:      auditor,
+		baseShortURL: baseShortURL,
+	}
+	if deletions != nil {
+		deletions.onDeleted = shortener.auditDeletions
+	}
+	return shortener
+}
+
+// audit записывает событие в журнал, дополняя его моментом операции и, если они не заданы,
+// идентификатором запроса и IP-адресом клиента из контекста.
+func (s *Shortener) audit(ctx context.Context, event model.AuditEvent) {
+	if s.auditor == nil {
+		return
+	}
+	s.auditor.Record(ctx, s.auditEvent(ctx, event))
+}
+
+// auditEvent дополняет событие журнала моментом операции и, если они не заданы,
+// идентификатором запроса и IP-адресом клиента из контекста.
+func (s *Shortener) auditEvent(ctx context.Context, event model.AuditEvent) model.AuditEvent {
+	meta := requestmeta.FromContext(ctx)
+	if event.RequestID == "" {
+		event.RequestID = meta.RequestID
+	}
+	if event.ClientIP == "" {
+		event.ClientIP = meta.ClientIP
+	}
+	event.Timestamp = time.Now().UTC()
+	return event
+}
+
+// auditDeletions записывает в журнал выполненные запросы на удаление
+// с идентификатором запроса и IP-адресом клиента, поставившего удаление в очередь.
+// Вызывается обработчиками очереди удаления, поэтому не ждёт места в буфере журнала:
+// недоступный журнал не должен останавливать удаление.
+func (s *Shortener) auditDeletions(ctx context.Context, requests []model.DeletionRequest) {
+	if s.auditor == nil {
+		return
+	}
+	for _, request := range requests {
+		s.auditor.RecordNoWait(ctx, s.auditEvent(ctx, model.AuditEvent{
+			Type:      model.AuditDeleteApplied,
+			UserID:    request.UserID,
+			RequestID: request.RequestID,
+			ClientIP:  request.ClientIP,
+			HashURLs:  request.HashURLs,
+		}))
+	}
+}
+
+// validateURL проверяет оригинальный URL настроенной проверкой или, если она не задана, security.ValidateURL.
+func (s *Shortener) validateURL(fullURL string) error {
+	if s.validator == nil {
+		return security.ValidateURL(fullURL)
+	}
+	return s.validator.Validate(fullURL)
+}
+
+// saveLink сохраняет ссылку, генерируя код, если алиас не задан.
+//
+// При коллизии (код занят другим URL) генерирует новый код из URL с солью
+// и повторяет сохранение не более maxCollisionRetries раз.
+//
+// Возвращает:
+//   - string: ключ, под которым хранится ссылка.
+//   - error: ошибку репозитория (в том числе repository.ErrURLAlreadyShortened) или генерации кода.
+func (s *Shortener) saveLink(ctx context.Context, link model.CreateShortDTO, userID string) (string, error) {
+	if link.Alias != "" {
+		link.HashURL = link.Alias
+		return s.repository.SaveLink(ctx, link, userID)
+	}
+	for attempt := 0; ; attempt++ {
+		hashURL, err := s.generateCode(ctx, link.OriginalURL, attempt)
+		if err != nil {
+			return "", fmt.Errorf("generate short code: %w", err)
+		}
+		link.HashURL = hashURL
+		storedHash, err := s.repository.SaveLink(ctx, link, userID)
+		if !errors.Is(err, repository.ErrHashCollision) || attempt == maxCollisionRetries {
+			return storedHash, err
+		}
+		logger.FromContext(ctx).Warn("short code collision, retrying with a salted code",
+			zap.String("hashURL", hashURL), zap.Int("attempt", attempt+1))
+	}
+}
+
+// saveBatch сохраняет пакет ссылок, заменяя коды, занятые другими URL.
+//
+// При коллизии элементы пакета с занятым кодом (кроме алиасов) получают новый код
+// из URL с солью, после чего пакет сохраняется повторно. Каждый элемент перегенерируется
+// не более maxCollisionRetries раз.
+func (s *Shortener) saveBatch(ctx context.Context, batchMap map[string]model.CreateShortDTO, userID string) error {
+	attempts := make(map[string]int)
+	for {
+		err := s.repository.SaveAll(ctx, batchMap, userID)
+		var collision *repository.HashCollisionError
+		if !errors.As(err, &collision) {
+			return err
+		}
+		regenerated := false
+		for correlationID, item := range batchMap {
+			if item.Alias != "" || item.HashURL != collision.HashURL {
+				continue
+			}
+			if attempts[correlationID] == maxCollisionRetries {
+				return err
+			}
+			attempts[correlationID]++
+			hashURL, genErr := s.generateCode(ctx, item.OriginalURL, attempts[correlationID])
+			if genErr != nil {
+				return fmt.Errorf("generate short code for %s: %w", correlationID, genErr)
+			}
+			item.HashURL = hashURL
+			item.ShortURL = fmt.Sprintf("%s/%s", s.baseShortURL, hashURL)
+			batchMap[correlationID] = item
+			regenerated = true
+		}
+		if !regenerated {
+			return err
+		}
+		logger.FromContext(ctx).Warn("short code collision in batch, retrying with salted codes", zap.String("hashURL", collision.HashURL))
+	}
+}
+
+// generateCode генерирует код для URL.
+// При attempt > 0 к URL добавляется случайная соль, чтобы детерминированная стратегия hash выдала
+// другой код: один URL могут сократить многие пользователи, и детерминированных солей на всех не хватит.
+func (s *Shortener) generateCode(ctx context.Context, fullURL string, attempt int) (string, error) {
+	if attempt > 0 {
+		salt := make([]byte, 8)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("service.generateCode: %w", err)
+		}
+		fullURL = fmt.Sprintf("%s#%s", fullURL, hex.EncodeToString(salt))
+	}
+	return s.generator.Generate(ctx, fullURL)
 }
 
 // createBatchMap преобразует пакет входящих данных в карту DTO для сохранения.
@@ -144,19 +461,30 @@ func CreateShortener(s repository.Repository, baseShortURL string) *Shortener {
 // Используется внутренне в методе CreateWithBatch.
 //
 // Параметры:
+//   - ctx: контекст запроса.
 //   - batch: массив элементов запроса.
 //
 // Возвращает:
 //   - map[string]model.CreateShortDTO: карта correlation_id → DTO.
-func (s *Shortener) createBatchMap(batch []model.CreateShortRequestBatchItemRequest) map[string]model.CreateShortDTO {
+//   - error: nil, если успешно, иначе — ошибку генерации кода.
+func (s *Shortener) createBatchMap(ctx context.Context, batch []model.CreateShortRequestBatchItemRequest) (map[string]model.CreateShortDTO, error) {
 	var createShortMap = make(map[string]model.CreateShortDTO)
+	now := time.Now()
 	for _, batchItem := range batch {
-		var hashURL = security.CreateHash(batchItem.OriginalURL)
+		var hashURL = batchItem.Alias
+		if hashURL == "" {
+			var err error
+			if hashURL, err = s.generateCode(ctx, batchItem.OriginalURL, 0); err != nil {
+				return nil, fmt.Errorf("generate short code for %s: %w", batchItem.CorrelationID, err)
+			}
+		}
 		createShortMap[batchItem.CorrelationID] = model.CreateShortDTO{
 			OriginalURL: batchItem.OriginalURL,
 			ShortURL:    fmt.Sprintf("%s/%s", s.baseShortURL, hashURL),
 			HashURL:     hashURL,
+			Alias:       batchItem.Alias,
+			ExpiresAt:   batchItem.Expiration(now),
 		}
 	}
-	return createShortMap
+	return createShortMap, nil
 }
@@ -1,13 +1,27 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/migration"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/repository"
 	"github.com/faust8888/shortener/internal/app/repository/inmemory"
+	"github.com/faust8888/shortener/internal/app/repository/postgres"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/shortcode"
+	"github.com/faust8888/shortener/internal/middleware/requestmeta"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 const (
@@ -19,7 +33,8 @@ const (
 
 func TestCreatingShortURLAndFinding(t *testing.T) {
 	cfg := config.Create()
-	service := CreateShortener(inmemory.NewInMemoryRepository(cfg), cfg.BaseShortURL)
+	cfg.StorageFilePath = filepath.Join(t.TempDir(), "storage.txt")
+	service := CreateShortener(inmemory.NewInMemoryRepository(cfg), cfg.BaseShortURL, newHashGenerator(t), nil, nil, nil)
 	tests := []struct {
 		name    string
 		fullURL string
@@ -38,12 +53,12 @@ func TestCreatingShortURLAndFinding(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			shortURL, err := service.Create(test.fullURL, test.userID)
+			shortURL, err := service.Create(context.Background(), test.fullURL, test.userID)
 			require.NoError(t, err, CreateShortURLErrorMessage)
 			parsedURL, _ := url.Parse(shortURL)
 
 			hashURL := strings.TrimPrefix(parsedURL.Path, "/")
-			returnedFullURL, err := service.FindByHash(hashURL)
+			returnedFullURL, err := service.FindByHash(context.Background(), hashURL)
 
 			require.NoError(t, err, GetFullURLErrorMessage)
 			assert.Equal(t, test.fullURL, returnedFullURL, URLNotMatchErrorMessage)
@@ -53,21 +68,215 @@ func TestCreatingShortURLAndFinding(t *testing.T) {
 
 func TestCouldNotFindFullURL(t *testing.T) {
 	cfg := config.Create()
-	shortener := CreateShortener(inmemory.NewInMemoryRepository(cfg), cfg.ServerAddress)
-	_, err := shortener.Create(TestURL, "123456")
+	cfg.StorageFilePath = filepath.Join(t.TempDir(), "storage.txt")
+	shortener := CreateShortener(inmemory.NewInMemoryRepository(cfg), cfg.ServerAddress, newHashGenerator(t), nil, nil, nil)
+	_, err := shortener.Create(context.Background(), TestURL, "123456")
 	require.NoError(t, err, CreateShortURLErrorMessage)
 
-	fullURL, err := shortener.FindByHash("not_existing_hash_key")
+	fullURL, err := shortener.FindByHash(context.Background(), "not_existing_hash_key")
 
 	require.Error(t, err, "Expected error when trying to find full URL")
 	require.Equal(t, "find by hash: short url not found for not_existing_hash_key", err.Error())
 	require.Equal(t, "", fullURL)
 }
 
+func TestCreatingShortURLWithStrategies(t *testing.T) {
+	for _, strategy := range []string{shortcode.StrategyHash, shortcode.StrategyCounter, shortcode.StrategyRandom} {
+		t.Run(strategy, func(t *testing.T) {
+			cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+			repo := inmemory.NewInMemoryRepository(cfg)
+			defer func() {
+				_ = repo.Close()
+			}()
+			generator, err := shortcode.NewGenerator(strategy, 0, repo)
+			require.NoError(t, err)
+			shortener := CreateShortener(repo, cfg.BaseShortURL, generator, nil, nil, nil)
+
+			created := make(map[string]string)
+			shortURL, err := shortener.Create(context.Background(), "https://yandex.ru/1", "user")
+			require.NoError(t, err)
+			created[shortURL] = "https://yandex.ru/1"
+			shortURL, err = shortener.CreateWithJSON(context.Background(), model.CreateShortRequest{URL: "https://yandex.ru/2"}, "user")
+			require.NoError(t, err)
+			created[shortURL] = "https://yandex.ru/2"
+			batch, err := shortener.CreateWithBatch(context.Background(), []model.CreateShortRequestBatchItemRequest{
+				{CorrelationID: "3", OriginalURL: "https://yandex.ru/3"},
+				{CorrelationID: "4", OriginalURL: "https://yandex.ru/4"},
+			}, "user")
+			require.NoError(t, err)
+			for _, item := range batch {
+				created[item.ShortURL] = "https://yandex.ru/" + item.CorrelationID
+			}
+
+			require.Len(t, created, 4, "short codes must be unique")
+			for shortURL, fullURL := range created {
+				found, err := shortener.FindByHash(context.Background(), strings.TrimPrefix(shortURL, cfg.BaseShortURL+"/"))
+				require.NoError(t, err)
+				assert.Equal(t, fullURL, found)
+			}
+			if strategy == shortcode.StrategyCounter {
+				assert.Contains(t, created, "http://localhost/1")
+			}
+		})
+	}
+}
+
+// collidingGenerator — генератор, выдающий один и тот же код для всех URL без соли.
+type collidingGenerator struct{}
+
+func (g *collidingGenerator) Generate(_ context.Context, fullURL string) (string, error) {
+	if !strings.Contains(fullURL, "#") {
+		return "collision", nil
+	}
+	return security.CreateHash(fullURL), nil
+}
+
+func TestCreatingShortURLWithCollision(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	repo := inmemory.NewInMemoryRepository(cfg)
+	defer func() {
+		_ = repo.Close()
+	}()
+	shortener := CreateShortener(repo, cfg.BaseShortURL, &collidingGenerator{}, nil, nil, nil)
+
+	first, err := shortener.Create(context.Background(), "https://yandex.ru/first", "user")
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost/collision", first)
+
+	second, err := shortener.CreateWithJSON(context.Background(), model.CreateShortRequest{URL: "https://yandex.ru/second"}, "user")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	again, err := shortener.Create(context.Background(), "https://yandex.ru/second", "user")
+	require.ErrorIs(t, err, repository.ErrURLAlreadyShortened)
+	assert.Equal(t, second, again, "the same user must get the existing code")
+	other, err := shortener.Create(context.Background(), "https://yandex.ru/second", "other")
+	require.NoError(t, err)
+	assert.NotEqual(t, second, other, "another user must get their own code")
+
+	batch, err := shortener.CreateWithBatch(context.Background(), []model.CreateShortRequestBatchItemRequest{
+		{CorrelationID: "third", OriginalURL: "https://yandex.ru/third"},
+		{CorrelationID: "fourth", OriginalURL: "https://yandex.ru/fourth"},
+	}, "user")
+	require.NoError(t, err)
+	require.Len(t, batch, 2)
+
+	created := map[string]string{first: "https://yandex.ru/first", second: "https://yandex.ru/second"}
+	for _, item := range batch {
+		created[item.ShortURL] = "https://yandex.ru/" + item.CorrelationID
+	}
+	require.Len(t, created, 4, "short codes must be unique")
+	for shortURL, fullURL := range created {
+		found, err := shortener.FindByHash(context.Background(), strings.TrimPrefix(shortURL, cfg.BaseShortURL+"/"))
+		require.NoError(t, err)
+		assert.Equal(t, fullURL, found)
+	}
+}
+
+// testBackends возвращает хранилища, на которых проверяется сервис: in-memory и, если задана
+// переменная окружения TEST_DATABASE_DSN, PostgreSQL.
+func testBackends(t *testing.T) map[string]func(t *testing.T) repository.Repository {
+	backends := map[string]func(t *testing.T) repository.Repository{
+		"inmemory": func(t *testing.T) repository.Repository {
+			repo := inmemory.NewInMemoryRepository(&config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt")})
+			t.Cleanup(func() {
+				_ = repo.Close()
+			})
+			return repo
+		},
+	}
+	if dsn := os.Getenv("TEST_DATABASE_DSN"); dsn != "" {
+		backends["postgres"] = func(t *testing.T) repository.Repository {
+			require.NoError(t, migration.RunFrom(dsn, filepath.Join("..", "migration", "sql")))
+			return postgres.NewPostgresRepository(&config.Config{DataSourceName: dsn, QueryTimeout: 5 * time.Second})
+		}
+	}
+	return backends
+}
+
+func TestCreatingAliasForShortenedURL(t *testing.T) {
+	for name, newRepository := range testBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			shortener := CreateShortener(newRepository(t), "http://localhost", newHashGenerator(t), nil, nil, nil)
+			suffix := time.Now().UnixNano()
+			fullURL := fmt.Sprintf("https://yandex.ru/alias/%d", suffix)
+			generated, err := shortener.Create(context.Background(), fullURL, "user")
+			require.NoError(t, err)
+
+			tests := []struct {
+				name    string
+				alias   string
+				userID  string
+				wantErr error
+			}{
+				{name: "Alias of the same user", alias: fmt.Sprintf("mine-%d", suffix), userID: "user"},
+				{name: "Alias of another user", alias: fmt.Sprintf("theirs-%d", suffix), userID: "other"},
+				{name: "Taken alias", alias: fmt.Sprintf("mine-%d", suffix), userID: "other", wantErr: repository.ErrAliasAlreadyTaken},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					shortURL, err := shortener.CreateWithJSON(context.Background(), model.CreateShortRequest{URL: fullURL, Alias: tt.alias}, tt.userID)
+					if tt.wantErr != nil {
+						require.ErrorIs(t, err, tt.wantErr)
+						return
+					}
+					require.NoError(t, err)
+					assert.Equal(t, "http://localhost/"+tt.alias, shortURL, "the alias must not be replaced with the generated code")
+					found, err := shortener.FindByHash(context.Background(), tt.alias)
+					require.NoError(t, err)
+					assert.Equal(t, fullURL, found)
+				})
+			}
+			found, err := shortener.FindByHash(context.Background(), strings.TrimPrefix(generated, "http://localhost/"))
+			require.NoError(t, err)
+			assert.Equal(t, fullURL, found, "the generated code must keep working")
+		})
+	}
+}
+
+// clickSaverMock — реализация интерфейса clickSaver, запоминающая сохранённые события.
+type clickSaverMock struct {
+	mu     sync.Mutex
+	clicks []model.ClickEvent
+}
+
+func (c *clickSaverMock) SaveClicks(_ context.Context, clicks []model.ClickEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clicks = append(c.clicks, clicks...)
+	return nil
+}
+
+func (c *clickSaverMock) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.clicks)
+}
+
+func TestClickCollector(t *testing.T) {
+	saver := &clickSaverMock{}
+	collector := NewClickCollector(saver, 2, time.Hour)
+
+	collector.Record(model.ClickEvent{HashURL: "a"})
+	collector.Record(model.ClickEvent{HashURL: "b"})
+	collector.Record(model.ClickEvent{HashURL: "c"})
+	assert.Equal(t, int64(1), collector.Dropped(), "event must be dropped when buffer is full")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = collector.Run(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	assert.Equal(t, 2, saver.count(), "buffered events must be saved on shutdown")
+}
+
 func BenchmarkCreatingShortURLAndFinding(b *testing.B) {
 	b.StopTimer()
 	cfg := config.Create()
-	service := CreateShortener(inmemory.NewInMemoryRepository(cfg), cfg.BaseShortURL)
+	service := CreateShortener(inmemory.NewInMemoryRepository(cfg), cfg.BaseShortURL, newHashGenerator(b), nil, nil, nil)
 	tests := []struct {
 		name    string
 		fullURL string
@@ -87,15 +296,317 @@ func BenchmarkCreatingShortURLAndFinding(b *testing.B) {
 	b.StartTimer()
 	for _, test := range tests {
 		b.Run(test.name, func(t *testing.B) {
-			shortURL, err := service.Create(test.fullURL, test.userID)
+			shortURL, err := service.Create(context.Background(), test.fullURL, test.userID)
 			require.NoError(t, err, CreateShortURLErrorMessage)
 			parsedURL, _ := url.Parse(shortURL)
 
 			hashURL := strings.TrimPrefix(parsedURL.Path, "/")
-			returnedFullURL, err := service.FindByHash(hashURL)
+			returnedFullURL, err := service.FindByHash(context.Background(), hashURL)
 
 			require.NoError(t, err, GetFullURLErrorMessage)
 			assert.Equal(t, test.fullURL, returnedFullURL, URLNotMatchErrorMessage)
 		})
 	}
 }
+
+func newHashGenerator(tb testing.TB) shortcode.Generator {
+	generator, err := shortcode.NewHashGenerator(0)
+	require.NoError(tb, err)
+	return generator
+}
+
+// recordingDeleter — хранилище, запоминающее пакеты удаления и отклоняющее первые failures вызовов,
+// а также все пакеты с запросами пользователя brokenUser.
+type recordingDeleter struct {
+	mu         sync.Mutex
+	failures   int
+	brokenUser string
+	batches    [][]model.DeletionRequest
+}
+
+func (r *recordingDeleter) DeleteBatch(_ context.Context, requests []model.DeletionRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("storage is unavailable")
+	}
+	for _, request := range requests {
+		if r.brokenUser != "" && request.UserID == r.brokenUser {
+			return errors.New("request is rejected by storage")
+		}
+	}
+	r.batches = append(r.batches, requests)
+	return nil
+}
+
+func (r *recordingDeleter) Batches() [][]model.DeletionRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]model.DeletionRequest(nil), r.batches...)
+}
+
+// runDeletionQueue запускает очередь в фоне и возвращает функцию, останавливающую её.
+func runDeletionQueue(q *DeletionQueue) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = q.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func TestDeletionQueueCoalescesRequests(t *testing.T) {
+	store := &recordingDeleter{}
+	q, err := NewDeletionQueue(store, 100, 100, 20*time.Millisecond, 2, "")
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "user1", HashURLs: []string{"a", "b"}}))
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "user2", HashURLs: []string{"c"}}))
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "user3", HashURLs: []string{"d"}}))
+
+	stop := runDeletionQueue(q)
+	defer stop()
+	require.Eventually(t, func() bool {
+		return q.Stats().Deleted == 4
+	}, time.Second, 10*time.Millisecond)
+
+	batches := store.Batches()
+	require.Len(t, batches, 1, "requests of different users must be deleted with a single call")
+	assert.Equal(t, []model.DeletionRequest{
+		{UserID: "user1", HashURLs: []string{"a", "b"}},
+		{UserID: "user2", HashURLs: []string{"c"}},
+		{UserID: "user3", HashURLs: []string{"d"}},
+	}, batches[0])
+	assert.Equal(t, int64(0), q.Stats().Pending)
+}
+
+func TestDeletionQueueRetries(t *testing.T) {
+	store := &recordingDeleter{failures: 2}
+	q, err := NewDeletionQueue(store, 100, 100, 10*time.Millisecond, 1, "")
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "user", HashURLs: []string{"a"}}))
+
+	stop := runDeletionQueue(q)
+	defer stop()
+	require.Eventually(t, func() bool {
+		return q.Stats().Deleted == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	stats := q.Stats()
+	assert.Equal(t, int64(2), stats.Retries)
+	assert.Equal(t, int64(0), stats.Failed)
+}
+
+func TestDeletionQueueDropsFailingRequests(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "deletions.jsonl")
+	store := &recordingDeleter{brokenUser: "broken"}
+	q, err := NewDeletionQueue(store, 100, 100, 5*time.Millisecond, 1, journal)
+	require.NoError(t, err)
+	q.retryDelay = time.Millisecond
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "broken", HashURLs: []string{"a"}}))
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "user", HashURLs: []string{"b"}}))
+
+	stop := runDeletionQueue(q)
+	require.Eventually(t, func() bool {
+		return q.Stats().Dropped == 1
+	}, 5*time.Second, 10*time.Millisecond, "a request that always fails must be dropped")
+	stop()
+
+	stats := q.Stats()
+	assert.Equal(t, int64(1), stats.Deleted, "a failing request must not block the others")
+	assert.Equal(t, int64(deleteMaxRequeues+1), stats.Failed)
+	assert.Equal(t, int64(0), stats.Pending)
+	assert.Equal(t, [][]model.DeletionRequest{{{UserID: "user", HashURLs: []string{"b"}}}}, store.Batches())
+
+	// Отброшенный запрос не восстанавливается из журнала.
+	restored, err := NewDeletionQueue(&recordingDeleter{}, 100, 100, time.Hour, 1, journal)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), restored.Stats().Pending)
+}
+
+func TestDeletionQueueRejectsWhenFull(t *testing.T) {
+	q, err := NewDeletionQueue(&recordingDeleter{}, 2, 10, time.Hour, 1, "")
+	require.NoError(t, err)
+	require.NoError(t, q.CheckCapacity(context.Background()))
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "user", HashURLs: []string{"a", "b"}}))
+	require.Error(t, q.CheckCapacity(context.Background()), "a saturated queue must fail the readiness check")
+
+	err = q.Enqueue(model.DeletionRequest{UserID: "user", HashURLs: []string{"c"}})
+	require.ErrorIs(t, err, ErrDeletionQueueFull)
+	stats := q.Stats()
+	assert.Equal(t, int64(2), stats.Pending)
+	assert.Equal(t, int64(2), stats.Capacity)
+	assert.Equal(t, int64(1), stats.Rejected)
+}
+
+func TestDeletionQueueDrainsOnShutdown(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	repo := inmemory.NewInMemoryRepository(cfg)
+	defer func() {
+		_ = repo.Close()
+	}()
+	q, err := NewDeletionQueue(repo, 100, 100, time.Hour, 1, "")
+	require.NoError(t, err)
+	shortener := CreateShortener(repo, cfg.BaseShortURL, newHashGenerator(t), nil, q, nil)
+	shortURL, err := shortener.Create(context.Background(), TestURL, "user")
+	require.NoError(t, err)
+	hashURL := strings.TrimPrefix(shortURL, cfg.BaseShortURL+"/")
+
+	stop := runDeletionQueue(q)
+	require.NoError(t, shortener.DeleteAsync(context.Background(), []string{hashURL}, "user"))
+	stop()
+	_, err = repo.FindByHash(context.Background(), hashURL)
+	require.ErrorIs(t, err, repository.ErrRecordDeleted)
+
+	err = shortener.DeleteAsync(context.Background(), []string{hashURL}, "user")
+	require.ErrorIs(t, err, ErrShuttingDown)
+}
+
+// auditRecorderMock запоминает события журнала изменяющих операций.
+type auditRecorderMock struct {
+	mu     sync.Mutex
+	events []model.AuditEvent
+}
+
+func (a *auditRecorderMock) Record(_ context.Context, event model.AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.events = append(a.events, event)
+}
+
+func (a *auditRecorderMock) RecordNoWait(ctx context.Context, event model.AuditEvent) {
+	a.Record(ctx, event)
+}
+
+func (a *auditRecorderMock) Events() []model.AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]model.AuditEvent(nil), a.events...)
+}
+
+func TestShortenerAudit(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	repo := inmemory.NewInMemoryRepository(cfg)
+	defer func() {
+		_ = repo.Close()
+	}()
+	q, err := NewDeletionQueue(repo, 100, 100, time.Hour, 1, "")
+	require.NoError(t, err)
+	auditor := &auditRecorderMock{}
+	shortener := CreateShortener(repo, cfg.BaseShortURL, newHashGenerator(t), nil, q, auditor)
+	ctx := requestmeta.WithMeta(context.Background(), requestmeta.Meta{RequestID: "req-1", ClientIP: "203.0.113.7"})
+
+	shortURL, err := shortener.Create(ctx, TestURL, "user")
+	require.NoError(t, err)
+	hashURL := strings.TrimPrefix(shortURL, cfg.BaseShortURL+"/")
+	// Повторное сокращение того же URL ничего не меняет и в журнал не попадает.
+	_, err = shortener.Create(ctx, TestURL, "user")
+	require.ErrorIs(t, err, repository.ErrURLAlreadyShortened)
+	_, err = shortener.CreateWithBatch(ctx, []model.CreateShortRequestBatchItemRequest{
+		{CorrelationID: "1", OriginalURL: "https://example.com/1"},
+		{CorrelationID: "2", OriginalURL: "https://example.com/2"},
+	}, "user")
+	require.NoError(t, err)
+
+	stop := runDeletionQueue(q)
+	require.NoError(t, shortener.DeleteAsync(ctx, []string{hashURL}, "user"))
+	stop()
+
+	events := auditor.Events()
+	require.Len(t, events, 4)
+	assert.Equal(t, model.AuditLinkCreated, events[0].Type)
+	assert.Equal(t, []string{hashURL}, events[0].HashURLs)
+	assert.Equal(t, []string{TestURL}, events[0].OriginalURLs)
+	assert.Equal(t, model.AuditLinksBatchCreated, events[1].Type)
+	assert.Equal(t, []string{"https://example.com/1", "https://example.com/2"}, events[1].OriginalURLs)
+	assert.Len(t, events[1].HashURLs, 2)
+	assert.Equal(t, model.AuditDeleteRequested, events[2].Type)
+	assert.Equal(t, model.AuditDeleteApplied, events[3].Type)
+	assert.Equal(t, []string{hashURL}, events[3].HashURLs)
+	for _, event := range events {
+		assert.Equal(t, "user", event.UserID)
+		assert.Equal(t, "req-1", event.RequestID)
+		assert.Equal(t, "203.0.113.7", event.ClientIP)
+		assert.False(t, event.Timestamp.IsZero())
+	}
+}
+
+func TestShortenerWithoutDeletionQueue(t *testing.T) {
+	cfg := &config.Config{StorageFilePath: filepath.Join(t.TempDir(), "storage.txt"), BaseShortURL: "http://localhost"}
+	repo := inmemory.NewInMemoryRepository(cfg)
+	defer func() {
+		_ = repo.Close()
+	}()
+	auditor := &auditRecorderMock{}
+	shortener := CreateShortener(repo, cfg.BaseShortURL, newHashGenerator(t), nil, nil, auditor)
+	shortURL, err := shortener.Create(context.Background(), TestURL, "user")
+	require.NoError(t, err)
+	hashURL := strings.TrimPrefix(shortURL, cfg.BaseShortURL+"/")
+
+	// Без очереди ссылка удаляется сразу, а состояние очереди пустое.
+	require.NoError(t, shortener.DeleteAsync(context.Background(), []string{hashURL}, "user"))
+	_, err = repo.FindByHash(context.Background(), hashURL)
+	require.ErrorIs(t, err, repository.ErrRecordDeleted)
+	assert.Equal(t, model.DeletionQueueStats{}, shortener.DeletionQueueStats())
+
+	events := auditor.Events()
+	require.Len(t, events, 3)
+	assert.Equal(t, model.AuditDeleteRequested, events[1].Type)
+	assert.Equal(t, model.AuditDeleteApplied, events[2].Type)
+}
+
+func TestDeletionQueueJournal(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "deletions.jsonl")
+	q, err := NewDeletionQueue(&recordingDeleter{}, 100, 100, time.Hour, 1, journal)
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "user1", HashURLs: []string{"a", "b"}}))
+	require.NoError(t, q.Enqueue(model.DeletionRequest{UserID: "user2", HashURLs: []string{"c"}}))
+
+	// Перезапуск без выполнения удалений: запросы восстанавливаются из журнала.
+	store := &recordingDeleter{}
+	restored, err := NewDeletionQueue(store, 100, 100, time.Hour, 1, journal)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Stats().Pending)
+	runDeletionQueue(restored)()
+	require.Len(t, store.Batches(), 1)
+	assert.Equal(t, []model.DeletionRequest{
+		{UserID: "user1", HashURLs: []string{"a", "b"}},
+		{UserID: "user2", HashURLs: []string{"c"}},
+	}, store.Batches()[0])
+
+	// Выполненные запросы удаляются из журнала при остановке очереди.
+	data, err := os.ReadFile(journal)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+	empty, err := NewDeletionQueue(&recordingDeleter{}, 100, 100, time.Hour, 1, journal)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), empty.Stats().Pending)
+}
+
+func TestDeletionQueueJournalAcks(t *testing.T) {
+	journal := filepath.Join(t.TempDir(), "deletions.jsonl")
+	// Журнал прежнего формата: запросы без номеров.
+	legacy := `{"user_id":"user1","hash_urls":["a","b"]}` + "\n" + `{"user_id":"user2","hash_urls":["c","d"]}` + "\n"
+	require.NoError(t, os.WriteFile(journal, []byte(legacy), 0666))
+	store := &recordingDeleter{}
+	q, err := NewDeletionQueue(store, 100, 2, time.Hour, 1, journal)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), q.Stats().Pending)
+
+	// Выполнение пакета дописывает в журнал отметку о завершении, не переписывая его.
+	q.process(context.Background(), q.take())
+	require.Len(t, store.Batches(), 1)
+	data, err := os.ReadFile(journal)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(data), "\n"))
+
+	// Перезапуск без остановки очереди восстанавливает только незавершённый запрос.
+	restored, err := NewDeletionQueue(&recordingDeleter{}, 100, 2, time.Hour, 1, journal)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), restored.Stats().Pending)
+	require.Len(t, restored.pending, 1)
+	assert.Equal(t, "user2", restored.pending[0].request.UserID)
+}
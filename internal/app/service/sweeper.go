@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"go.uber.org/zap"
+	"time"
+)
+
+// ExpiredSweeper — фоновая задача, периодически удаляющая из хранилища ссылки с истёкшим сроком жизни.
+type ExpiredSweeper struct {
+	repository expiredDeleter // Хранилище, из которого удаляются истёкшие ссылки
+	interval   time.Duration  // Период между очистками
+}
+
+type expiredDeleter interface {
+	DeleteExpired(ctx context.Context, now time.Time) ([]string, error)
+}
+
+// Run запускает периодическую очистку и блокируется до отмены контекста.
+//
+// Ошибки очистки логируются и не прерывают работу: следующая попытка будет
+// выполнена через interval.
+//
+// Параметры:
+//   - ctx: контекст, при отмене которого очистка завершается.
+//
+// Возвращает:
+//   - error: всегда nil, чтобы корректно завершаться в составе errgroup.
+func (s *ExpiredSweeper) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Log.Info("expired links sweeper stopped")
+			return nil
+		case now := <-ticker.C:
+			s.sweep(ctx, now)
+		}
+	}
+}
+
+// sweep выполняет одну очистку истёкших ссылок.
+func (s *ExpiredSweeper) sweep(ctx context.Context, now time.Time) {
+	deleted, err := s.repository.DeleteExpired(ctx, now)
+	if err != nil {
+		logger.Log.Error("couldn't delete expired URLs", zap.Error(err))
+		return
+	}
+	if len(deleted) > 0 {
+		logger.Log.Info("deleted expired URLs", zap.Int("count", len(deleted)))
+	}
+}
+
+// NewExpiredSweeper создаёт фоновую задачу очистки истёкших ссылок.
+//
+// Параметры:
+//   - r: хранилище, поддерживающее удаление истёкших ссылок.
+//   - interval: период между очистками.
+//
+// Возвращает:
+//   - *ExpiredSweeper: готовая к запуску задача.
+func NewExpiredSweeper(r expiredDeleter, interval time.Duration) *ExpiredSweeper {
+	return &ExpiredSweeper{repository: r, interval: interval}
+}
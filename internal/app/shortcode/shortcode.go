@@ -0,0 +1,223 @@
+// Package shortcode предоставляет стратегии генерации кодов коротких ссылок.
+//
+// Поддерживаются стратегии:
+//   - hash: первые символы Base64-URL(SHA-256) оригинального URL (детерминированная);
+//   - counter: Base62-представление следующего значения последовательности хранилища;
+//   - random: случайная Base62-строка с повторной генерацией при коллизии.
+package shortcode
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Стратегии генерации кодов.
+const (
+	// StrategyHash — код из хэша оригинального URL.
+	StrategyHash = "hash"
+	// StrategyCounter — код из значения последовательности хранилища.
+	StrategyCounter = "counter"
+	// StrategyRandom — случайный код.
+	StrategyRandom = "random"
+)
+
+// Длины кодов по умолчанию и ограничения.
+const (
+	// DefaultHashLength — длина кода стратегии hash по умолчанию.
+	DefaultHashLength = 10
+	// DefaultRandomLength — длина кода стратегии random по умолчанию.
+	DefaultRandomLength = 8
+	// maxHashLength — длина Base64-URL-представления SHA-256 без выравнивания.
+	maxHashLength = 43
+	// maxCodeLength — максимальная длина кода (ограничена размером колонки short_url).
+	maxCodeLength = 255
+	// maxAttempts — количество попыток подобрать свободный код для стратегий counter и random.
+	maxAttempts = 10
+)
+
+// base62Alphabet — алфавит кодов стратегий counter и random.
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// ErrNoFreeCode — ошибка, возникающая, если за maxAttempts попыток не удалось подобрать свободный код.
+var ErrNoFreeCode = errors.New("couldn't generate a free short code")
+
+// Generator — интерфейс генератора кодов коротких ссылок.
+type Generator interface {
+	// Generate создаёт код короткой ссылки для оригинального URL.
+	//
+	// Параметры:
+	//   - ctx: контекст запроса.
+	//   - fullURL: оригинальный URL.
+	//
+	// Возвращает:
+	//   - string: код короткой ссылки.
+	//   - error: nil, если успешно, иначе — ошибку.
+	Generate(ctx context.Context, fullURL string) (string, error)
+}
+
+// Sequence — источник монотонно возрастающих идентификаторов для стратегии counter.
+type Sequence interface {
+	// NextID возвращает следующее значение последовательности.
+	NextID(ctx context.Context) (int64, error)
+}
+
+// ExistenceChecker — проверка занятости кода для стратегий counter и random.
+type ExistenceChecker interface {
+	// ExistsByHash возвращает true, если код уже используется.
+	ExistsByHash(ctx context.Context, hashURL string) (bool, error)
+}
+
+// Store — хранилище, которое нужно генераторам counter и random.
+type Store interface {
+	Sequence
+	ExistenceChecker
+}
+
+// HashGenerator — генератор кодов из SHA-256 оригинального URL.
+// Один и тот же URL всегда получает один и тот же код.
+type HashGenerator struct {
+	length int // Длина кода
+}
+
+// Generate возвращает первые length символов Base64-URL-представления SHA-256 от fullURL.
+func (g *HashGenerator) Generate(_ context.Context, fullURL string) (string, error) {
+	hashBytes := sha256.Sum256([]byte(fullURL))
+	return base64.RawURLEncoding.EncodeToString(hashBytes[:])[:g.length], nil
+}
+
+// CounterGenerator — генератор кодов из значений последовательности хранилища.
+// Коды получаются короткими и не повторяются; занятые коды (например, алиасы) пропускаются.
+type CounterGenerator struct {
+	sequence Sequence         // Источник значений
+	checker  ExistenceChecker // Проверка занятости кода
+	length   int              // Минимальная длина кода (дополняется слева нулями); 0 — без дополнения
+}
+
+// Generate возвращает Base62-представление следующего свободного значения последовательности.
+func (g *CounterGenerator) Generate(ctx context.Context, _ string) (string, error) {
+	for i := 0; i < maxAttempts; i++ {
+		id, err := g.sequence.NextID(ctx)
+		if err != nil {
+			return "", fmt.Errorf("shortcode.counter.nextID: %w", err)
+		}
+		code := encodeBase62(id)
+		if len(code) < g.length {
+			code = strings.Repeat(string(base62Alphabet[0]), g.length-len(code)) + code
+		}
+		taken, err := g.checker.ExistsByHash(ctx, code)
+		if err != nil {
+			return "", fmt.Errorf("shortcode.counter.exists: %w", err)
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+	return "", ErrNoFreeCode
+}
+
+// RandomGenerator — генератор случайных кодов фиксированной длины.
+// При совпадении с уже существующим кодом генерирует новый, не более maxAttempts раз.
+type RandomGenerator struct {
+	checker ExistenceChecker // Проверка занятости кода
+	length  int              // Длина кода
+}
+
+// Generate возвращает случайный свободный Base62-код.
+func (g *RandomGenerator) Generate(ctx context.Context, _ string) (string, error) {
+	for i := 0; i < maxAttempts; i++ {
+		code, err := randomBase62(g.length)
+		if err != nil {
+			return "", fmt.Errorf("shortcode.random: %w", err)
+		}
+		taken, err := g.checker.ExistsByHash(ctx, code)
+		if err != nil {
+			return "", fmt.Errorf("shortcode.random.exists: %w", err)
+		}
+		if !taken {
+			return code, nil
+		}
+	}
+	return "", ErrNoFreeCode
+}
+
+// NewHashGenerator создаёт генератор кодов из хэша URL.
+//
+// Параметр:
+//   - length: длина кода от 1 до 43; 0 — DefaultHashLength.
+//
+// Возвращает:
+//   - *HashGenerator: готовый к использованию генератор.
+//   - error: nil, если длина допустима, иначе — ошибку.
+func NewHashGenerator(length int) (*HashGenerator, error) {
+	if length == 0 {
+		length = DefaultHashLength
+	}
+	if length < 0 || length > maxHashLength {
+		return nil, fmt.Errorf("invalid hash short code length %d: must be between 1 and %d", length, maxHashLength)
+	}
+	return &HashGenerator{length: length}, nil
+}
+
+// NewGenerator создаёт генератор кодов по названию стратегии.
+//
+// Параметры:
+//   - strategy: StrategyHash, StrategyCounter или StrategyRandom.
+//   - length: длина кода (для counter — минимальная длина); 0 — значение по умолчанию для стратегии.
+//   - store: хранилище для последовательности и проверки занятости кодов.
+//
+// Возвращает:
+//   - Generator: готовый к использованию генератор.
+//   - error: nil, если стратегия и длина допустимы, иначе — ошибку.
+func NewGenerator(strategy string, length int, store Store) (Generator, error) {
+	if length < 0 || length > maxCodeLength {
+		return nil, fmt.Errorf("invalid short code length %d: must be between 0 and %d", length, maxCodeLength)
+	}
+	switch strategy {
+	case StrategyHash:
+		return NewHashGenerator(length)
+	case StrategyCounter:
+		return &CounterGenerator{sequence: store, checker: store, length: length}, nil
+	case StrategyRandom:
+		if length == 0 {
+			length = DefaultRandomLength
+		}
+		return &RandomGenerator{checker: store, length: length}, nil
+	default:
+		return nil, fmt.Errorf("unknown short code strategy %q", strategy)
+	}
+}
+
+// encodeBase62 возвращает Base62-представление неотрицательного числа.
+func encodeBase62(n int64) string {
+	if n == 0 {
+		return string(base62Alphabet[0])
+	}
+	var buf [11]byte
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
+}
+
+// randomBase62 возвращает криптографически случайную Base62-строку заданной длины.
+func randomBase62(length int) (string, error) {
+	alphabetSize := big.NewInt(int64(len(base62Alphabet)))
+	code := make([]byte, length)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetSize)
+		if err != nil {
+			return "", err
+		}
+		code[i] = base62Alphabet[n.Int64()]
+	}
+	return string(code), nil
+}
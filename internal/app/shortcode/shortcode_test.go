@@ -0,0 +1,106 @@
+package shortcode
+
+import (
+	"context"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+// storeStub — последовательность в памяти с заранее занятыми кодами.
+type storeStub struct {
+	next  int64
+	taken map[string]bool
+}
+
+func (s *storeStub) NextID(_ context.Context) (int64, error) {
+	s.next++
+	return s.next, nil
+}
+
+func (s *storeStub) ExistsByHash(_ context.Context, hashURL string) (bool, error) {
+	return s.taken[hashURL], nil
+}
+
+func TestNewGenerator(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		length   int
+		wantErr  bool
+	}{
+		{name: "Hash with default length", strategy: StrategyHash},
+		{name: "Hash with custom length", strategy: StrategyHash, length: 6},
+		{name: "Hash longer than SHA-256", strategy: StrategyHash, length: 44, wantErr: true},
+		{name: "Counter", strategy: StrategyCounter},
+		{name: "Random", strategy: StrategyRandom, length: 12},
+		{name: "Negative length", strategy: StrategyRandom, length: -1, wantErr: true},
+		{name: "Unknown strategy", strategy: "uuid", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			generator, err := NewGenerator(test.strategy, test.length, &storeStub{})
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, generator)
+		})
+	}
+}
+
+func TestHashGenerator(t *testing.T) {
+	generator, err := NewHashGenerator(0)
+	require.NoError(t, err)
+	code, err := generator.Generate(context.Background(), "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Equal(t, security.CreateHash("https://yandex.ru"), code, "default strategy must keep existing codes")
+
+	again, err := generator.Generate(context.Background(), "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Equal(t, code, again)
+
+	short, err := NewHashGenerator(6)
+	require.NoError(t, err)
+	code, err = short.Generate(context.Background(), "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Len(t, code, 6)
+}
+
+func TestCounterGenerator(t *testing.T) {
+	store := &storeStub{next: 60, taken: map[string]bool{"00z": true}}
+	generator, err := NewGenerator(StrategyCounter, 3, store)
+	require.NoError(t, err)
+
+	codes := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		code, err := generator.Generate(context.Background(), "https://yandex.ru")
+		require.NoError(t, err)
+		codes = append(codes, code)
+	}
+	// 61 → "z" занят, 62 → "10", 63 → "11", 64 → "12".
+	assert.Equal(t, []string{"010", "011", "012"}, codes)
+	assert.Equal(t, "0", encodeBase62(0))
+	assert.Equal(t, "1c", encodeBase62(100))
+}
+
+func TestRandomGenerator(t *testing.T) {
+	generator, err := NewGenerator(StrategyRandom, 0, &storeStub{})
+	require.NoError(t, err)
+	code, err := generator.Generate(context.Background(), "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Regexp(t, regexp.MustCompile("^[0-9A-Za-z]{8}$"), code)
+
+	// Для кода длины 1 все 62 варианта заняты — свободный код подобрать невозможно.
+	taken := make(map[string]bool)
+	for _, c := range base62Alphabet {
+		taken[string(c)] = true
+	}
+	exhausted, err := NewGenerator(StrategyRandom, 1, &storeStub{taken: taken})
+	require.NoError(t, err)
+	_, err = exhausted.Generate(context.Background(), "https://yandex.ru")
+	assert.ErrorIs(t, err, ErrNoFreeCode)
+}
@@ -0,0 +1,146 @@
+package urlcheck
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"go.uber.org/zap"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Blocklist — список запрещённых доменов, загружаемый из файла и перечитываемый при его изменении.
+//
+// Файл содержит по одному домену в строке; пустые строки и строки, начинающиеся с "#", пропускаются.
+// Домен запрещает и все свои поддомены: example.com запрещает www.example.com. Префикс "*." допускается
+// и означает то же самое.
+type Blocklist struct {
+	path     string        // Путь к файлу списка
+	interval time.Duration // Период проверки изменения файла
+
+	domains atomic.Pointer[map[string]struct{}] // Текущий список доменов
+	modTime time.Time                           // Время изменения загруженного файла; используется только Run
+	size    int64                               // Размер загруженного файла; используется только Run
+}
+
+// Match проверяет, запрещён ли хост или один из его родительских доменов.
+//
+// Параметр:
+//   - host: хост в нижнем регистре без завершающей точки.
+//
+// Возвращает:
+//   - string: запрещённый домен, под который попал хост.
+//   - bool: true, если хост запрещён.
+func (b *Blocklist) Match(host string) (string, bool) {
+	domains := *b.domains.Load()
+	for domain := host; domain != ""; {
+		if _, ok := domains[domain]; ok {
+			return domain, true
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+	return "", false
+}
+
+// Len возвращает количество доменов в списке.
+func (b *Blocklist) Len() int {
+	return len(*b.domains.Load())
+}
+
+// Run проверяет файл каждые interval и перечитывает его, если изменились время изменения или размер.
+// Если файл не удалось прочитать, остаётся прежний список.
+//
+// Параметры:
+//   - ctx: контекст, при отмене которого проверка останавливается.
+//
+// Возвращает:
+//   - error: всегда nil, чтобы корректно завершаться в составе errgroup.
+func (b *Blocklist) Run(ctx context.Context) error {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			changed, err := b.changed()
+			if err != nil {
+				logger.Log.Error("couldn't check the URL blocklist", zap.String("path", b.path), zap.Error(err))
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if err = b.load(); err != nil {
+				logger.Log.Error("couldn't reload the URL blocklist", zap.String("path", b.path), zap.Error(err))
+				continue
+			}
+			logger.Log.Info("URL blocklist reloaded", zap.String("path", b.path), zap.Int("domains", b.Len()))
+		}
+	}
+}
+
+// changed проверяет, изменился ли файл с последней загрузки.
+func (b *Blocklist) changed() (bool, error) {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return false, err
+	}
+	return !info.ModTime().Equal(b.modTime) || info.Size() != b.size, nil
+}
+
+// load читает файл и заменяет текущий список.
+func (b *Blocklist) load() error {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	domains := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains[normalizeHost(strings.TrimPrefix(line, "*."))] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+	b.domains.Store(&domains)
+	b.modTime, b.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// NewBlocklist загружает список запрещённых доменов из файла.
+//
+// Параметры:
+//   - path: путь к файлу списка.
+//   - interval: период проверки изменения файла в Run; неположительное значение заменяется на 30 секунд.
+//
+// Возвращает:
+//   - *Blocklist: загруженный список; его Run запускается вызывающим кодом.
+//   - error: nil, если успешно, иначе — ошибку чтения файла.
+func NewBlocklist(path string, interval time.Duration) (*Blocklist, error) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	b := &Blocklist{path: path, interval: interval}
+	if err := b.load(); err != nil {
+		return nil, fmt.Errorf("urlcheck.newBlocklist: %w", err)
+	}
+	return b, nil
+}
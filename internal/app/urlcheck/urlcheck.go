@@ -0,0 +1,217 @@
+// Package urlcheck проверяет безопасность оригинальных URL перед сокращением.
+//
+// Проверки выполняются по порядку, и URL отклоняется первой непройденной:
+//   - длина URL не больше настроенной;
+//   - URL разбирается и содержит схему (иначе — security.ErrInvalidURL, как и без этого пакета);
+//   - схема входит в список разрешённых (по умолчанию http и https), что отсекает javascript:, data:, file: и т.п.;
+//   - URL содержит хост (иначе — security.ErrInvalidURL);
+//   - URL не ведёт на сам сервис сокращения (иначе переход по ссылке зациклится);
+//   - хост не является локальным или частным адресом (localhost, 127.0.0.0/8, 10.0.0.0/8, fe80::/10 и т.п.),
+//     в том числе записанным в десятичной, восьмеричной или шестнадцатеричной форме (http://2130706433/);
+//   - домен и его родительские домены не входят в список запрещённых (Blocklist).
+//
+// Доменные имена не разрешаются в адреса: сервис не обращается по сокращаемым URL,
+// а проверка нужна, чтобы короткие ссылки не вели пользователей во внутренние сети.
+package urlcheck
+
+import (
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/faust8888/shortener/internal/app/security"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Коды причин отклонения URL.
+const (
+	// CodeTooLong — URL длиннее допустимого.
+	CodeTooLong = "url_too_long"
+	// CodeSchemeNotAllowed — схема URL не входит в список разрешённых.
+	CodeSchemeNotAllowed = "scheme_not_allowed"
+	// CodeSelfReference — URL ведёт на сам сервис сокращения.
+	CodeSelfReference = "self_reference"
+	// CodePrivateAddress — хост URL является локальным или частным адресом.
+	CodePrivateAddress = "private_address"
+	// CodeBlockedDomain — домен URL входит в список запрещённых.
+	CodeBlockedDomain = "blocked_domain"
+)
+
+// ErrRejected — базовая ошибка для всех отклонённых URL; конкретная причина — в *Error.
+var ErrRejected = errors.New("url rejected")
+
+// Error — причина отклонения URL.
+type Error struct {
+	Code          string // Код причины, например CodeSchemeNotAllowed
+	Message       string // Описание причины для пользователя
+	URL           string // Отклонённый URL
+	CorrelationID string // Идентификатор элемента пакетного запроса; пустой для одиночного
+}
+
+// Error возвращает описание причины отклонения.
+func (e *Error) Error() string {
+	if e.CorrelationID != "" {
+		return fmt.Sprintf("correlation_id %s: %s: %s", e.CorrelationID, ErrRejected, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrRejected, e.Message)
+}
+
+// Unwrap позволяет проверять отклонение через errors.Is(err, ErrRejected).
+func (e *Error) Unwrap() error {
+	return ErrRejected
+}
+
+// Response возвращает тело ответа 422 Unprocessable Entity.
+func (e *Error) Response() model.URLRejectionResponse {
+	return model.URLRejectionResponse{
+		Code:          e.Code,
+		Message:       e.Message,
+		URL:           e.URL,
+		CorrelationID: e.CorrelationID,
+	}
+}
+
+// WithCorrelationID возвращает копию ошибки с идентификатором элемента пакетного запроса.
+func (e *Error) WithCorrelationID(correlationID string) *Error {
+	withID := *e
+	withID.CorrelationID = correlationID
+	return &withID
+}
+
+// Validator — набор проверок оригинальных URL.
+type Validator struct {
+	maxLength    int                 // Максимальная длина URL; 0 — без ограничения
+	schemes      map[string]struct{} // Разрешённые схемы в нижнем регистре
+	allowPrivate bool                // Разрешены локальные и частные адреса
+	selfHost     string              // Хост сервиса сокращения из BaseShortURL
+	blocklist    *Blocklist          // Список запрещённых доменов; nil — не используется
+}
+
+// Validate проверяет URL.
+//
+// Параметр:
+//   - rawURL: оригинальный URL.
+//
+// Возвращает:
+//   - error: nil, если URL допустим; security.ErrInvalidURL, если URL некорректен;
+//     иначе — *Error с причиной отклонения.
+func (v *Validator) Validate(rawURL string) error {
+	reject := func(code, format string, args ...any) error {
+		return &Error{Code: code, Message: fmt.Sprintf(format, args...), URL: rawURL}
+	}
+	if v.maxLength > 0 && len(rawURL) > v.maxLength {
+		return reject(CodeTooLong, "url is %d characters long, maximum is %d", len(rawURL), v.maxLength)
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Scheme == "" {
+		return security.ErrInvalidURL
+	}
+	scheme := strings.ToLower(parsedURL.Scheme)
+	if _, ok := v.schemes[scheme]; !ok {
+		return reject(CodeSchemeNotAllowed, "scheme %q is not allowed", scheme)
+	}
+	host := normalizeHost(parsedURL.Hostname())
+	if host == "" {
+		return security.ErrInvalidURL
+	}
+	if v.selfHost != "" && host == v.selfHost {
+		return reject(CodeSelfReference, "url points to the shortener itself")
+	}
+	if !v.allowPrivate && isPrivateHost(host) {
+		return reject(CodePrivateAddress, "host %s is a local or private address", host)
+	}
+	if v.blocklist != nil {
+		if domain, blocked := v.blocklist.Match(host); blocked {
+			return reject(CodeBlockedDomain, "domain %s is blocked", domain)
+		}
+	}
+	return nil
+}
+
+// NewValidator создаёт набор проверок по конфигурации.
+//
+// Параметры:
+//   - cfg: конфигурация приложения (URLAllowedSchemes, URLMaxLength, URLAllowPrivateHosts, BaseShortURL).
+//   - blocklist: список запрещённых доменов; nil отключает проверку.
+//
+// Возвращает:
+//   - *Validator: готовый к использованию набор проверок.
+func NewValidator(cfg *config.Config, blocklist *Blocklist) *Validator {
+	v := &Validator{
+		maxLength:    cfg.URLMaxLength,
+		schemes:      make(map[string]struct{}),
+		allowPrivate: cfg.URLAllowPrivateHosts,
+		blocklist:    blocklist,
+	}
+	for _, scheme := range strings.Split(cfg.URLAllowedSchemes, ",") {
+		if scheme = strings.ToLower(strings.TrimSpace(scheme)); scheme != "" {
+			v.schemes[scheme] = struct{}{}
+		}
+	}
+	if baseURL, err := url.Parse(cfg.BaseShortURL); err == nil {
+		v.selfHost = normalizeHost(baseURL.Hostname())
+	}
+	return v
+}
+
+// normalizeHost приводит хост к нижнему регистру и убирает завершающую точку полного доменного имени.
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// isPrivateHost проверяет, что хост — локальное имя или адрес, недоступный из интернета.
+func isPrivateHost(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ip = parseLooseIPv4(host)
+	}
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace — адреса операторского NAT (RFC 6598), недоступные из интернета.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// parseLooseIPv4 разбирает IPv4-адрес в формах, которые браузеры принимают помимо обычной:
+// из 1–4 частей (127.1, 2130706433), с восьмеричными (0177.0.0.1) и шестнадцатеричными (0x7f.1) частями.
+// Последняя часть занимает все оставшиеся байты адреса. Возвращает nil, если host — не адрес.
+func parseLooseIPv4(host string) net.IP {
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+	var address uint64
+	for i, part := range parts {
+		// strconv с основанием 0 принимает также 0b, 0o и "_", которых в адресах не бывает.
+		prefix := strings.ToLower(part[:min(len(part), 2)])
+		if strings.Contains(part, "_") || prefix == "0b" || prefix == "0o" {
+			return nil
+		}
+		value, err := strconv.ParseUint(part, 0, 32)
+		if err != nil {
+			return nil
+		}
+		if i < len(parts)-1 {
+			if value > 0xff {
+				return nil
+			}
+			address |= value << (8 * (3 - i))
+			continue
+		}
+		if value >= 1<<(8*(4-i)) {
+			return nil
+		}
+		address |= value
+	}
+	return net.IPv4(byte(address>>24), byte(address>>16), byte(address>>8), byte(address))
+}
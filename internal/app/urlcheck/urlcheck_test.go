@@ -0,0 +1,135 @@
+package urlcheck
+
+import (
+	"context"
+	"github.com/faust8888/shortener/internal/app/config"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		BaseShortURL:      "https://sho.rt",
+		URLAllowedSchemes: "http, HTTPS",
+		URLMaxLength:      100,
+	}
+}
+
+func writeBlocklist(t *testing.T, path string, lines ...string) {
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644))
+}
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "# phishing", "", "evil.com", "*.bad.org")
+	blocklist, err := NewBlocklist(path, time.Minute)
+	require.NoError(t, err)
+	validator := NewValidator(newTestConfig(), blocklist)
+
+	tests := []struct {
+		name     string
+		url      string
+		wantCode string
+		wantErr  error
+	}{
+		{name: "Public http URL", url: "http://example.com/path?q=1"},
+		{name: "Public https URL with upper-case scheme", url: "HTTPS://example.com"},
+		{name: "Public IP address", url: "http://8.8.8.8/"},
+		{name: "Domain similar to a blocked one", url: "https://notevil.com"},
+		{name: "Too long", url: "https://example.com/" + strings.Repeat("a", 100), wantCode: CodeTooLong},
+		{name: "Without scheme", url: "example.com", wantErr: security.ErrInvalidURL},
+		{name: "Without host", url: "http:///path", wantErr: security.ErrInvalidURL},
+		{name: "javascript scheme", url: "javascript:alert(1)", wantCode: CodeSchemeNotAllowed},
+		{name: "file scheme", url: "file:///etc/passwd", wantCode: CodeSchemeNotAllowed},
+		{name: "data scheme", url: "data:text/html,<script>", wantCode: CodeSchemeNotAllowed},
+		{name: "Shortener itself", url: "https://SHO.RT./abc", wantCode: CodeSelfReference},
+		{name: "localhost", url: "http://localhost:8080", wantCode: CodePrivateAddress},
+		{name: "localhost subdomain", url: "http://app.localhost", wantCode: CodePrivateAddress},
+		{name: "Loopback", url: "http://127.0.0.1", wantCode: CodePrivateAddress},
+		{name: "Private network", url: "http://192.168.1.1/admin", wantCode: CodePrivateAddress},
+		{name: "Link-local metadata address", url: "http://169.254.169.254/latest", wantCode: CodePrivateAddress},
+		{name: "Unspecified", url: "http://0.0.0.0", wantCode: CodePrivateAddress},
+		{name: "IPv6 loopback", url: "http://[::1]:80", wantCode: CodePrivateAddress},
+		{name: "IPv6 unique local", url: "http://[fd00::1]", wantCode: CodePrivateAddress},
+		{name: "IPv4-mapped IPv6 loopback", url: "http://[::ffff:127.0.0.1]", wantCode: CodePrivateAddress},
+		{name: "Decimal loopback", url: "http://2130706433", wantCode: CodePrivateAddress},
+		{name: "Hexadecimal loopback", url: "http://0x7f.1", wantCode: CodePrivateAddress},
+		{name: "Octal private network", url: "http://012.0.0.1", wantCode: CodePrivateAddress},
+		{name: "Blocked domain", url: "https://evil.com", wantCode: CodeBlockedDomain},
+		{name: "Subdomain of a blocked domain", url: "https://login.EVIL.com", wantCode: CodeBlockedDomain},
+		{name: "Wildcard blocked domain", url: "https://bad.org", wantCode: CodeBlockedDomain},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validator.Validate(test.url)
+			switch {
+			case test.wantCode != "":
+				var rejected *Error
+				require.ErrorAs(t, err, &rejected)
+				assert.ErrorIs(t, err, ErrRejected)
+				assert.Equal(t, test.wantCode, rejected.Code)
+				assert.Equal(t, test.url, rejected.URL)
+			case test.wantErr != nil:
+				assert.ErrorIs(t, err, test.wantErr)
+			default:
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateAllowPrivateHosts(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.URLAllowPrivateHosts = true
+	cfg.URLMaxLength = 0
+
+	validator := NewValidator(cfg, nil)
+
+	assert.NoError(t, validator.Validate("http://192.168.1.1/"+strings.Repeat("a", 1000)))
+}
+
+func TestErrorWithCorrelationID(t *testing.T) {
+	err := &Error{Code: CodeBlockedDomain, Message: "domain evil.com is blocked", URL: "https://evil.com"}
+
+	withID := err.WithCorrelationID("42")
+
+	assert.Empty(t, err.CorrelationID)
+	assert.Equal(t, "42", withID.Response().CorrelationID)
+	assert.Equal(t, "correlation_id 42: url rejected: domain evil.com is blocked", withID.Error())
+}
+
+func TestBlocklistReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	writeBlocklist(t, path, "evil.com")
+	blocklist, err := NewBlocklist(path, 10*time.Millisecond)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- blocklist.Run(ctx)
+	}()
+
+	writeBlocklist(t, path, "evil.com", "worse.com")
+	assert.Eventually(t, func() bool {
+		_, blocked := blocklist.Match("worse.com")
+		return blocked
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.Remove(path))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2, blocklist.Len(), "the previous list must be kept if the file can't be read")
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestNewBlocklistMissingFile(t *testing.T) {
+	_, err := NewBlocklist(filepath.Join(t.TempDir(), "missing.txt"), time.Minute)
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+// Package admin содержит middleware для защиты служебного API (/api/admin/*) и метрик (/metrics).
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// TokenHeader — заголовок запроса, в котором передаётся административный токен.
+const TokenHeader = "X-Admin-Token"
+
+// ActorHeader — необязательный заголовок запроса с именем администратора для журнала его действий.
+// Токен у всех администраторов общий, поэтому без заголовка действие в журнале остаётся без автора.
+const ActorHeader = "X-Admin-Actor"
+
+// NewMiddleware возвращает middleware, пропускающий запрос только с верным административным токеном.
+//
+// Если токен в конфигурации не задан, служебное API считается отключённым и на любой запрос
+// возвращается 404 Not Found.
+//
+// Параметр:
+//   - token: административный токен из конфигурации.
+//
+// Возможные HTTP-статусы:
+// - 401 Unauthorized — токен отсутствует или неверен.
+// - 404 Not Found — служебное API отключено.
+func NewMiddleware(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				http.NotFound(w, r)
+				return
+			}
+			provided := r.Header.Get(TokenHeader)
+			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				http.Error(w, "invalid admin token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
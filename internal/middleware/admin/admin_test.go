@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		provided string
+		wantCode int
+	}{
+		{
+			name:     "Admin API is disabled without a token",
+			provided: "",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Admin API stays disabled for any provided token",
+			provided: "secret",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Missing token",
+			token:    "secret",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Wrong token",
+			token:    "secret",
+			provided: "secret2",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "Valid token",
+			token:    "secret",
+			provided: "secret",
+			wantCode: http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := NewMiddleware(test.token)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/links", nil)
+			if test.provided != "" {
+				req.Header.Set(TokenHeader, test.provided)
+			}
+			res := httptest.NewRecorder()
+
+			handler.ServeHTTP(res, req)
+
+			assert.Equal(t, test.wantCode, res.Code)
+		})
+	}
+}
@@ -0,0 +1,244 @@
+// Package auth содержит middleware аутентификации пользователей HTTP API.
+//
+// Пользователь передаёт одно из:
+// - JWT-токен в куке security.AuthorizationTokenName (браузеры);
+// - JWT-токен в заголовке "Authorization: Bearer <токен>" (CLI и межсервисные вызовы);
+// - ключ API в заголовке "Authorization: Bearer shk_..." (долгоживущие ключи, см. service.APIKeys).
+//
+// Заголовок Authorization проверяется первым: если он передан, кука не используется.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/faust8888/shortener/internal/middleware/tracing"
+	"net/http"
+)
+
+// errAPIKeysUnsupported — ошибка проверки ключа API, если middleware создан без сервиса ключей.
+var errAPIKeysUnsupported = errors.New("api keys are not supported")
+
+// errSessionRequired — ошибка запроса с ключом API к маршруту, доступному только по JWT-токену.
+var errSessionRequired = errors.New("api keys are not allowed here, use a token")
+
+// userIDContextKey — ключ контекста, под которым middleware сохраняет идентификатор пользователя.
+type userIDContextKey struct{}
+
+// APIKeyVerifier — интерфейс проверки ключей API (см. service.APIKeys).
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (string, error)
+}
+
+// Middleware аутентифицирует запросы и сохраняет идентификатор пользователя в контексте запроса.
+type Middleware struct {
+	authenticator *security.Authenticator // Сервис выпуска и проверки JWT-токенов
+	apiKeys       APIKeyVerifier          // Сервис проверки ключей API; nil, если ключи не поддерживаются
+}
+
+// Issue возвращает middleware, который пропускает и анонимные запросы: если учётных данных нет,
+// выпускается токен нового пользователя и отправляется в куке.
+//
+// Токен из куки, который скоро истечёт или подписан прежним ключом, перевыпускается с тем же
+// идентификатором пользователя и тоже отправляется в куке. Токены из заголовка Authorization
+// не перевыпускаются: клиент сам управляет ими.
+//
+// Возможные HTTP-статусы:
+// - 401 Unauthorized — недействительный токен или ключ API.
+// - 500 Internal Server Error — токен не удалось выпустить или ключ API не удалось проверить.
+func (m *Middleware) Issue(next http.Handler) http.Handler {
+	return m.handler(next, true, true)
+}
+
+// Require возвращает middleware, который отклоняет запросы без учётных данных.
+// В остальном он работает так же, как Issue.
+//
+// Возможные HTTP-статусы:
+// - 401 Unauthorized — учётные данные отсутствуют, токен или ключ API недействителен.
+// - 500 Internal Server Error — токен не удалось перевыпустить или ключ API не удалось проверить.
+func (m *Middleware) Require(next http.Handler) http.Handler {
+	return m.handler(next, false, true)
+}
+
+// RequireSession возвращает middleware, который, как Require, отклоняет запросы без учётных данных,
+// а также запросы с ключом API: маршрут доступен только по JWT-токену (из куки или заголовка Authorization).
+// Используется для управления ключами API, чтобы утёкший ключ не мог выпустить новые ключи
+// и пережить отзыв исходного.
+//
+// Возможные HTTP-статусы:
+// - 401 Unauthorized — учётные данные отсутствуют или токен недействителен.
+// - 403 Forbidden — передан ключ API.
+// - 500 Internal Server Error — токен не удалось перевыпустить.
+func (m *Middleware) RequireSession(next http.Handler) http.Handler {
+	return m.handler(next, false, false)
+}
+
+// TokenUserID возвращает идентификатор пользователя из действительного JWT-токена запроса
+// (из заголовка Authorization или из куки), не выпуская и не перевыпуская токен.
+// Ключи API не проверяются, чтобы не обращаться к хранилищу.
+//
+// Параметры:
+//   - r: HTTP-запрос.
+//
+// Возвращает:
+//   - string: идентификатор пользователя.
+//   - bool: false, если JWT-токена нет или он недействителен.
+func (m *Middleware) TokenUserID(r *http.Request) (string, bool) {
+	token := security.GetBearerToken(r)
+	if token == "" {
+		token = security.GetToken(r)
+	}
+	if token == "" || security.IsAPIKey(token) {
+		return "", false
+	}
+	userID, err := m.authenticator.UserID(token)
+	return userID, err == nil
+}
+
+// handler аутентифицирует запрос и передаёт его next с идентификатором пользователя в контексте.
+// При issue анонимному пользователю выпускается токен; без apiKeys запросы с ключом API отклоняются с 403.
+func (m *Middleware) handler(next http.Handler, issue, apiKeys bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var userID string
+		var ok bool
+		token := security.GetBearerToken(r)
+		if !apiKeys && security.IsAPIKey(token) {
+			http.Error(w, errSessionRequired.Error(), http.StatusForbidden)
+			return
+		}
+		if token != "" {
+			userID, ok = m.authenticateBearer(w, r, token)
+		} else {
+			userID, ok = m.authenticateCookie(w, r, issue)
+		}
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithUserID(r.Context(), userID)))
+	})
+}
+
+// authenticateBearer проверяет JWT-токен или ключ API из заголовка Authorization.
+//
+// Возвращает:
+//   - string: идентификатор пользователя.
+//   - bool: false, если ответ с ошибкой уже записан.
+func (m *Middleware) authenticateBearer(w http.ResponseWriter, r *http.Request, token string) (string, bool) {
+	if security.IsAPIKey(token) {
+		return m.authenticateAPIKey(w, r, token)
+	}
+	_, span := tracing.Start(r.Context(), "security.UserID")
+	userID, err := m.authenticator.UserID(token)
+	tracing.End(span, err)
+	if err != nil {
+		rejectBearer(w, err)
+		return "", false
+	}
+	return userID, true
+}
+
+// authenticateAPIKey проверяет ключ API сервисом ключей.
+//
+// Возвращает:
+//   - string: идентификатор владельца ключа.
+//   - bool: false, если ответ с ошибкой уже записан.
+func (m *Middleware) authenticateAPIKey(w http.ResponseWriter, r *http.Request, key string) (string, bool) {
+	if m.apiKeys == nil {
+		rejectBearer(w, errAPIKeysUnsupported)
+		return "", false
+	}
+	userID, err := m.apiKeys.VerifyAPIKey(r.Context(), key)
+	if errors.Is(err, service.ErrInvalidAPIKey) {
+		rejectBearer(w, err)
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+	return userID, true
+}
+
+// rejectBearer отвечает 401 Unauthorized на недействительный токен из заголовка Authorization.
+func rejectBearer(w http.ResponseWriter, err error) {
+	reject(w, security.BearerScheme+` error="invalid_token"`, err)
+}
+
+// reject отвечает 401 Unauthorized с заголовком WWW-Authenticate, подсказывающим клиенту схему аутентификации.
+func reject(w http.ResponseWriter, challenge string, err error) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, err.Error(), http.StatusUnauthorized)
+}
+
+// authenticateCookie извлекает идентификатор пользователя из куки с токеном; при необходимости
+// выпускает или перевыпускает токен и отправляет его клиенту в куке.
+//
+// Возвращает:
+//   - string: идентификатор пользователя.
+//   - bool: false, если ответ с ошибкой уже записан.
+func (m *Middleware) authenticateCookie(w http.ResponseWriter, r *http.Request, issue bool) (string, bool) {
+	var session security.Session
+	var err error
+	token := security.GetToken(r)
+	if token == "" {
+		if !issue {
+			reject(w, security.BearerScheme, security.ErrNoAuthorizationToken)
+			return "", false
+		}
+		if session, err = m.authenticator.NewSession(); err != nil {
+			http.Error(w, fmt.Sprintf("build token: %s", err.Error()), http.StatusInternalServerError)
+			return "", false
+		}
+	} else {
+		_, span := tracing.Start(r.Context(), "security.Authenticate")
+		session, err = m.authenticator.Authenticate(token)
+		tracing.End(span, err)
+		if err != nil {
+			reject(w, security.BearerScheme, err)
+			return "", false
+		}
+	}
+	if session.Token != "" {
+		http.SetCookie(w, m.authenticator.Cookie(session.Token))
+	}
+	return session.UserID, true
+}
+
+// UserIDFromContext возвращает идентификатор пользователя, сохранённый middleware.
+//
+// Параметр:
+//   - ctx: контекст запроса.
+//
+// Возвращает:
+//   - string: идентификатор пользователя.
+//   - bool: false, если запрос не прошёл через middleware.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(userIDContextKey{}).(string)
+	return userID, ok && userID != ""
+}
+
+// WithUserID возвращает контекст с идентификатором пользователя.
+//
+// Параметры:
+//   - ctx: родительский контекст.
+//   - userID: идентификатор пользователя.
+//
+// Возвращает:
+//   - context.Context: контекст, из которого UserIDFromContext вернёт userID.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userIDContextKey{}, userID)
+}
+
+// NewMiddleware создаёт middleware аутентификации.
+//
+// Параметры:
+//   - authenticator: сервис выпуска и проверки JWT-токенов.
+//   - apiKeys: сервис проверки ключей API; nil, если ключи API не поддерживаются.
+//
+// Возвращает:
+//   - *Middleware: middleware с режимами Issue и Require.
+func NewMiddleware(authenticator *security.Authenticator, apiKeys APIKeyVerifier) *Middleware {
+	return &Middleware{authenticator: authenticator, apiKeys: apiKeys}
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/app/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testAuthKey = "test-key"
+	testAPIKey  = security.APIKeyPrefix + "valid"
+)
+
+// apiKeysStub — проверка ключей API, знающая один ключ testAPIKey пользователя "bot".
+type apiKeysStub struct {
+	err error // Ошибка хранилища, возвращаемая для любого ключа
+}
+
+func (s apiKeysStub) VerifyAPIKey(_ context.Context, key string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	if key != testAPIKey {
+		return "", service.ErrInvalidAPIKey
+	}
+	return "bot", nil
+}
+
+// newTestMiddleware создаёт middleware с ключом подписи testAuthKey и проверкой ключей API apiKeys.
+func newTestMiddleware(t *testing.T, apiKeys APIKeyVerifier) (*Middleware, *security.Authenticator) {
+	authenticator, err := security.NewAuthenticator(security.NewKeyring(testAuthKey), security.AuthOptions{
+		TokenTTL:       24 * time.Hour,
+		RefreshWindow:  time.Hour,
+		CookieSameSite: security.SameSiteLax,
+	})
+	require.NoError(t, err)
+	return NewMiddleware(authenticator, apiKeys), authenticator
+}
+
+func TestMiddleware(t *testing.T) {
+	m, authenticator := newTestMiddleware(t, apiKeysStub{})
+	session, err := authenticator.NewSession()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		require    bool
+		header     string
+		cookie     string
+		wantStatus int
+		wantUserID string
+		wantCookie bool
+	}{
+		{name: "Anonymous request gets a new user", wantStatus: http.StatusOK, wantCookie: true},
+		{name: "Anonymous request is rejected when a token is required", require: true, wantStatus: http.StatusUnauthorized},
+		{name: "Token in cookie", require: true, cookie: session.Token, wantStatus: http.StatusOK, wantUserID: session.UserID},
+		{name: "Invalid token in cookie", cookie: "invalid", wantStatus: http.StatusUnauthorized},
+		{name: "Bearer token", require: true, header: "Bearer " + session.Token, wantStatus: http.StatusOK, wantUserID: session.UserID},
+		{name: "Bearer token takes precedence over cookie", require: true, header: "Bearer " + session.Token, cookie: "invalid", wantStatus: http.StatusOK, wantUserID: session.UserID},
+		{name: "Invalid bearer token is not replaced by a new user", header: "Bearer invalid", wantStatus: http.StatusUnauthorized},
+		{name: "API key", require: true, header: "Bearer " + testAPIKey, wantStatus: http.StatusOK, wantUserID: "bot"},
+		{name: "Unknown API key", header: "Bearer " + security.APIKeyPrefix + "unknown", wantStatus: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var userID string
+			next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				userID, _ = UserIDFromContext(r.Context())
+			})
+			handler := m.Issue(next)
+			if test.require {
+				handler = m.Require(next)
+			}
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if test.header != "" {
+				req.Header.Set(security.AuthorizationHeader, test.header)
+			}
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: security.AuthorizationTokenName, Value: test.cookie})
+			}
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			require.Equal(t, test.wantStatus, res.Code)
+			if test.wantStatus == http.StatusUnauthorized {
+				assert.NotEmpty(t, res.Header().Get("WWW-Authenticate"))
+				return
+			}
+			cookies := res.Result().Cookies()
+			if !test.wantCookie {
+				assert.Equal(t, test.wantUserID, userID)
+				assert.Empty(t, cookies, "a valid token must not be reissued")
+				return
+			}
+			require.Len(t, cookies, 1)
+			issuedUserID, err := authenticator.UserID(cookies[0].Value)
+			require.NoError(t, err)
+			assert.Equal(t, issuedUserID, userID)
+		})
+	}
+}
+
+func TestMiddlewareAPIKeyErrors(t *testing.T) {
+	send := func(m *Middleware) int {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set(security.AuthorizationHeader, "Bearer "+testAPIKey)
+		res := httptest.NewRecorder()
+		m.Issue(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(res, req)
+		return res.Code
+	}
+	m, _ := newTestMiddleware(t, apiKeysStub{err: errors.New("storage is down")})
+	assert.Equal(t, http.StatusInternalServerError, send(m), "a storage failure must not look like an invalid key")
+	m, _ = newTestMiddleware(t, nil)
+	assert.Equal(t, http.StatusUnauthorized, send(m))
+}
+
+func TestMiddlewareRequireSession(t *testing.T) {
+	m, authenticator := newTestMiddleware(t, apiKeysStub{})
+	session, err := authenticator.NewSession()
+	require.NoError(t, err)
+	send := func(header string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		if header != "" {
+			req.Header.Set(security.AuthorizationHeader, header)
+		}
+		res := httptest.NewRecorder()
+		m.RequireSession(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(res, req)
+		return res.Code
+	}
+	assert.Equal(t, http.StatusOK, send("Bearer "+session.Token))
+	assert.Equal(t, http.StatusForbidden, send("Bearer "+testAPIKey), "a valid api key must be rejected")
+	assert.Equal(t, http.StatusUnauthorized, send(""))
+}
+
+func TestTokenUserID(t *testing.T) {
+	m, authenticator := newTestMiddleware(t, apiKeysStub{})
+	session, err := authenticator.NewSession()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header string
+		cookie string
+		want   string
+	}{
+		{name: "No token"},
+		{name: "Token in cookie", cookie: session.Token, want: session.UserID},
+		{name: "Bearer token", header: "Bearer " + session.Token, want: session.UserID},
+		{name: "API key is not checked", header: "Bearer " + testAPIKey},
+		{name: "Invalid token", cookie: "invalid"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if test.header != "" {
+				req.Header.Set(security.AuthorizationHeader, test.header)
+			}
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: security.AuthorizationTokenName, Value: test.cookie})
+			}
+			userID, ok := m.TokenUserID(req)
+			assert.Equal(t, test.want != "", ok)
+			assert.Equal(t, test.want, userID)
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package logger
 
 import (
+	"context"
 	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
 	"net/http"
 	"time"
 )
@@ -33,6 +37,25 @@ func Initialize(level string) error {
 	return nil
 }
 
+// FromContext возвращает логгер, добавляющий к записям идентификаторы трассы и спана из ctx.
+// Если ctx не содержит спана, возвращается Log.
+//
+// Параметр:
+//   - ctx: контекст запроса.
+//
+// Возвращает:
+//   - *zap.Logger: логгер с полями trace_id и span_id.
+func FromContext(ctx context.Context) *zap.Logger {
+	spanContext := trace.SpanContextFromContext(ctx)
+	if !spanContext.IsValid() {
+		return Log
+	}
+	return Log.With(
+		zap.String("trace_id", spanContext.TraceID().String()),
+		zap.String("span_id", spanContext.SpanID().String()),
+	)
+}
+
 // NewMiddleware возвращает HTTP middleware, который логирует входящие запросы и исходящие ответы.
 //
 // Для каждого запроса логируются:
@@ -41,19 +64,40 @@ func Initialize(level string) error {
 //   - статус ответа,
 //   - объём переданных данных,
 //   - время выполнения.
+//
+// Если запрос трассируется, к записям добавляются идентификаторы трассы и спана.
 func NewMiddleware(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		wrappedWriter := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-		Log.Info("----> incoming HTTP request",
+		log := FromContext(r.Context())
+		log.Info("----> incoming HTTP request",
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 		)
 		handler.ServeHTTP(wrappedWriter, r)
-		Log.Info("<---- outgoing HTTP response",
+		log.Info("<---- outgoing HTTP response",
 			zap.Int("status", wrappedWriter.Status()),
 			zap.Int("size", wrappedWriter.BytesWritten()),
 			zap.String("execution time", time.Since(start).String()),
 		)
 	})
 }
+
+// NewUnaryInterceptor возвращает унарный gRPC-интерсептор, который логирует входящие вызовы и их результат.
+//
+// Для каждого вызова логируются:
+//   - полное имя метода,
+//   - код статуса ответа,
+//   - время выполнения.
+func NewUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	log := FromContext(ctx)
+	log.Info("----> incoming gRPC call", zap.String("method", info.FullMethod))
+	resp, err := handler(ctx, req)
+	log.Info("<---- outgoing gRPC response",
+		zap.String("code", status.Code(err).String()),
+		zap.String("execution time", time.Since(start).String()),
+	)
+	return resp, err
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// observeLog подменяет Log логгером, запоминающим записи, и восстанавливает его после теста.
+func observeLog(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.InfoLevel)
+	previous := Log
+	Log = zap.New(core)
+	t.Cleanup(func() {
+		Log = previous
+	})
+	return logs
+}
+
+func TestMiddleware(t *testing.T) {
+	logs := observeLog(t)
+	handler := NewMiddleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("created"))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/shorten", nil))
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	request := entries[0].ContextMap()
+	assert.Equal(t, http.MethodPost, request["method"])
+	assert.Equal(t, "/api/shorten", request["path"])
+	response := entries[1].ContextMap()
+	assert.EqualValues(t, http.StatusCreated, response["status"])
+	assert.EqualValues(t, len("created"), response["size"])
+	assert.Contains(t, response, "execution time")
+}
+
+func TestFromContext(t *testing.T) {
+	logs := observeLog(t)
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx := trace.ContextWithSpanContext(req.Context(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	// Без спана записи идут без идентификаторов трассы.
+	FromContext(req.Context()).Info("untraced")
+	FromContext(ctx).Info("traced")
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	assert.NotContains(t, entries[0].ContextMap(), "trace_id")
+	assert.Equal(t, traceID.String(), entries[1].ContextMap()["trace_id"])
+	assert.Equal(t, spanID.String(), entries[1].ContextMap()["span_id"])
+}
@@ -0,0 +1,250 @@
+// Package metrics собирает метрики приложения и отдаёт их в текстовом формате Prometheus.
+//
+// Метрики регистрируются в собственном реестре Registry, а не в глобальном реестре
+// клиентской библиотеки, чтобы набор экспортируемых метрик полностью определялся приложением.
+package metrics
+
+import (
+	"database/sql"
+	"github.com/faust8888/shortener/internal/app/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// namespace — общий префикс имён метрик приложения.
+const namespace = "shortener"
+
+// unmatchedRoute — значение метки route для запросов, не попавших ни в один маршрут.
+// Используется вместо пути запроса, чтобы произвольные URL не порождали новые временные ряды.
+const unmatchedRoute = "unmatched"
+
+// Результаты перехода по короткой ссылке (значения метки result метрики redirects_total).
+const (
+	// RedirectHit — ссылка найдена, выполнен редирект.
+	RedirectHit = "hit"
+	// RedirectMiss — ссылка не найдена.
+	RedirectMiss = "miss"
+	// RedirectGone — ссылка удалена или истёк срок её жизни.
+	RedirectGone = "gone"
+)
+
+// Registry — реестр всех метрик приложения.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "code"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	redirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Number of short link resolutions by result: hit, miss or gone.",
+	}, []string{"result"})
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Number of requests rejected by the rate limiter by budget.",
+	}, []string{"budget"})
+	storageDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "storage_operation_duration_seconds",
+		Help:      "Latency of storage operations by backend and operation.",
+		Buckets:   []float64{.0001, .0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"backend", "operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		redirects,
+		rateLimited,
+		storageDuration,
+	)
+	// Все результаты переходов экспортируются сразу, даже если их ещё не было.
+	for _, result := range []string{RedirectHit, RedirectMiss, RedirectGone} {
+		redirects.WithLabelValues(result)
+	}
+}
+
+// Handler возвращает HTTP-обработчик, отдающий метрики реестра Registry.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// NewMiddleware возвращает HTTP middleware, который учитывает количество и время выполнения запросов.
+//
+// Запросы группируются по шаблону маршрута chi (например, /{hashKeyURL}), а не по пути,
+// поэтому middleware должен быть подключён к роутеру chi.
+func NewMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		wrappedWriter := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		handler.ServeHTTP(wrappedWriter, r)
+
+		route := unmatchedRoute
+		if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil && routeCtx.RoutePattern() != "" {
+			route = routeCtx.RoutePattern()
+		}
+		status := wrappedWriter.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RecordRedirect учитывает результат перехода по короткой ссылке.
+//
+// Параметр:
+//   - result: RedirectHit, RedirectMiss или RedirectGone.
+func RecordRedirect(result string) {
+	redirects.WithLabelValues(result).Inc()
+}
+
+// RecordRateLimited учитывает запрос, отклонённый ограничением частоты запросов.
+//
+// Параметр:
+//   - budget: имя бюджета, например "create" или "redirect".
+func RecordRateLimited(budget string) {
+	rateLimited.WithLabelValues(budget).Inc()
+}
+
+// ObserveStorage учитывает время выполнения операции хранилища.
+// Предназначен для вызова через defer в начале метода: defer metrics.ObserveStorage("postgres", "FindByHash", time.Now()).
+//
+// Параметры:
+//   - backend: тип хранилища.
+//   - operation: имя операции.
+//   - start: момент начала операции.
+func ObserveStorage(backend, operation string, start time.Time) {
+	storageDuration.WithLabelValues(backend, operation).Observe(time.Since(start).Seconds())
+}
+
+// RegisterDeletionQueue регистрирует метрики очереди фонового удаления ссылок.
+// Значения читаются из stats при каждом сборе метрик.
+//
+// Параметр:
+//   - stats: функция, возвращающая текущее состояние очереди.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку регистрации (например, при повторном вызове).
+func RegisterDeletionQueue(stats func() model.DeletionQueueStats) error {
+	gauge := func(name, help string, value func(model.DeletionQueueStats) int64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "delete_queue", Name: name, Help: help,
+		}, func() float64 {
+			return float64(value(stats()))
+		})
+	}
+	counter := func(name, help string, value func(model.DeletionQueueStats) int64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "delete_queue", Name: name, Help: help,
+		}, func() float64 {
+			return float64(value(stats()))
+		})
+	}
+	queueCollectors := []prometheus.Collector{
+		gauge("pending", "Number of short codes waiting for deletion.",
+			func(s model.DeletionQueueStats) int64 { return s.Pending }),
+		gauge("in_flight", "Number of short codes being deleted.",
+			func(s model.DeletionQueueStats) int64 { return s.InFlight }),
+		gauge("capacity", "Maximal number of short codes in the queue.",
+			func(s model.DeletionQueueStats) int64 { return s.Capacity }),
+		counter("deleted_total", "Number of deleted short codes.",
+			func(s model.DeletionQueueStats) int64 { return s.Deleted }),
+		counter("retries_total", "Number of retried deletion batches.",
+			func(s model.DeletionQueueStats) int64 { return s.Retries }),
+		counter("failed_total", "Number of deletion batches returned to the queue after all retries.",
+			func(s model.DeletionQueueStats) int64 { return s.Failed }),
+		counter("dropped_total", "Number of deletion requests dropped after being returned to the queue too many times.",
+			func(s model.DeletionQueueStats) int64 { return s.Dropped }),
+		counter("rejected_total", "Number of deletion requests rejected because the queue was full.",
+			func(s model.DeletionQueueStats) int64 { return s.Rejected }),
+	}
+	for _, c := range queueCollectors {
+		if err := Registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterCache регистрирует метрики кэша коротких ссылок.
+// Значения читаются из stats при каждом сборе метрик.
+//
+// Параметр:
+//   - stats: функция, возвращающая текущую статистику кэша.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку регистрации (например, при повторном вызове).
+func RegisterCache(stats func() model.CacheStats) error {
+	cacheCollectors := []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "cache", Name: "hits_total", Help: "Number of link lookups served from the cache.",
+		}, func() float64 {
+			return float64(stats().Hits)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "cache", Name: "misses_total", Help: "Number of link lookups passed to the storage.",
+		}, func() float64 {
+			return float64(stats().Misses)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "cache", Name: "hit_ratio", Help: "Share of link lookups served from the cache since start.",
+		}, func() float64 {
+			return stats().HitRatio
+		}),
+	}
+	for _, c := range cacheCollectors {
+		if err := Registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterAudit регистрирует метрики журнала изменяющих операций.
+// Значения читаются из dropped при каждом сборе метрик.
+//
+// Параметр:
+//   - dropped: функция, возвращающая количество событий журнала, отброшенных из-за заполненного буфера.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку регистрации (например, при повторном вызове).
+func RegisterAudit(dropped func() int64) error {
+	return Registry.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "audit", Name: "dropped_total",
+		Help: "Number of audit events dropped because the audit buffer was full.",
+	}, func() float64 {
+		return float64(dropped())
+	}))
+}
+
+// RegisterDBStats регистрирует метрики пула подключений к базе данных (sql.DB.Stats()).
+//
+// Параметр:
+//   - db: пул подключений к базе данных.
+//
+// Возвращает:
+//   - error: nil, если успешно, иначе — ошибку регистрации (например, при повторном вызове).
+func RegisterDBStats(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, namespace))
+}
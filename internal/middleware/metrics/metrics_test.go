@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddlewareRouteLabel(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(NewMiddleware)
+	router.Get("/links/{hash}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	router.Post("/links", func(http.ResponseWriter, *http.Request) {})
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantRoute string
+		wantCode  string
+	}{
+		{
+			name:      "Route pattern instead of the path",
+			method:    http.MethodGet,
+			path:      "/links/abc123",
+			wantRoute: "/links/{hash}",
+			wantCode:  "307",
+		},
+		{
+			name:      "Status defaults to 200 when the handler writes nothing",
+			method:    http.MethodPost,
+			path:      "/links",
+			wantRoute: "/links",
+			wantCode:  "200",
+		},
+		{
+			name:      "Unmatched route",
+			method:    http.MethodGet,
+			path:      "/unknown/abc123",
+			wantRoute: unmatchedRoute,
+			wantCode:  "404",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counter := httpRequests.WithLabelValues(test.method, test.wantRoute, test.wantCode)
+			before := testutil.ToFloat64(counter)
+
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(test.method, test.path, nil))
+
+			assert.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+
+	// Путь запроса не становится значением метки.
+	res := httptest.NewRecorder()
+	Handler().ServeHTTP(res, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, res.Code)
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `shortener_http_requests_total{code="307",method="GET",route="/links/{hash}"}`)
+	assert.NotContains(t, string(body), "abc123")
+}
+
+func TestRecordRedirect(t *testing.T) {
+	before := testutil.ToFloat64(redirects.WithLabelValues(RedirectGone))
+	RecordRedirect(RedirectGone)
+	assert.Equal(t, before+1, testutil.ToFloat64(redirects.WithLabelValues(RedirectGone)))
+}
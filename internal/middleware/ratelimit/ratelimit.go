@@ -0,0 +1,338 @@
+// Package ratelimit содержит middleware, ограничивающий частоту запросов клиента по алгоритму token bucket.
+//
+// Каждый запрос расходует бюджет IP-адреса клиента, а запрос с действительным JWT-токеном —
+// ещё и бюджет пользователя из токена: новые анонимные токены выпускаются без ограничений,
+// поэтому бюджет пользователя не может заменять бюджет IP-адреса. IP-адрес клиента — адрес
+// соединения; только если соединение пришло от доверенного обратного прокси (TrustedProxies),
+// адрес берётся из заголовка X-Forwarded-For. Каждый Limiter хранит свой
+// бюджет, поэтому создание ссылок и переходы по ним ограничиваются независимо.
+//
+// Для gRPC-сервера ограничение выполняет NewUnaryInterceptor по IP-адресу клиента.
+package ratelimit
+
+import (
+	"context"
+	"github.com/faust8888/shortener/internal/middleware/logger"
+	"github.com/faust8888/shortener/internal/middleware/metrics"
+	"github.com/faust8888/shortener/internal/middleware/requestmeta"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Заголовки ответа с состоянием бюджета клиента.
+const (
+	// LimitHeader — ёмкость бюджета (максимальное количество запросов подряд).
+	LimitHeader = "X-RateLimit-Limit"
+	// RemainingHeader — количество запросов, доступных прямо сейчас.
+	RemainingHeader = "X-RateLimit-Remaining"
+	// ResetHeader — количество секунд до полного восстановления бюджета.
+	ResetHeader = "X-RateLimit-Reset"
+	// RetryAfterHeader — количество секунд до следующего разрешённого запроса (только для 429).
+	RetryAfterHeader = "Retry-After"
+	// ForwardedForHeader — заголовок с цепочкой адресов клиента и прокси, которые передали запрос.
+	ForwardedForHeader = "X-Forwarded-For"
+)
+
+// Имена бюджетов.
+const (
+	// BudgetCreate — бюджет создания коротких ссылок.
+	BudgetCreate = "create"
+	// BudgetRedirect — бюджет переходов по коротким ссылкам.
+	BudgetRedirect = "redirect"
+)
+
+// sweepInterval — период удаления бюджетов клиентов, давно не присылавших запросы.
+const sweepInterval = time.Minute
+
+// bucket — бюджет одного клиента.
+type bucket struct {
+	tokens float64   // Доступные запросы, включая дробную часть восстановления
+	last   time.Time // Момент последнего пересчёта tokens
+}
+
+// Decision — результат проверки запроса.
+type Decision struct {
+	Allowed    bool          // Запрос разрешён
+	Remaining  int           // Количество запросов, доступных после этого
+	Reset      time.Duration // Время до полного восстановления бюджета
+	RetryAfter time.Duration // Время до следующего разрешённого запроса; 0, если запрос разрешён
+}
+
+// Limiter — набор бюджетов клиентов с общими параметрами.
+//
+// Бюджет клиента вмещает burst запросов и пополняется со скоростью rate запросов в секунду.
+type Limiter struct {
+	name  string  // Имя бюджета в метриках, например "create"
+	rate  float64 // Скорость пополнения бюджета, запросов в секунду
+	burst int     // Ёмкость бюджета
+
+	mu        sync.Mutex         // Защищает поля ниже
+	buckets   map[string]*bucket // Ключ клиента → бюджет
+	lastSweep time.Time          // Момент последнего удаления неактивных бюджетов
+	now       func() time.Time   // Источник текущего времени
+}
+
+// Allow расходует по одному запросу из бюджета каждого ключа клиента, если ни один из бюджетов
+// не исчерпан; иначе не расходует ничего.
+//
+// Параметр:
+//   - keys: ключи клиента, например IP-адрес и идентификатор пользователя.
+//
+// Возвращает:
+//   - Decision: результат проверки и состояние самого исчерпанного из бюджетов.
+func (l *Limiter) Allow(keys ...string) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	buckets := make([]*bucket, 0, len(keys))
+	tokens := float64(l.burst)
+	for _, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: float64(l.burst), last: now}
+			l.buckets[key] = b
+		}
+		b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+		buckets = append(buckets, b)
+		tokens = math.Min(tokens, b.tokens)
+	}
+
+	decision := Decision{Allowed: tokens >= 1}
+	if decision.Allowed {
+		for _, b := range buckets {
+			b.tokens--
+		}
+		tokens--
+	} else {
+		decision.RetryAfter = l.duration(1 - tokens)
+	}
+	decision.Remaining = int(tokens)
+	decision.Reset = l.duration(float64(l.burst) - tokens)
+	return decision
+}
+
+// duration возвращает время восстановления tokens запросов.
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep удаляет бюджеты, полностью восстановившиеся с последнего запроса: они не отличаются от новых.
+// Выполняется не чаще раза в sweepInterval. Вызывающий код должен удерживать l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	full := l.duration(float64(l.burst))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// NewLimiter создаёт набор бюджетов клиентов.
+//
+// Параметры:
+//   - name: имя бюджета в метриках (BudgetCreate или BudgetRedirect).
+//   - rate: скорость пополнения бюджета, запросов в секунду.
+//   - burst: ёмкость бюджета; неположительное значение заменяется на 1.
+//
+// Возвращает:
+//   - *Limiter: набор бюджетов без клиентов; nil, если rate неположительна (ограничение отключено).
+func NewLimiter(name string, rate float64, burst int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &Limiter{
+		name:    name,
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// TrustedProxies — обратные прокси, которым доверяется заголовок X-Forwarded-For.
+type TrustedProxies struct {
+	prefixes []netip.Prefix // Адреса и подсети прокси
+}
+
+// ClientIP возвращает IP-адрес клиента запроса.
+//
+// Если соединение пришло не от доверенного прокси, это адрес соединения. Иначе X-Forwarded-For
+// просматривается справа налево (каждый прокси дописывает адрес своего клиента в конец), и клиентом
+// считается первый адрес, не принадлежащий доверенным прокси: адреса левее него мог подставить сам клиент.
+func (p *TrustedProxies) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !p.contains(peer) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values(ForwardedForHeader), ","), ",")
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = hop.Unmap()
+		if !p.contains(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// contains проверяет, что addr — адрес доверенного прокси.
+func (p *TrustedProxies) contains(addr netip.Addr) bool {
+	if p == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTrustedProxies разбирает список доверенных обратных прокси.
+// Некорректные элементы списка пропускаются с предупреждением в логе.
+//
+// Параметр:
+//   - list: IP-адреса и подсети CIDR через запятую, например "10.0.0.0/8, 192.0.2.1".
+//
+// Возвращает:
+//   - *TrustedProxies: доверенные прокси; пустой список не доверяет X-Forwarded-For ни для одного запроса.
+func NewTrustedProxies(list string) *TrustedProxies {
+	p := &TrustedProxies{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				logger.Log.Warn("Failed to parse trusted proxy, skipping", zap.String("proxy", item), zap.Error(err))
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		p.prefixes = append(p.prefixes, prefix.Masked())
+	}
+	return p
+}
+
+// UserResolver — интерфейс определения пользователя по JWT-токену запроса без обращения
+// к хранилищу (см. auth.Middleware).
+type UserResolver interface {
+	TokenUserID(r *http.Request) (string, bool)
+}
+
+// NewMiddleware возвращает middleware, ограничивающий частоту запросов клиента бюджетом limiter.
+//
+// В каждый ответ добавляются заголовки X-RateLimit-Limit, X-RateLimit-Remaining и X-RateLimit-Reset.
+// Если limiter равен nil, ограничение отключено и запросы пропускаются без изменений.
+//
+// Параметры:
+//   - limiter: бюджеты клиентов.
+//   - users: источник идентификатора пользователя из токена запроса.
+//   - proxies: доверенные обратные прокси; nil — IP-адрес клиента всегда берётся из адреса соединения.
+//
+// Возможные HTTP-статусы:
+// - 429 Too Many Requests — бюджет клиента исчерпан; в заголовке Retry-After — через сколько секунд повторить запрос.
+func NewMiddleware(limiter *Limiter, users UserResolver, proxies *TrustedProxies) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			decision := limiter.Allow(clientKeys(r, users, proxies)...)
+			w.Header().Set(LimitHeader, strconv.Itoa(limiter.burst))
+			w.Header().Set(RemainingHeader, strconv.Itoa(decision.Remaining))
+			w.Header().Set(ResetHeader, strconv.Itoa(seconds(decision.Reset)))
+			if !decision.Allowed {
+				metrics.RecordRateLimited(limiter.name)
+				w.Header().Set(RetryAfterHeader, strconv.Itoa(seconds(decision.RetryAfter)))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKeys возвращает ключи клиента: IP-адрес (см. TrustedProxies.ClientIP) и, если токен действителен,
+// идентификатор пользователя из него. Клиенты с ключами API ограничиваются только по IP-адресу:
+// ключ проверяется по хранилищу, а ограничение частоты должно срабатывать раньше обращения к нему.
+func clientKeys(r *http.Request, users UserResolver, proxies *TrustedProxies) []string {
+	keys := []string{ipKey(proxies.ClientIP(r))}
+	if userID, ok := users.TokenUserID(r); ok {
+		keys = append(keys, "user:"+userID)
+	}
+	return keys
+}
+
+// ipKey возвращает ключ бюджета IP-адреса.
+func ipKey(host string) string {
+	return "ip:" + host
+}
+
+// NewUnaryInterceptor возвращает унарный gRPC-интерсептор, ограничивающий частоту вызовов methods
+// бюджетом limiter по IP-адресу клиента. IP-адрес берётся из контекста, поэтому интерсептор
+// регистрируется после requestmeta.NewUnaryInterceptor.
+// Если limiter равен nil, ограничение отключено.
+//
+// Параметры:
+//   - limiter: бюджеты клиентов.
+//   - methods: полные имена ограничиваемых методов, например proto.Shortener_Shorten_FullMethodName.
+//
+// Возвращает:
+//   - grpc.UnaryServerInterceptor: интерсептор; исчерпание бюджета приводит к codes.ResourceExhausted.
+func NewUnaryInterceptor(limiter *Limiter, methods ...string) grpc.UnaryServerInterceptor {
+	limited := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		limited[method] = struct{}{}
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if _, ok := limited[info.FullMethod]; !ok || limiter == nil {
+			return handler(ctx, req)
+		}
+		decision := limiter.Allow(ipKey(requestmeta.FromContext(ctx).ClientIP))
+		if !decision.Allowed {
+			metrics.RecordRateLimited(limiter.name)
+			_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(RetryAfterHeader), strconv.Itoa(seconds(decision.RetryAfter))))
+			return nil, status.Error(codes.ResourceExhausted, http.StatusText(http.StatusTooManyRequests))
+		}
+		return handler(ctx, req)
+	}
+}
+
+// seconds округляет длительность вверх до целых секунд.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
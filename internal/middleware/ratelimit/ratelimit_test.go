@@ -0,0 +1,194 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/faust8888/shortener/internal/app/security"
+	"github.com/faust8888/shortener/internal/middleware/auth"
+	"github.com/faust8888/shortener/internal/middleware/requestmeta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAuthKey = "test-key"
+
+// newTestUsers создаёт middleware аутентификации, проверяющий токены ключом testAuthKey.
+func newTestUsers(t *testing.T) *auth.Middleware {
+	authenticator, err := security.NewAuthenticator(security.NewKeyring(testAuthKey), security.AuthOptions{TokenTTL: time.Hour, CookieSameSite: security.SameSiteLax})
+	require.NoError(t, err)
+	return auth.NewMiddleware(authenticator, nil)
+}
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *time.Time) {
+	now := time.Now()
+	limiter := NewLimiter(BudgetCreate, rate, burst)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestLimiterAllow(t *testing.T) {
+	limiter, now := newTestLimiter(2, 3)
+
+	for i := 2; i >= 0; i-- {
+		decision := limiter.Allow("client")
+		require.True(t, decision.Allowed)
+		assert.Equal(t, i, decision.Remaining)
+	}
+	decision := limiter.Allow("client")
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 500*time.Millisecond, decision.RetryAfter)
+	assert.Equal(t, 1500*time.Millisecond, decision.Reset)
+	assert.True(t, limiter.Allow("other").Allowed, "clients must have separate budgets")
+
+	*now = now.Add(500 * time.Millisecond)
+	assert.True(t, limiter.Allow("client").Allowed, "the budget must be restored over time")
+	assert.False(t, limiter.Allow("client").Allowed)
+
+	*now = now.Add(time.Hour)
+	limiter.Allow("client")
+	assert.Len(t, limiter.buckets, 1, "idle budgets must be removed")
+}
+
+func TestNewLimiterDisabled(t *testing.T) {
+	assert.Nil(t, NewLimiter(BudgetCreate, 0, 10))
+
+	handler := NewMiddleware(nil, newTestUsers(t), nil)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusCreated, res.Code)
+	assert.Empty(t, res.Header().Get(LimitHeader))
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter, _ := newTestLimiter(0.5, 1)
+	handler := NewMiddleware(limiter, newTestUsers(t), nil)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	token, err := security.BuildToken(testAuthKey)
+	require.NoError(t, err)
+	send := func(remoteAddr, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: security.AuthorizationTokenName, Value: token})
+		}
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	res := send("10.0.0.1:1000", "")
+	assert.Equal(t, http.StatusCreated, res.Code)
+	assert.Equal(t, "1", res.Header().Get(LimitHeader))
+	assert.Equal(t, "0", res.Header().Get(RemainingHeader))
+	assert.Equal(t, "2", res.Header().Get(ResetHeader))
+	assert.Empty(t, res.Header().Get(RetryAfterHeader))
+
+	res = send("10.0.0.1:2000", "")
+	assert.Equal(t, http.StatusTooManyRequests, res.Code, "requests from the same IP must share a budget")
+	assert.Equal(t, "2", res.Header().Get(RetryAfterHeader))
+
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:3000", token).Code, "a fresh token must not bypass the IP budget")
+	assert.Equal(t, http.StatusCreated, send("10.0.0.2:1000", token).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.3:1000", token).Code, "a user budget must not depend on IP")
+	assert.Equal(t, http.StatusCreated, send("10.0.0.4:1000", "").Code, "a rejected user request must not charge the IP budget")
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:4000", "invalid").Code, "invalid tokens must fall back to IP")
+}
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies := NewTrustedProxies("10.0.0.0/8, 192.0.2.1, not-an-address")
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name:         "Header of an untrusted peer is ignored",
+			remoteAddr:   "203.0.113.7:1000",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "203.0.113.7",
+		},
+		{
+			name:         "Client behind a trusted proxy",
+			remoteAddr:   "10.0.0.1:1000",
+			forwardedFor: []string{"198.51.100.1"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:         "Address spoofed by the client is skipped",
+			remoteAddr:   "192.0.2.1:1000",
+			forwardedFor: []string{"1.1.1.1, 198.51.100.1", "10.0.0.2"},
+			want:         "198.51.100.1",
+		},
+		{
+			name:       "Trusted proxy without the header",
+			remoteAddr: "10.0.0.1:1000",
+			want:       "10.0.0.1",
+		},
+		{
+			name:         "Malformed hop stops the walk",
+			remoteAddr:   "10.0.0.1:1000",
+			forwardedFor: []string{"198.51.100.1, garbage"},
+			want:         "10.0.0.1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwardedFor {
+				req.Header.Add(ForwardedForHeader, value)
+			}
+			assert.Equal(t, test.want, proxies.ClientIP(req))
+			var untrusted *TrustedProxies
+			assert.Equal(t, strings.Split(test.remoteAddr, ":")[0], untrusted.ClientIP(req), "without proxies the peer address is used")
+		})
+	}
+}
+
+func TestMiddlewareBehindProxy(t *testing.T) {
+	limiter, _ := newTestLimiter(0.5, 1)
+	handler := NewMiddleware(limiter, newTestUsers(t), NewTrustedProxies("10.0.0.1"))(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	send := func(forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		req.Header.Set(ForwardedForHeader, forwardedFor)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, send("198.51.100.1"))
+	assert.Equal(t, http.StatusCreated, send("198.51.100.2"), "clients behind one proxy must have separate budgets")
+	assert.Equal(t, http.StatusTooManyRequests, send("198.51.100.1"))
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	limiter, _ := newTestLimiter(0.5, 1)
+	call := func(interceptor grpc.UnaryServerInterceptor, clientIP, method string) error {
+		ctx := requestmeta.WithMeta(context.Background(), requestmeta.Meta{ClientIP: clientIP})
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(context.Context, interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	interceptor := NewUnaryInterceptor(limiter, "/limited")
+
+	require.NoError(t, call(interceptor, "10.0.0.1", "/limited"))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call(interceptor, "10.0.0.1", "/limited")))
+	assert.NoError(t, call(interceptor, "10.0.0.2", "/limited"), "clients must have separate budgets")
+	assert.NoError(t, call(interceptor, "10.0.0.1", "/other"), "other methods must not be limited")
+	assert.NoError(t, call(NewUnaryInterceptor(nil, "/limited"), "10.0.0.1", "/limited"), "nil limiter must disable the limit")
+}
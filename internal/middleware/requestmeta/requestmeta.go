@@ -0,0 +1,104 @@
+// Package requestmeta сохраняет в контексте запроса его идентификатор и IP-адрес клиента.
+//
+// Идентификатор берётся из заголовка X-Request-ID (HTTP) или ключа метаданных x-request-id (gRPC),
+// а если он не передан или некорректен — генерируется. HTTP middleware возвращает идентификатор
+// в одноимённом заголовке ответа, чтобы клиент мог сослаться на запрос.
+// IP-адрес берётся из адреса соединения; заголовкам прокси (X-Forwarded-For) пакет не доверяет.
+package requestmeta
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"net/http"
+)
+
+// Header — заголовок HTTP с идентификатором запроса.
+const Header = "X-Request-ID"
+
+// MetadataKey — ключ метаданных gRPC с идентификатором запроса.
+const MetadataKey = "x-request-id"
+
+// maxRequestIDLength — максимальная длина идентификатора, принимаемого от клиента.
+const maxRequestIDLength = 128
+
+// Meta — сведения о запросе, изменяющем данные.
+type Meta struct {
+	RequestID string // Идентификатор запроса
+	ClientIP  string // IP-адрес клиента без порта
+}
+
+// contextKey — ключ контекста для Meta.
+type contextKey struct{}
+
+// WithMeta возвращает копию контекста со сведениями о запросе.
+func WithMeta(ctx context.Context, meta Meta) context.Context {
+	return context.WithValue(ctx, contextKey{}, meta)
+}
+
+// FromContext возвращает сведения о запросе из контекста.
+//
+// Возвращает:
+//   - Meta: сведения о запросе; пустые, если контекст не прошёл через middleware или интерсептор пакета.
+func FromContext(ctx context.Context) Meta {
+	meta, _ := ctx.Value(contextKey{}).(Meta)
+	return meta
+}
+
+// NewMiddleware возвращает HTTP middleware, сохраняющий в контексте идентификатор запроса
+// и IP-адрес клиента и возвращающий идентификатор в заголовке X-Request-ID.
+func NewMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		meta := Meta{RequestID: requestID(r.Header.Get(Header)), ClientIP: hostOf(r.RemoteAddr)}
+		w.Header().Set(Header, meta.RequestID)
+		handler.ServeHTTP(w, r.WithContext(WithMeta(r.Context(), meta)))
+	})
+}
+
+// NewUnaryInterceptor возвращает унарный gRPC-интерсептор, сохраняющий в контексте
+// идентификатор вызова из метаданных x-request-id и IP-адрес клиента.
+func NewUnaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var received string
+	if values := metadata.ValueFromIncomingContext(ctx, MetadataKey); len(values) > 0 {
+		received = values[0]
+	}
+	meta := Meta{RequestID: requestID(received)}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		meta.ClientIP = hostOf(p.Addr.String())
+	}
+	return handler(WithMeta(ctx, meta), req)
+}
+
+// requestID возвращает идентификатор, полученный от клиента, если он корректен, иначе — новый.
+// Корректный идентификатор непуст, не длиннее maxRequestIDLength и состоит из печатных ASCII-символов.
+func requestID(received string) string {
+	if received == "" || len(received) > maxRequestIDLength {
+		return newRequestID()
+	}
+	for i := 0; i < len(received); i++ {
+		if received[i] < 0x21 || received[i] > 0x7e {
+			return newRequestID()
+		}
+	}
+	return received
+}
+
+// newRequestID генерирует случайный идентификатор из 32 шестнадцатеричных символов.
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// hostOf возвращает адрес без порта или сам адрес, если порта в нём нет.
+func hostOf(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
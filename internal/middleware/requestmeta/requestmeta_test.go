@@ -0,0 +1,64 @@
+package requestmeta
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name          string
+		requestID     string
+		wantRequestID string
+	}{
+		{name: "Request ID is propagated", requestID: "abc-123", wantRequestID: "abc-123"},
+		{name: "Request ID is generated when missing"},
+		{name: "Request ID with spaces is replaced", requestID: "abc 123"},
+		{name: "Too long request ID is replaced", requestID: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var meta Meta
+			handler := NewMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				meta = FromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.RemoteAddr = "203.0.113.7:54321"
+			if test.requestID != "" {
+				req.Header.Set(Header, test.requestID)
+			}
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			assert.Equal(t, "203.0.113.7", meta.ClientIP)
+			assert.Equal(t, meta.RequestID, res.Header().Get(Header))
+			if test.wantRequestID != "" {
+				assert.Equal(t, test.wantRequestID, meta.RequestID)
+			} else {
+				assert.Len(t, meta.RequestID, 32)
+			}
+		})
+	}
+}
+
+func TestUnaryInterceptor(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataKey, "rpc-1"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 3200}})
+	var meta Meta
+	_, err := NewUnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		meta = FromContext(ctx)
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Meta{RequestID: "rpc-1", ClientIP: "2001:db8::1"}, meta)
+	assert.Equal(t, Meta{}, FromContext(context.Background()))
+}
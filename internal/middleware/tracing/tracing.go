@@ -0,0 +1,184 @@
+// Package tracing настраивает трассировку запросов в формате OpenTelemetry.
+//
+// Контекст трассировки принимается из заголовка W3C traceparent (HTTP) или одноимённого
+// ключа метаданных (gRPC), так что спаны сервиса продолжают трассу вызывающей стороны.
+// Спаны выгружаются экспортёром, выбранным в конфигурации: OTLP/HTTP, stdout или никаким.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"os"
+)
+
+// Экспортёры спанов.
+const (
+	// ExporterNone - спаны не выгружаются; контекст трассировки всё равно принимается и попадает в логи.
+	ExporterNone = "none"
+	// ExporterStdout - спаны выводятся в stdout в формате JSON.
+	ExporterStdout = "stdout"
+	// ExporterOTLP - спаны отправляются коллектору по протоколу OTLP/HTTP.
+	ExporterOTLP = "otlp"
+)
+
+// ServiceName — имя сервиса в ресурсе трассировки.
+const ServiceName = "shortener"
+
+// instrumentationName — имя библиотеки инструментирования, под которым создаются спаны.
+const instrumentationName = "github.com/faust8888/shortener"
+
+// Initialize настраивает глобальный провайдер трассировки и W3C-пропагатор.
+//
+// Параметры:
+//   - exporter: ExporterNone, ExporterStdout или ExporterOTLP.
+//   - endpoint: URL коллектора OTLP/HTTP (например, http://localhost:4318); используется только для ExporterOTLP.
+//
+// Возвращает:
+//   - func(context.Context) error: функция, выгружающая накопленные спаны и останавливающая провайдер.
+//   - error: nil, если успешно, иначе — ошибку создания экспортёра.
+func Initialize(exporter, endpoint string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "", ExporterNone:
+		provider := sdktrace.NewTracerProvider(sdktrace.WithResource(newResource()))
+		otel.SetTracerProvider(provider)
+		return provider.Shutdown, nil
+	case ExporterStdout:
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterOTLP:
+		spanExporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint+"/v1/traces"))
+	default:
+		return nil, fmt.Errorf("tracing.initialize: unknown exporter %q", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing.initialize: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(newResource()),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// newResource описывает сервис, которому принадлежат спаны.
+func newResource() *resource.Resource {
+	return resource.NewSchemaless(semconv.ServiceName(ServiceName))
+}
+
+// Start начинает дочерний спан текущей трассы.
+//
+// Параметры:
+//   - ctx: контекст с родительским спаном.
+//   - name: имя спана, например "Shortener.Create" или "postgres.FindByHash".
+//   - attrs: атрибуты спана.
+//
+// Возвращает:
+//   - context.Context: контекст с новым спаном.
+//   - trace.Span: спан, который нужно завершить вызовом End.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End завершает спан, отмечая его ошибкой, если err не nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// NewMiddleware возвращает HTTP middleware, открывающий серверный спан на каждый запрос.
+//
+// Контекст трассировки извлекается из заголовка traceparent. Спан называется по методу
+// и шаблону маршрута chi (например, "GET /{hashKeyURL}"), поэтому middleware должен быть
+// подключён к роутеру chi. Ответы со статусом 5xx отмечаются как ошибка.
+func NewMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)))
+		defer span.End()
+
+		wrappedWriter := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		handler.ServeHTTP(wrappedWriter, r.WithContext(ctx))
+
+		if routeCtx := chi.RouteContext(r.Context()); routeCtx != nil && routeCtx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + routeCtx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(routeCtx.RoutePattern()))
+		}
+		code := wrappedWriter.Status()
+		if code == 0 {
+			code = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(code))
+		if code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(code))
+		}
+	})
+}
+
+// NewUnaryInterceptor возвращает унарный gRPC-интерсептор, открывающий серверный спан на каждый вызов.
+// Контекст трассировки извлекается из ключа метаданных traceparent.
+func NewUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	}
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, info.FullMethod,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.RPCSystemGRPC, attribute.String("rpc.method", info.FullMethod)))
+	defer span.End()
+	resp, err := handler(ctx, req)
+	if err != nil {
+		span.SetStatus(codes.Error, status.Convert(err).Message())
+	}
+	span.SetAttributes(attribute.String("rpc.grpc.status_code", status.Code(err).String()))
+	return resp, err
+}
+
+// metadataCarrier адаптирует метаданные gRPC к propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+// Get возвращает первое значение ключа.
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Set заменяет значение ключа.
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+// Keys возвращает все ключи метаданных.
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// collector — локальный коллектор OTLP/HTTP, запоминающий полученные спаны.
+type collector struct {
+	mu    sync.Mutex
+	spans []*tracepb.Span
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil || r.URL.Path != "/v1/traces" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var req collectortrace.ExportTraceServiceRequest
+	if err = proto.Unmarshal(body, &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	for _, resourceSpans := range req.GetResourceSpans() {
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			c.spans = append(c.spans, scopeSpans.GetSpans()...)
+		}
+	}
+	c.mu.Unlock()
+	resp, _ := proto.Marshal(&collectortrace.ExportTraceServiceResponse{})
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resp)
+}
+
+func (c *collector) spanNames() map[string]*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make(map[string]*tracepb.Span, len(c.spans))
+	for _, span := range c.spans {
+		result[span.GetName()] = span
+	}
+	return result
+}
+
+func TestOTLPExport(t *testing.T) {
+	received := &collector{}
+	collectorServer := httptest.NewServer(received)
+	defer collectorServer.Close()
+
+	shutdown, err := Initialize(ExporterOTLP, collectorServer.URL)
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(NewMiddleware)
+	router.Get("/{hash}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "postgres.FindByHash")
+		span.End()
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/abc", nil)
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	// Shutdown выгружает все накопленные спаны.
+	require.NoError(t, shutdown(context.Background()))
+
+	spans := received.spanNames()
+	require.Contains(t, spans, "GET /{hash}")
+	require.Contains(t, spans, "postgres.FindByHash")
+	serverSpan := spans["GET /{hash}"]
+	assert.Equal(t, traceID, hex.EncodeToString(serverSpan.GetTraceId()), "incoming traceparent must be continued")
+	assert.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(serverSpan.GetParentSpanId()))
+	assert.Equal(t, serverSpan.GetSpanId(), spans["postgres.FindByHash"].GetParentSpanId())
+}
+
+func TestInitializeUnknownExporter(t *testing.T) {
+	_, err := Initialize("jaeger", "")
+	require.Error(t, err)
+}